@@ -0,0 +1,296 @@
+// Command swiftlog-server runs the API, ingestor, websocket, and ai-worker
+// components in a single process against one shared DB pool and Redis
+// client, for small self-hosted deployments that don't need (or want to
+// operate) four separate binaries. Each component is the same code the
+// standalone cmd/api, cmd/ingestor, cmd/websocket, and cmd/ai-worker
+// binaries run, wired together here instead of duplicated; a deployment
+// that outgrows this can split any component back out to its own
+// replica by pointing ENABLE_* at false here and running that binary
+// separately against the same database.
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aliancn/swiftlog/backend/internal/aiworker"
+	"github.com/aliancn/swiftlog/backend/internal/apiserver"
+	"github.com/aliancn/swiftlog/backend/internal/auth"
+	"github.com/aliancn/swiftlog/backend/internal/config"
+	"github.com/aliancn/swiftlog/backend/internal/database"
+	"github.com/aliancn/swiftlog/backend/internal/dispatch"
+	"github.com/aliancn/swiftlog/backend/internal/ingestor"
+	"github.com/aliancn/swiftlog/backend/internal/logging"
+	"github.com/aliancn/swiftlog/backend/internal/loki"
+	"github.com/aliancn/swiftlog/backend/internal/queue"
+	"github.com/aliancn/swiftlog/backend/internal/quota"
+	"github.com/aliancn/swiftlog/backend/internal/repository"
+	"github.com/aliancn/swiftlog/backend/internal/tracing"
+	ws "github.com/aliancn/swiftlog/backend/internal/websocket"
+	"github.com/aliancn/swiftlog/backend/internal/wsserver"
+	pb "github.com/aliancn/swiftlog/backend/proto"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // register the gzip decompressor for clients that opt into compression
+)
+
+func main() {
+	cfg, err := config.LoadServerConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if config.PrintConfigRequested(os.Args) {
+		config.Print("swiftlog-server", cfg.Summary())
+		return
+	}
+
+	slog.SetDefault(logging.New("swiftlog-server"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shutdownTracing, err := tracing.Init(ctx, "swiftlog-server")
+	if err != nil {
+		log.Printf("Warning: failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			log.Printf("Warning: failed to shut down tracing: %v", err)
+		}
+	}()
+
+	if cfg.API.Environment == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// One shared DB pool and Redis client for every enabled component,
+	// instead of each opening its own the way it would running standalone.
+	log.Println("Connecting to database...")
+	db, err := database.NewFromURL(ctx, cfg.DB.URL, database.PoolConfig{
+		MaxOpenConns:    cfg.DB.MaxOpenConns,
+		MaxIdleConns:    cfg.DB.MaxIdleConns,
+		ConnMaxLifetime: cfg.DB.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DB.ConnMaxIdleTime,
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	go db.WatchPoolStats(ctx, cfg.DB.PoolStatsInterval)
+
+	log.Println("Connecting to Redis...")
+	redisClient, err := database.NewRedisClient(ctx, cfg.Redis.URL)
+	if err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer redisClient.Close()
+
+	lokiClient := loki.NewClient(&loki.Config{URL: cfg.LokiURL, Timeout: 10 * time.Second})
+
+	ws.EnableLegacyChannel(cfg.Worker.LegacyPubsub)
+	log.Printf("Legacy global pub/sub channel: enabled=%t", cfg.Worker.LegacyPubsub)
+
+	log.Printf("Components enabled: api=%t ingestor=%t websocket=%t ai-worker=%t", cfg.EnableAPI, cfg.EnableIngestor, cfg.EnableWebsocket, cfg.EnableAIWorker)
+
+	var grpcServer *grpc.Server
+	var httpAPIServer, httpWSServer *http.Server
+	var wsSrv *wsserver.Server
+	var worker *aiworker.Worker
+	var dispatchCtx context.Context
+	var cancelDispatch, cancelWork context.CancelFunc
+
+	if cfg.EnableIngestor {
+		logRunRepo := repository.NewLogRunRepository(db.DB)
+		projectRepo := repository.NewProjectRepository(db.DB)
+		groupRepo := repository.NewLogGroupRepository(db.DB)
+		settingsRepo := repository.NewSettingsRepository(db.DB)
+		taskQueue := queue.NewQueue(redisClient)
+
+		tokenService := auth.NewTokenService(db.DB)
+		tokenService.SetRedisClient(redisClient)
+		tokenService.StartInvalidationListener(ctx)
+
+		ingestorService := ingestor.NewService(&ingestor.Config{
+			LogRunRepo:    logRunRepo,
+			ProjectRepo:   projectRepo,
+			GroupRepo:     groupRepo,
+			SettingsRepo:  settingsRepo,
+			LokiClient:    lokiClient,
+			RedisClient:   redisClient,
+			TaskQueue:     taskQueue,
+			BatchSize:     100,
+			BatchInterval: 1 * time.Second,
+		})
+
+		grpcOpts := []grpc.ServerOption{
+			grpc.StatsHandler(otelgrpc.NewServerHandler()),
+			grpc.UnaryInterceptor(auth.GRPCAuthInterceptor(tokenService)),
+			grpc.StreamInterceptor(auth.GRPCAuthStreamInterceptor(tokenService)),
+		}
+		if cfg.Ingestor.GRPCTLSCert != "" {
+			creds, err := credentials.NewServerTLSFromFile(cfg.Ingestor.GRPCTLSCert, cfg.Ingestor.GRPCTLSKey)
+			if err != nil {
+				log.Fatalf("Failed to load gRPC TLS certificate: %v", err)
+			}
+			grpcOpts = append(grpcOpts, grpc.Creds(creds))
+			log.Println("gRPC ingestor TLS enabled")
+		}
+		grpcServer = grpc.NewServer(grpcOpts...)
+		pb.RegisterLogStreamerServer(grpcServer, ingestorService)
+
+		lis, err := net.Listen("tcp", ":"+cfg.Ingestor.GRPCPort)
+		if err != nil {
+			log.Fatalf("Failed to listen on port %s: %v", cfg.Ingestor.GRPCPort, err)
+		}
+		log.Printf("Starting gRPC Ingestor service on port %s...", cfg.Ingestor.GRPCPort)
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				slog.Error("gRPC ingestor server stopped unexpectedly", "error", err)
+			}
+		}()
+	}
+
+	if cfg.EnableAIWorker {
+		logRunRepo := repository.NewLogRunRepository(db.DB)
+		groupRepo := repository.NewLogGroupRepository(db.DB)
+		projectRepo := repository.NewProjectRepository(db.DB)
+		settingsRepo := repository.NewSettingsRepository(db.DB)
+		instanceSettingsRepo := repository.NewInstanceSettingsRepository(db.DB)
+		analysisVersionRepo := repository.NewAnalysisVersionRepository(db.DB)
+		taskQueue := queue.NewQueue(redisClient)
+		quotaTracker := quota.NewTokenUsageTracker(redisClient)
+
+		dispatchCtx, cancelDispatch = context.WithCancel(context.Background())
+		var workCtx context.Context
+		workCtx, cancelWork = context.WithCancel(context.Background())
+
+		log.Println("Starting AI Worker...")
+		worker = aiworker.NewWorker(logRunRepo, groupRepo, projectRepo, settingsRepo, instanceSettingsRepo, lokiClient, redisClient, taskQueue, quotaTracker, analysisVersionRepo)
+		worker.SetMaxTaskAge(cfg.Worker.TaskMaxAge)
+		go worker.Run(dispatchCtx, workCtx)
+
+		go aiworker.RunStuckProcessingReconciler(workCtx, redisClient, logRunRepo, groupRepo, projectRepo, taskQueue, cfg.Worker.ReconcileStuckThreshold, cfg.Worker.ReconcileInterval, cfg.Worker.ReconcileMaxAttempts, cfg.Worker.ReconcileBatchSize)
+
+		taskQueue.SetVisibilityTimeout(cfg.Worker.QueueVisibilityTimeout)
+		go aiworker.RunQueueReclaimSweep(workCtx, redisClient, taskQueue, cfg.Worker.QueueReclaimInterval)
+
+		go func() {
+			for runID := range taskQueue.SubscribeCancelSignals(ctx) {
+				worker.CancelIfRunning(runID)
+			}
+		}()
+
+		if cfg.Worker.AutoRetry.Enabled {
+			go aiworker.RunAutoRetrySweep(workCtx, logRunRepo, groupRepo, projectRepo, taskQueue, cfg.Worker.AutoRetry.Interval, cfg.Worker.AutoRetry.Window, cfg.Worker.AutoRetry.BatchSize)
+		}
+
+		var genericQueue queue.TaskQueue = taskQueue
+		if cfg.Worker.QueueBackend == "streams" {
+			genericQueue = queue.NewStreamsQueue(redisClient, aiworker.NewConsumerID())
+		}
+		genericDispatcher := dispatch.NewDispatcher(genericQueue)
+		genericDispatcher.Register(queue.TaskTypeRetentionCleanup, aiworker.HandleRetentionCleanup(logRunRepo))
+		go genericDispatcher.Run(workCtx)
+
+		if cfg.Worker.Retention.Enabled {
+			go aiworker.RunRetentionCleanupScheduler(workCtx, genericQueue, cfg.Worker.Retention.Interval, cfg.Worker.Retention.Days)
+		}
+	}
+
+	if cfg.EnableWebsocket {
+		wsSrv, err = wsserver.New(ctx, cfg.WS, db, redisClient)
+		if err != nil {
+			log.Fatalf("Failed to build WebSocket server: %v", err)
+		}
+		httpWSServer = &http.Server{Addr: ":" + cfg.WS.Port, Handler: wsSrv.Router}
+		log.Printf("Starting WebSocket server on port %s...", cfg.WS.Port)
+		go func() {
+			if err := httpWSServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("WebSocket server stopped unexpectedly", "error", err)
+			}
+		}()
+	}
+
+	if cfg.EnableAPI {
+		router, err := apiserver.New(ctx, cfg.API, db, redisClient)
+		if err != nil {
+			log.Fatalf("Failed to build API server: %v", err)
+		}
+		httpAPIServer = &http.Server{Addr: ":" + cfg.API.Port, Handler: router}
+		log.Printf("Starting API server on port %s...", cfg.API.Port)
+		go func() {
+			if err := httpAPIServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("API server stopped unexpectedly", "error", err)
+			}
+		}()
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	<-sigChan
+	log.Println("Shutdown signal received, draining components in order: ingestor, ai-worker, websocket hub, HTTP")
+
+	// Drain ingestor: stop taking new gRPC streams and let in-flight ones
+	// finish, same as cmd/ingestor's own shutdown.
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+		log.Println("Ingestor drained")
+	}
+
+	// Drain ai-worker: stop pulling new tasks, wait out the grace period
+	// for in-flight analyses, then requeue whatever's still running.
+	if worker != nil {
+		cancelDispatch()
+		gracePeriod := cfg.Worker.ShutdownGracePeriod
+		if worker.WaitInFlight(gracePeriod) {
+			log.Println("All in-flight analyses finished before shutdown")
+		} else {
+			log.Printf("Grace period of %s expired with analyses still in flight; requeuing them", gracePeriod)
+			cancelWork()
+			n := worker.DrainUnfinished(context.Background())
+			log.Printf("Requeued %d unfinished analysis task(s)", n)
+		}
+		log.Println("AI worker drained")
+	}
+
+	// Close the websocket hub: tell every connected client this is a clean
+	// shutdown, wait out the grace period, then force-close stragglers.
+	if wsSrv != nil {
+		wsSrv.ShuttingDown.Store(true)
+		reason := "server restarting"
+		connections := wsSrv.Hub.ConnectionCount()
+		wsSrv.Hub.Shutdown(reason)
+		log.Printf("Sent close frames (code 1001, %q) to %d connections, waiting up to %s for clients to disconnect", reason, connections, cfg.WS.ShutdownGrace)
+		time.Sleep(cfg.WS.ShutdownGrace)
+		wsSrv.Hub.ForceDisconnectAll()
+		log.Println("WebSocket hub closed")
+	}
+
+	// Stop HTTP last: the API and WebSocket routers.
+	httpShutdownCtx, httpShutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer httpShutdownCancel()
+	if httpWSServer != nil {
+		if err := httpWSServer.Shutdown(httpShutdownCtx); err != nil {
+			log.Printf("WebSocket HTTP server shutdown error: %v", err)
+		}
+	}
+	if httpAPIServer != nil {
+		if err := httpAPIServer.Shutdown(httpShutdownCtx); err != nil {
+			log.Printf("API HTTP server shutdown error: %v", err)
+		}
+	}
+
+	cancel()
+	log.Println("Server stopped")
+}
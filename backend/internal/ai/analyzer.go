@@ -3,22 +3,34 @@ package ai
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/aliancn/swiftlog/backend/internal/ai")
+
 // Analyzer handles AI-powered log analysis using OpenAI API
 type Analyzer struct {
-	apiKey       string
-	baseURL      string
-	model        string
-	maxTokens    int
-	systemPrompt string
-	httpClient   *http.Client
+	apiKey             string
+	baseURL            string
+	model              string
+	maxTokens          int
+	systemPrompt       string
+	userPromptTemplate string
+	httpClient         *http.Client
 }
 
 // Config holds analyzer configuration
@@ -28,6 +40,16 @@ type Config struct {
 	Model        string
 	MaxTokens    int
 	SystemPrompt string
+
+	// UserPromptTemplate, if set, replaces the default analysis prompt.
+	// It's rendered with RenderTemplate against the PromptContext passed to
+	// AnalyzeLogs, plus "logs", "exit_code" and "status" filled in from the
+	// run being analyzed. Leave empty to use the built-in prompt.
+	UserPromptTemplate string
+
+	// RequestTimeout bounds each HTTP call to the AI provider. Zero uses the
+	// default of 30 seconds.
+	RequestTimeout time.Duration
 }
 
 // NewAnalyzer creates a new AI analyzer
@@ -44,15 +66,19 @@ func NewAnalyzer(cfg *Config) *Analyzer {
 	if cfg.SystemPrompt == "" {
 		cfg.SystemPrompt = "You are an expert log analyzer. Analyze the provided script execution logs and provide a concise summary highlighting key events, errors, and outcomes."
 	}
+	if cfg.RequestTimeout == 0 {
+		cfg.RequestTimeout = 30 * time.Second
+	}
 
 	return &Analyzer{
-		apiKey:       cfg.APIKey,
-		baseURL:      cfg.BaseURL,
-		model:        cfg.Model,
-		maxTokens:    cfg.MaxTokens,
-		systemPrompt: cfg.SystemPrompt,
+		apiKey:             cfg.APIKey,
+		baseURL:            cfg.BaseURL,
+		model:              cfg.Model,
+		maxTokens:          cfg.MaxTokens,
+		systemPrompt:       cfg.SystemPrompt,
+		userPromptTemplate: cfg.UserPromptTemplate,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: cfg.RequestTimeout,
 		},
 	}
 }
@@ -76,7 +102,9 @@ type OpenAIResponse struct {
 		Message Message `json:"message"`
 	} `json:"choices"`
 	Usage struct {
-		TotalTokens int `json:"total_tokens"`
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
 	} `json:"usage"`
 }
 
@@ -85,23 +113,158 @@ type AnalysisResult struct {
 	Report      string
 	TokensUsed  int
 	GeneratedAt time.Time
+
+	// PromptWarnings names template variables referenced by the system
+	// prompt or user prompt template that had no value in the render
+	// context, so the caller can surface them without failing analysis.
+	PromptWarnings []string
+
+	// PromptTokens and CompletionTokens are the split token counts reported
+	// by the provider, for cost estimation. They're 0 if the provider
+	// didn't report a split (TokensUsed is still the total either way).
+	PromptTokens     int
+	CompletionTokens int
+
+	// ErrorCategory is the raw category text the model returned (see
+	// errorCategoryInstruction), or empty if it didn't include one. Callers
+	// should normalize it (e.g. via models.NormalizeErrorCategory) before
+	// persisting or displaying it.
+	ErrorCategory string
+}
+
+// contextWindows lists known context window sizes (in tokens) for common
+// models. Models not listed fall back to defaultContextWindow.
+var contextWindows = map[string]int{
+	"gpt-4o":        128000,
+	"gpt-4o-mini":   128000,
+	"gpt-4-turbo":   128000,
+	"gpt-4":         8192,
+	"gpt-3.5-turbo": 16385,
+	"o1":            200000,
+	"o1-mini":       128000,
+}
+
+// defaultContextWindow is used for models we don't recognize.
+const defaultContextWindow = 128000
+
+// promptOverheadTokens is a rough budget reserved for the system prompt,
+// prompt scaffolding (buildPrompt's boilerplate), and response formatting.
+const promptOverheadTokens = 1000
+
+// minTokenBudget is the floor we won't shrink the log budget below, even on
+// a retry with a tighter budget.
+const minTokenBudget = 200
+
+// estimateTokens returns a heuristic token count for s. It assumes roughly
+// 4 bytes per token, which is a reasonable approximation for English log
+// text without needing a real tokenizer dependency.
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// contextWindowForModel returns the known context window for model, or
+// defaultContextWindow if the model isn't recognized.
+func contextWindowForModel(model string) int {
+	if window, ok := contextWindows[model]; ok {
+		return window
+	}
+	return defaultContextWindow
+}
+
+// tokenBudget computes how many tokens of log content we can afford to send,
+// given the model's context window minus the completion's max_tokens and a
+// fixed overhead for the rest of the prompt.
+func (a *Analyzer) tokenBudget() int {
+	budget := contextWindowForModel(a.model) - a.maxTokens - promptOverheadTokens - estimateTokens(a.systemPrompt)
+	if budget < minTokenBudget {
+		budget = minTokenBudget
+	}
+	return budget
 }
 
-// AnalyzeLogs analyzes log content and generates a report
-func (a *Analyzer) AnalyzeLogs(ctx context.Context, logs []string, exitCode int32, runStatus string, maxLogLines int, truncateStrategy string) (*AnalysisResult, error) {
-	// Prepare log content based on user's truncation strategy
-	logContent := prepareLogs(logs, maxLogLines, truncateStrategy)
+// isContextLengthError reports whether err looks like a provider-side
+// context-length error, so callers know it's worth retrying with a tighter
+// truncation budget rather than surfacing a generic failure.
+func isContextLengthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "context_length_exceeded") ||
+		strings.Contains(msg, "context length") ||
+		strings.Contains(msg, "maximum context length")
+}
+
+// WithReportLanguage appends a language instruction to systemPrompt so the
+// model responds in the requested language. An empty language leaves the
+// prompt untouched, preserving the existing (English) behavior.
+func WithReportLanguage(systemPrompt, language string) string {
+	if language == "" {
+		return systemPrompt
+	}
+	return fmt.Sprintf("%s\n\nRespond in %s.", systemPrompt, language)
+}
 
-	// Create prompt
-	prompt := buildPrompt(logContent, exitCode, runStatus)
+// AnalyzeLogs analyzes log content and generates a report. vars supplies the
+// values available to the system prompt and user prompt template (e.g.
+// "project", "group", "run_id"); it may be nil if no templating is used.
+// partial marks the run as still in progress, so the model is told the logs
+// are incomplete rather than treating a truncated tail as the whole story.
+func (a *Analyzer) AnalyzeLogs(ctx context.Context, logs []string, exitCode int32, runStatus string, maxLogLines int, truncateStrategy string, partial bool, vars PromptContext) (*AnalysisResult, error) {
+	budget := a.tokenBudget()
+
+	result, err := a.analyzeWithBudget(ctx, logs, exitCode, runStatus, truncateStrategy, partial, budget, vars)
+	if err != nil {
+		if !isContextLengthError(err) {
+			return nil, err
+		}
+		// Retry once with a tighter budget; the provider's error means our
+		// heuristic estimate undershot the model's actual context window.
+		tighterBudget := budget / 2
+		if tighterBudget < minTokenBudget {
+			tighterBudget = minTokenBudget
+		}
+		result, err = a.analyzeWithBudget(ctx, logs, exitCode, runStatus, truncateStrategy, partial, tighterBudget, vars)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result.GeneratedAt = time.Now()
+	return result, nil
+}
+
+// Fingerprint computes a stable content hash of the exact analysis that
+// would be sent to the provider for logs/exitCode/runStatus/truncateStrategy
+// and vars: the model plus the fully-rendered system and user prompts, after
+// truncation. Two calls with equivalent inputs (even if vars is built in a
+// different key order) hash identically, since RenderTemplate only reads
+// vars and doesn't fold its ordering into the output. It never calls the
+// provider, so it's cheap to compute before deciding whether an analysis is
+// actually needed.
+func (a *Analyzer) Fingerprint(logs []string, exitCode int32, runStatus, truncateStrategy string, vars PromptContext) string {
+	logContent := prepareLogs(logs, a.tokenBudget(), truncateStrategy)
+	systemPrompt, _ := RenderTemplate(a.systemPrompt, vars)
+	prompt, _ := a.renderUserPrompt(logContent, exitCode, runStatus, false, vars)
+
+	h := sha256.Sum256([]byte(a.model + "\x00" + systemPrompt + "\x00" + prompt))
+	return hex.EncodeToString(h[:])
+}
+
+// analyzeWithBudget prepares logs within tokenBudget and calls the provider.
+func (a *Analyzer) analyzeWithBudget(ctx context.Context, logs []string, exitCode int32, runStatus, truncateStrategy string, partial bool, tokenBudget int, vars PromptContext) (*AnalysisResult, error) {
+	logContent := prepareLogs(logs, tokenBudget, truncateStrategy)
+
+	systemPrompt, sysWarnings := RenderTemplate(a.systemPrompt, vars)
+	prompt, promptWarnings := a.renderUserPrompt(logContent, exitCode, runStatus, partial, vars)
+	warnings := append(sysWarnings, promptWarnings...)
 
-	// Call OpenAI API
 	req := OpenAIRequest{
 		Model: a.model,
 		Messages: []Message{
 			{
 				Role:    "system",
-				Content: a.systemPrompt,
+				Content: systemPrompt,
 			},
 			{
 				Role:    "user",
@@ -111,31 +274,196 @@ func (a *Analyzer) AnalyzeLogs(ctx context.Context, logs []string, exitCode int3
 		MaxTokens: a.maxTokens,
 	}
 
-	report, tokensUsed, err := a.callOpenAI(ctx, req)
+	report, promptTokens, completionTokens, err := a.callOpenAI(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	report, category := extractErrorCategory(report)
+
+	return &AnalysisResult{
+		Report:           report,
+		TokensUsed:       promptTokens + completionTokens,
+		PromptWarnings:   warnings,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		ErrorCategory:    category,
+	}, nil
+}
+
+// renderUserPrompt builds the user-facing analysis prompt. When no custom
+// template is configured it falls back to the built-in prompt; otherwise it
+// renders userPromptTemplate against vars plus the run-derived values.
+func (a *Analyzer) renderUserPrompt(logContent string, exitCode int32, runStatus string, partial bool, vars PromptContext) (string, []string) {
+	if a.userPromptTemplate == "" {
+		return buildPrompt(logContent, exitCode, runStatus, partial, vars), nil
+	}
+
+	merged := make(PromptContext, len(vars)+4)
+	for k, v := range vars {
+		merged[k] = v
+	}
+	merged["logs"] = logContent
+	merged["exit_code"] = fmt.Sprintf("%d", exitCode)
+	merged["status"] = runStatus
+	merged["partial"] = fmt.Sprintf("%t", partial)
+
+	return RenderTemplate(a.userPromptTemplate, merged)
+}
+
+// AnalyzeDiff analyzes a failing run by comparing it against diffContent (a
+// diff versus the last successful run in the same group, e.g. from
+// DiffLogs) plus currentTail (the current run's own log lines), focusing the
+// model on what changed rather than the raw logs. It doesn't use a custom
+// UserPromptTemplate — diff mode has its own fixed prompt shape.
+func (a *Analyzer) AnalyzeDiff(ctx context.Context, diffContent, currentTail string, exitCode int32, runStatus string, vars PromptContext) (*AnalysisResult, error) {
+	budget := a.tokenBudget()
+	diffBudget := int(float64(budget) * 0.7)
+	tailBudget := budget - diffBudget
+
+	truncatedDiff := prepareLogs(strings.Split(diffContent, "\n"), diffBudget, "tail")
+	truncatedTail := prepareLogs(strings.Split(currentTail, "\n"), tailBudget, "tail")
+
+	systemPrompt, warnings := RenderTemplate(a.systemPrompt, vars)
+	prompt := buildDiffPrompt(truncatedDiff, truncatedTail, exitCode, runStatus)
+
+	req := OpenAIRequest{
+		Model: a.model,
+		Messages: []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: prompt},
+		},
+		MaxTokens: a.maxTokens,
+	}
+
+	report, promptTokens, completionTokens, err := a.callOpenAI(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	report, category := extractErrorCategory(report)
 
 	return &AnalysisResult{
-		Report:      report,
-		TokensUsed:  tokensUsed,
-		GeneratedAt: time.Now(),
+		Report:           report,
+		TokensUsed:       promptTokens + completionTokens,
+		PromptWarnings:   warnings,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		ErrorCategory:    category,
+		GeneratedAt:      time.Now(),
 	}, nil
 }
 
-// callOpenAI makes a request to the OpenAI API
-func (a *Analyzer) callOpenAI(ctx context.Context, req OpenAIRequest) (string, int, error) {
+// ConversationTurn is one prior question/answer exchange in a follow-up
+// conversation, supplied to AnswerFollowUp so the model has the thread's
+// history for context.
+type ConversationTurn struct {
+	Question string
+	Answer   string
+}
+
+// AnswerFollowUp answers a follow-up question about a run whose AI report
+// has already been generated. logs and truncateStrategy are prepared the
+// same way as the original analysis, so the model sees consistent log
+// context; history is the prior exchanges in the conversation, oldest first.
+func (a *Analyzer) AnswerFollowUp(ctx context.Context, logs []string, truncateStrategy, report string, history []ConversationTurn, question string, vars PromptContext) (*AnalysisResult, error) {
+	budget := a.tokenBudget()
+	logBudget := int(float64(budget) * 0.6)
+	logContent := prepareLogs(logs, logBudget, truncateStrategy)
+
+	systemPrompt, warnings := RenderTemplate(a.systemPrompt, vars)
+
+	messages := []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: buildFollowUpContextPrompt(logContent, report)},
+	}
+	for _, turn := range history {
+		messages = append(messages,
+			Message{Role: "user", Content: turn.Question},
+			Message{Role: "assistant", Content: turn.Answer},
+		)
+	}
+	messages = append(messages, Message{Role: "user", Content: question})
+
+	req := OpenAIRequest{
+		Model:     a.model,
+		Messages:  messages,
+		MaxTokens: a.maxTokens,
+	}
+
+	answer, promptTokens, completionTokens, err := a.callOpenAI(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AnalysisResult{
+		Report:           answer,
+		TokensUsed:       promptTokens + completionTokens,
+		PromptWarnings:   warnings,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		GeneratedAt:      time.Now(),
+	}, nil
+}
+
+// buildFollowUpContextPrompt introduces a follow-up conversation with the
+// logs and report the earlier analysis was based on, so the model can answer
+// grounded in that context rather than the conversation history alone.
+func buildFollowUpContextPrompt(logContent, report string) string {
+	var builder strings.Builder
+
+	builder.WriteString("You previously analyzed the following script execution logs and produced the report below. ")
+	builder.WriteString("The user now has follow-up questions about this run. Answer using only the logs and report provided, and say so if the answer isn't in them.\n\n")
+	builder.WriteString("Logs:\n")
+	builder.WriteString(logContent)
+	builder.WriteString("\n\nReport:\n")
+	builder.WriteString(report)
+
+	return builder.String()
+}
+
+// buildDiffPrompt creates the analysis prompt for diff mode
+func buildDiffPrompt(diffContent, currentTail string, exitCode int32, runStatus string) string {
+	var builder strings.Builder
+
+	builder.WriteString("This script run failed. Compare it against the most recent successful run in the same group to identify what changed.\n\n")
+	builder.WriteString("Execution Status: ")
+	builder.WriteString(runStatus)
+	builder.WriteString("\n")
+	builder.WriteString(fmt.Sprintf("Exit Code: %d\n\n", exitCode))
+	builder.WriteString("Diff versus the last successful run (\"-\" = only in the successful run, \"+\" = only in the current run, unmarked = unchanged):\n")
+	builder.WriteString(diffContent)
+	builder.WriteString("\n\nTail of the current (failing) run's logs:\n")
+	builder.WriteString(currentTail)
+	builder.WriteString("\n\nPlease provide:\n")
+	builder.WriteString("1. What changed between the last successful run and this one\n")
+	builder.WriteString("2. Which change most likely caused the failure\n")
+	builder.WriteString("3. Suggested fixes or next steps\n")
+	builder.WriteString(errorCategoryInstruction)
+
+	return builder.String()
+}
+
+// callOpenAI makes a request to the OpenAI API, returning the report text
+// and the split prompt/completion token counts.
+func (a *Analyzer) callOpenAI(ctx context.Context, req OpenAIRequest) (string, int, int, error) {
+	ctx, span := tracer.Start(ctx, "ai.provider_call", trace.WithAttributes(
+		attribute.String("ai.model", req.Model),
+		attribute.Int("ai.max_tokens", req.MaxTokens),
+	))
+	defer span.End()
+
 	// Marshal request
 	body, err := json.Marshal(req)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to marshal request: %w", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", 0, 0, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create HTTP request using configured base URL
 	url := fmt.Sprintf("%s/chat/completions", a.baseURL)
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to create request: %w", err)
+		return "", 0, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
@@ -144,39 +472,66 @@ func (a *Analyzer) callOpenAI(ctx context.Context, req OpenAIRequest) (string, i
 	// Send request
 	resp, err := a.httpClient.Do(httpReq)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to call OpenAI API: %w", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", 0, 0, fmt.Errorf("failed to call OpenAI API: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to read response: %w", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", 0, 0, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", 0, fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(respBody))
+		err := fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(respBody))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", 0, 0, err
 	}
 
 	// Parse response
 	var openAIResp OpenAIResponse
 	if err := json.Unmarshal(respBody, &openAIResp); err != nil {
-		return "", 0, fmt.Errorf("failed to unmarshal response: %w", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", 0, 0, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
 	if len(openAIResp.Choices) == 0 {
-		return "", 0, fmt.Errorf("no choices in response")
+		err := fmt.Errorf("no choices in response")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", 0, 0, err
 	}
 
 	report := openAIResp.Choices[0].Message.Content
-	tokensUsed := openAIResp.Usage.TotalTokens
 
-	return report, tokensUsed, nil
+	span.SetAttributes(
+		attribute.Int("ai.prompt_tokens", openAIResp.Usage.PromptTokens),
+		attribute.Int("ai.completion_tokens", openAIResp.Usage.CompletionTokens),
+	)
+
+	return report, openAIResp.Usage.PromptTokens, openAIResp.Usage.CompletionTokens, nil
 }
 
-// prepareLogs limits log content based on truncation strategy
-func prepareLogs(logs []string, maxLines int, strategy string) string {
-	if len(logs) <= maxLines {
+// prepareLogs truncates log content to fit within tokenBudget, applying the
+// configured strategy at the token level rather than counting lines. A
+// handful of very long lines can blow a line-count budget just as easily as
+// many short ones, so we estimate tokens per line and fill the budget
+// directly.
+func prepareLogs(logs []string, tokenBudget int, strategy string) string {
+	total := 0
+	lineTokens := make([]int, len(logs))
+	for i, line := range logs {
+		lineTokens[i] = estimateTokens(line) + 1 // +1 for the newline
+		total += lineTokens[i]
+	}
+
+	if total <= tokenBudget {
 		return strings.Join(logs, "\n")
 	}
 
@@ -184,51 +539,250 @@ func prepareLogs(logs []string, maxLines int, strategy string) string {
 
 	switch strategy {
 	case "head":
-		// Keep first N lines
-		for i := 0; i < maxLines && i < len(logs); i++ {
+		n, used := takeWhileUnderBudget(lineTokens, 0, len(logs), tokenBudget)
+		for i := 0; i < n; i++ {
 			builder.WriteString(logs[i])
 			builder.WriteString("\n")
 		}
-		builder.WriteString(fmt.Sprintf("\n... [%d lines omitted] ...\n", len(logs)-maxLines))
+		builder.WriteString(fmt.Sprintf("\n... [%d lines omitted, ~%d tokens] ...\n", len(logs)-n, total-used))
 
 	case "tail":
-		// Keep last N lines
-		builder.WriteString(fmt.Sprintf("... [%d lines omitted] ...\n\n", len(logs)-maxLines))
-		for i := len(logs) - maxLines; i < len(logs); i++ {
+		n, used := takeWhileUnderBudgetReverse(lineTokens, tokenBudget)
+		start := len(logs) - n
+		builder.WriteString(fmt.Sprintf("... [%d lines omitted, ~%d tokens] ...\n\n", start, total-used))
+		for i := start; i < len(logs); i++ {
 			builder.WriteString(logs[i])
 			builder.WriteString("\n")
 		}
 
 	case "smart":
-		// Keep first 40% and last 60% with summary
-		firstPart := int(float64(maxLines) * 0.4)
-		lastPart := maxLines - firstPart
+		// Keep the first 40% and last 60% of the budget, by tokens.
+		headBudget := int(float64(tokenBudget) * 0.4)
+		tailBudget := tokenBudget - headBudget
+
+		headN, headUsed := takeWhileUnderBudget(lineTokens, 0, len(logs), headBudget)
+		tailN, tailUsed := takeWhileUnderBudgetReverse(lineTokens, tailBudget)
+		tailStart := len(logs) - tailN
+		if tailStart < headN {
+			tailStart = headN
+		}
 
-		for i := 0; i < firstPart; i++ {
+		for i := 0; i < headN; i++ {
 			builder.WriteString(logs[i])
 			builder.WriteString("\n")
 		}
 
-		builder.WriteString(fmt.Sprintf("\n... [%d lines omitted] ...\n\n", len(logs)-maxLines))
+		omitted := tailStart - headN
+		builder.WriteString(fmt.Sprintf("\n... [%d lines omitted, ~%d tokens] ...\n\n", omitted, total-headUsed-tailUsed))
 
-		for i := len(logs) - lastPart; i < len(logs); i++ {
+		for i := tailStart; i < len(logs); i++ {
 			builder.WriteString(logs[i])
 			builder.WriteString("\n")
 		}
 
+	case "smart_v2":
+		return prepareLogsSmartV2(logs, lineTokens, total, tokenBudget)
+
 	default:
 		// Default to tail strategy
-		return prepareLogs(logs, maxLines, "tail")
+		return prepareLogs(logs, tokenBudget, "tail")
 	}
 
 	return builder.String()
 }
 
-// buildPrompt creates the analysis prompt
-func buildPrompt(logContent string, exitCode int32, runStatus string) string {
+// errorPatterns are substrings whose presence in a log line strongly
+// suggests it's part of a failure (a panic, a traceback, a fatal signal).
+// Matching is case-sensitive on purpose: "ERROR" in a log level column is a
+// much stronger signal than "error" appearing in ordinary prose.
+var errorPatterns = []string{
+	"panic:", "Traceback", "ERROR", "FATAL", "Exception",
+	"exit status", "OOM-killer", "Out of memory", "core dumped",
+	"segmentation fault", "SIGSEGV", "SIGABRT",
+}
+
+// scoreLine assigns an importance score to a single log line: STDERR output
+// scores higher than STDOUT, and lines matching a known error pattern score
+// higher still.
+func scoreLine(line string) int {
+	score := 0
+	if strings.HasPrefix(line, "[STDERR]") {
+		score += 2
+	}
+	for _, pattern := range errorPatterns {
+		if strings.Contains(line, pattern) {
+			score += 5
+			break
+		}
+	}
+	return score
+}
+
+// prepareLogsSmartV2 scores every line by error signal, spreads a small
+// amount of that score to neighboring lines so surrounding context survives
+// alongside the error itself, then greedily fills the token budget with the
+// highest-scoring contiguous chunks, restoring original order. Runs of
+// dropped lines are marked with an elision count so the reader knows what
+// was cut and how much.
+func prepareLogsSmartV2(logs []string, lineTokens []int, total, tokenBudget int) string {
+	scores := make([]int, len(logs))
+	for i, line := range logs {
+		scores[i] = scoreLine(line)
+	}
+
+	// Give lines adjacent to a scored line partial credit, so a stack trace's
+	// surrounding context (the line that triggered it, the lines it printed
+	// right after) survives even if they don't match a pattern themselves.
+	boosted := make([]int, len(scores))
+	copy(boosted, scores)
+	for i, s := range scores {
+		if s == 0 {
+			continue
+		}
+		for _, j := range []int{i - 2, i - 1, i + 1, i + 2} {
+			if j >= 0 && j < len(boosted) && boosted[j] < s/2 {
+				boosted[j] = s / 2
+			}
+		}
+	}
+
+	included := make([]bool, len(logs))
+	used := 0
+
+	// Rank line indices by score, highest first, and include lines
+	// (in original order) until the budget runs out.
+	order := make([]int, len(logs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return boosted[order[a]] > boosted[order[b]]
+	})
+
+	for _, i := range order {
+		if used+lineTokens[i] > tokenBudget {
+			continue
+		}
+		included[i] = true
+		used += lineTokens[i]
+	}
+
 	var builder strings.Builder
+	omittedRun := 0
+	omittedTokens := 0
+	flushElision := func() {
+		if omittedRun == 0 {
+			return
+		}
+		builder.WriteString(fmt.Sprintf("... [%d lines omitted, ~%d tokens] ...\n", omittedRun, omittedTokens))
+		omittedRun = 0
+		omittedTokens = 0
+	}
+
+	for i, line := range logs {
+		if !included[i] {
+			omittedRun++
+			omittedTokens += lineTokens[i]
+			continue
+		}
+		flushElision()
+		builder.WriteString(line)
+		builder.WriteString("\n")
+	}
+	flushElision()
+
+	return builder.String()
+}
+
+// takeWhileUnderBudget returns how many lines (starting at start, up to end)
+// fit within budget tokens, and the tokens actually used.
+func takeWhileUnderBudget(lineTokens []int, start, end, budget int) (n, used int) {
+	for i := start; i < end; i++ {
+		if used+lineTokens[i] > budget {
+			break
+		}
+		used += lineTokens[i]
+		n++
+	}
+	return n, used
+}
+
+// takeWhileUnderBudgetReverse returns how many lines from the end of
+// lineTokens fit within budget tokens, and the tokens actually used.
+func takeWhileUnderBudgetReverse(lineTokens []int, budget int) (n, used int) {
+	for i := len(lineTokens) - 1; i >= 0; i-- {
+		if used+lineTokens[i] > budget {
+			break
+		}
+		used += lineTokens[i]
+		n++
+	}
+	return n, used
+}
+
+// errorCategoryTaxonomy lists the fixed set of root-cause labels the model
+// is asked to classify a run into. It's duplicated (not imported from
+// models.ErrorCategory) so this package stays independent of the models
+// package; the worker normalizes whatever comes back against the
+// authoritative taxonomy in models.NormalizeErrorCategory before persisting.
+var errorCategoryTaxonomy = []string{
+	"oom", "network", "permission", "dependency", "syntax",
+	"timeout", "configuration", "flaky-test", "unknown",
+}
+
+// errorCategoryInstruction is appended to prompts that should classify the
+// run's outcome, asking for a final, machine-parseable line.
+var errorCategoryInstruction = fmt.Sprintf(
+	"On its own final line, output exactly \"Category: <category>\" where <category> is one of: %s. "+
+		"Use \"unknown\" if the run succeeded or no category clearly applies.\n",
+	strings.Join(errorCategoryTaxonomy, ", "),
+)
+
+// errorCategoryLineRe matches the "Category: <value>" line requested by
+// errorCategoryInstruction, case-insensitively, at the start of a line.
+var errorCategoryLineRe = regexp.MustCompile(`(?im)^\s*category:\s*([a-z-]+)\s*$`)
+
+// partialRunInstruction is prepended to the prompt for a run that's still in
+// progress, so the model doesn't mistake a truncated tail for the whole run.
+const partialRunInstruction = "IMPORTANT: this run has not finished yet. The logs below are only what has " +
+	"been captured so far, not the complete execution. Base your analysis solely on this partial data, and " +
+	"make clear in your summary that the run is still in progress and this is a provisional report.\n\n"
+
+// extractErrorCategory pulls the "Category: <value>" line out of report (if
+// present), returning the report with that line removed and the raw
+// (un-normalized) category text. It returns the report unchanged and an
+// empty category if no such line is found.
+func extractErrorCategory(report string) (string, string) {
+	loc := errorCategoryLineRe.FindStringSubmatchIndex(report)
+	if loc == nil {
+		return report, ""
+	}
+	category := report[loc[2]:loc[3]]
+	cleaned := strings.TrimSpace(report[:loc[0]] + report[loc[1]:])
+	return cleaned, category
+}
+
+// buildPrompt creates the analysis prompt. vars["command"], if set, is the
+// command line that produced the run, so the model has context for what was
+// actually being executed instead of just its output.
+func buildPrompt(logContent string, exitCode int32, runStatus string, partial bool, vars PromptContext) string {
+	var builder strings.Builder
+
+	if partial {
+		builder.WriteString(partialRunInstruction)
+	}
 
 	builder.WriteString("Analyze the following script execution logs:\n\n")
+	if name := vars["name"]; name != "" {
+		builder.WriteString("Run Name: ")
+		builder.WriteString(name)
+		builder.WriteString("\n")
+	}
+	if command := vars["command"]; command != "" {
+		builder.WriteString("Command: ")
+		builder.WriteString(command)
+		builder.WriteString("\n")
+	}
 	builder.WriteString("Execution Status: ")
 	builder.WriteString(runStatus)
 	builder.WriteString("\n")
@@ -245,6 +799,7 @@ func buildPrompt(logContent string, exitCode int32, runStatus string) string {
 	} else {
 		builder.WriteString("3. Any warnings or noteworthy observations\n")
 	}
+	builder.WriteString(errorCategoryInstruction)
 
 	return builder.String()
 }
@@ -0,0 +1,156 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// diffLineCap bounds the LCS computation in unifiedDiff, which is O(n*m) in
+// time and memory. Beyond this many lines on either side we fall back to a
+// coarser set-based summary instead of blowing up worker memory/CPU.
+const diffLineCap = 2000
+
+// DiffLogs computes a diff-style comparison between a baseline run's logs
+// and the current run's logs, after normalizing each line (trimming
+// trailing whitespace). It's meant to highlight what changed between "the
+// last time this worked" and now for an LLM prompt, not to be a
+// byte-perfect diff tool.
+func DiffLogs(baseline, current []string) string {
+	baseline = normalizeLines(baseline)
+	current = normalizeLines(current)
+
+	if len(baseline) > diffLineCap || len(current) > diffLineCap {
+		return summarizeDiff(baseline, current)
+	}
+
+	return unifiedDiff(baseline, current)
+}
+
+func normalizeLines(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = strings.TrimRight(l, " \t\r")
+	}
+	return out
+}
+
+// unifiedDiff computes an LCS-based line diff and renders it with leading
+// "+"/"-"/" " markers, similar in spirit to `diff -u` but without hunk
+// headers — the model gets the whole thing.
+func unifiedDiff(a, b []string) string {
+	lcs := longestCommonSubsequence(a, b)
+
+	var out strings.Builder
+	i, j, k := 0, 0, 0
+	for k < len(lcs) {
+		for i < len(a) && a[i] != lcs[k] {
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		}
+		for j < len(b) && b[j] != lcs[k] {
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+		out.WriteString("  " + lcs[k] + "\n")
+		i++
+		j++
+		k++
+	}
+	for ; i < len(a); i++ {
+		out.WriteString("- " + a[i] + "\n")
+	}
+	for ; j < len(b); j++ {
+		out.WriteString("+ " + b[j] + "\n")
+	}
+
+	return out.String()
+}
+
+// longestCommonSubsequence returns the longest common subsequence of a and b
+// via the standard O(n*m) dynamic-programming table.
+func longestCommonSubsequence(a, b []string) []string {
+	n, m := len(a), len(b)
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] >= dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+
+	lcs := make([]string, 0, dp[0][0])
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			lcs = append(lcs, a[i])
+			i++
+			j++
+		case dp[i+1][j] >= dp[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return lcs
+}
+
+// summarizeDiff is the diffLineCap fallback: instead of a full ordered diff,
+// it reports which distinct lines appear only in the baseline or only in the
+// current run, which is cheap (linear) and still useful for spotting a
+// regression in a very large log.
+func summarizeDiff(a, b []string) string {
+	aCount := make(map[string]int, len(a))
+	for _, l := range a {
+		aCount[l]++
+	}
+	bCount := make(map[string]int, len(b))
+	for _, l := range b {
+		bCount[l]++
+	}
+
+	var removed, added []string
+	for l, ca := range aCount {
+		if bCount[l] < ca {
+			removed = append(removed, l)
+		}
+	}
+	for l, cb := range bCount {
+		if aCount[l] < cb {
+			added = append(added, l)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	const maxExamples = 200
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("Logs too large for a full diff (%d vs %d lines); showing distinct lines unique to each side (up to %d each).\n\n", len(a), len(b), maxExamples))
+
+	out.WriteString(fmt.Sprintf("Lines only in the baseline run (%d total):\n", len(removed)))
+	for i, l := range removed {
+		if i >= maxExamples {
+			break
+		}
+		out.WriteString("- " + l + "\n")
+	}
+
+	out.WriteString(fmt.Sprintf("\nLines only in the current run (%d total):\n", len(added)))
+	for i, l := range added {
+		if i >= maxExamples {
+			break
+		}
+		out.WriteString("+ " + l + "\n")
+	}
+
+	return out.String()
+}
@@ -0,0 +1,57 @@
+package ai
+
+// ModelPricing holds the USD cost per 1,000 tokens for a model.
+type ModelPricing struct {
+	PromptPer1K     float64 `json:"prompt_per_1k"`
+	CompletionPer1K float64 `json:"completion_per_1k"`
+}
+
+// defaultPricing is a built-in table of $/1K tokens for common OpenAI
+// models, current as of when this was written. It's intentionally rough:
+// good enough for a cost estimate, not for an invoice.
+var defaultPricing = map[string]ModelPricing{
+	"gpt-4o":        {PromptPer1K: 0.0025, CompletionPer1K: 0.01},
+	"gpt-4o-mini":   {PromptPer1K: 0.00015, CompletionPer1K: 0.0006},
+	"gpt-4-turbo":   {PromptPer1K: 0.01, CompletionPer1K: 0.03},
+	"gpt-4":         {PromptPer1K: 0.03, CompletionPer1K: 0.06},
+	"gpt-3.5-turbo": {PromptPer1K: 0.0005, CompletionPer1K: 0.0015},
+	"o1":            {PromptPer1K: 0.015, CompletionPer1K: 0.06},
+	"o1-mini":       {PromptPer1K: 0.003, CompletionPer1K: 0.012},
+}
+
+// PricingTable resolves per-model pricing, preferring admin-configured
+// overrides (for proxies and models we don't ship a built-in price for)
+// over the built-in table.
+type PricingTable struct {
+	overrides map[string]ModelPricing
+}
+
+// NewPricingTable creates a PricingTable that consults overrides before
+// falling back to the built-in pricing. overrides may be nil.
+func NewPricingTable(overrides map[string]ModelPricing) *PricingTable {
+	return &PricingTable{overrides: overrides}
+}
+
+// Lookup returns the pricing for model and whether pricing is known at all
+// (built-in or override). Unknown models return the zero value and false,
+// so callers can skip cost estimation rather than reporting a bogus $0.
+func (t *PricingTable) Lookup(model string) (ModelPricing, bool) {
+	if t != nil {
+		if p, ok := t.overrides[model]; ok {
+			return p, true
+		}
+	}
+	p, ok := defaultPricing[model]
+	return p, ok
+}
+
+// EstimateCost returns the estimated USD cost of an analysis, or 0 with
+// ok=false if the model's pricing isn't known.
+func (t *PricingTable) EstimateCost(model string, promptTokens, completionTokens int) (cost float64, ok bool) {
+	pricing, known := t.Lookup(model)
+	if !known {
+		return 0, false
+	}
+	cost = float64(promptTokens)/1000*pricing.PromptPer1K + float64(completionTokens)/1000*pricing.CompletionPer1K
+	return cost, true
+}
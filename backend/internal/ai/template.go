@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// templateVarPattern matches Go-template-style placeholders like
+// "{{project}}". Anything inside the braces that isn't a bare identifier
+// (e.g. literal JSON such as "{{\"key\": \"value\"}}") is left untouched,
+// which is what gives us "escaping" for free: just don't write an
+// identifier in there.
+var templateVarPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*\}\}`)
+
+// PromptContext supplies the values available to prompt templates.
+type PromptContext map[string]string
+
+// ValidateTemplate checks a prompt template for unbalanced braces so a
+// typo is caught when settings are saved, not when analysis runs.
+func ValidateTemplate(tmpl string) error {
+	depth := 0
+	for i := 0; i < len(tmpl); i++ {
+		switch {
+		case strings.HasPrefix(tmpl[i:], "{{"):
+			depth++
+			i++
+		case strings.HasPrefix(tmpl[i:], "}}"):
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("unmatched '}}' at position %d", i)
+			}
+			i++
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("unclosed '{{' in template")
+	}
+	return nil
+}
+
+// RenderTemplate substitutes every "{{name}}" placeholder with the matching
+// value from vars. Placeholders with no matching variable render as an
+// empty string, and their name is returned as a warning so the caller can
+// record it in analysis metadata instead of failing the analysis outright.
+func RenderTemplate(tmpl string, vars PromptContext) (string, []string) {
+	var warnings []string
+	seen := make(map[string]bool)
+
+	rendered := templateVarPattern.ReplaceAllStringFunc(tmpl, func(match string) string {
+		name := templateVarPattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		if !seen[name] {
+			warnings = append(warnings, name)
+			seen[name] = true
+		}
+		return ""
+	})
+
+	return rendered, warnings
+}
@@ -0,0 +1,107 @@
+package aiworker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aliancn/swiftlog/backend/internal/queue"
+	"github.com/aliancn/swiftlog/backend/internal/repository"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// TestReconcileStuckProcessing_OnlyOneReplicaProceeds is the replica-race
+// case: two "replicas" calling the reconciler at the same instant against
+// the same Redis lock must not both list and touch the stuck runs - only
+// the one that wins the SetNX should query the database at all.
+func TestReconcileStuckProcessing_OnlyOneReplicaProceeds(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// Only the winning replica should get this far: ListStuckProcessing
+	// finds nothing, so the reconciler returns without touching
+	// groupRepo/projectRepo.
+	mock.ExpectQuery("SELECT (.|\n)*FROM log_runs").WillReturnRows(sqlmock.NewRows(
+		[]string{"id", "group_id", "start_time", "end_time", "status", "exit_code", "ai_report", "ai_status", "ai_metadata", "ai_content_hash", "created_at", "updated_at"},
+	))
+
+	logRunRepo := repository.NewLogRunRepository(db)
+	groupRepo := repository.NewLogGroupRepository(db)
+	projectRepo := repository.NewProjectRepository(db)
+	taskQueue := queue.NewQueue(redisClient)
+
+	var wg sync.WaitGroup
+	ready := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ready
+			reconcileStuckProcessing(context.Background(), redisClient, logRunRepo, groupRepo, projectRepo, taskQueue, time.Minute, 3, 10)
+		}()
+	}
+	close(ready)
+	wg.Wait()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expected exactly one replica to query the database, got: %v", err)
+	}
+}
+
+// TestReconcileStuckProcessing_LockExpiryAllowsLaterRun covers the
+// non-racing case: once the lock TTL has passed, a later reconcile sweep
+// (e.g. the next ticker tick) must be able to acquire it again.
+func TestReconcileStuckProcessing_LockExpiryAllowsLaterRun(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	emptyRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows(
+			[]string{"id", "group_id", "start_time", "end_time", "status", "exit_code", "ai_report", "ai_status", "ai_metadata", "ai_content_hash", "created_at", "updated_at"},
+		)
+	}
+	mock.ExpectQuery("SELECT (.|\n)*FROM log_runs").WillReturnRows(emptyRows())
+	mock.ExpectQuery("SELECT (.|\n)*FROM log_runs").WillReturnRows(emptyRows())
+
+	logRunRepo := repository.NewLogRunRepository(db)
+	groupRepo := repository.NewLogGroupRepository(db)
+	projectRepo := repository.NewProjectRepository(db)
+	taskQueue := queue.NewQueue(redisClient)
+
+	reconcileStuckProcessing(context.Background(), redisClient, logRunRepo, groupRepo, projectRepo, taskQueue, time.Minute, 3, 10)
+
+	// Simulate the lock TTL elapsing by deleting it directly, rather than
+	// waiting out the real two-minute reconcileLockTTL.
+	mr.Del(reconcileLockKey)
+
+	reconcileStuckProcessing(context.Background(), redisClient, logRunRepo, groupRepo, projectRepo, taskQueue, time.Minute, 3, 10)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expected both sweeps to query the database once the lock was released: %v", err)
+	}
+}
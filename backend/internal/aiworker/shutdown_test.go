@@ -0,0 +1,119 @@
+package aiworker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aliancn/swiftlog/backend/internal/queue"
+	"github.com/aliancn/swiftlog/backend/internal/repository"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// newShutdownTestWorker builds a Worker with just enough wired up to
+// exercise trackInFlight/DrainUnfinished: a sqlmock-backed LogRunRepository
+// (DrainUnfinished only needs UpdateAIStatus) and a real miniredis-backed
+// Queue. Everything else DrainUnfinished doesn't touch is left nil.
+func newShutdownTestWorker(t *testing.T) (*Worker, sqlmock.Sqlmock, *redis.Client) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	logRunRepo := repository.NewLogRunRepository(db)
+	taskQueue := queue.NewQueue(redisClient)
+
+	w := NewWorker(logRunRepo, nil, nil, nil, nil, nil, redisClient, taskQueue, nil, nil)
+	return w, mock, redisClient
+}
+
+// TestDrainUnfinished_RequeuesSlowAnalysisWithoutLosingIt is the graceful
+// shutdown scenario: a task whose analysis is still running when the
+// shutdown grace period expires must be reset to pending and requeued
+// exactly once, and the "slow analyzer" goroutine still working on it must
+// recognize the handoff and not also report a result for it.
+//
+// It drives trackInFlight/untrackInFlight the same way worker() does around
+// a real analysis, with a slow fake analyzer standing in for
+// processRunByID, since that's the seam DrainUnfinished actually operates
+// on - the real analysis path pulls in the AI provider and Loki, which is
+// its own concern already covered by where those are tested.
+func TestDrainUnfinished_RequeuesSlowAnalysisWithoutLosingIt(t *testing.T) {
+	w, mock, _ := newShutdownTestWorker(t)
+	ctx := context.Background()
+
+	mock.ExpectExec("UPDATE log_runs").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	task := &queue.AIAnalysisTask{RunID: uuid.New(), UserID: uuid.New()}
+
+	analysisFinished := make(chan struct{})
+	go func() {
+		// The "slow fake analyzer": takes far longer than the shutdown
+		// grace period given to WaitInFlight below.
+		w.trackInFlight(task)
+		time.Sleep(200 * time.Millisecond)
+		stillOwned := w.untrackInFlight(task.RunID)
+		if stillOwned {
+			t.Errorf("untrackInFlight reported ownership after DrainUnfinished already claimed the task")
+		}
+		close(analysisFinished)
+	}()
+
+	// Give the goroutine a moment to register as in-flight before the grace
+	// period "expires".
+	time.Sleep(20 * time.Millisecond)
+
+	if finished := w.WaitInFlight(50 * time.Millisecond); finished {
+		t.Fatal("WaitInFlight reported all tasks finished before the slow analyzer completed")
+	}
+
+	requeued := w.DrainUnfinished(ctx)
+	if requeued != 1 {
+		t.Fatalf("DrainUnfinished requeued %d tasks, want 1", requeued)
+	}
+
+	select {
+	case <-analysisFinished:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the slow analyzer goroutine to finish")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("run was not reset to pending: %v", err)
+	}
+}
+
+// TestDrainUnfinished_LeavesCompletedTasksAlone covers the other half of
+// the race DrainUnfinished has to get right: a task that finishes (and
+// calls untrackInFlight) before shutdown fires must not be found - and
+// therefore not requeued - by a subsequent drain.
+func TestDrainUnfinished_LeavesCompletedTasksAlone(t *testing.T) {
+	w, _, _ := newShutdownTestWorker(t)
+	ctx := context.Background()
+
+	task := &queue.AIAnalysisTask{RunID: uuid.New(), UserID: uuid.New()}
+	w.trackInFlight(task)
+	if owned := w.untrackInFlight(task.RunID); !owned {
+		t.Fatal("untrackInFlight reported the task as already claimed, but nothing else touched it yet")
+	}
+
+	requeued := w.DrainUnfinished(ctx)
+	if requeued != 0 {
+		t.Fatalf("DrainUnfinished requeued %d tasks, want 0 (the task already finished)", requeued)
+	}
+}
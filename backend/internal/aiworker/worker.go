@@ -0,0 +1,1144 @@
+package aiworker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliancn/swiftlog/backend/internal/ai"
+	"github.com/aliancn/swiftlog/backend/internal/dispatch"
+	"github.com/aliancn/swiftlog/backend/internal/loki"
+	"github.com/aliancn/swiftlog/backend/internal/models"
+	"github.com/aliancn/swiftlog/backend/internal/queue"
+	"github.com/aliancn/swiftlog/backend/internal/quota"
+	"github.com/aliancn/swiftlog/backend/internal/redact"
+	"github.com/aliancn/swiftlog/backend/internal/repository"
+	ws "github.com/aliancn/swiftlog/backend/internal/websocket"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Package aiworker holds the AI-analysis worker's business logic
+// (dispatch loop, per-run analysis, retry/reconcile sweeps), independent
+// of how it's started, so cmd/ai-worker and cmd/swiftlog-server can both
+// run one against a shared DB pool and Redis client without duplicating it.
+var tracer = otel.Tracer("github.com/aliancn/swiftlog/backend/internal/aiworker")
+
+// Worker processes AI analysis jobs
+type Worker struct {
+	logRunRepo           *repository.LogRunRepository
+	groupRepo            *repository.LogGroupRepository
+	projectRepo          *repository.ProjectRepository
+	settingsRepo         *repository.SettingsRepository
+	instanceSettingsRepo *repository.InstanceSettingsRepository
+	lokiClient           *loki.Client
+	redisClient          *redis.Client
+	taskQueue            *queue.Queue
+	quotaTracker         *quota.TokenUsageTracker
+	analysisVersionRepo  *repository.AnalysisVersionRepository
+
+	// inFlight tracks tasks currently being processed, so a graceful
+	// shutdown can wait for them and, if they don't finish in time, requeue
+	// exactly the ones still running instead of losing or guessing at them.
+	// inFlightEmpty is signalled whenever inFlight drains to zero, which is
+	// what WaitInFlight actually waits on; a sync.WaitGroup can't be used
+	// here since trackInFlight's Add and WaitInFlight's Wait can run
+	// concurrently, which is the exact misuse the stdlib docs warn about.
+	mu            sync.Mutex
+	inFlight      map[uuid.UUID]*queue.AIAnalysisTask
+	inFlightEmpty *sync.Cond
+
+	// cancelFuncs holds the cancel function for each run currently being
+	// analyzed, so a cancel signal for that run can abort it. Guarded by mu.
+	cancelFuncs map[uuid.UUID]context.CancelFunc
+
+	// consumerID identifies this process's processing list in the task
+	// queue (queue.Queue.ConsumeAITask/Ack/Reclaim), so a task moved into it
+	// can be found and reclaimed if this process crashes before finishing.
+	consumerID string
+
+	// maxTaskAge bounds how old a task's CreatedAt may be before the
+	// dispatcher expires it instead of analyzing it, so a queue backlog left
+	// over from an outage doesn't burn tokens analyzing runs nobody's
+	// waiting on anymore. Zero disables the check.
+	maxTaskAge time.Duration
+}
+
+// SetMaxTaskAge overrides maxTaskAge; see its field doc comment. Only the
+// dispatcher goroutine needs this set, so it's applied after construction
+// like SetVisibilityTimeout on the queue.
+func (w *Worker) SetMaxTaskAge(d time.Duration) {
+	w.maxTaskAge = d
+}
+
+// NewWorker creates a new AI worker
+func NewWorker(
+	logRunRepo *repository.LogRunRepository,
+	groupRepo *repository.LogGroupRepository,
+	projectRepo *repository.ProjectRepository,
+	settingsRepo *repository.SettingsRepository,
+	instanceSettingsRepo *repository.InstanceSettingsRepository,
+	lokiClient *loki.Client,
+	redisClient *redis.Client,
+	taskQueue *queue.Queue,
+	quotaTracker *quota.TokenUsageTracker,
+	analysisVersionRepo *repository.AnalysisVersionRepository,
+) *Worker {
+	w := &Worker{
+		logRunRepo:           logRunRepo,
+		groupRepo:            groupRepo,
+		projectRepo:          projectRepo,
+		settingsRepo:         settingsRepo,
+		instanceSettingsRepo: instanceSettingsRepo,
+		lokiClient:           lokiClient,
+		redisClient:          redisClient,
+		taskQueue:            taskQueue,
+		quotaTracker:         quotaTracker,
+		analysisVersionRepo:  analysisVersionRepo,
+		inFlight:             make(map[uuid.UUID]*queue.AIAnalysisTask),
+		cancelFuncs:          make(map[uuid.UUID]context.CancelFunc),
+		consumerID:           NewConsumerID(),
+	}
+	w.inFlightEmpty = sync.NewCond(&w.mu)
+	return w
+}
+
+// NewConsumerID builds a process-wide identity for this worker's processing
+// list. It only needs to be unique per running process, not stable across
+// restarts: a restarted process gets a fresh, empty processing list, and its
+// old one (if any tasks were still in it) is picked up by Reclaim.
+func NewConsumerID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Run starts the worker loop using event-driven architecture with concurrent
+// workers. dispatchCtx governs whether new tasks are pulled off Redis;
+// workCtx is passed down to each task's actual analysis.
+func (w *Worker) Run(dispatchCtx, workCtx context.Context) {
+	// Use a fixed number of concurrent workers
+	// This can be made configurable in the future
+	const maxConcurrentWorkers = 10
+
+	log.Printf("Starting %d concurrent workers for AI analysis...", maxConcurrentWorkers)
+
+	// Create a channel to distribute tasks to workers
+	taskChan := make(chan *queue.AIAnalysisTask, maxConcurrentWorkers*2)
+
+	// Start worker goroutines. They run on workCtx, not dispatchCtx: a
+	// shutdown signal stops new tasks from being pulled in immediately, but
+	// lets in-flight (and already-buffered) tasks keep running until the
+	// grace period expires.
+	for i := 0; i < maxConcurrentWorkers; i++ {
+		go w.worker(workCtx, i+1, taskChan)
+	}
+
+	// Main loop: consume tasks from Redis and distribute to workers
+	log.Println("Worker dispatcher running, waiting for AI analysis tasks from queue...")
+	for {
+		select {
+		case <-dispatchCtx.Done():
+			close(taskChan)
+			return
+		default:
+			// Block and wait for task from Redis queue (5 second timeout)
+			task, err := w.taskQueue.ConsumeAITask(dispatchCtx, w.consumerID, 5*time.Second)
+			if err != nil {
+				log.Printf("Error consuming task: %v", err)
+				continue
+			}
+
+			// No task available (timeout), continue waiting
+			if task == nil {
+				continue
+			}
+
+			// Skip tasks that sat in the queue longer than maxTaskAge: after
+			// an outage the queue can hold tasks for runs whose users no
+			// longer care, and analyzing them wastes tokens and delays
+			// fresher work.
+			if w.maxTaskAge > 0 {
+				if age := time.Since(task.CreatedAt); age > w.maxTaskAge {
+					log.Printf("Task for run %s expired (queued %s ago, max age %s)", task.RunID, age.Round(time.Second), w.maxTaskAge)
+					w.expireTask(task)
+					continue
+				}
+			}
+
+			// Send task to worker channel
+			select {
+			case taskChan <- task:
+				// Task sent successfully
+			case <-dispatchCtx.Done():
+				// Already moved into our processing list with nowhere to go
+				// now that the channel is closing; ack that spot and requeue
+				// the task itself rather than leaving it to time out via
+				// Reclaim.
+				_ = w.taskQueue.Ack(context.Background(), w.consumerID, task)
+				if err := w.taskQueue.RequeueAITask(context.Background(), task, "worker shut down before dispatch"); err != nil {
+					log.Printf("Failed to requeue task for run %s during shutdown: %v", task.RunID, err)
+				}
+				close(taskChan)
+				return
+			}
+		}
+	}
+}
+
+// expireTask marks task's run as failed with reason "task expired" and
+// records it in the dead-letter queue flagged as expired rather than
+// errored, instead of handing it to a worker goroutine for analysis. Called
+// by Run's dispatch loop for a task whose CreatedAt exceeds maxTaskAge.
+func (w *Worker) expireTask(task *queue.AIAnalysisTask) {
+	ctx := context.Background()
+	message := "task expired"
+
+	_ = w.logRunRepo.UpdateAIReport(ctx, task.RunID, message, models.AIStatusFailed)
+	aiStatus := string(models.AIStatusFailed)
+	_ = ws.PublishRunUpdate(ctx, w.redisClient, task.RunID, nil, nil, &aiStatus, &message)
+
+	_ = w.taskQueue.Ack(ctx, w.consumerID, task)
+	_ = w.taskQueue.MarkTaskTerminal(ctx, task.RunID, task.UserID, queue.TaskStateFailed)
+
+	if err := w.taskQueue.ExpireAITask(ctx, task, message); err != nil {
+		log.Printf("Failed to record expired task for run %s in dead-letter queue: %v", task.RunID, err)
+	}
+}
+
+// worker processes tasks from the task channel until it's closed by the
+// dispatcher. A task still in flight when ctx (workCtx) is cancelled by the
+// shutdown grace period is left for DrainUnfinished to requeue; the worker
+// only handles the notify/failure paths for tasks that ran to completion.
+func (w *Worker) worker(ctx context.Context, workerID int, taskChan <-chan *queue.AIAnalysisTask) {
+	slog.Info("worker started", "worker_id", workerID)
+	for task := range taskChan {
+		slog.InfoContext(ctx, "worker processing task", "worker_id", workerID, "run_id", task.RunID, "user_id", task.UserID)
+
+		// Rejoin the trace of whatever triggered this task, so the queue
+		// wait and processing spans below show up under the originating
+		// request's trace instead of starting a disconnected one.
+		traceCtx := task.ExtractContext(ctx)
+		_, waitSpan := tracer.Start(traceCtx, "ai.queue.wait",
+			trace.WithTimestamp(task.CreatedAt),
+			trace.WithAttributes(attribute.String("ai.run_id", task.RunID.String())))
+		waitSpan.End(trace.WithTimestamp(time.Now()))
+
+		processCtx, processSpan := tracer.Start(traceCtx, "ai.worker.process_run", trace.WithAttributes(
+			attribute.String("ai.run_id", task.RunID.String()),
+			attribute.Int("ai.worker_id", workerID),
+		))
+
+		taskCtx, cancelTask := context.WithCancel(processCtx)
+		w.trackInFlight(task)
+		w.registerCancelFunc(task.RunID, cancelTask)
+
+		var err error
+		if task.HasOverride() {
+			err = w.processOverrideByID(taskCtx, task.RunID, task.UserID, task.PromptOverride, task.MaxTokensOverride)
+		} else {
+			err = w.processRunByID(taskCtx, task.RunID, task.UserID, task.Force, task.Mode, task.Partial)
+		}
+		w.unregisterCancelFunc(task.RunID)
+		cancelTask()
+		stillOwned := w.untrackInFlight(task.RunID)
+
+		if !stillOwned {
+			// DrainUnfinished already claimed this task and reset/requeued
+			// it; don't also report a (likely context-cancelled) failure, and
+			// leave the duplicate-task guard held since the task isn't
+			// actually finished yet.
+			slog.InfoContext(ctx, "worker abandoned run to shutdown drain", "worker_id", workerID, "run_id", task.RunID)
+			processSpan.End()
+			continue
+		}
+
+		// The task is genuinely finished (or was never guarded, for a
+		// forced/custom-prompt task); release the duplicate-task guard so a
+		// new standard analysis can be queued for this run, and ack the task
+		// so Reclaim doesn't mistake it for one a crashed consumer dropped.
+		_ = w.taskQueue.ClearInFlight(ctx, task.RunID)
+		_ = w.taskQueue.Ack(ctx, w.consumerID, task)
+
+		if err == nil {
+			slog.InfoContext(ctx, "worker completed run successfully", "worker_id", workerID, "run_id", task.RunID)
+			_ = w.taskQueue.MarkTaskTerminal(ctx, task.RunID, task.UserID, queue.TaskStateCompleted)
+			_ = w.taskQueue.NotifyAIResult(ctx, task.RunID, "completed", "Analysis completed successfully")
+		} else if taskCtx.Err() == context.Canceled {
+			slog.InfoContext(ctx, "worker run cancelled by user request", "worker_id", workerID, "run_id", task.RunID)
+			_ = w.taskQueue.MarkTaskTerminal(ctx, task.RunID, task.UserID, queue.TaskStateCancelled)
+			_ = w.taskQueue.NotifyAIResult(ctx, task.RunID, "cancelled", "Analysis cancelled by user")
+			processSpan.SetStatus(codes.Error, "cancelled")
+		} else {
+			slog.ErrorContext(ctx, "worker failed to process run", "worker_id", workerID, "run_id", task.RunID, "error", err)
+			_ = w.taskQueue.MarkTaskTerminal(ctx, task.RunID, task.UserID, queue.TaskStateFailed)
+			_ = w.taskQueue.NotifyAIResult(ctx, task.RunID, "failed", err.Error())
+			processSpan.RecordError(err)
+			processSpan.SetStatus(codes.Error, err.Error())
+		}
+		processSpan.End()
+	}
+	slog.Info("worker stopped", "worker_id", workerID)
+}
+
+// trackInFlight registers task as currently being processed, so a graceful
+// shutdown can wait for it or requeue it if it doesn't finish in time.
+func (w *Worker) trackInFlight(task *queue.AIAnalysisTask) {
+	w.mu.Lock()
+	w.inFlight[task.RunID] = task
+	w.mu.Unlock()
+}
+
+// untrackInFlight removes runID from the in-flight set, reporting whether it
+// was still there. false means DrainUnfinished already removed it (and took
+// over requeuing/resetting it) while this task's analysis was still running.
+func (w *Worker) untrackInFlight(runID uuid.UUID) bool {
+	w.mu.Lock()
+	_, owned := w.inFlight[runID]
+	delete(w.inFlight, runID)
+	empty := len(w.inFlight) == 0
+	w.mu.Unlock()
+	if empty {
+		w.inFlightEmpty.Broadcast()
+	}
+	return owned
+}
+
+// registerCancelFunc records cancel as the way to abort runID's in-progress
+// analysis, so a cancel signal for that run can call it.
+func (w *Worker) registerCancelFunc(runID uuid.UUID, cancel context.CancelFunc) {
+	w.mu.Lock()
+	w.cancelFuncs[runID] = cancel
+	w.mu.Unlock()
+}
+
+// unregisterCancelFunc removes runID's cancel function once its analysis has
+// finished, so a late-arriving cancel signal for it becomes a no-op.
+func (w *Worker) unregisterCancelFunc(runID uuid.UUID) {
+	w.mu.Lock()
+	delete(w.cancelFuncs, runID)
+	w.mu.Unlock()
+}
+
+// CancelIfRunning cancels the in-flight analysis for runID if this worker
+// process currently has one running. It's a no-op if the analysis already
+// finished (completed or failed wins the race) or is running on a different
+// worker replica.
+func (w *Worker) CancelIfRunning(runID uuid.UUID) {
+	w.mu.Lock()
+	cancel, ok := w.cancelFuncs[runID]
+	w.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// WaitInFlight blocks until every currently in-flight task finishes or
+// timeout elapses, returning true if everything finished cleanly.
+func (w *Worker) WaitInFlight(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		w.mu.Lock()
+		for len(w.inFlight) > 0 {
+			w.inFlightEmpty.Wait()
+		}
+		w.mu.Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// DrainUnfinished snapshots any tasks still in flight after the shutdown
+// grace period expired, resets their runs to pending, and re-enqueues the
+// tasks (preserving Force/Mode/Attempts) so they're retried instead of lost.
+// It returns the number of tasks requeued. Call after cancelling the
+// context passed as workCtx to Run, so the abandoned analyses actually stop.
+func (w *Worker) DrainUnfinished(ctx context.Context) int {
+	w.mu.Lock()
+	tasks := make([]*queue.AIAnalysisTask, 0, len(w.inFlight))
+	for _, task := range w.inFlight {
+		tasks = append(tasks, task)
+	}
+	w.inFlight = make(map[uuid.UUID]*queue.AIAnalysisTask)
+	w.mu.Unlock()
+	w.inFlightEmpty.Broadcast()
+
+	for _, task := range tasks {
+		if err := w.logRunRepo.UpdateAIStatus(ctx, task.RunID, models.AIStatusPending); err != nil {
+			log.Printf("Failed to reset run %s to pending during shutdown drain: %v", task.RunID, err)
+		}
+		// Ack first so Reclaim doesn't also pick up this same entry once its
+		// visibility timeout elapses and requeue it a second time.
+		_ = w.taskQueue.Ack(ctx, w.consumerID, task)
+		if err := w.taskQueue.RequeueAITask(ctx, task, "worker shut down before analysis finished"); err != nil {
+			log.Printf("Failed to requeue run %s during shutdown drain: %v", task.RunID, err)
+		}
+	}
+
+	return len(tasks)
+}
+
+// processRunByID fetches a run by ID and processes it
+func (w *Worker) processRunByID(ctx context.Context, runID, userID uuid.UUID, force bool, mode string, partial bool) error {
+	run, err := w.logRunRepo.GetByID(ctx, runID)
+	if err != nil {
+		// Mark as failed in database
+		_ = w.logRunRepo.UpdateAIReport(ctx, runID, fmt.Sprintf("Error: Run not found: %v", err), models.AIStatusFailed)
+		return fmt.Errorf("failed to get run: %w", err)
+	}
+
+	// A duplicate task (e.g. a retry enqueued while a manual trigger was
+	// already processing) can slip past PublishAITask's guard via a
+	// different entry point. Skip it here rather than re-analyzing and
+	// clobbering a result that's already in progress or done, unless the
+	// caller explicitly asked for a forced re-analysis. A partial analysis
+	// is exempt: it's expected to run while the standard pipeline considers
+	// the run still pending.
+	if !force && !partial && (run.AIStatus == models.AIStatusProcessing || run.AIStatus == models.AIStatusCompleted) {
+		log.Printf("Skipping run %s: AI status is already %s", runID, run.AIStatus)
+		return nil
+	}
+
+	if err := w.processRun(ctx, run, userID, force, mode, partial); err != nil {
+		// A user-requested cancellation isn't a failure; record it as such.
+		// The task's own context is likely cancelled at this point, so this
+		// final write uses a fresh context rather than the one that just
+		// aborted the analysis.
+		if ctx.Err() == context.Canceled {
+			message := "Analysis cancelled by user"
+			_ = w.logRunRepo.UpdateAIReport(context.Background(), runID, message, models.AIStatusCancelled)
+
+			aiStatus := string(models.AIStatusCancelled)
+			_ = ws.PublishRunUpdate(context.Background(), w.redisClient, runID, nil, nil, &aiStatus, &message)
+
+			return err
+		}
+
+		// Mark as failed in database
+		errorMsg := fmt.Sprintf("Error: %v", err)
+		_ = w.logRunRepo.UpdateAIReport(context.Background(), runID, errorMsg, models.AIStatusFailed)
+
+		// Publish AI status update event
+		aiStatus := string(models.AIStatusFailed)
+		_ = ws.PublishRunUpdate(context.Background(), w.redisClient, runID, nil, nil, &aiStatus, &errorMsg)
+
+		return err
+	}
+
+	return nil
+}
+
+// processRun analyzes a single run using user-specific settings. If force is
+// false and a completed analysis with an identical content hash already
+// exists for this user, the prior report is reused instead of calling the
+// provider again. If mode is "diff", the run is compared against the last
+// successful run in its group instead of being analyzed in isolation,
+// falling back to standard analysis when no prior successful run exists.
+func (w *Worker) processRun(ctx context.Context, run *models.LogRun, userID uuid.UUID, force bool, mode string, partial bool) error {
+	slog.InfoContext(ctx, "processing run", "run_id", run.ID, "user_id", userID, "status", run.Status, "exit_code", run.ExitCode)
+
+	// Update status to processing
+	if err := w.logRunRepo.UpdateAIStatus(ctx, run.ID, models.AIStatusProcessing); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+
+	// Publish AI status update event
+	aiStatus := string(models.AIStatusProcessing)
+	_ = ws.PublishRunUpdate(ctx, w.redisClient, run.ID, nil, nil, &aiStatus, nil)
+
+	// Get the group to find the project
+	group, err := w.groupRepo.GetByID(ctx, run.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to get group: %w", err)
+	}
+
+	project, err := w.projectRepo.GetByID(ctx, group.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to get project: %w", err)
+	}
+
+	// Fetch effective settings for this user/project
+	effectiveSettings, err := w.settingsRepo.GetEffectiveSettings(ctx, group.ProjectID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get effective settings: %w", err)
+	}
+
+	// Check if AI is enabled
+	if !effectiveSettings.AIEnabled {
+		return fmt.Errorf("AI analysis is disabled for this user/project")
+	}
+
+	// Check API key
+	if effectiveSettings.AIAPIKey == "" {
+		return fmt.Errorf("AI API key not configured")
+	}
+
+	// Reject if the user has already exhausted their monthly AI token quota
+	if effectiveSettings.AIMonthlyTokenQuota != nil {
+		usage, err := w.quotaTracker.CurrentUsage(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to check AI token quota: %w", err)
+		}
+		if usage >= *effectiveSettings.AIMonthlyTokenQuota {
+			return fmt.Errorf("AI monthly token quota exceeded")
+		}
+	}
+
+	slog.InfoContext(ctx, "using AI settings", "run_id", run.ID, "model", effectiveSettings.AIModel, "base_url", effectiveSettings.AIBaseURL,
+		"max_tokens", effectiveSettings.AIMaxTokens, "max_log_lines", effectiveSettings.AIMaxLogLines, "strategy", effectiveSettings.AILogTruncateStrategy)
+
+	// Create analyzer with user-specific settings
+	analyzer := ai.NewAnalyzer(&ai.Config{
+		APIKey:             effectiveSettings.AIAPIKey,
+		BaseURL:            effectiveSettings.AIBaseURL,
+		Model:              effectiveSettings.AIModel,
+		MaxTokens:          effectiveSettings.AIMaxTokens,
+		SystemPrompt:       ai.WithReportLanguage(effectiveSettings.AISystemPrompt, effectiveSettings.AIReportLanguage),
+		UserPromptTemplate: effectiveSettings.AIUserPromptTemplate,
+		RequestTimeout:     time.Duration(effectiveSettings.AIRequestTimeoutSeconds) * time.Second,
+	})
+
+	// Bound the whole analysis call at a bit more than the configured HTTP
+	// timeout, so a task can't hang past it (e.g. on a slow DNS lookup or
+	// TCP handshake that the HTTP client's own timeout wouldn't cover) while
+	// still leaving room for the request itself to complete.
+	requestCtx, cancelRequest := context.WithTimeout(ctx, time.Duration(effectiveSettings.AIRequestTimeoutSeconds)*time.Second+10*time.Second)
+	defer cancelRequest()
+
+	// Fetch logs from Loki
+	logs, err := w.lokiClient.QueryLogs(ctx, run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch logs: %w", err)
+	}
+
+	if len(logs) == 0 {
+		return fmt.Errorf("no logs found for run")
+	}
+
+	// Convert logs to string array
+	logLines := make([]string, len(logs))
+	for i, log := range logs {
+		logLines[i] = log.Line
+	}
+
+	// Redact likely secrets before anything leaves our infrastructure.
+	metadata := models.JSONMap{}
+	if effectiveSettings.AIRedactSecrets {
+		redactor, err := redact.New(effectiveSettings.AIRedactExtraPatterns)
+		if err != nil {
+			return fmt.Errorf("invalid redaction pattern: %w", err)
+		}
+		var counts map[string]int
+		logLines, counts = redactor.Redact(logLines)
+		if len(counts) > 0 {
+			metadata["redactions"] = counts
+			total := 0
+			for _, n := range counts {
+				total += n
+			}
+			metadata["redactions_total"] = total
+			slog.InfoContext(ctx, "redacted likely secrets before analysis", "run_id", run.ID, "count", total)
+		}
+	}
+
+	// Get exit code
+	exitCode := int32(0)
+	if run.ExitCode.Valid {
+		exitCode = run.ExitCode.Int32
+	}
+
+	// Analyze logs with user-specific settings
+	promptVars := ai.PromptContext{
+		"project":   project.Name,
+		"group":     group.Name,
+		"run_id":    run.ID.String(),
+		"status":    string(run.Status),
+		"exit_code": fmt.Sprintf("%d", exitCode),
+		"command":   run.CommandLine.String,
+		"name":      run.Name.String,
+	}
+
+	var result *ai.AnalysisResult
+	var contentHash string
+	diffMode := false
+
+	if mode == "diff" {
+		baseline, err := w.logRunRepo.FindLastSuccessfulRun(ctx, run.GroupID, run.ID)
+		if err != nil {
+			return fmt.Errorf("failed to find last successful run: %w", err)
+		}
+		if baseline == nil {
+			metadata["diff_mode_note"] = "no prior successful run found; using standard analysis"
+		} else {
+			baseLogs, err := w.lokiClient.QueryLogs(ctx, baseline.ID)
+			if err != nil {
+				return fmt.Errorf("failed to fetch baseline logs: %w", err)
+			}
+			baseLines := make([]string, len(baseLogs))
+			for i, l := range baseLogs {
+				baseLines[i] = l.Line
+			}
+			if effectiveSettings.AIRedactSecrets {
+				redactor, err := redact.New(effectiveSettings.AIRedactExtraPatterns)
+				if err != nil {
+					return fmt.Errorf("invalid redaction pattern: %w", err)
+				}
+				baseLines, _ = redactor.Redact(baseLines)
+			}
+
+			diffContent := ai.DiffLogs(baseLines, logLines)
+			result, err = analyzer.AnalyzeDiff(requestCtx, diffContent, strings.Join(logLines, "\n"), exitCode, string(run.Status), promptVars)
+			if err != nil {
+				return fmt.Errorf("AI analysis failed: %w", err)
+			}
+			metadata["diff_baseline_run_id"] = baseline.ID.String()
+			diffMode = true
+		}
+	}
+
+	if !diffMode {
+		// A partial analysis is a provisional snapshot of a run that hasn't
+		// finished yet, so its fingerprint can never be treated as a stable,
+		// reusable completed analysis for some other run.
+		if !partial {
+			contentHash = analyzer.Fingerprint(logLines, exitCode, string(run.Status), string(effectiveSettings.AILogTruncateStrategy), promptVars)
+
+			if !force {
+				if reused, err := w.reuseCompletedAnalysis(ctx, run.ID, userID, contentHash); err != nil {
+					slog.WarnContext(ctx, "failed to check for a reusable analysis", "run_id", run.ID, "error", err)
+				} else if reused {
+					return nil
+				}
+			}
+		}
+
+		result, err = analyzer.AnalyzeLogs(requestCtx, logLines, exitCode, string(run.Status),
+			effectiveSettings.AIMaxLogLines, string(effectiveSettings.AILogTruncateStrategy), partial, promptVars)
+		if err != nil {
+			return fmt.Errorf("AI analysis failed: %w", err)
+		}
+	}
+
+	if partial {
+		metadata["partial"] = true
+	}
+
+	slog.InfoContext(ctx, "analysis complete", "run_id", run.ID, "tokens_used", result.TokensUsed)
+
+	if err := w.quotaTracker.RecordUsage(ctx, userID, result.TokensUsed); err != nil {
+		slog.WarnContext(ctx, "failed to record AI token usage", "user_id", userID, "run_id", run.ID, "error", err)
+	}
+
+	if len(result.PromptWarnings) > 0 {
+		metadata["prompt_warnings"] = result.PromptWarnings
+		log.Printf("Prompt template referenced unknown variable(s) for run %s: %v", run.ID, result.PromptWarnings)
+	}
+
+	metadata["prompt_tokens"] = result.PromptTokens
+	metadata["completion_tokens"] = result.CompletionTokens
+	if cost, ok := w.estimateCost(ctx, effectiveSettings.AIModel, result.PromptTokens, result.CompletionTokens); ok {
+		metadata["cost_usd"] = cost
+	}
+
+	category := models.NormalizeErrorCategory(result.ErrorCategory)
+
+	status := models.AIStatusCompleted
+	if partial {
+		status = models.AIStatusPartial
+	}
+
+	// Save report. Diff-mode and partial results skip the content-hash reuse
+	// cache entirely: a diff report only makes sense against the baseline it
+	// was computed from, and a partial report isn't a stable fingerprint of
+	// the run's final logs.
+	if diffMode || partial {
+		if err := w.logRunRepo.UpdateAIReportWithMetadata(ctx, run.ID, result.Report, status, metadata, category); err != nil {
+			return fmt.Errorf("failed to save report: %w", err)
+		}
+	} else if err := w.logRunRepo.UpdateAIReportWithContentHash(ctx, run.ID, result.Report, status, metadata, category, contentHash); err != nil {
+		return fmt.Errorf("failed to save report: %w", err)
+	}
+
+	// Publish AI status update event with report
+	aiStatus = string(models.AIStatusCompleted)
+	_ = ws.PublishRunUpdate(ctx, w.redisClient, run.ID, nil, nil, &aiStatus, &result.Report)
+
+	return nil
+}
+
+// processOverrideByID looks up runID and runs a one-off custom-prompt
+// analysis for it. Unlike processRunByID, failure here doesn't touch the
+// run's standard AIStatus/AIReport: it's a side analysis, not the run's
+// primary one.
+func (w *Worker) processOverrideByID(ctx context.Context, runID, userID uuid.UUID, promptOverride string, maxTokensOverride int) error {
+	run, err := w.logRunRepo.GetByID(ctx, runID)
+	if err != nil {
+		return fmt.Errorf("failed to get run: %w", err)
+	}
+	return w.processOverrideAnalysis(ctx, run, userID, promptOverride, maxTokensOverride)
+}
+
+// processOverrideAnalysis runs a single analysis with a custom prompt
+// and/or max-tokens budget in place of the user's configured defaults,
+// storing the result as an additional analysis version instead of updating
+// the run's standard AIReport. It skips the reused-analysis cache and diff
+// mode entirely: a custom prompt has no standard fingerprint to match
+// against, and comparing it to a baseline isn't part of what was asked for.
+func (w *Worker) processOverrideAnalysis(ctx context.Context, run *models.LogRun, userID uuid.UUID, promptOverride string, maxTokensOverride int) error {
+	log.Printf("Processing custom-prompt analysis for run %s (user %s)", run.ID, userID)
+
+	group, err := w.groupRepo.GetByID(ctx, run.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to get group: %w", err)
+	}
+
+	project, err := w.projectRepo.GetByID(ctx, group.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to get project: %w", err)
+	}
+
+	effectiveSettings, err := w.settingsRepo.GetEffectiveSettings(ctx, group.ProjectID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get effective settings: %w", err)
+	}
+
+	if !effectiveSettings.AIEnabled {
+		return fmt.Errorf("AI analysis is disabled for this user/project")
+	}
+	if effectiveSettings.AIAPIKey == "" {
+		return fmt.Errorf("AI API key not configured")
+	}
+
+	if effectiveSettings.AIMonthlyTokenQuota != nil {
+		usage, err := w.quotaTracker.CurrentUsage(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to check AI token quota: %w", err)
+		}
+		if usage >= *effectiveSettings.AIMonthlyTokenQuota {
+			return fmt.Errorf("AI monthly token quota exceeded")
+		}
+	}
+
+	maxTokens := effectiveSettings.AIMaxTokens
+	if maxTokensOverride > 0 {
+		maxTokens = maxTokensOverride
+	}
+
+	systemPrompt := ai.WithReportLanguage(effectiveSettings.AISystemPrompt, effectiveSettings.AIReportLanguage)
+	if promptOverride != "" {
+		systemPrompt = promptOverride
+	}
+
+	analyzer := ai.NewAnalyzer(&ai.Config{
+		APIKey:             effectiveSettings.AIAPIKey,
+		BaseURL:            effectiveSettings.AIBaseURL,
+		Model:              effectiveSettings.AIModel,
+		MaxTokens:          maxTokens,
+		SystemPrompt:       systemPrompt,
+		UserPromptTemplate: effectiveSettings.AIUserPromptTemplate,
+		RequestTimeout:     time.Duration(effectiveSettings.AIRequestTimeoutSeconds) * time.Second,
+	})
+
+	requestCtx, cancelRequest := context.WithTimeout(ctx, time.Duration(effectiveSettings.AIRequestTimeoutSeconds)*time.Second+10*time.Second)
+	defer cancelRequest()
+
+	logs, err := w.lokiClient.QueryLogs(ctx, run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch logs: %w", err)
+	}
+	if len(logs) == 0 {
+		return fmt.Errorf("no logs found for run")
+	}
+
+	logLines := make([]string, len(logs))
+	for i, l := range logs {
+		logLines[i] = l.Line
+	}
+
+	metadata := models.JSONMap{
+		"prompt_override_used": promptOverride != "",
+	}
+	if maxTokensOverride > 0 {
+		metadata["max_tokens_override"] = maxTokensOverride
+	}
+
+	if effectiveSettings.AIRedactSecrets {
+		redactor, err := redact.New(effectiveSettings.AIRedactExtraPatterns)
+		if err != nil {
+			return fmt.Errorf("invalid redaction pattern: %w", err)
+		}
+		var counts map[string]int
+		logLines, counts = redactor.Redact(logLines)
+		if len(counts) > 0 {
+			metadata["redactions"] = counts
+			total := 0
+			for _, n := range counts {
+				total += n
+			}
+			metadata["redactions_total"] = total
+			slog.InfoContext(ctx, "redacted likely secrets before analysis", "run_id", run.ID, "count", total)
+		}
+	}
+
+	exitCode := int32(0)
+	if run.ExitCode.Valid {
+		exitCode = run.ExitCode.Int32
+	}
+
+	promptVars := ai.PromptContext{
+		"project":   project.Name,
+		"group":     group.Name,
+		"run_id":    run.ID.String(),
+		"status":    string(run.Status),
+		"exit_code": fmt.Sprintf("%d", exitCode),
+		"command":   run.CommandLine.String,
+		"name":      run.Name.String,
+	}
+
+	result, err := analyzer.AnalyzeLogs(requestCtx, logLines, exitCode, string(run.Status),
+		effectiveSettings.AIMaxLogLines, string(effectiveSettings.AILogTruncateStrategy), false, promptVars)
+	if err != nil {
+		return fmt.Errorf("AI analysis failed: %w", err)
+	}
+
+	slog.InfoContext(ctx, "custom-prompt analysis complete", "run_id", run.ID, "tokens_used", result.TokensUsed)
+
+	if err := w.quotaTracker.RecordUsage(ctx, userID, result.TokensUsed); err != nil {
+		slog.WarnContext(ctx, "failed to record AI token usage", "user_id", userID, "run_id", run.ID, "error", err)
+	}
+
+	if len(result.PromptWarnings) > 0 {
+		metadata["prompt_warnings"] = result.PromptWarnings
+		log.Printf("Prompt template referenced unknown variable(s) for run %s: %v", run.ID, result.PromptWarnings)
+	}
+
+	metadata["prompt_tokens"] = result.PromptTokens
+	metadata["completion_tokens"] = result.CompletionTokens
+	if cost, ok := w.estimateCost(ctx, effectiveSettings.AIModel, result.PromptTokens, result.CompletionTokens); ok {
+		metadata["cost_usd"] = cost
+	}
+
+	category := models.NormalizeErrorCategory(result.ErrorCategory)
+
+	if _, err := w.analysisVersionRepo.CreateVersion(ctx, run.ID, userID, result.Report, promptOverride, maxTokensOverride, category, metadata, result.TokensUsed); err != nil {
+		return fmt.Errorf("failed to save analysis version: %w", err)
+	}
+
+	return nil
+}
+
+// reuseCompletedAnalysis looks for a prior completed analysis owned by
+// userID with the same content hash and, if found, copies its report onto
+// runID and marks it completed, recording zero tokens. It returns true if a
+// prior analysis was reused.
+func (w *Worker) reuseCompletedAnalysis(ctx context.Context, runID, userID uuid.UUID, contentHash string) (bool, error) {
+	prior, err := w.logRunRepo.FindCompletedByContentHash(ctx, userID, runID, contentHash)
+	if err != nil {
+		return false, err
+	}
+	if prior == nil {
+		return false, nil
+	}
+
+	report := fmt.Sprintf("%s\n\n_(reused analysis from run %s: identical logs, prompt, and model)_", prior.AIReport.String, prior.ID)
+	metadata := models.JSONMap{
+		"reused_from_run_id": prior.ID.String(),
+		"prompt_tokens":      0,
+		"completion_tokens":  0,
+	}
+
+	category := models.NormalizeErrorCategory(prior.ErrorCategory.String)
+	if err := w.logRunRepo.UpdateAIReportWithContentHash(ctx, runID, report, models.AIStatusCompleted, metadata, category, contentHash); err != nil {
+		return false, fmt.Errorf("failed to save reused report: %w", err)
+	}
+
+	log.Printf("Reused analysis from run %s for run %s (identical content hash)", prior.ID, runID)
+
+	aiStatus := string(models.AIStatusCompleted)
+	_ = ws.PublishRunUpdate(ctx, w.redisClient, runID, nil, nil, &aiStatus, &report)
+
+	return true, nil
+}
+
+// estimateCost looks up admin-configured pricing overrides and returns the
+// estimated USD cost of an analysis. ok is false if pricing for the model
+// isn't known (built-in or override), or the overrides can't be loaded.
+func (w *Worker) estimateCost(ctx context.Context, model string, promptTokens, completionTokens int) (float64, bool) {
+	instanceSettings, err := w.instanceSettingsRepo.Get(ctx)
+	if err != nil {
+		log.Printf("Failed to load instance settings for cost estimation: %v", err)
+		return 0, false
+	}
+
+	var overrides map[string]ai.ModelPricing
+	if len(instanceSettings.AIPricingOverride) > 0 {
+		raw, err := json.Marshal(instanceSettings.AIPricingOverride)
+		if err != nil || json.Unmarshal(raw, &overrides) != nil {
+			log.Printf("Failed to decode AI pricing overrides: %v", err)
+		}
+	}
+
+	return ai.NewPricingTable(overrides).EstimateCost(model, promptTokens, completionTokens)
+}
+
+// RunAutoRetrySweep periodically re-enqueues recent instance-wide AI
+// analysis failures onto the low-priority queue, skipping runs already
+// queued. It's a coarse retry: it doesn't yet classify failures as
+// transient vs. permanent, so it's opt-in via AI_AUTO_RETRY_FAILED.
+func RunAutoRetrySweep(ctx context.Context, logRunRepo *repository.LogRunRepository, groupRepo *repository.LogGroupRepository, projectRepo *repository.ProjectRepository, taskQueue *queue.Queue, interval, window time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			since := time.Now().Add(-window)
+			runs, err := logRunRepo.ListRecentFailedForAutoRetry(ctx, since, batchSize)
+			if err != nil {
+				log.Printf("Auto-retry sweep: failed to list failed runs: %v", err)
+				continue
+			}
+
+			queued := 0
+			for _, run := range runs {
+				alreadyQueued, err := taskQueue.IsQueued(ctx, run.ID)
+				if err != nil || alreadyQueued {
+					continue
+				}
+				group, err := groupRepo.GetByID(ctx, run.GroupID)
+				if err != nil {
+					continue
+				}
+				project, err := projectRepo.GetByID(ctx, group.ProjectID)
+				if err != nil {
+					continue
+				}
+				if err := logRunRepo.UpdateAIStatus(ctx, run.ID, models.AIStatusPending); err != nil {
+					continue
+				}
+				if err := taskQueue.PublishAIRetryTask(ctx, run.ID, project.UserID); err != nil {
+					continue
+				}
+				queued++
+			}
+			log.Printf("Auto-retry sweep: found %d failed run(s), queued %d for retry", len(runs), queued)
+		}
+	}
+}
+
+// retentionCleanupPayload is the queue.TaskEnvelope.Payload for a
+// TaskTypeRetentionCleanup job: delete every log run older than
+// RetentionDays.
+type retentionCleanupPayload struct {
+	RetentionDays int `json:"retention_days"`
+}
+
+// RunRetentionCleanupScheduler periodically publishes a
+// TaskTypeRetentionCleanup task, letting dispatch.Dispatcher's registered
+// handler do the actual deletion. It runs once at startup and then on a
+// ticker for the lifetime of the process.
+func RunRetentionCleanupScheduler(ctx context.Context, taskQueue queue.TaskQueue, interval time.Duration, retentionDays int) {
+	publish := func() {
+		if err := taskQueue.PublishTask(ctx, queue.TaskTypeRetentionCleanup, uuid.New(), retentionCleanupPayload{RetentionDays: retentionDays}); err != nil {
+			log.Printf("Retention cleanup scheduler: failed to publish task: %v", err)
+		}
+	}
+
+	publish()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			publish()
+		}
+	}
+}
+
+// HandleRetentionCleanup is the dispatch.Handler for TaskTypeRetentionCleanup:
+// it deletes every log run older than the payload's RetentionDays.
+func HandleRetentionCleanup(logRunRepo *repository.LogRunRepository) dispatch.Handler {
+	return func(ctx context.Context, envelope queue.TaskEnvelope) error {
+		var payload retentionCleanupPayload
+		if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+			return fmt.Errorf("failed to unmarshal retention cleanup payload: %w", err)
+		}
+		if payload.RetentionDays <= 0 {
+			return fmt.Errorf("invalid retention_days %d", payload.RetentionDays)
+		}
+
+		cutoff := time.Now().AddDate(0, 0, -payload.RetentionDays)
+		deleted, err := logRunRepo.DeleteOlderThan(ctx, cutoff)
+		if err != nil {
+			return fmt.Errorf("failed to delete log runs older than %s: %w", cutoff, err)
+		}
+
+		log.Printf("Retention cleanup: deleted %d log run(s) older than %d day(s)", deleted, payload.RetentionDays)
+		return nil
+	}
+}
+
+// reclaimLockKey and reclaimLockTTL guard against multiple worker replicas
+// running Reclaim at the same time: whichever replica's ticker fires first
+// grabs the lock, the rest see acquired=false and skip that round.
+const (
+	reclaimLockKey = "swiftlog:ai:reclaim:lock"
+	reclaimLockTTL = 2 * time.Minute
+)
+
+// RunQueueReclaimSweep periodically calls taskQueue.Reclaim to put back on
+// the queue any task that's been sitting in a consumer's processing list
+// past the visibility timeout, almost always because that consumer crashed
+// mid-task. It runs once immediately on startup and then every interval
+// until ctx is cancelled.
+func RunQueueReclaimSweep(ctx context.Context, redisClient *redis.Client, taskQueue *queue.Queue, interval time.Duration) {
+	reclaimStaleTasks(ctx, redisClient, taskQueue)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reclaimStaleTasks(ctx, redisClient, taskQueue)
+		}
+	}
+}
+
+// reclaimStaleTasks acquires the reclaim lock and, if successful, runs a
+// single Reclaim pass. It's a no-op if another replica currently holds the
+// lock.
+func reclaimStaleTasks(ctx context.Context, redisClient *redis.Client, taskQueue *queue.Queue) {
+	acquired, err := redisClient.SetNX(ctx, reclaimLockKey, "1", reclaimLockTTL).Result()
+	if err != nil {
+		log.Printf("Queue reclaim: failed to acquire lock: %v", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	n, err := taskQueue.Reclaim(ctx)
+	if err != nil {
+		log.Printf("Queue reclaim: failed to reclaim stale tasks: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Queue reclaim: requeued %d stale task(s)", n)
+	}
+}
+
+// reconcileLockKey and reconcileLockTTL guard against multiple worker
+// replicas reconciling the same stuck runs at once: whichever replica's
+// ticker fires first grabs the lock, the rest see acquired=false and skip
+// that round. The lock isn't explicitly released; it just expires.
+const (
+	reconcileLockKey = "swiftlog:ai:reconcile:lock"
+	reconcileLockTTL = 2 * time.Minute
+)
+
+// RunStuckProcessingReconciler finds AI analyses left stuck in "processing"
+// by a crashed worker (OOM, node failure, ...) and either re-enqueues them
+// (up to maxAttempts) or marks them permanently failed. It runs once
+// immediately on startup and then every interval until ctx is cancelled.
+func RunStuckProcessingReconciler(ctx context.Context, redisClient *redis.Client, logRunRepo *repository.LogRunRepository, groupRepo *repository.LogGroupRepository, projectRepo *repository.ProjectRepository, taskQueue *queue.Queue, threshold, interval time.Duration, maxAttempts, batchSize int) {
+	reconcileStuckProcessing(ctx, redisClient, logRunRepo, groupRepo, projectRepo, taskQueue, threshold, maxAttempts, batchSize)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reconcileStuckProcessing(ctx, redisClient, logRunRepo, groupRepo, projectRepo, taskQueue, threshold, maxAttempts, batchSize)
+		}
+	}
+}
+
+// reconcileStuckProcessing acquires the reconcile lock and, if successful,
+// re-enqueues or fails runs whose processing has been stuck for longer than
+// threshold. It's a no-op if another replica currently holds the lock.
+func reconcileStuckProcessing(ctx context.Context, redisClient *redis.Client, logRunRepo *repository.LogRunRepository, groupRepo *repository.LogGroupRepository, projectRepo *repository.ProjectRepository, taskQueue *queue.Queue, threshold time.Duration, maxAttempts, batchSize int) {
+	acquired, err := redisClient.SetNX(ctx, reconcileLockKey, "1", reconcileLockTTL).Result()
+	if err != nil {
+		log.Printf("Reconciler: failed to acquire lock: %v", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	runs, err := logRunRepo.ListStuckProcessing(ctx, threshold, batchSize)
+	if err != nil {
+		log.Printf("Reconciler: failed to list stuck runs: %v", err)
+		return
+	}
+	if len(runs) == 0 {
+		return
+	}
+
+	requeued, failed := 0, 0
+	for _, run := range runs {
+		group, err := groupRepo.GetByID(ctx, run.GroupID)
+		if err != nil {
+			log.Printf("Reconciler: failed to get group for run %s: %v", run.ID, err)
+			continue
+		}
+		project, err := projectRepo.GetByID(ctx, group.ProjectID)
+		if err != nil {
+			log.Printf("Reconciler: failed to get project for run %s: %v", run.ID, err)
+			continue
+		}
+
+		attempts, err := logRunRepo.IncrementReconcileAttempts(ctx, run.ID)
+		if err != nil {
+			log.Printf("Reconciler: failed to increment reconcile attempts for run %s: %v", run.ID, err)
+			continue
+		}
+
+		if attempts > maxAttempts {
+			if err := logRunRepo.UpdateAIReport(ctx, run.ID, "worker crashed during analysis", models.AIStatusFailed); err != nil {
+				log.Printf("Reconciler: failed to mark run %s failed: %v", run.ID, err)
+				continue
+			}
+			failed++
+			continue
+		}
+
+		if err := logRunRepo.UpdateAIStatus(ctx, run.ID, models.AIStatusPending); err != nil {
+			log.Printf("Reconciler: failed to reset run %s to pending: %v", run.ID, err)
+			continue
+		}
+		if err := taskQueue.PublishAIRetryTask(ctx, run.ID, project.UserID); err != nil {
+			log.Printf("Reconciler: failed to requeue run %s: %v", run.ID, err)
+			continue
+		}
+		requeued++
+	}
+
+	log.Printf("Reconciler: found %d stuck run(s), requeued %d, marked %d permanently failed", len(runs), requeued, failed)
+}
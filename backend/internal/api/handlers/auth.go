@@ -1,9 +1,21 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/aliancn/swiftlog/backend/internal/api/middleware"
 	"github.com/aliancn/swiftlog/backend/internal/auth"
+	"github.com/aliancn/swiftlog/backend/internal/auth/oidc"
+	"github.com/aliancn/swiftlog/backend/internal/auth/passwordpolicy"
+	"github.com/aliancn/swiftlog/backend/internal/auth/throttle"
+	"github.com/aliancn/swiftlog/backend/internal/email"
 	"github.com/aliancn/swiftlog/backend/internal/repository"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -11,27 +23,100 @@ import (
 
 // AuthHandler handles authentication-related API requests
 type AuthHandler struct {
-	userRepo     *repository.UserRepository
-	settingsRepo *repository.SettingsRepository
-	tokenService *auth.TokenService
+	userRepo             *repository.UserRepository
+	settingsRepo         *repository.SettingsRepository
+	instanceSettingsRepo *repository.InstanceSettingsRepository
+	tokenService         *auth.TokenService
+	ticketService        *auth.TicketService
+	sessionService       *auth.SessionService
+	passwordResetService *auth.PasswordResetService
+	loginThrottle        *throttle.Limiter
+	emailSender          *email.Sender
+
+	oidcProvider *oidc.Provider
+	oidcConfig   oidc.Config
+	oidcOnly     bool
+	frontendURL  string
 }
 
-// NewAuthHandler creates a new auth handler
+// NewAuthHandler creates a new auth handler. oidcProvider is nil when OIDC
+// isn't configured (oidcConfig.Enabled is false), in which case
+// OIDCLogin/OIDCCallback respond 404 rather than attempting the flow.
+// emailSender is always non-nil, but its Config.Configured() is false when
+// no SMTP relay is set up, in which case password reset falls back to the
+// admin-generated link endpoint.
 func NewAuthHandler(
 	userRepo *repository.UserRepository,
 	settingsRepo *repository.SettingsRepository,
+	instanceSettingsRepo *repository.InstanceSettingsRepository,
 	tokenService *auth.TokenService,
+	ticketService *auth.TicketService,
+	sessionService *auth.SessionService,
+	passwordResetService *auth.PasswordResetService,
+	loginThrottle *throttle.Limiter,
+	emailSender *email.Sender,
+	oidcProvider *oidc.Provider,
+	oidcConfig oidc.Config,
+	oidcOnly bool,
+	frontendURL string,
 ) *AuthHandler {
 	return &AuthHandler{
-		userRepo:     userRepo,
-		settingsRepo: settingsRepo,
-		tokenService: tokenService,
+		userRepo:             userRepo,
+		settingsRepo:         settingsRepo,
+		instanceSettingsRepo: instanceSettingsRepo,
+		tokenService:         tokenService,
+		ticketService:        ticketService,
+		sessionService:       sessionService,
+		passwordResetService: passwordResetService,
+		loginThrottle:        loginThrottle,
+		emailSender:          emailSender,
+		oidcProvider:         oidcProvider,
+		oidcConfig:           oidcConfig,
+		oidcOnly:             oidcOnly,
+		frontendURL:          frontendURL,
 	}
 }
 
+// enforcePasswordPolicy evaluates password against the instance's
+// configured policy (or the built-in defaults) for username, and writes a
+// 400 response with the structured {score, warnings, suggestions}
+// feedback if it fails. Returns whether the password was accepted.
+func (h *AuthHandler) enforcePasswordPolicy(c *gin.Context, password, username string) bool {
+	policy, err := h.instanceSettingsRepo.EffectivePasswordPolicy(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load password policy"})
+		return false
+	}
+
+	result := passwordpolicy.Evaluate(policy, password, username)
+	if !result.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":       "Password does not meet policy requirements",
+			"score":       result.Score,
+			"warnings":    result.Warnings,
+			"suggestions": result.Suggestions,
+		})
+		return false
+	}
+	return true
+}
+
+// loginIdentities returns the two throttle identities a login attempt is
+// tracked under: the claimed username and the source IP, so a distributed
+// attack spread across many usernames from one address (or one username
+// hit from many addresses) is caught either way.
+func loginIdentities(c *gin.Context, username string) (userIdentity, ipIdentity string) {
+	return "user:" + strings.ToLower(username), "ip:" + c.ClientIP()
+}
+
 // Login authenticates a user and returns a session token
 // POST /api/v1/auth/login
 func (h *AuthHandler) Login(c *gin.Context) {
+	if h.oidcOnly {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Password login is disabled, sign in via SSO instead"})
+		return
+	}
+
 	var req struct {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
@@ -42,40 +127,102 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	userIdentity, ipIdentity := loginIdentities(c, req.Username)
+
+	// Check the throttle before touching the database or bcrypt, so an
+	// attacker hammering an already-locked-out identity doesn't get to
+	// spend the server's CPU on it.
+	for _, identity := range []string{userIdentity, ipIdentity} {
+		blocked, retryAfter, err := h.loginThrottle.Check(c.Request.Context(), identity)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login throttle"})
+			return
+		}
+		if blocked {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many login attempts, try again later"})
+			return
+		}
+	}
+
+	recordFailure := func() {
+		if err := h.loginThrottle.RecordFailure(c.Request.Context(), userIdentity); err != nil {
+			log.Printf("Failed to record login throttle failure for user identity: %v", err)
+		}
+		if err := h.loginThrottle.RecordFailure(c.Request.Context(), ipIdentity); err != nil {
+			log.Printf("Failed to record login throttle failure for ip identity: %v", err)
+		}
+	}
+
 	// Get user from database
 	user, err := h.userRepo.GetByUsername(c.Request.Context(), req.Username)
 	if err != nil {
+		recordFailure()
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 		return
 	}
 
 	// Verify password
 	if err := auth.VerifyPassword(req.Password, user.PasswordHash); err != nil {
+		recordFailure()
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 		return
 	}
 
-	// Create API token for session
-	rawToken, apiToken, err := h.tokenService.CreateToken(c.Request.Context(), user.ID, "web-session")
+	if err := h.loginThrottle.Reset(c.Request.Context(), userIdentity); err != nil {
+		log.Printf("Failed to reset login throttle for user identity: %v", err)
+	}
+	if err := h.loginThrottle.Reset(c.Request.Context(), ipIdentity); err != nil {
+		log.Printf("Failed to reset login throttle for ip identity: %v", err)
+	}
+
+	sessionToken, refreshToken, expiresAt, err := h.sessionService.CreateSession(c.Request.Context(), user.ID, c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
 		return
 	}
 
+	issueCSRFToken(c)
+
 	c.JSON(http.StatusOK, gin.H{
-		"token": rawToken,
+		"token":         sessionToken,
+		"refresh_token": refreshToken,
+		"expires_at":    expiresAt,
 		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"is_admin": user.IsAdmin,
+			"id":                   user.ID,
+			"username":             user.Username,
+			"is_admin":             user.IsAdmin,
+			"must_change_password": user.MustChangePassword,
 		},
-		"token_info": apiToken,
 	})
 }
 
+// issueCSRFToken generates a fresh double-submit CSRF token and hands it
+// to the client two ways: a non-HttpOnly cookie (middleware.RequireCSRFToken
+// reads it back from there) and a response header (so a client that
+// doesn't want the cookie's value, e.g. one still on the bearer-token
+// flow, can pick it up too). It's called on every successful login/refresh
+// regardless of whether the caller ends up cookie-authenticated, since
+// there's no cost to always issuing one and it keeps this from silently
+// depending on cookie auth existing yet.
+func issueCSRFToken(c *gin.Context) {
+	token, err := auth.GenerateCSRFToken()
+	if err != nil {
+		log.Printf("Failed to generate CSRF token: %v", err)
+		return
+	}
+	c.SetCookie(middleware.CSRFTokenCookieName, token, 0, "/", "", true, false)
+	c.Header(middleware.CSRFHeaderName, token)
+}
+
 // Register creates a new user account
 // POST /api/v1/auth/register
 func (h *AuthHandler) Register(c *gin.Context) {
+	if h.oidcOnly {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Password registration is disabled, sign in via SSO instead"})
+		return
+	}
+
 	var req struct {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
@@ -86,6 +233,10 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	if !h.enforcePasswordPolicy(c, req.Password, req.Username) {
+		return
+	}
+
 	// Hash password
 	passwordHash, err := auth.HashPassword(req.Password)
 	if err != nil {
@@ -108,24 +259,345 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		c.Request.Context().Value("logger")
 	}
 
-	// Create API token for session
-	rawToken, apiToken, err := h.tokenService.CreateToken(c.Request.Context(), user.ID, "web-session")
+	sessionToken, refreshToken, expiresAt, err := h.sessionService.CreateSession(c.Request.Context(), user.ID, c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"token": rawToken,
+		"token":         sessionToken,
+		"refresh_token": refreshToken,
+		"expires_at":    expiresAt,
 		"user": gin.H{
 			"id":       user.ID,
 			"username": user.Username,
 			"is_admin": user.IsAdmin,
 		},
-		"token_info": apiToken,
 	})
 }
 
+// RefreshSession exchanges a refresh token for a new session/refresh token
+// pair. The refresh token is rotated: the one supplied here is invalidated
+// whether or not the caller ever uses the new pair.
+// POST /api/v1/auth/refresh
+func (h *AuthHandler) RefreshSession(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	sessionToken, refreshToken, expiresAt, err := h.sessionService.RefreshSession(c.Request.Context(), req.RefreshToken, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	issueCSRFToken(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         sessionToken,
+		"refresh_token": refreshToken,
+		"expires_at":    expiresAt,
+	})
+}
+
+// Logout revokes the session token used to authenticate this request. It
+// doesn't invalidate the paired refresh token; a client that wants a hard
+// logout should discard that itself.
+// POST /api/v1/auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	authHeader := c.GetHeader("Authorization")
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing authorization header"})
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(c.Request.Context(), token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// genericPasswordResetResponse is returned for every password reset
+// request regardless of whether the username exists, so the endpoint
+// can't be used to enumerate accounts.
+const genericPasswordResetResponse = "If that account exists, a password reset link has been sent, or is available from an administrator."
+
+// RequestPasswordReset issues a single-use password reset token for a
+// username, delivered by email when SMTP is configured and the account has
+// one on file. It always responds with the same message and rate-limits
+// every attempt (not just failures, since there's no failure to
+// distinguish here), so it can't be used to enumerate accounts or hammer
+// the mail relay.
+// POST /api/v1/auth/password-reset/request
+func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userIdentity, ipIdentity := "pwreset-user:"+strings.ToLower(req.Username), "pwreset-ip:"+c.ClientIP()
+	for _, identity := range []string{userIdentity, ipIdentity} {
+		blocked, retryAfter, err := h.loginThrottle.Check(c.Request.Context(), identity)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check request throttle"})
+			return
+		}
+		if blocked {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, try again later"})
+			return
+		}
+	}
+	if err := h.loginThrottle.RecordFailure(c.Request.Context(), userIdentity); err != nil {
+		log.Printf("Failed to record password reset throttle for user identity: %v", err)
+	}
+	if err := h.loginThrottle.RecordFailure(c.Request.Context(), ipIdentity); err != nil {
+		log.Printf("Failed to record password reset throttle for ip identity: %v", err)
+	}
+
+	user, err := h.userRepo.GetByUsername(c.Request.Context(), req.Username)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": genericPasswordResetResponse})
+		return
+	}
+
+	token, err := h.passwordResetService.IssueResetToken(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Printf("Failed to issue password reset token for %s: %v", user.Username, err)
+		c.JSON(http.StatusOK, gin.H{"message": genericPasswordResetResponse})
+		return
+	}
+	resetURL := h.frontendURL + "/reset-password?token=" + url.QueryEscape(token)
+
+	if h.emailSender != nil && user.Email.Valid {
+		if err := h.emailSender.SendPasswordReset(user.Email.String, resetURL); err != nil {
+			log.Printf("Failed to email password reset link to user %s: %v", user.Username, err)
+		}
+	} else {
+		log.Printf("Password reset requested for user %s but no email is on file or SMTP isn't configured; an admin must hand them the link out-of-band", user.Username)
+	}
+
+	log.Printf("audit: password reset requested for user %s (id %s) from %s", user.Username, user.ID, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"message": genericPasswordResetResponse})
+}
+
+// ConfirmPasswordReset consumes a password reset token, sets the new
+// password, and revokes every session and API token the user held, so a
+// reset also recovers from a compromised long-lived credential rather than
+// just the password.
+// POST /api/v1/auth/password-reset/confirm
+func (h *AuthHandler) ConfirmPasswordReset(c *gin.Context) {
+	var req struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	ipIdentity := "pwreset-confirm-ip:" + c.ClientIP()
+	blocked, retryAfter, err := h.loginThrottle.Check(c.Request.Context(), ipIdentity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check request throttle"})
+		return
+	}
+	if blocked {
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, try again later"})
+		return
+	}
+
+	userID, err := h.passwordResetService.ConsumeResetToken(c.Request.Context(), req.Token)
+	if err != nil {
+		if err := h.loginThrottle.RecordFailure(c.Request.Context(), ipIdentity); err != nil {
+			log.Printf("Failed to record password reset confirm throttle: %v", err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	// The token is already consumed at this point, so a policy failure here
+	// costs the user a fresh reset request - an accepted tradeoff, since
+	// validating the policy before consuming would let the same token be
+	// probed against the policy repeatedly.
+	user, err := h.userRepo.GetByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+	if !h.enforcePasswordPolicy(c, req.NewPassword, user.Username) {
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userRepo.UpdatePassword(c.Request.Context(), userID, passwordHash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	if err := h.sessionService.RevokeAllSessions(c.Request.Context(), userID); err != nil {
+		log.Printf("Failed to revoke sessions after password reset for user %s: %v", userID, err)
+	}
+	if err := h.tokenService.RevokeAllTokensForUser(c.Request.Context(), userID); err != nil {
+		log.Printf("Failed to revoke API tokens after password reset for user %s: %v", userID, err)
+	}
+
+	log.Printf("audit: password reset completed for user id %s from %s", userID, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
+}
+
+// AdminGeneratePasswordResetLink issues a password reset link for a user
+// without sending an email, so an admin can hand it to the user
+// out-of-band on instances without SMTP configured.
+// POST /api/v1/admin/users/:id/password-reset-link
+func (h *AuthHandler) AdminGeneratePasswordResetLink(c *gin.Context) {
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	admin, err := h.userRepo.GetByID(c.Request.Context(), adminID)
+	if err != nil || !admin.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	target, err := h.userRepo.GetByID(c.Request.Context(), targetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	token, err := h.passwordResetService.IssueResetToken(c.Request.Context(), target.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue reset token"})
+		return
+	}
+
+	log.Printf("audit: admin %s generated a password reset link for user %s (id %s)", admin.Username, target.Username, target.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"reset_url": h.frontendURL + "/reset-password?token=" + url.QueryEscape(token),
+	})
+}
+
+// OIDCLogin redirects the browser to the identity provider's authorization
+// endpoint to start an SSO login.
+// GET /api/v1/auth/oidc/login
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	if h.oidcProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC SSO is not configured"})
+		return
+	}
+
+	redirectURL, err := h.oidcProvider.AuthorizationURL(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to build oidc authorization url: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start SSO login"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// OIDCCallback completes an SSO login: it exchanges the authorization code
+// for a verified ID token, enforces the configured email domain and admin
+// group policy, provisions the user on first login, and hands the browser
+// off to the frontend with a SwiftLog session in the URL fragment (a
+// fragment, rather than a query string, so the tokens aren't sent to the
+// frontend's own server or captured in server access logs).
+// GET /api/v1/auth/oidc/callback
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	if h.oidcProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC SSO is not configured"})
+		return
+	}
+
+	if errParam := c.Query("error"); errParam != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SSO login failed: " + errParam})
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing code or state"})
+		return
+	}
+
+	claims, err := h.oidcProvider.Callback(c.Request.Context(), code, state)
+	if err != nil {
+		log.Printf("oidc callback failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "SSO login failed"})
+		return
+	}
+
+	if !claims.EmailVerified {
+		// A self-asserted or IdP-unverified email can't be trusted to
+		// identify an account - accepting it here would let anyone who
+		// controls (or can lie about owning) that address at the IdP sign
+		// in as whoever already owns it in SwiftLog.
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your identity provider did not confirm your email address is verified"})
+		return
+	}
+
+	if !h.oidcConfig.EmailDomainAllowed(claims.Email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your email domain is not permitted to sign in"})
+		return
+	}
+
+	isAdmin := h.oidcConfig.IsAdminGroup(claims.GroupMembership(h.oidcConfig.AdminGroupClaim))
+
+	user, created, err := h.userRepo.GetOrCreateOIDCUser(c.Request.Context(), claims.Subject, claims.Email, isAdmin)
+	if errors.Is(err, repository.ErrOIDCEmailAlreadyLinked) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists and isn't linked to SSO; ask an administrator to link it"})
+		return
+	}
+	if err != nil {
+		log.Printf("failed to provision oidc user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete SSO login"})
+		return
+	}
+
+	if created {
+		if _, err := h.settingsRepo.CreateDefaultUserSettings(c.Request.Context(), user.ID); err != nil {
+			log.Printf("Warning: Failed to create default settings for oidc user %s: %v", user.Username, err)
+		}
+	}
+
+	sessionToken, refreshToken, expiresAt, err := h.sessionService.CreateSession(c.Request.Context(), user.ID, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	fragment := url.Values{
+		"token":         {sessionToken},
+		"refresh_token": {refreshToken},
+		"expires_at":    {expiresAt.Format(time.RFC3339)},
+	}
+	c.Redirect(http.StatusFound, h.frontendURL+"/oidc/callback#"+fragment.Encode())
+}
+
 // GetCurrentUser returns the currently authenticated user
 // GET /api/v1/auth/me
 func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
@@ -138,25 +610,213 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":       user.ID,
-		"username": user.Username,
-		"is_admin": user.IsAdmin,
-		"created_at": user.CreatedAt,
+		"id":                   user.ID,
+		"username":             user.Username,
+		"is_admin":             user.IsAdmin,
+		"must_change_password": user.MustChangePassword,
+		"created_at":           user.CreatedAt,
 	})
 }
 
+// ChangePassword updates the current user's password after verifying
+// their current one
+// POST /api/v1/auth/change-password
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+	user, err := h.userRepo.GetByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := auth.VerifyPassword(req.CurrentPassword, user.PasswordHash); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	if !h.enforcePasswordPolicy(c, req.NewPassword, user.Username) {
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userRepo.UpdatePassword(c.Request.Context(), userID, passwordHash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
 // ListTokens returns all API tokens for the current user
 // GET /api/v1/auth/tokens
+// credentialInfo is one entry in the caller's credential inventory: either
+// a web session or a long-lived API token, normalized to a common shape so
+// the frontend can show them in one list.
+type credentialInfo struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"` // "session" or "token"
+	Name       string     `json:"name,omitempty"`
+	Hint       string     `json:"hint,omitempty"`
+	IP         string     `json:"ip,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Current    bool       `json:"current"`
+}
+
 func (h *AuthHandler) ListTokens(c *gin.Context) {
 	userID := c.MustGet("user_id").(uuid.UUID)
+	currentType, _ := c.Get("credential_type")
+	currentHash, _ := c.Get("credential_hash")
+	currentTokenID, _ := c.Get("credential_id")
+
+	var credentials []credentialInfo
+
+	sessions, err := h.sessionService.ListSessions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+	for _, s := range sessions {
+		lastUsedAt := s.LastUsedAt
+		credentials = append(credentials, credentialInfo{
+			ID:         s.Hash,
+			Type:       "session",
+			IP:         s.IP,
+			LastUsedAt: &lastUsedAt,
+			CreatedAt:  s.CreatedAt,
+			Current:    currentType == "session" && currentHash == s.Hash,
+		})
+	}
 
 	tokens, err := h.tokenService.ListTokensByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tokens"})
 		return
 	}
+	for _, t := range tokens {
+		var lastUsedAt *time.Time
+		if t.LastUsedAt.Valid {
+			lastUsedAt = &t.LastUsedAt.Time
+		}
+		var ip string
+		if t.LastUsedIP.Valid {
+			ip = t.LastUsedIP.String
+		}
+		credentials = append(credentials, credentialInfo{
+			ID:         t.ID.String(),
+			Type:       "token",
+			Name:       t.Name,
+			Hint:       t.TokenHint,
+			IP:         ip,
+			LastUsedAt: lastUsedAt,
+			CreatedAt:  t.CreatedAt,
+			Current:    currentType == "token" && currentTokenID == t.ID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": credentials})
+}
+
+// RevokeAll invalidates the caller's web sessions and API tokens in one
+// call, for a "log out everywhere else" button after a lost device. With
+// ?except_current=true the request's own credential (and its refresh
+// token) survives; without it, every credential including the current one
+// is revoked. The token side of this is a single DB transaction; the
+// session side lives in Redis and can't join it, so a crash between the
+// two leaves at most a stale credential behind rather than a fully
+// inconsistent state.
+// POST /api/v1/auth/tokens/revoke-all
+func (h *AuthHandler) RevokeAll(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	exceptCurrent := c.Query("except_current") == "true"
+	credentialType, _ := c.Get("credential_type")
+
+	if exceptCurrent && credentialType == "session" {
+		hashVal, _ := c.Get("credential_hash")
+		hash, _ := hashVal.(string)
+		if _, err := h.sessionService.RevokeSessionsExcept(c.Request.Context(), userID, hash); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+			return
+		}
+		if err := h.tokenService.RevokeAllTokensForUser(c.Request.Context(), userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+			return
+		}
+	} else if exceptCurrent && credentialType == "token" {
+		tokenIDVal, _ := c.Get("credential_id")
+		tokenID, _ := tokenIDVal.(uuid.UUID)
+		if _, err := h.tokenService.RevokeTokensExcept(c.Request.Context(), userID, tokenID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+			return
+		}
+		if err := h.sessionService.RevokeAllSessions(c.Request.Context(), userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+			return
+		}
+	} else {
+		if err := h.sessionService.RevokeAllSessions(c.Request.Context(), userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+			return
+		}
+		if err := h.tokenService.RevokeAllTokensForUser(c.Request.Context(), userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+			return
+		}
+	}
+
+	log.Printf("audit: user id %s revoked credentials (except_current=%t) from %s", userID, exceptCurrent, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"message": "Credentials revoked"})
+}
+
+// AdminRevokeUserCredentials invalidates every web session and API token
+// belonging to another user (admin only), for use after that user reports
+// a compromised device or leaves the organization.
+// POST /api/v1/admin/users/:id/revoke-all
+func (h *AuthHandler) AdminRevokeUserCredentials(c *gin.Context) {
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	admin, err := h.userRepo.GetByID(c.Request.Context(), adminID)
+	if err != nil || !admin.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	target, err := h.userRepo.GetByID(c.Request.Context(), targetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := h.sessionService.RevokeAllSessions(c.Request.Context(), target.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	if err := h.tokenService.RevokeAllTokensForUser(c.Request.Context(), target.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+	log.Printf("audit: admin %s revoked all credentials for user %s (id %s)", admin.Username, target.Username, target.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Credentials revoked"})
 }
 
 // CreateToken creates a new API token for the current user
@@ -180,7 +840,7 @@ func (h *AuthHandler) CreateToken(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"token": rawToken,
+		"token":      rawToken,
 		"token_info": apiToken,
 	})
 }
@@ -215,6 +875,59 @@ func (h *AuthHandler) DeleteToken(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Token deleted successfully"})
 }
 
+// RotateToken issues a replacement API token and schedules the old one to
+// stop validating after a grace overlap, rather than revoking it
+// immediately, so callers mid-flight with the old token have time to pick
+// up the new one.
+// POST /api/v1/auth/tokens/:id/rotate
+func (h *AuthHandler) RotateToken(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	tokenID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	token, err := h.tokenService.GetTokenByID(c.Request.Context(), tokenID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+	if token.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	rawToken, newToken, err := h.tokenService.RotateToken(c.Request.Context(), tokenID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      rawToken,
+		"token_info": newToken,
+	})
+}
+
+// IssueWSTicket issues a short-lived, single-use ticket the caller can pass
+// as ?ticket= on a WebSocket URL instead of its long-lived API token.
+// POST /api/v1/ws-ticket
+func (h *AuthHandler) IssueWSTicket(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	ticket, err := h.ticketService.IssueTicket(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"ticket":     ticket,
+		"expires_in": 30,
+	})
+}
+
 // ListUsers returns all users (admin only)
 // GET /api/v1/auth/users
 func (h *AuthHandler) ListUsers(c *gin.Context) {
@@ -234,3 +947,48 @@ func (h *AuthHandler) ListUsers(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"users": users})
 }
+
+// SetUserQuota sets or clears another user's monthly AI token quota (admin only)
+// PUT /api/v1/auth/users/:id/quota
+func (h *AuthHandler) SetUserQuota(c *gin.Context) {
+	// Check if user is admin
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	admin, err := h.userRepo.GetByID(c.Request.Context(), adminID)
+	if err != nil || !admin.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		MonthlyTokenQuota *int64 `json:"ai_monthly_token_quota"` // null = unlimited
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.settingsRepo.GetUserSettings(c.Request.Context(), targetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User settings not found"})
+		return
+	}
+
+	if req.MonthlyTokenQuota != nil {
+		settings.AIMonthlyTokenQuota = sql.NullInt64{Int64: *req.MonthlyTokenQuota, Valid: true}
+	} else {
+		settings.AIMonthlyTokenQuota = sql.NullInt64{Valid: false}
+	}
+
+	if err := h.settingsRepo.UpdateUserSettings(c.Request.Context(), settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user quota"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ai_monthly_token_quota": settings.AIMonthlyTokenQuota})
+}
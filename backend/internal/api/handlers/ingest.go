@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aliancn/swiftlog/backend/internal/ingestor"
+	"github.com/aliancn/swiftlog/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxIngestLinesPerRequest caps how many lines a single POST .../lines
+// call may submit, so one oversized batch can't tie up the request or
+// outgrow what a single Loki push is meant to carry.
+const maxIngestLinesPerRequest = 5000
+
+// maxIngestBodyBytes caps the decompressed request body accepted by the
+// lines endpoint.
+const maxIngestBodyBytes = 10 << 20 // 10MB
+
+// IngestHandler exposes the same run-creation/log-append/run-completion
+// flow as the gRPC LogStreamer service over plain HTTP/JSON, for callers
+// that can't speak gRPC — shell scripts, serverless functions, restricted
+// egress environments. It shares internal/ingestor.Core with the gRPC
+// service so the two transports can't diverge.
+type IngestHandler struct {
+	core       *ingestor.Core
+	logRunRepo *repository.LogRunRepository
+}
+
+// NewIngestHandler creates a new ingest handler.
+func NewIngestHandler(core *ingestor.Core, logRunRepo *repository.LogRunRepository) *IngestHandler {
+	return &IngestHandler{core: core, logRunRepo: logRunRepo}
+}
+
+type createIngestRunRequest struct {
+	Project string            `json:"project"`
+	Group   string            `json:"group"`
+	Tags    map[string]string `json:"tags"`
+	Name    string            `json:"name"`
+}
+
+// CreateRun starts a new log run, the HTTP equivalent of the gRPC stream's
+// initial metadata message.
+// POST /api/v1/ingest/runs
+func (h *IngestHandler) CreateRun(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req createIngestRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := ingestor.ValidateTags(req.Tags); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ingestor.ValidateName(req.Name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	run, err := h.core.StartRun(c.Request.Context(), userID, req.Project, req.Group, req.Tags, "", "", "", req.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create run"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"run_id": run.ID.String()})
+}
+
+type ingestLineRequest struct {
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Content   string    `json:"content"`
+}
+
+// AppendLines accepts a batch of log lines for an in-progress run, either
+// as a JSON array or as newline-delimited JSON (one object per line) —
+// whichever the body looks like. The body may be gzip-compressed
+// (Content-Encoding: gzip), since piping a large log through curl benefits
+// from not sending it raw.
+// POST /api/v1/ingest/runs/:id/lines
+func (h *IngestHandler) AppendLines(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	run, err := h.logRunRepo.GetByID(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+
+	body, err := ingestRequestBody(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	requests, err := decodeIngestLines(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(requests) > maxIngestLinesPerRequest {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("too many lines in one request (max %d)", maxIngestLinesPerRequest)})
+		return
+	}
+
+	lines := make([]ingestor.LogLine, len(requests))
+	for i, r := range requests {
+		ts := r.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		lines[i] = ingestor.LogLine{Timestamp: ts, Level: r.Level, Content: r.Content}
+	}
+
+	if err := h.core.AppendLines(c.Request.Context(), run, userID, run.ID.String(), lines); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ingest lines"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ingested": len(lines)})
+}
+
+type completeIngestRunRequest struct {
+	ExitCode int32 `json:"exit_code"`
+}
+
+// CompleteRun marks a run finished with the given exit code, the HTTP
+// equivalent of the gRPC stream's completion message.
+// POST /api/v1/ingest/runs/:id/complete
+func (h *IngestHandler) CompleteRun(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	run, err := h.logRunRepo.GetByID(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+
+	var req completeIngestRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.core.CompleteRun(c.Request.Context(), run, userID, req.ExitCode); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete run"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ingestRequestBody returns the request body, transparently gunzipping it
+// when Content-Encoding says it's gzipped, and caps it at
+// maxIngestBodyBytes either way.
+func ingestRequestBody(c *gin.Context) ([]byte, error) {
+	var reader io.Reader = c.Request.Body
+	if strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	limited := io.LimitReader(reader, maxIngestBodyBytes+1)
+	body, err := io.ReadAll(limited)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(body) > maxIngestBodyBytes {
+		return nil, fmt.Errorf("request body exceeds %d byte limit", maxIngestBodyBytes)
+	}
+	return body, nil
+}
+
+// decodeIngestLines parses body as either a JSON array of line objects or
+// NDJSON (one line object per line), detected by whichever the first
+// non-whitespace byte looks like.
+func decodeIngestLines(body []byte) ([]ingestLineRequest, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	if trimmed[0] == '[' {
+		var lines []ingestLineRequest
+		if err := json.Unmarshal(trimmed, &lines); err != nil {
+			return nil, fmt.Errorf("invalid JSON array body: %w", err)
+		}
+		return lines, nil
+	}
+
+	var lines []ingestLineRequest
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxIngestBodyBytes)
+	for scanner.Scan() {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var line ingestLineRequest
+		if err := json.Unmarshal(raw, &line); err != nil {
+			return nil, fmt.Errorf("invalid NDJSON line: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read NDJSON body: %w", err)
+	}
+	return lines, nil
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aliancn/swiftlog/backend/internal/ai"
+	"github.com/aliancn/swiftlog/backend/internal/auth/throttle"
+	"github.com/aliancn/swiftlog/backend/internal/models"
+	"github.com/aliancn/swiftlog/backend/internal/queue"
+	"github.com/aliancn/swiftlog/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InstanceSettingsHandler handles admin-only instance-wide settings.
+type InstanceSettingsHandler struct {
+	instanceSettingsRepo *repository.InstanceSettingsRepository
+	userRepo             *repository.UserRepository
+	taskQueue            *queue.Queue
+	loginThrottle        *throttle.Limiter
+}
+
+// NewInstanceSettingsHandler creates a new instance settings handler.
+func NewInstanceSettingsHandler(
+	instanceSettingsRepo *repository.InstanceSettingsRepository,
+	userRepo *repository.UserRepository,
+	taskQueue *queue.Queue,
+	loginThrottle *throttle.Limiter,
+) *InstanceSettingsHandler {
+	return &InstanceSettingsHandler{
+		instanceSettingsRepo: instanceSettingsRepo,
+		userRepo:             userRepo,
+		taskQueue:            taskQueue,
+		loginThrottle:        loginThrottle,
+	}
+}
+
+func (h *InstanceSettingsHandler) requireAdmin(c *gin.Context) bool {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	user, err := h.userRepo.GetByID(c.Request.Context(), userID)
+	if err != nil || !user.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return false
+	}
+	return true
+}
+
+// GetAIPricing returns the admin-configured AI pricing overrides
+// GET /api/v1/admin/ai-pricing
+func (h *InstanceSettingsHandler) GetAIPricing(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	settings, err := h.instanceSettingsRepo.Get(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch instance settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ai_pricing_overrides": settings.AIPricingOverride})
+}
+
+// UpdateAIPricing replaces the admin-configured AI pricing overrides
+// PUT /api/v1/admin/ai-pricing
+func (h *InstanceSettingsHandler) UpdateAIPricing(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	var overrides map[string]ai.ModelPricing
+	if err := c.ShouldBindJSON(&overrides); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pricing := make(models.JSONMap, len(overrides))
+	for model, p := range overrides {
+		pricing[model] = p
+	}
+
+	if err := h.instanceSettingsRepo.UpdatePricingOverrides(c.Request.Context(), pricing); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update instance settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ai_pricing_overrides": pricing})
+}
+
+// GetPasswordPolicy returns the effective password policy (the admin's
+// configured policy, or the built-in defaults if unconfigured)
+// GET /api/v1/admin/password-policy
+func (h *InstanceSettingsHandler) GetPasswordPolicy(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	policy, err := h.instanceSettingsRepo.EffectivePasswordPolicy(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch instance settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"password_policy": policy})
+}
+
+// UpdatePasswordPolicy replaces the admin-configured password policy
+// PUT /api/v1/admin/password-policy
+func (h *InstanceSettingsHandler) UpdatePasswordPolicy(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	var policy models.PasswordPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.instanceSettingsRepo.UpdatePasswordPolicy(c.Request.Context(), policy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update instance settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"password_policy": policy})
+}
+
+// GetQueueStats returns today's per-user AI analysis queue activity: how
+// many tasks each of the busiest users has enqueued and completed, their
+// average wait time, and an aggregate across every user, so an admin can
+// tell whose tasks are filling the queue when it backs up.
+// GET /api/v1/admin/queue-stats
+func (h *InstanceSettingsHandler) GetQueueStats(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	topN := 10
+	if topNStr := c.Query("top"); topNStr != "" {
+		if n, err := strconv.Atoi(topNStr); err == nil && n > 0 {
+			topN = n
+		}
+	}
+
+	stats, err := h.taskQueue.GetQueueStats(c.Request.Context(), topN)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// dlqPageLimit is the default and maximum number of DLQ entries returned per
+// page, matching the pagination convention used elsewhere in the API.
+const dlqPageLimit = 50
+
+// ListDLQ returns a page of AI analysis tasks that exhausted their requeue
+// attempts, so an admin can see which runs and users are affected and why.
+// GET /api/v1/admin/dlq
+func (h *InstanceSettingsHandler) ListDLQ(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	offset := 0
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+	limit := dlqPageLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= dlqPageLimit {
+			limit = l
+		}
+	}
+
+	page, err := h.taskQueue.ListDLQ(c.Request.Context(), offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dead-letter queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// RequeueDLQEntry moves a run's dead-letter entry back onto the AI analysis
+// queue with its attempt counter reset, giving it a fresh set of retries.
+// POST /api/v1/admin/dlq/:run_id/requeue
+func (h *InstanceSettingsHandler) RequeueDLQEntry(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	if err := h.taskQueue.RequeueFromDLQ(c.Request.Context(), runID); err != nil {
+		if err == queue.ErrNotInDLQ {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Run not found in dead-letter queue"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue run"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "requeued"})
+}
+
+// DiscardDLQEntry permanently removes a run's dead-letter entry without
+// requeuing it.
+// DELETE /api/v1/admin/dlq/:run_id
+func (h *InstanceSettingsHandler) DiscardDLQEntry(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	if _, err := h.taskQueue.RemoveDLQ(c.Request.Context(), runID); err != nil {
+		if err == queue.ErrNotInDLQ {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Run not found in dead-letter queue"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discard dead-letter entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "discarded"})
+}
+
+// GetLoginLockout reports whether a username is currently rate-limited or
+// locked out of POST /auth/login, and if so for how much longer.
+// GET /api/v1/admin/login-lockouts/:username
+func (h *InstanceSettingsHandler) GetLoginLockout(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	identity := "user:" + strings.ToLower(c.Param("username"))
+	blocked, retryAfter, err := h.loginThrottle.Check(c.Request.Context(), identity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check lockout status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"username":         c.Param("username"),
+		"blocked":          blocked,
+		"retry_after_secs": int(retryAfter.Seconds()),
+	})
+}
+
+// ClearLoginLockout clears a username's failed-login count and any active
+// delay or lockout.
+// DELETE /api/v1/admin/login-lockouts/:username
+func (h *InstanceSettingsHandler) ClearLoginLockout(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	identity := "user:" + strings.ToLower(c.Param("username"))
+	if err := h.loginThrottle.Reset(c.Request.Context(), identity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear lockout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "cleared"})
+}
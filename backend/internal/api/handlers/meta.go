@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/aliancn/swiftlog/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// MetaHandler serves unauthenticated, instance-wide metadata that the
+// frontend needs before a user is logged in, such as the password policy
+// to enforce in a signup form's strength meter.
+type MetaHandler struct {
+	instanceSettingsRepo *repository.InstanceSettingsRepository
+}
+
+// NewMetaHandler creates a new meta handler.
+func NewMetaHandler(instanceSettingsRepo *repository.InstanceSettingsRepository) *MetaHandler {
+	return &MetaHandler{instanceSettingsRepo: instanceSettingsRepo}
+}
+
+// GetMeta returns public instance metadata.
+// GET /api/v1/meta
+func (h *MetaHandler) GetMeta(c *gin.Context) {
+	policy, err := h.instanceSettingsRepo.EffectivePasswordPolicy(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch instance settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"password_policy": policy})
+}
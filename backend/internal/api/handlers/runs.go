@@ -1,24 +1,50 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/aliancn/swiftlog/backend/internal/ai"
 	"github.com/aliancn/swiftlog/backend/internal/loki"
 	"github.com/aliancn/swiftlog/backend/internal/models"
 	"github.com/aliancn/swiftlog/backend/internal/queue"
+	"github.com/aliancn/swiftlog/backend/internal/quota"
+	"github.com/aliancn/swiftlog/backend/internal/redact"
 	"github.com/aliancn/swiftlog/backend/internal/repository"
+	ws "github.com/aliancn/swiftlog/backend/internal/websocket"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 )
 
+// maxConversationMessages caps how many exchanges a single follow-up
+// conversation thread may accumulate, so a chatty session can't run up an
+// unbounded token bill against one run.
+const maxConversationMessages = 20
+
+// maxRetryFailedBatch caps how many runs a single retry-failed request can
+// re-enqueue, so a broad filter can't flood the queue in one call.
+const maxRetryFailedBatch = 200
+
+// defaultRetryFailedBatch is used when the caller doesn't specify a limit.
+const defaultRetryFailedBatch = 50
+
 // RunsHandler handles log run-related API requests
 type RunsHandler struct {
-	logRunRepo  *repository.LogRunRepository
-	groupRepo   *repository.LogGroupRepository
-	projectRepo *repository.ProjectRepository
-	lokiClient  *loki.Client
-	taskQueue   *queue.Queue
+	logRunRepo          *repository.LogRunRepository
+	groupRepo           *repository.LogGroupRepository
+	projectRepo         *repository.ProjectRepository
+	settingsRepo        *repository.SettingsRepository
+	conversationRepo    *repository.ConversationRepository
+	analysisVersionRepo *repository.AnalysisVersionRepository
+	lokiClient          *loki.Client
+	taskQueue           *queue.Queue
+	quotaTracker        *quota.TokenUsageTracker
+	redisClient         *redis.Client
 }
 
 // NewRunsHandler creates a new runs handler
@@ -26,15 +52,25 @@ func NewRunsHandler(
 	logRunRepo *repository.LogRunRepository,
 	groupRepo *repository.LogGroupRepository,
 	projectRepo *repository.ProjectRepository,
+	settingsRepo *repository.SettingsRepository,
+	conversationRepo *repository.ConversationRepository,
+	analysisVersionRepo *repository.AnalysisVersionRepository,
 	lokiClient *loki.Client,
 	taskQueue *queue.Queue,
+	quotaTracker *quota.TokenUsageTracker,
+	redisClient *redis.Client,
 ) *RunsHandler {
 	return &RunsHandler{
-		logRunRepo:  logRunRepo,
-		groupRepo:   groupRepo,
-		projectRepo: projectRepo,
-		lokiClient:  lokiClient,
-		taskQueue:   taskQueue,
+		logRunRepo:          logRunRepo,
+		groupRepo:           groupRepo,
+		projectRepo:         projectRepo,
+		settingsRepo:        settingsRepo,
+		conversationRepo:    conversationRepo,
+		analysisVersionRepo: analysisVersionRepo,
+		lokiClient:          lokiClient,
+		taskQueue:           taskQueue,
+		quotaTracker:        quotaTracker,
+		redisClient:         redisClient,
 	}
 }
 
@@ -78,7 +114,34 @@ func (h *RunsHandler) ListRuns(c *gin.Context) {
 		}
 	}
 
-	runs, err := h.logRunRepo.ListByGroupID(c.Request.Context(), groupID, limit, offset)
+	// category filters to a single AI-classified error category (e.g.
+	// "dependency"); unset means no filter.
+	var category *models.ErrorCategory
+	if categoryStr := c.Query("category"); categoryStr != "" {
+		normalized := models.NormalizeErrorCategory(categoryStr)
+		category = &normalized
+	}
+
+	// tag filters to a single "key=value" pair (e.g. "env=staging"); unset
+	// means no filter.
+	var tagKey, tagValue *string
+	if tagStr := c.Query("tag"); tagStr != "" {
+		key, value, found := strings.Cut(tagStr, "=")
+		if !found {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag: must be in key=value form"})
+			return
+		}
+		tagKey, tagValue = &key, &value
+	}
+
+	// name_contains filters to runs whose name contains this substring
+	// (case-insensitive); unset or empty means no filter.
+	var nameContains *string
+	if nameStr := c.Query("name_contains"); nameStr != "" {
+		nameContains = &nameStr
+	}
+
+	runs, err := h.logRunRepo.ListByGroupID(c.Request.Context(), groupID, category, tagKey, tagValue, nameContains, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch runs"})
 		return
@@ -160,13 +223,51 @@ func (h *RunsHandler) GetRunLogs(c *gin.Context) {
 		}
 	}
 
+	// since/until narrow the queried time range (RFC3339); unset falls back
+	// to QueryLogs' default of the last 7 days.
+	end := time.Now()
+	start := end.Add(-7 * 24 * time.Hour)
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since: must be RFC3339"})
+			return
+		}
+		start = since
+	}
+	if untilStr := c.Query("until"); untilStr != "" {
+		until, err := time.Parse(time.RFC3339, untilStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid until: must be RFC3339"})
+			return
+		}
+		end = until
+	}
+
+	// level filters to a single STDOUT/STDERR level; unset means no filter.
+	level := strings.ToUpper(c.Query("level"))
+	if level != "" && level != "STDOUT" && level != "STDERR" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid level: must be stdout or stderr"})
+		return
+	}
+
 	// Query logs from Loki
-	logs, err := h.lokiClient.QueryLogs(c.Request.Context(), runID)
+	logs, err := h.lokiClient.QueryLogsRange(c.Request.Context(), runID, start, end)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch logs"})
 		return
 	}
 
+	if level != "" {
+		filtered := make([]loki.LogEntry, 0, len(logs))
+		for _, entry := range logs {
+			if entryLevel, _ := entry.LevelAndContent(); entryLevel == level {
+				filtered = append(filtered, entry)
+			}
+		}
+		logs = filtered
+	}
+
 	c.JSON(http.StatusOK, logs)
 }
 
@@ -180,6 +281,19 @@ func (h *RunsHandler) TriggerAIAnalysis(c *gin.Context) {
 		return
 	}
 
+	// The request body is optional: a one-off prompt and/or max-tokens
+	// override for this single analysis. When set, the result is stored as
+	// an additional analysis version rather than overwriting the run's
+	// standard AIReport.
+	var overrideReq struct {
+		PromptOverride    string `json:"prompt_override" binding:"max=4000"`
+		MaxTokensOverride int    `json:"max_tokens_override" binding:"omitempty,min=50,max=8000"`
+	}
+	if err := c.ShouldBindJSON(&overrideReq); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Verify ownership
 	run, err := h.logRunRepo.GetByID(c.Request.Context(), runID)
 	if err != nil {
@@ -199,20 +313,514 @@ func (h *RunsHandler) TriggerAIAnalysis(c *gin.Context) {
 		return
 	}
 
+	// Reject the request up front if the user has already exhausted their
+	// monthly AI token quota, rather than queuing work the worker would
+	// just fail anyway.
+	effectiveSettings, err := h.settingsRepo.GetEffectiveSettings(c.Request.Context(), project.ID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load AI settings"})
+		return
+	}
+	if effectiveSettings.AIMonthlyTokenQuota != nil {
+		usage, err := h.quotaTracker.CurrentUsage(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check AI token quota"})
+			return
+		}
+		if usage >= *effectiveSettings.AIMonthlyTokenQuota {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Monthly AI token quota exceeded"})
+			return
+		}
+	}
+
+	// force=true bypasses the reused-analysis cache even if a completed run
+	// with an identical content hash exists.
+	force := c.Query("force") == "true"
+
+	// mode=diff compares against the last successful run in the group
+	// instead of analyzing the current run's logs in isolation.
+	mode := c.Query("mode")
+
+	// partial=true requests an early analysis of a run that's still
+	// running, using whatever logs have been captured so far. It only
+	// makes sense for a run that hasn't finished yet.
+	partial := c.Query("partial") == "true"
+	if partial && run.Status != models.RunStatusRunning {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Partial analysis is only available for runs that are still running"})
+		return
+	}
+
+	// Publish task to Redis queue for AI worker. Check for a duplicate
+	// before touching the run's status, so a second click while one is
+	// already queued or processing doesn't reset it back to pending.
+	if err := h.taskQueue.PublishAITask(c.Request.Context(), runID, userID, force, mode, overrideReq.PromptOverride, overrideReq.MaxTokensOverride, partial); err != nil {
+		if errors.Is(err, queue.ErrAlreadyQueued) {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "AI analysis already queued or in progress for this run",
+				"run_id":  runID.String(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue AI analysis"})
+		return
+	}
+
 	// Update AI status to pending in database
 	if err := h.logRunRepo.UpdateAIStatus(c.Request.Context(), runID, models.AIStatusPending); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update AI status"})
 		return
 	}
 
-	// Publish task to Redis queue for AI worker
-	if err := h.taskQueue.PublishAITask(c.Request.Context(), runID, userID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue AI analysis"})
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "AI analysis queued",
+		"run_id":  runID.String(),
+	})
+}
+
+// ListAnalysisVersions returns the custom-prompt analysis versions recorded
+// for a run, newest first. The standard analysis stays on the run itself
+// (ai_report/ai_status); this only covers one-off overrides triggered via
+// TriggerAIAnalysis's prompt_override/max_tokens_override.
+// GET /api/v1/runs/:id/analyze/versions
+func (h *RunsHandler) ListAnalysisVersions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	// Verify ownership
+	run, err := h.logRunRepo.GetByID(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+
+	group, err := h.groupRepo.GetByID(c.Request.Context(), run.GroupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ownership"})
+		return
+	}
+
+	project, err := h.projectRepo.GetByID(c.Request.Context(), group.ProjectID)
+	if err != nil || project.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	versions, err := h.analysisVersionRepo.ListByRunID(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch analysis versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  versions,
+		"total": len(versions),
+	})
+}
+
+// GetAnalysisStatus returns the current queue/processing status of a run's
+// AI analysis task: whether it's queued, processing, or already finished,
+// along with attempts, which worker (if any) picked it up, and an
+// approximate queue position captured when it was enqueued.
+// GET /api/v1/runs/:id/analysis-status
+func (h *RunsHandler) GetAnalysisStatus(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	// Verify ownership
+	run, err := h.logRunRepo.GetByID(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+
+	group, err := h.groupRepo.GetByID(c.Request.Context(), run.GroupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ownership"})
+		return
+	}
+
+	project, err := h.projectRepo.GetByID(c.Request.Context(), group.ProjectID)
+	if err != nil || project.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	status, err := h.taskQueue.GetTaskStatus(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch task status"})
+		return
+	}
+	if status == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"run_id":      runID.String(),
+			"ai_status":   run.AIStatus,
+			"task_status": nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"run_id":      runID.String(),
+		"ai_status":   run.AIStatus,
+		"task_status": status,
+	})
+}
+
+// CancelAIAnalysis cancels a queued or in-flight AI analysis for a run. A
+// queued task is simply dequeued; an in-flight one is asked to abort via a
+// best-effort cancel signal to whichever worker is running it. If the
+// analysis finishes before the signal is handled, completion wins and the
+// signal is silently dropped.
+// POST /api/v1/runs/:id/analyze/cancel
+func (h *RunsHandler) CancelAIAnalysis(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	// Verify ownership
+	run, err := h.logRunRepo.GetByID(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+
+	group, err := h.groupRepo.GetByID(c.Request.Context(), run.GroupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ownership"})
+		return
+	}
+
+	project, err := h.projectRepo.GetByID(c.Request.Context(), group.ProjectID)
+	if err != nil || project.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	if run.AIStatus != models.AIStatusPending && run.AIStatus != models.AIStatusProcessing {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No active analysis to cancel"})
+		return
+	}
+
+	if run.AIStatus == models.AIStatusPending {
+		removed, err := h.taskQueue.RemoveQueuedTask(c.Request.Context(), runID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel queued analysis"})
+			return
+		}
+		if removed {
+			if err := h.logRunRepo.UpdateAIStatus(c.Request.Context(), runID, models.AIStatusCancelled); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update AI status"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"message": "AI analysis cancelled",
+				"run_id":  runID.String(),
+			})
+			return
+		}
+		// Lost the race: a worker already popped the task before we could
+		// dequeue it. Fall through to the in-flight cancel signal below.
+	}
+
+	if err := h.taskQueue.PublishCancelSignal(c.Request.Context(), runID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send cancel signal"})
 		return
 	}
 
 	c.JSON(http.StatusAccepted, gin.H{
-		"message": "AI analysis queued",
+		"message": "Cancellation requested",
+		"run_id":  runID.String(),
+	})
+}
+
+// AbortRun force-closes a run stuck in "running", e.g. because the CLI that
+// started it crashed before sending a completion message. It's the same
+// terminal transition CompleteRun/AbortRun in internal/ingestor.Core make,
+// just triggered by the run's owner instead of the streaming connection
+// itself.
+// POST /api/v1/runs/:id/abort
+func (h *RunsHandler) AbortRun(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	run, err := h.logRunRepo.GetByID(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+
+	group, err := h.groupRepo.GetByID(c.Request.Context(), run.GroupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ownership"})
+		return
+	}
+
+	project, err := h.projectRepo.GetByID(c.Request.Context(), group.ProjectID)
+	if err != nil || project.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	if run.Status.IsTerminal() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Run already finished", "status": run.Status})
+		return
+	}
+
+	if err := h.logRunRepo.UpdateStatus(c.Request.Context(), runID, models.RunStatusAborted, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to abort run"})
+		return
+	}
+
+	statusStr := string(models.RunStatusAborted)
+	_ = ws.PublishRunUpdate(c.Request.Context(), h.redisClient, runID, &statusStr, nil, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Run aborted",
 		"run_id":  runID.String(),
+		"status":  models.RunStatusAborted,
+	})
+}
+
+// ChatAboutRun answers a follow-up question about a run's existing AI
+// report. It blocks on the provider call rather than queuing through the
+// worker, since a single follow-up question is cheap relative to a full
+// analysis; runs with no completed report are rejected up front.
+// POST /api/v1/runs/:id/chat
+func (h *RunsHandler) ChatAboutRun(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req struct {
+		Question       string     `json:"question" binding:"required"`
+		ConversationID *uuid.UUID `json:"conversation_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	run, err := h.logRunRepo.GetByID(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+	if run.AIStatus != models.AIStatusCompleted || !run.AIReport.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Run has no completed AI report to ask about"})
+		return
+	}
+
+	group, err := h.groupRepo.GetByID(c.Request.Context(), run.GroupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ownership"})
+		return
+	}
+
+	project, err := h.projectRepo.GetByID(c.Request.Context(), group.ProjectID)
+	if err != nil || project.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var conversationID uuid.UUID
+	var history []ai.ConversationTurn
+	if req.ConversationID != nil {
+		conversationID = *req.ConversationID
+		exchanges, err := h.conversationRepo.ListByConversationID(c.Request.Context(), conversationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load conversation"})
+			return
+		}
+		if len(exchanges) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+		if exchanges[0].RunID != runID || exchanges[0].UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		if len(exchanges) >= maxConversationMessages {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Conversation has reached its message limit"})
+			return
+		}
+		for _, exchange := range exchanges {
+			history = append(history, ai.ConversationTurn{Question: exchange.Question, Answer: exchange.Answer})
+		}
+	}
+
+	effectiveSettings, err := h.settingsRepo.GetEffectiveSettings(c.Request.Context(), project.ID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load AI settings"})
+		return
+	}
+	if effectiveSettings.AIAPIKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "AI API key not configured"})
+		return
+	}
+	if effectiveSettings.AIMonthlyTokenQuota != nil {
+		usage, err := h.quotaTracker.CurrentUsage(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check AI token quota"})
+			return
+		}
+		if usage >= *effectiveSettings.AIMonthlyTokenQuota {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Monthly AI token quota exceeded"})
+			return
+		}
+	}
+
+	logs, err := h.lokiClient.QueryLogs(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch logs"})
+		return
+	}
+	logLines := make([]string, len(logs))
+	for i, l := range logs {
+		logLines[i] = l.Line
+	}
+	if effectiveSettings.AIRedactSecrets {
+		redactor, err := redact.New(effectiveSettings.AIRedactExtraPatterns)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid redaction pattern"})
+			return
+		}
+		logLines, _ = redactor.Redact(logLines)
+	}
+
+	exitCode := int32(0)
+	if run.ExitCode.Valid {
+		exitCode = run.ExitCode.Int32
+	}
+	promptVars := ai.PromptContext{
+		"project":   project.Name,
+		"group":     group.Name,
+		"run_id":    run.ID.String(),
+		"status":    string(run.Status),
+		"exit_code": strconv.Itoa(int(exitCode)),
+		"command":   run.CommandLine.String,
+		"name":      run.Name.String,
+	}
+
+	analyzer := ai.NewAnalyzer(&ai.Config{
+		APIKey:         effectiveSettings.AIAPIKey,
+		BaseURL:        effectiveSettings.AIBaseURL,
+		Model:          effectiveSettings.AIModel,
+		MaxTokens:      effectiveSettings.AIMaxTokens,
+		SystemPrompt:   ai.WithReportLanguage(effectiveSettings.AISystemPrompt, effectiveSettings.AIReportLanguage),
+		RequestTimeout: time.Duration(effectiveSettings.AIRequestTimeoutSeconds) * time.Second,
+	})
+
+	result, err := analyzer.AnswerFollowUp(c.Request.Context(), logLines, string(effectiveSettings.AILogTruncateStrategy),
+		run.AIReport.String, history, req.Question, promptVars)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "AI follow-up failed"})
+		return
+	}
+
+	if err := h.quotaTracker.RecordUsage(c.Request.Context(), userID, result.TokensUsed); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record token usage"})
+		return
+	}
+
+	exchange, err := h.conversationRepo.CreateExchange(c.Request.Context(), conversationID, runID, userID, req.Question, result.Report, result.TokensUsed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save conversation exchange"})
+		return
+	}
+
+	c.JSON(http.StatusOK, exchange)
+}
+
+// RetryFailedAnalyses re-enqueues the caller's runs stuck in ai_status=failed,
+// scoped to an optional project, group, and/or minimum creation time.
+// Matching runs already sitting in the queue are skipped, and the batch size
+// is capped so one call can't flood the queue.
+// POST /api/v1/analyses/retry-failed
+func (h *RunsHandler) RetryFailedAnalyses(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var projectID, groupID *uuid.UUID
+	if idStr := c.Query("project_id"); idStr != "" {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project_id"})
+			return
+		}
+		projectID = &id
+	}
+	if idStr := c.Query("group_id"); idStr != "" {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group_id"})
+			return
+		}
+		groupID = &id
+	}
+
+	var since *time.Time
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		t, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since (expected RFC3339)"})
+			return
+		}
+		since = &t
+	}
+
+	limit := defaultRetryFailedBatch
+	if limitStr := c.Query("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = l
+	}
+	if limit > maxRetryFailedBatch {
+		limit = maxRetryFailedBatch
+	}
+
+	runs, err := h.logRunRepo.ListFailedForRetry(c.Request.Context(), userID, projectID, groupID, since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list failed analyses"})
+		return
+	}
+
+	queued := 0
+	for _, run := range runs {
+		alreadyQueued, err := h.taskQueue.IsQueued(c.Request.Context(), run.ID)
+		if err != nil || alreadyQueued {
+			continue
+		}
+		if err := h.logRunRepo.UpdateAIStatus(c.Request.Context(), run.ID, models.AIStatusPending); err != nil {
+			continue
+		}
+		if err := h.taskQueue.PublishAIRetryTask(c.Request.Context(), run.ID, userID); err != nil {
+			continue
+		}
+		queued++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"matched": len(runs),
+		"queued":  queued,
 	})
 }
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"net/http"
 
+	"github.com/aliancn/swiftlog/backend/internal/ai"
 	"github.com/aliancn/swiftlog/backend/internal/models"
 	"github.com/aliancn/swiftlog/backend/internal/repository"
 	"github.com/gin-gonic/gin"
@@ -19,7 +20,7 @@ type SettingsHandler struct {
 // NewSettingsHandler creates a new settings handler
 func NewSettingsHandler(
 	settingsRepo *repository.SettingsRepository,
-	projectRepo  *repository.ProjectRepository,
+	projectRepo *repository.ProjectRepository,
 ) *SettingsHandler {
 	return &SettingsHandler{
 		settingsRepo: settingsRepo,
@@ -39,7 +40,7 @@ func (h *SettingsHandler) GetUserSettings(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"settings":     settings,
+		"settings":    settings,
 		"has_api_key": settings.AIAPIKey.Valid && settings.AIAPIKey.String != "",
 	})
 }
@@ -50,16 +51,21 @@ func (h *SettingsHandler) UpdateUserSettings(c *gin.Context) {
 	userID := c.MustGet("user_id").(uuid.UUID)
 
 	var req struct {
-		AIEnabled             bool                     `json:"ai_enabled"`
-		AIBaseURL             string                   `json:"ai_base_url" binding:"required"`
-		AIAPIKey              *string                  `json:"ai_api_key"` // null = don't update
-		AIModel               string                   `json:"ai_model" binding:"required"`
-		AIMaxTokens           int                      `json:"ai_max_tokens" binding:"required,min=1"`
-		AIAutoAnalyze         bool                     `json:"ai_auto_analyze"`
-		AIMaxLogLines         int                      `json:"ai_max_log_lines" binding:"required,min=1"`
-		AILogTruncateStrategy models.TruncateStrategy `json:"ai_log_truncate_strategy" binding:"required"`
-		AISystemPrompt        string                   `json:"ai_system_prompt" binding:"required"`
-		AIMaxConcurrent       int                      `json:"ai_max_concurrent" binding:"required,min=1,max=10"`
+		AIEnabled               bool                    `json:"ai_enabled"`
+		AIBaseURL               string                  `json:"ai_base_url" binding:"required"`
+		AIAPIKey                *string                 `json:"ai_api_key"` // null = don't update
+		AIModel                 string                  `json:"ai_model" binding:"required"`
+		AIMaxTokens             int                     `json:"ai_max_tokens" binding:"required,min=1"`
+		AIAutoAnalyze           bool                    `json:"ai_auto_analyze"`
+		AIMaxLogLines           int                     `json:"ai_max_log_lines" binding:"required,min=1"`
+		AILogTruncateStrategy   models.TruncateStrategy `json:"ai_log_truncate_strategy" binding:"required"`
+		AISystemPrompt          string                  `json:"ai_system_prompt" binding:"required"`
+		AIUserPromptTemplate    string                  `json:"ai_user_prompt_template"`
+		AIReportLanguage        string                  `json:"ai_report_language"`
+		AIMaxConcurrent         int                     `json:"ai_max_concurrent" binding:"required,min=1,max=10"`
+		AIRequestTimeoutSeconds int                     `json:"ai_request_timeout_seconds" binding:"required,min=5,max=300"`
+		AIRedactSecrets         bool                    `json:"ai_redact_secrets"`
+		AIRedactExtraPatterns   []string                `json:"ai_redact_extra_patterns"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -67,6 +73,15 @@ func (h *SettingsHandler) UpdateUserSettings(c *gin.Context) {
 		return
 	}
 
+	if err := ai.ValidateTemplate(req.AISystemPrompt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ai_system_prompt: " + err.Error()})
+		return
+	}
+	if err := ai.ValidateTemplate(req.AIUserPromptTemplate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ai_user_prompt_template: " + err.Error()})
+		return
+	}
+
 	// Get current settings to preserve API key if not provided
 	current, err := h.settingsRepo.GetUserSettings(c.Request.Context(), userID)
 	if err != nil {
@@ -75,17 +90,23 @@ func (h *SettingsHandler) UpdateUserSettings(c *gin.Context) {
 	}
 
 	settings := &models.UserSettings{
-		UserID:                userID,
-		AIEnabled:             req.AIEnabled,
-		AIBaseURL:             req.AIBaseURL,
-		AIAPIKey:              current.AIAPIKey, // Keep existing key
-		AIModel:               req.AIModel,
-		AIMaxTokens:           req.AIMaxTokens,
-		AIAutoAnalyze:         req.AIAutoAnalyze,
-		AIMaxLogLines:         req.AIMaxLogLines,
-		AILogTruncateStrategy: req.AILogTruncateStrategy,
-		AISystemPrompt:        req.AISystemPrompt,
-		AIMaxConcurrent:       req.AIMaxConcurrent,
+		UserID:                  userID,
+		AIEnabled:               req.AIEnabled,
+		AIBaseURL:               req.AIBaseURL,
+		AIAPIKey:                current.AIAPIKey, // Keep existing key
+		AIModel:                 req.AIModel,
+		AIMaxTokens:             req.AIMaxTokens,
+		AIAutoAnalyze:           req.AIAutoAnalyze,
+		AIMaxLogLines:           req.AIMaxLogLines,
+		AILogTruncateStrategy:   req.AILogTruncateStrategy,
+		AISystemPrompt:          req.AISystemPrompt,
+		AIUserPromptTemplate:    req.AIUserPromptTemplate,
+		AIReportLanguage:        req.AIReportLanguage,
+		AIMonthlyTokenQuota:     current.AIMonthlyTokenQuota, // Admin-managed, not self-editable
+		AIMaxConcurrent:         req.AIMaxConcurrent,
+		AIRequestTimeoutSeconds: req.AIRequestTimeoutSeconds,
+		AIRedactSecrets:         req.AIRedactSecrets,
+		AIRedactExtraPatterns:   req.AIRedactExtraPatterns,
 	}
 
 	// Update API key if provided
@@ -105,7 +126,7 @@ func (h *SettingsHandler) UpdateUserSettings(c *gin.Context) {
 	// Fetch updated settings
 	updated, _ := h.settingsRepo.GetUserSettings(c.Request.Context(), userID)
 	c.JSON(http.StatusOK, gin.H{
-		"settings":     updated,
+		"settings":    updated,
 		"has_api_key": updated.AIAPIKey.Valid && updated.AIAPIKey.String != "",
 	})
 }
@@ -142,14 +163,14 @@ func (h *SettingsHandler) GetProjectSettings(c *gin.Context) {
 
 	if settings == nil {
 		c.JSON(http.StatusOK, gin.H{
-			"settings":     nil,
+			"settings":    nil,
 			"has_api_key": false,
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"settings":     settings,
+		"settings":    settings,
 		"has_api_key": settings.AIAPIKey.Valid && settings.AIAPIKey.String != "",
 	})
 }
@@ -176,15 +197,20 @@ func (h *SettingsHandler) UpdateProjectSettings(c *gin.Context) {
 	}
 
 	var req struct {
-		AIEnabled             *bool                     `json:"ai_enabled"`
-		AIBaseURL             *string                   `json:"ai_base_url"`
-		AIAPIKey              *string                   `json:"ai_api_key"`
-		AIModel               *string                   `json:"ai_model"`
-		AIMaxTokens           *int                      `json:"ai_max_tokens"`
-		AIAutoAnalyze         *bool                     `json:"ai_auto_analyze"`
-		AIMaxLogLines         *int                      `json:"ai_max_log_lines"`
-		AILogTruncateStrategy *models.TruncateStrategy `json:"ai_log_truncate_strategy"`
-		AISystemPrompt        *string                   `json:"ai_system_prompt"`
+		AIEnabled               *bool                    `json:"ai_enabled"`
+		AIBaseURL               *string                  `json:"ai_base_url"`
+		AIAPIKey                *string                  `json:"ai_api_key"`
+		AIModel                 *string                  `json:"ai_model"`
+		AIMaxTokens             *int                     `json:"ai_max_tokens"`
+		AIAutoAnalyze           *bool                    `json:"ai_auto_analyze"`
+		AIMaxLogLines           *int                     `json:"ai_max_log_lines"`
+		AILogTruncateStrategy   *models.TruncateStrategy `json:"ai_log_truncate_strategy"`
+		AISystemPrompt          *string                  `json:"ai_system_prompt"`
+		AIUserPromptTemplate    *string                  `json:"ai_user_prompt_template"`
+		AIReportLanguage        *string                  `json:"ai_report_language"`
+		AIRequestTimeoutSeconds *int                     `json:"ai_request_timeout_seconds" binding:"omitempty,min=5,max=300"`
+		AIRedactSecrets         *bool                    `json:"ai_redact_secrets"`
+		AIRedactExtraPatterns   []string                 `json:"ai_redact_extra_patterns"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -192,16 +218,34 @@ func (h *SettingsHandler) UpdateProjectSettings(c *gin.Context) {
 		return
 	}
 
+	if req.AISystemPrompt != nil {
+		if err := ai.ValidateTemplate(*req.AISystemPrompt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ai_system_prompt: " + err.Error()})
+			return
+		}
+	}
+	if req.AIUserPromptTemplate != nil {
+		if err := ai.ValidateTemplate(*req.AIUserPromptTemplate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ai_user_prompt_template: " + err.Error()})
+			return
+		}
+	}
+
 	settings := &models.ProjectSettings{
-		ProjectID:             projectID,
-		AIEnabled:             req.AIEnabled,
-		AIBaseURL:             req.AIBaseURL,
-		AIModel:               req.AIModel,
-		AIMaxTokens:           req.AIMaxTokens,
-		AIAutoAnalyze:         req.AIAutoAnalyze,
-		AIMaxLogLines:         req.AIMaxLogLines,
-		AILogTruncateStrategy: req.AILogTruncateStrategy,
-		AISystemPrompt:        req.AISystemPrompt,
+		ProjectID:               projectID,
+		AIEnabled:               req.AIEnabled,
+		AIBaseURL:               req.AIBaseURL,
+		AIModel:                 req.AIModel,
+		AIMaxTokens:             req.AIMaxTokens,
+		AIAutoAnalyze:           req.AIAutoAnalyze,
+		AIMaxLogLines:           req.AIMaxLogLines,
+		AILogTruncateStrategy:   req.AILogTruncateStrategy,
+		AISystemPrompt:          req.AISystemPrompt,
+		AIUserPromptTemplate:    req.AIUserPromptTemplate,
+		AIReportLanguage:        req.AIReportLanguage,
+		AIRequestTimeoutSeconds: req.AIRequestTimeoutSeconds,
+		AIRedactSecrets:         req.AIRedactSecrets,
+		AIRedactExtraPatterns:   req.AIRedactExtraPatterns,
 	}
 
 	// Handle API key
@@ -221,7 +265,7 @@ func (h *SettingsHandler) UpdateProjectSettings(c *gin.Context) {
 	// Fetch updated settings
 	updated, _ := h.settingsRepo.GetProjectSettings(c.Request.Context(), projectID)
 	c.JSON(http.StatusOK, gin.H{
-		"settings":     updated,
+		"settings":    updated,
 		"has_api_key": updated != nil && updated.AIAPIKey.Valid && updated.AIAPIKey.String != "",
 	})
 }
@@ -42,6 +42,12 @@ func (h *StatusHandler) GetStatistics(c *gin.Context) {
 		return
 	}
 
+	totalAICost, err := h.logRunRepo.GetTotalAICost(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch AI cost"})
+		return
+	}
+
 	response := gin.H{
 		"run_statistics": gin.H{
 			"running":   stats.RunningCount,
@@ -57,7 +63,9 @@ func (h *StatusHandler) GetStatistics(c *gin.Context) {
 			"failed":     stats.AIFailedCount,
 			"total":      stats.AIPendingCount + stats.AIProcessingCount + stats.AICompletedCount + stats.AIFailedCount,
 		},
-		"queue_length": queueLength,
+		"error_category_counts": stats.ErrorCategoryCounts,
+		"queue_length":          queueLength,
+		"total_ai_cost_usd":     totalAICost,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -5,11 +5,18 @@ import (
 	"strings"
 
 	"github.com/aliancn/swiftlog/backend/internal/auth"
+	"github.com/aliancn/swiftlog/backend/internal/repository"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
-// AuthMiddleware creates a Gin middleware for JWT/token authentication
-func AuthMiddleware(tokenService *auth.TokenService) gin.HandlerFunc {
+// AuthMiddleware creates a Gin middleware accepting either a web session
+// (see auth.SessionService, what login now issues) or a long-lived API
+// token (see auth.TokenService, for CLI/CI use, and for web-session tokens
+// minted before sessions existed, kept valid for a deprecation window).
+// Session tokens are tried first since they're what the browser sends on
+// every request.
+func AuthMiddleware(tokenService *auth.TokenService, sessionService *auth.SessionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Extract token from Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -31,16 +38,69 @@ func AuthMiddleware(tokenService *auth.TokenService) gin.HandlerFunc {
 			return
 		}
 
-		// Validate token
-		userID, err := tokenService.ValidateToken(c.Request.Context(), token)
+		userID, sessionHash, sessionErr := sessionService.ValidateSession(c.Request.Context(), token, c.ClientIP())
+		if sessionErr == nil {
+			c.Set("user_id", userID)
+			c.Set("credential_type", "session")
+			c.Set("credential_hash", sessionHash)
+			c.Next()
+			return
+		}
+
+		userID, tokenID, err := tokenService.ValidateToken(c.Request.Context(), token, c.ClientIP())
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired credentials"})
 			c.Abort()
 			return
 		}
 
-		// Set user ID in context
 		c.Set("user_id", userID)
+		c.Set("credential_type", "token")
+		c.Set("credential_id", tokenID)
 		c.Next()
 	}
 }
+
+// RequirePasswordChange blocks every protected endpoint except
+// GetCurrentUser and ChangePassword for a user whose must_change_password
+// flag is set (currently only a freshly bootstrapped admin account with a
+// generated password), so the generated credential can't be used for
+// anything until it's replaced.
+func RequirePasswordChange(userRepo *repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if passwordChangeExempt(c.Request.Method, c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		userID := c.MustGet("user_id").(uuid.UUID)
+		user, err := userRepo.GetByID(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired credentials"})
+			c.Abort()
+			return
+		}
+
+		if user.MustChangePassword {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Password change required before continuing",
+				"code":  "password_change_required",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func passwordChangeExempt(method, path string) bool {
+	switch {
+	case method == http.MethodGet && path == "/api/v1/auth/me":
+		return true
+	case method == http.MethodPost && path == "/api/v1/auth/change-password":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header a request's ID is read from (if an
+// upstream proxy already assigned one) and echoed back on.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID assigns a request_id to every request (reusing one supplied in
+// RequestIDHeader, if present), stores it in the Gin context for handlers
+// and StructuredLogger to pick up, and echoes it back in the response
+// header so a client can correlate its own logs with ours.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// StructuredLogger replaces gin's default access logger with one slog
+// record per request, carrying request_id and (once auth middleware has
+// run) user_id, so a request's lifecycle can be reconstructed by grepping
+// logs for either key across services.
+func StructuredLogger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		}
+		if requestID, ok := c.Get("request_id"); ok {
+			attrs = append(attrs, "request_id", requestID)
+		}
+		if userID, ok := c.Get("user_id"); ok {
+			attrs = append(attrs, "user_id", userID)
+		}
+
+		if len(c.Errors) > 0 {
+			logger.Error("request", append(attrs, "errors", c.Errors.String())...)
+			return
+		}
+		logger.Info("request", attrs...)
+	}
+}
@@ -0,0 +1,273 @@
+// Package apiserver builds the API's gin router and its one-time startup
+// side effects (admin user seeding), independent of how the router is
+// served, so cmd/api and cmd/swiftlog-server can both mount it against a
+// shared DB pool and Redis client without duplicating its construction.
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aliancn/swiftlog/backend/internal/api/handlers"
+	"github.com/aliancn/swiftlog/backend/internal/api/middleware"
+	"github.com/aliancn/swiftlog/backend/internal/auth"
+	"github.com/aliancn/swiftlog/backend/internal/auth/oidc"
+	"github.com/aliancn/swiftlog/backend/internal/auth/throttle"
+	"github.com/aliancn/swiftlog/backend/internal/config"
+	"github.com/aliancn/swiftlog/backend/internal/database"
+	"github.com/aliancn/swiftlog/backend/internal/email"
+	"github.com/aliancn/swiftlog/backend/internal/ingestor"
+	"github.com/aliancn/swiftlog/backend/internal/loki"
+	"github.com/aliancn/swiftlog/backend/internal/queue"
+	"github.com/aliancn/swiftlog/backend/internal/quota"
+	"github.com/aliancn/swiftlog/backend/internal/repository"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// New builds the API's gin router against db and redisClient, seeding the
+// initial admin user along the way exactly as cmd/api's main does. The
+// caller owns db and redisClient's lifecycle and is responsible for
+// actually serving the returned router and shutting it down.
+func New(ctx context.Context, cfg *config.APIConfig, db *database.DB, redisClient *redis.Client) (*gin.Engine, error) {
+	oidcConfig := oidc.Config{
+		Enabled:         cfg.OIDC.Enabled,
+		Issuer:          cfg.OIDC.Issuer,
+		ClientID:        cfg.OIDC.ClientID,
+		ClientSecret:    cfg.OIDC.ClientSecret,
+		RedirectURL:     cfg.OIDC.RedirectURL,
+		DomainAllowlist: cfg.OIDC.DomainAllowlist,
+		AdminGroupClaim: cfg.OIDC.AdminGroupClaim,
+		AdminGroups:     cfg.OIDC.AdminGroups,
+	}
+	oidcOnly := cfg.OIDC.Only
+
+	lokiClient := loki.NewClient(&loki.Config{URL: cfg.LokiURL, Timeout: 10 * time.Second})
+
+	taskQueue := queue.NewQueue(redisClient)
+	quotaTracker := quota.NewTokenUsageTracker(redisClient)
+
+	projectRepo := repository.NewProjectRepository(db.DB)
+	groupRepo := repository.NewLogGroupRepository(db.DB)
+	logRunRepo := repository.NewLogRunRepository(db.DB)
+	userRepo := repository.NewUserRepository(db.DB)
+	settingsRepo := repository.NewSettingsRepository(db.DB)
+	instanceSettingsRepo := repository.NewInstanceSettingsRepository(db.DB)
+	conversationRepo := repository.NewConversationRepository(db.DB)
+	analysisVersionRepo := repository.NewAnalysisVersionRepository(db.DB)
+
+	tokenService := auth.NewTokenService(db.DB)
+	tokenService.SetRotationOverlap(cfg.TokenRotationOverlap)
+	tokenService.SetRedisClient(redisClient)
+	tokenService.StartInvalidationListener(ctx)
+	ticketService := auth.NewTicketService(redisClient)
+	sessionService := auth.NewSessionService(redisClient)
+	passwordResetService := auth.NewPasswordResetService(redisClient)
+	loginThrottle := throttle.New(redisClient)
+	emailSender := email.NewSender(email.Config{
+		Host:     cfg.SMTP.Host,
+		Port:     cfg.SMTP.Port,
+		Username: cfg.SMTP.Username,
+		Password: cfg.SMTP.Password,
+		From:     cfg.SMTP.From,
+	})
+
+	var oidcProvider *oidc.Provider
+	if oidcConfig.Enabled {
+		oidcProvider = oidc.NewProvider(oidcConfig, redisClient)
+	}
+
+	log.Println("Initializing admin user...")
+	if err := initializeAdmin(ctx, userRepo, settingsRepo, cfg.Admin.Username, cfg.Admin.Password, cfg.Admin.PasswordFile); err != nil {
+		log.Printf("Warning: Failed to initialize admin user: %v", err)
+	}
+
+	projectsHandler := handlers.NewProjectsHandler(projectRepo, groupRepo)
+	groupsHandler := handlers.NewGroupsHandler(groupRepo, projectRepo)
+	runsHandler := handlers.NewRunsHandler(logRunRepo, groupRepo, projectRepo, settingsRepo, conversationRepo, analysisVersionRepo, lokiClient, taskQueue, quotaTracker, redisClient)
+	authHandler := handlers.NewAuthHandler(userRepo, settingsRepo, instanceSettingsRepo, tokenService, ticketService, sessionService, passwordResetService, loginThrottle, emailSender, oidcProvider, oidcConfig, oidcOnly, cfg.FrontendURL)
+	metaHandler := handlers.NewMetaHandler(instanceSettingsRepo)
+	statusHandler := handlers.NewStatusHandler(logRunRepo, taskQueue)
+	settingsHandler := handlers.NewSettingsHandler(settingsRepo, projectRepo)
+	instanceSettingsHandler := handlers.NewInstanceSettingsHandler(instanceSettingsRepo, userRepo, taskQueue, loginThrottle)
+	ingestCore := ingestor.NewCore(&ingestor.Config{
+		LogRunRepo:   logRunRepo,
+		ProjectRepo:  projectRepo,
+		GroupRepo:    groupRepo,
+		SettingsRepo: settingsRepo,
+		LokiClient:   lokiClient,
+		RedisClient:  redisClient,
+		TaskQueue:    taskQueue,
+	})
+	ingestHandler := handlers.NewIngestHandler(ingestCore, logRunRepo)
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(otelgin.Middleware("api"))
+	router.Use(middleware.RequestID())
+	router.Use(middleware.StructuredLogger(slog.Default()))
+
+	router.Use(cors.New(cors.Config{
+		AllowOrigins:     cfg.CORSOrigins,
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"Content-Length"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(200, gin.H{"status": "healthy"})
+	})
+
+	router.GET("/metrics", func(c *gin.Context) {
+		c.JSON(200, gin.H{"db": db.StatsSnapshot()})
+	})
+
+	v1 := router.Group("/api/v1")
+	{
+		authGroup := v1.Group("/auth")
+		{
+			authGroup.POST("/login", authHandler.Login)
+			authGroup.POST("/register", authHandler.Register)
+			authGroup.POST("/refresh", authHandler.RefreshSession)
+			authGroup.POST("/logout", authHandler.Logout)
+			authGroup.POST("/password-reset/request", authHandler.RequestPasswordReset)
+			authGroup.POST("/password-reset/confirm", authHandler.ConfirmPasswordReset)
+			authGroup.GET("/oidc/login", authHandler.OIDCLogin)
+			authGroup.GET("/oidc/callback", authHandler.OIDCCallback)
+		}
+
+		v1.GET("/projects", projectsHandler.ListProjects)
+		v1.GET("/projects/:id", projectsHandler.GetProject)
+		v1.GET("/projects/:id/groups", projectsHandler.GetProjectGroups)
+		v1.GET("/groups/:id", groupsHandler.GetGroup)
+		v1.GET("/groups/:id/runs", runsHandler.ListRuns)
+		v1.GET("/runs/:id", runsHandler.GetRun)
+		v1.GET("/runs/:id/logs", runsHandler.GetRunLogs)
+
+		v1.GET("/status/statistics", statusHandler.GetStatistics)
+		v1.GET("/status/recent", statusHandler.GetRecentRuns)
+
+		v1.GET("/meta", metaHandler.GetMeta)
+
+		protected := v1.Group("")
+		protected.Use(middleware.AuthMiddleware(tokenService, sessionService))
+		protected.Use(middleware.RequirePasswordChange(userRepo))
+		protected.Use(middleware.RequireCSRFToken(cfg.CSRFTrustedOrigins))
+		{
+			protected.POST("/projects", projectsHandler.CreateProject)
+			protected.POST("/runs/:id/analyze", runsHandler.TriggerAIAnalysis)
+			protected.POST("/runs/:id/analyze/cancel", runsHandler.CancelAIAnalysis)
+			protected.POST("/runs/:id/abort", runsHandler.AbortRun)
+			protected.GET("/runs/:id/analyze/versions", runsHandler.ListAnalysisVersions)
+			protected.GET("/runs/:id/analysis-status", runsHandler.GetAnalysisStatus)
+			protected.POST("/runs/:id/chat", runsHandler.ChatAboutRun)
+			protected.POST("/analyses/retry-failed", runsHandler.RetryFailedAnalyses)
+
+			protected.GET("/auth/me", authHandler.GetCurrentUser)
+			protected.GET("/auth/users", authHandler.ListUsers)
+			protected.PUT("/auth/users/:id/quota", authHandler.SetUserQuota)
+
+			protected.GET("/auth/tokens", authHandler.ListTokens)
+			protected.POST("/auth/tokens", authHandler.CreateToken)
+			protected.DELETE("/auth/tokens/:id", authHandler.DeleteToken)
+			protected.POST("/auth/tokens/:id/rotate", authHandler.RotateToken)
+			protected.POST("/auth/tokens/revoke-all", authHandler.RevokeAll)
+			protected.POST("/auth/change-password", authHandler.ChangePassword)
+
+			protected.POST("/ws-ticket", authHandler.IssueWSTicket)
+
+			protected.GET("/settings", settingsHandler.GetUserSettings)
+			protected.PUT("/settings", settingsHandler.UpdateUserSettings)
+
+			protected.GET("/projects/:id/settings", settingsHandler.GetProjectSettings)
+			protected.PUT("/projects/:id/settings", settingsHandler.UpdateProjectSettings)
+			protected.DELETE("/projects/:id/settings", settingsHandler.DeleteProjectSettings)
+			protected.GET("/projects/:id/settings/effective", settingsHandler.GetEffectiveSettings)
+
+			protected.GET("/admin/ai-pricing", instanceSettingsHandler.GetAIPricing)
+			protected.PUT("/admin/ai-pricing", instanceSettingsHandler.UpdateAIPricing)
+			protected.GET("/admin/password-policy", instanceSettingsHandler.GetPasswordPolicy)
+			protected.PUT("/admin/password-policy", instanceSettingsHandler.UpdatePasswordPolicy)
+			protected.GET("/admin/queue-stats", instanceSettingsHandler.GetQueueStats)
+			protected.GET("/admin/dlq", instanceSettingsHandler.ListDLQ)
+			protected.POST("/admin/dlq/:run_id/requeue", instanceSettingsHandler.RequeueDLQEntry)
+			protected.DELETE("/admin/dlq/:run_id", instanceSettingsHandler.DiscardDLQEntry)
+			protected.GET("/admin/login-lockouts/:username", instanceSettingsHandler.GetLoginLockout)
+			protected.DELETE("/admin/login-lockouts/:username", instanceSettingsHandler.ClearLoginLockout)
+			protected.POST("/admin/users/:id/password-reset-link", authHandler.AdminGeneratePasswordResetLink)
+			protected.POST("/admin/users/:id/revoke-all", authHandler.AdminRevokeUserCredentials)
+
+			protected.POST("/ingest/runs", ingestHandler.CreateRun)
+			protected.POST("/ingest/runs/:id/lines", ingestHandler.AppendLines)
+			protected.POST("/ingest/runs/:id/complete", ingestHandler.CompleteRun)
+		}
+	}
+
+	return router, nil
+}
+
+// initializeAdmin creates the admin user if no users exist. If password is
+// empty (ADMIN_PASSWORD not set), it generates a random one instead of
+// falling back to a well-known default, prints it once, optionally writes
+// it to passwordFile, and flags the account with must_change_password so
+// it can only reach the change-password endpoint until that password is
+// replaced.
+func initializeAdmin(ctx context.Context, userRepo *repository.UserRepository, settingsRepo *repository.SettingsRepository, username, password, passwordFile string) error {
+	count, err := userRepo.Count(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count users: %w", err)
+	}
+
+	if count > 0 {
+		log.Println("Users already exist, skipping admin creation")
+		return nil
+	}
+
+	generated := password == ""
+	if generated {
+		password, err = auth.GenerateAdminPassword()
+		if err != nil {
+			return fmt.Errorf("failed to generate admin password: %w", err)
+		}
+	}
+
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	admin, err := userRepo.Create(ctx, username, passwordHash, true)
+	if err != nil {
+		return fmt.Errorf("failed to create admin user: %w", err)
+	}
+
+	if generated {
+		if err := userRepo.SetMustChangePassword(ctx, admin.ID, true); err != nil {
+			log.Printf("Warning: Failed to flag generated admin password for forced change: %v", err)
+		}
+		log.Printf("Generated admin password for %q, change it immediately: %s", admin.Username, password)
+		if passwordFile != "" {
+			if err := os.WriteFile(passwordFile, []byte(password+"\n"), 0o600); err != nil {
+				log.Printf("Warning: Failed to write admin password to %s: %v", passwordFile, err)
+			} else {
+				log.Printf("Admin password also written to %s", passwordFile)
+			}
+		}
+	}
+
+	_, err = settingsRepo.CreateDefaultUserSettings(ctx, admin.ID)
+	if err != nil {
+		log.Printf("Warning: Failed to create default settings for admin user: %v", err)
+	}
+
+	log.Printf("Admin user created: %s (ID: %s)", admin.Username, admin.ID)
+	return nil
+}
@@ -0,0 +1,127 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aliancn/swiftlog/backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// adminBootstrapTestUserID is reused across the users and user_settings
+// insert fixtures below so the generated admin's ID is consistent between
+// the two inserts initializeAdmin issues.
+var adminBootstrapTestUserID = uuid.New()
+
+func newAdminBootstrapTestRepos(t *testing.T) (*repository.UserRepository, *repository.SettingsRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return repository.NewUserRepository(db), repository.NewSettingsRepository(db), mock
+}
+
+func expectAdminUserCreated(mock sqlmock.Sqlmock, username string) {
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs(username, sqlmock.AnyArg(), true, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_admin", "email", "must_change_password", "created_at"}).
+			AddRow(adminBootstrapTestUserID, username, true, nil, false, time.Now()))
+}
+
+func expectDefaultSettingsCreated(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("INSERT INTO user_settings").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "user_id", "ai_enabled", "ai_base_url", "ai_api_key", "ai_model", "ai_max_tokens",
+			"ai_auto_analyze", "ai_max_log_lines", "ai_log_truncate_strategy",
+			"ai_system_prompt", "ai_user_prompt_template", "ai_report_language", "ai_monthly_token_quota",
+			"ai_max_concurrent", "ai_request_timeout_seconds", "ai_redact_secrets", "ai_redact_extra_patterns",
+			"created_at", "updated_at",
+		}).AddRow(
+			adminBootstrapTestUserID, adminBootstrapTestUserID, true, "https://api.openai.com/v1", nil, "gpt-4o-mini", 500,
+			false, 1000, "tail",
+			"You are a helpful assistant.", "", "en", nil,
+			3, 300, true, nil,
+			time.Now(), time.Now(),
+		))
+}
+
+// TestInitializeAdmin_ExplicitPasswordSkipsGeneration covers the operator
+// setting ADMIN_PASSWORD explicitly: the given password is used as-is, no
+// password is generated or printed, and must_change_password is never set.
+func TestInitializeAdmin_ExplicitPasswordSkipsGeneration(t *testing.T) {
+	userRepo, settingsRepo, mock := newAdminBootstrapTestRepos(t)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectAdminUserCreated(mock, "admin")
+	expectDefaultSettingsCreated(mock)
+
+	if err := initializeAdmin(context.Background(), userRepo, settingsRepo, "admin", "s3cr3t-explicit-password", ""); err != nil {
+		t.Fatalf("initializeAdmin failed: %v", err)
+	}
+
+	// No SetMustChangePassword UPDATE should have run for an explicit
+	// password, so asserting all expectations were met (and nothing more)
+	// is sufficient - an unexpected UPDATE would fail this.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet or unexpected database calls: %v", err)
+	}
+}
+
+// TestInitializeAdmin_GeneratesPasswordAndForcesChange covers the safer
+// default path: no ADMIN_PASSWORD means a strong password is generated,
+// the account is flagged with must_change_password, and (when a path is
+// given) the password is also written to a root-only file.
+func TestInitializeAdmin_GeneratesPasswordAndForcesChange(t *testing.T) {
+	userRepo, settingsRepo, mock := newAdminBootstrapTestRepos(t)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectAdminUserCreated(mock, "admin")
+	mock.ExpectExec("UPDATE users SET must_change_password").
+		WithArgs(true, adminBootstrapTestUserID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectDefaultSettingsCreated(mock)
+
+	passwordFile := filepath.Join(t.TempDir(), "admin-password")
+
+	if err := initializeAdmin(context.Background(), userRepo, settingsRepo, "admin", "", passwordFile); err != nil {
+		t.Fatalf("initializeAdmin failed: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet or unexpected database calls: %v", err)
+	}
+
+	written, err := os.ReadFile(passwordFile)
+	if err != nil {
+		t.Fatalf("expected generated password to be written to %s: %v", passwordFile, err)
+	}
+	if len(written) < 2 {
+		t.Fatalf("password file %s looks empty: %q", passwordFile, written)
+	}
+}
+
+// TestInitializeAdmin_SkipsWhenUsersExist covers the steady-state case: an
+// already-initialized instance must not touch the users table at all on
+// subsequent startups.
+func TestInitializeAdmin_SkipsWhenUsersExist(t *testing.T) {
+	userRepo, settingsRepo, mock := newAdminBootstrapTestRepos(t)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	if err := initializeAdmin(context.Background(), userRepo, settingsRepo, "admin", "", ""); err != nil {
+		t.Fatalf("initializeAdmin failed: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet or unexpected database calls: %v", err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
@@ -88,7 +89,7 @@ func authenticateRequest(ctx context.Context, tokenService *TokenService) (uuid.
 		token = token[7:]
 	}
 
-	userID, err := tokenService.ValidateToken(ctx, token)
+	userID, _, err := tokenService.ValidateToken(ctx, token, peerAddr(ctx))
 	if err != nil {
 		return uuid.Nil, err
 	}
@@ -96,6 +97,15 @@ func authenticateRequest(ctx context.Context, tokenService *TokenService) (uuid.
 	return userID, nil
 }
 
+// peerAddr returns the caller's address for a gRPC request, or "" if
+// unavailable, for recording a token's last-used IP.
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
 // GetUserIDFromContext extracts the user ID from the context
 func GetUserIDFromContext(ctx context.Context) (uuid.UUID, error) {
 	userID, ok := ctx.Value(UserIDKey).(uuid.UUID)
@@ -0,0 +1,534 @@
+// Package oidc implements enough of OpenID Connect's authorization-code
+// flow with PKCE for single sign-on against a team's identity provider: the
+// discovery document, the authorize redirect, the code-for-tokens exchange,
+// and ID token signature verification. It intentionally doesn't try to be a
+// general-purpose OIDC client - only RS256-signed ID tokens are supported,
+// and there's no token refresh, since a SwiftLog session (see
+// auth.SessionService) is what the browser actually holds after login.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliancn/swiftlog/backend/internal/auth"
+	"github.com/redis/go-redis/v9"
+)
+
+// stateTTL is how long an in-flight login (state + nonce + PKCE verifier)
+// is kept in Redis before it must be restarted.
+const stateTTL = 10 * time.Minute
+
+const stateKeyPrefix = "swiftlog:oidc:state:"
+
+// Config holds the env-configured OIDC settings for a single identity
+// provider. Enabled is false unless every field required to run the flow
+// is set, so the rest of the API can check it once instead of nil-checking
+// a *Provider at every call site.
+type Config struct {
+	Enabled      bool
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	// DomainAllowlist restricts auto-provisioning to email addresses at
+	// these domains. Empty means any domain is accepted.
+	DomainAllowlist []string
+
+	// AdminGroupClaim is the ID token claim carrying the user's groups
+	// (e.g. "groups"); a user whose value for that claim intersects
+	// AdminGroups is auto-provisioned as an admin. Ignored if either
+	// field is empty.
+	AdminGroupClaim string
+	AdminGroups     []string
+}
+
+// discoveryDocument is the subset of an issuer's
+// /.well-known/openid-configuration this package uses.
+type discoveryDocument struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider drives the authorization-code-with-PKCE flow against a single
+// configured issuer, caching its discovery document and signing keys.
+type Provider struct {
+	cfg         Config
+	redisClient *redis.Client
+	httpClient  *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]*rsa.PublicKey
+	keysAt    time.Time
+}
+
+// NewProvider creates a Provider for cfg. Discovery and key fetching happen
+// lazily on first use rather than here, so a misconfigured or unreachable
+// issuer doesn't block startup.
+func NewProvider(cfg Config, redisClient *redis.Client) *Provider {
+	return &Provider{
+		cfg:         cfg,
+		redisClient: redisClient,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// pendingLogin is what's stored in Redis between the authorize redirect and
+// the callback, keyed by the opaque state value.
+type pendingLogin struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// AuthorizationURL generates a fresh state, nonce, and PKCE verifier,
+// stashes them in Redis under the state (single-use, expiring after
+// stateTTL), and returns the URL to redirect the browser to.
+func (p *Provider) AuthorizationURL(ctx context.Context) (redirectURL string, err error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := auth.GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate oidc state: %w", err)
+	}
+	nonce, err := auth.GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate oidc nonce: %w", err)
+	}
+	codeVerifier, err := auth.GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate pkce verifier: %w", err)
+	}
+
+	pending, err := json.Marshal(pendingLogin{Nonce: nonce, CodeVerifier: codeVerifier})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal pending oidc login: %w", err)
+	}
+	if err := p.redisClient.Set(ctx, stateKeyPrefix+state, pending, stateTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store pending oidc login: %w", err)
+	}
+
+	challenge := codeChallengeS256(codeVerifier)
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	return doc.AuthorizationEndpoint + "?" + query.Encode(), nil
+}
+
+// codeChallengeS256 derives a PKCE code_challenge from codeVerifier using
+// the S256 transform: base64url(sha256(verifier)), no padding.
+func codeChallengeS256(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Callback consumes a single-use state (rejecting an unknown, expired, or
+// already-used one, e.g. a replayed callback), exchanges code for tokens,
+// and validates the returned ID token, checking its signature, issuer,
+// audience, expiry, and that its nonce matches the one issued alongside
+// this state.
+func (p *Provider) Callback(ctx context.Context, code, state string) (*Claims, error) {
+	rawPending, err := p.redisClient.GetDel(ctx, stateKeyPrefix+state).Result()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("invalid, expired, or already-used oidc state")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up pending oidc login: %w", err)
+	}
+
+	var pending pendingLogin
+	if err := json.Unmarshal([]byte(rawPending), &pending); err != nil {
+		return nil, fmt.Errorf("corrupt pending oidc login: %w", err)
+	}
+
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, err := p.exchangeCode(ctx, doc.TokenEndpoint, code, pending.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := p.verifyIDToken(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("id token issuer %q does not match configured issuer %q", claims.Issuer, p.cfg.Issuer)
+	}
+	if !claims.hasAudience(p.cfg.ClientID) {
+		return nil, fmt.Errorf("id token audience does not include client %q", p.cfg.ClientID)
+	}
+	if time.Now().After(claims.Expiry) {
+		return nil, fmt.Errorf("id token has expired")
+	}
+	if claims.Nonce != pending.Nonce {
+		return nil, fmt.Errorf("id token nonce does not match the one issued for this login")
+	}
+
+	return claims, nil
+}
+
+// tokenResponse is the subset of a token endpoint response this package
+// uses.
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+func (p *Provider) exchangeCode(ctx context.Context, tokenEndpoint, code, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to build token exchange request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange code for tokens: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token endpoint response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, body)
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", fmt.Errorf("failed to parse token endpoint response: %w", err)
+	}
+	if tr.IDToken == "" {
+		return "", fmt.Errorf("token endpoint response did not include an id_token")
+	}
+
+	return tr.IDToken, nil
+}
+
+// discover fetches and caches the issuer's discovery document. It's never
+// refreshed once fetched: an issuer changing its endpoints without a
+// restart isn't a case this handles.
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build oidc discovery request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch oidc discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read oidc discovery document: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery endpoint returned %d: %s", resp.StatusCode, body)
+	}
+
+	var doc discoveryDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse oidc discovery document: %w", err)
+	}
+
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+// jwks is the subset of a JSON Web Key Set this package understands: RSA
+// signing keys.
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Alg string `json:"alg"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// jwksCacheTTL bounds how long fetched signing keys are trusted before
+// being re-fetched, so a rotated key is picked up without a restart.
+const jwksCacheTTL = 1 * time.Hour
+
+// publicKey returns the RSA public key for kid, fetching and caching the
+// issuer's JWKS if it isn't already known or the cache has expired.
+func (p *Provider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	stale := time.Since(p.keysAt) > jwksCacheTTL
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok && !stale {
+		return key, nil
+	}
+
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, doc.JWKSURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build jwks request: %w", err)
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned %d: %s", resp.StatusCode, body)
+	}
+
+	var set jwks
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		pub, err := rsaPublicKeyFromJWK(k.N, k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysAt = time.Now()
+	key, ok = p.keys[kid]
+	p.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no signing key found for kid %q", kid)
+	}
+	return key, nil
+}
+
+// rsaPublicKeyFromJWK decodes a JWK's base64url-encoded modulus and
+// exponent into an *rsa.PublicKey.
+func rsaPublicKeyFromJWK(rawN, rawE string) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(rawN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk modulus: %w", err)
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(rawE)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk exponent: %w", err)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(new(big.Int).SetBytes(eBytes).Int64()),
+	}, nil
+}
+
+// jwtHeader is the subset of a JWS header this package uses.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Claims is the subset of an ID token's claims this package parses,
+// alongside the raw claim set for reading provider-specific claims like
+// group membership.
+type Claims struct {
+	Issuer        string `json:"iss"`
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Nonce         string `json:"nonce"`
+	Expiry        time.Time
+
+	raw map[string]interface{}
+}
+
+// hasAudience reports whether clientID appears in the token's aud claim,
+// which per the OIDC spec may be either a single string or an array.
+func (c *Claims) hasAudience(clientID string) bool {
+	switch aud := c.raw["aud"].(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GroupMembership returns the string values of claimName (a single string
+// or an array of strings), for checking against Config.AdminGroups.
+func (c *Claims) GroupMembership(claimName string) []string {
+	switch v := c.raw[claimName].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		groups := make([]string, 0, len(v))
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	default:
+		return nil
+	}
+}
+
+// verifyIDToken checks rawToken's RS256 signature against the issuer's
+// published JWKS and parses its claims. It does not check iss/aud/exp/nonce
+// itself; Callback does, since those checks depend on request-scoped state
+// (the pending login) that this method doesn't have.
+func (p *Provider) verifyIDToken(ctx context.Context, rawToken string) (*Claims, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed id token")
+	}
+
+	rawHeader, rawPayload, rawSig := parts[0], parts[1], parts[2]
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(rawHeader)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token header encoding: %w", err)
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, fmt.Errorf("invalid id token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported id token signing algorithm %q", header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(rawSig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token signature encoding: %w", err)
+	}
+
+	pubKey, err := p.publicKey(ctx, header.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve id token signing key: %w", err)
+	}
+
+	hashed := sha256.Sum256([]byte(rawHeader + "." + rawPayload))
+	if err := rsa.VerifyPKCS1v15(pubKey, crypto.SHA256, hashed[:], sig); err != nil {
+		return nil, fmt.Errorf("id token signature verification failed: %w", err)
+	}
+
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(rawPayload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token payload encoding: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(payloadBytes, &raw); err != nil {
+		return nil, fmt.Errorf("invalid id token payload: %w", err)
+	}
+
+	claims := &Claims{raw: raw}
+	if err := json.Unmarshal(payloadBytes, claims); err != nil {
+		return nil, fmt.Errorf("invalid id token claims: %w", err)
+	}
+	if exp, ok := raw["exp"].(float64); ok {
+		claims.Expiry = time.Unix(int64(exp), 0)
+	}
+
+	return claims, nil
+}
+
+// EmailDomainAllowed reports whether email's domain is permitted to
+// auto-provision, per Config.DomainAllowlist. An empty allowlist permits
+// every domain.
+func (cfg Config) EmailDomainAllowed(email string) bool {
+	if len(cfg.DomainAllowlist) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, allowed := range cfg.DomainAllowlist {
+		if strings.ToLower(allowed) == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdminGroup reports whether groups (a user's membership, as returned by
+// Claims.GroupMembership(cfg.AdminGroupClaim)) intersects Config.AdminGroups.
+func (cfg Config) IsAdminGroup(groups []string) bool {
+	if cfg.AdminGroupClaim == "" || len(cfg.AdminGroups) == 0 {
+		return false
+	}
+	for _, g := range groups {
+		for _, admin := range cfg.AdminGroups {
+			if g == admin {
+				return true
+			}
+		}
+	}
+	return false
+}
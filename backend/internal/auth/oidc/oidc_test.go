@@ -0,0 +1,251 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testIdP is a minimal OIDC identity provider backing an httptest.Server:
+// discovery, JWKS, and a token endpoint that always hands back a
+// caller-supplied ID token, so each test controls exactly what claims the
+// "IdP" asserts.
+type testIdP struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	kid      string
+	idToken  string // set by the test before exercising Callback
+	tokenErr int    // non-zero to make the token endpoint fail with this status
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+	idp := &testIdP{key: key, kid: "test-key-1"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discoveryDocument{
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": idp.kid,
+				"kty": "RSA",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(idp.key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if idp.tokenErr != 0 {
+			w.WriteHeader(idp.tokenErr)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(tokenResponse{IDToken: idp.idToken})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// signIDToken builds and signs a minimal RS256 ID token with the given
+// claims, defaulting iss/aud/exp to values that pass Callback's checks so a
+// test only needs to override what it's actually exercising.
+func (idp *testIdP) signIDToken(t *testing.T, overrides map[string]interface{}) string {
+	t.Helper()
+	claims := map[string]interface{}{
+		"iss": idp.server.URL,
+		"aud": "test-client",
+		"exp": float64(time.Now().Add(time.Hour).Unix()),
+	}
+	for k, v := range overrides {
+		claims[k] = v
+	}
+
+	header := map[string]string{"alg": "RS256", "kid": idp.kid}
+	headerJSON, _ := json.Marshal(header)
+	claimsJSON, _ := json.Marshal(claims)
+
+	rawHeader := base64.RawURLEncoding.EncodeToString(headerJSON)
+	rawPayload := base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	hashed := sha256.Sum256([]byte(rawHeader + "." + rawPayload))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatalf("failed to sign id token: %v", err)
+	}
+
+	return fmt.Sprintf("%s.%s.%s", rawHeader, rawPayload, base64.RawURLEncoding.EncodeToString(sig))
+}
+
+func newTestProvider(t *testing.T, idp *testIdP) (*Provider, *miniredis.Miniredis) {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	cfg := Config{
+		Enabled:  true,
+		Issuer:   idp.server.URL,
+		ClientID: "test-client",
+	}
+	return NewProvider(cfg, redisClient), mr
+}
+
+// startLogin drives AuthorizationURL and returns the state it minted, so a
+// test can then call Callback with a valid, single-use state to exercise.
+func startLogin(t *testing.T, p *Provider) string {
+	t.Helper()
+	redirectURL, err := p.AuthorizationURL(context.Background())
+	if err != nil {
+		t.Fatalf("AuthorizationURL failed: %v", err)
+	}
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		t.Fatalf("failed to parse authorization url: %v", err)
+	}
+	return u.Query().Get("state")
+}
+
+func TestCallback_ValidToken(t *testing.T) {
+	idp := newTestIdP(t)
+	p, _ := newTestProvider(t, idp)
+
+	state := startLogin(t, p)
+	pending := pendingLoginFor(t, p, state)
+	idp.idToken = idp.signIDToken(t, map[string]interface{}{"nonce": pending.Nonce, "email": "user@example.com"})
+
+	claims, err := p.Callback(context.Background(), "test-code", state)
+	if err != nil {
+		t.Fatalf("Callback failed for a valid token: %v", err)
+	}
+	if claims.Email != "user@example.com" {
+		t.Errorf("Email = %q, want %q", claims.Email, "user@example.com")
+	}
+}
+
+func TestCallback_UnknownState(t *testing.T) {
+	idp := newTestIdP(t)
+	p, _ := newTestProvider(t, idp)
+
+	if _, err := p.Callback(context.Background(), "test-code", "never-issued-state"); err == nil {
+		t.Fatal("Callback succeeded with a state that was never issued")
+	}
+}
+
+func TestCallback_StateIsSingleUse(t *testing.T) {
+	idp := newTestIdP(t)
+	p, _ := newTestProvider(t, idp)
+
+	state := startLogin(t, p)
+	pending := pendingLoginFor(t, p, state)
+	idp.idToken = idp.signIDToken(t, map[string]interface{}{"nonce": pending.Nonce})
+
+	if _, err := p.Callback(context.Background(), "test-code", state); err != nil {
+		t.Fatalf("first Callback failed: %v", err)
+	}
+	if _, err := p.Callback(context.Background(), "test-code", state); err == nil {
+		t.Fatal("Callback succeeded when replayed with an already-consumed state")
+	}
+}
+
+func TestCallback_NonceMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	p, _ := newTestProvider(t, idp)
+
+	state := startLogin(t, p)
+	idp.idToken = idp.signIDToken(t, map[string]interface{}{"nonce": "not-the-nonce-issued-for-this-login"})
+
+	if _, err := p.Callback(context.Background(), "test-code", state); err == nil {
+		t.Fatal("Callback succeeded despite a nonce that doesn't match the one issued for this login")
+	}
+}
+
+func TestCallback_WrongAudience(t *testing.T) {
+	idp := newTestIdP(t)
+	p, _ := newTestProvider(t, idp)
+
+	state := startLogin(t, p)
+	pending := pendingLoginFor(t, p, state)
+	idp.idToken = idp.signIDToken(t, map[string]interface{}{"nonce": pending.Nonce, "aud": "some-other-client"})
+
+	if _, err := p.Callback(context.Background(), "test-code", state); err == nil {
+		t.Fatal("Callback succeeded with an id token issued for a different client")
+	}
+}
+
+func TestCallback_WrongIssuer(t *testing.T) {
+	idp := newTestIdP(t)
+	p, _ := newTestProvider(t, idp)
+
+	state := startLogin(t, p)
+	pending := pendingLoginFor(t, p, state)
+	idp.idToken = idp.signIDToken(t, map[string]interface{}{"nonce": pending.Nonce, "iss": "https://attacker.example.com"})
+
+	if _, err := p.Callback(context.Background(), "test-code", state); err == nil {
+		t.Fatal("Callback succeeded with an id token from an unexpected issuer")
+	}
+}
+
+func TestCallback_ExpiredToken(t *testing.T) {
+	idp := newTestIdP(t)
+	p, _ := newTestProvider(t, idp)
+
+	state := startLogin(t, p)
+	pending := pendingLoginFor(t, p, state)
+	idp.idToken = idp.signIDToken(t, map[string]interface{}{
+		"nonce": pending.Nonce,
+		"exp":   float64(time.Now().Add(-time.Hour).Unix()),
+	})
+
+	if _, err := p.Callback(context.Background(), "test-code", state); err == nil {
+		t.Fatal("Callback succeeded with an expired id token")
+	}
+}
+
+// pendingLoginFor reads back the pending login AuthorizationURL stashed
+// under state, so a test can sign an ID token carrying the exact nonce
+// Callback will check for - without this, every test would need to guess
+// or intercept a randomly generated nonce.
+func pendingLoginFor(t *testing.T, p *Provider, state string) pendingLogin {
+	t.Helper()
+	raw, err := p.redisClient.Get(context.Background(), stateKeyPrefix+state).Result()
+	if err != nil {
+		t.Fatalf("failed to read back pending login: %v", err)
+	}
+	var pending pendingLogin
+	if err := json.Unmarshal([]byte(raw), &pending); err != nil {
+		t.Fatalf("failed to unmarshal pending login: %v", err)
+	}
+	return pending
+}
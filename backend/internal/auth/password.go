@@ -31,3 +31,14 @@ func HashPassword(password string) (string, error) {
 func VerifyPassword(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
+
+// adminPasswordLength is the number of random base62 characters generated
+// for a bootstrap admin password when ADMIN_PASSWORD isn't set explicitly.
+const adminPasswordLength = 20
+
+// GenerateAdminPassword returns a random password to seed the admin
+// account with when no explicit ADMIN_PASSWORD is configured, well above
+// MinPasswordLength so it always passes HashPassword.
+func GenerateAdminPassword() (string, error) {
+	return randomBase62(adminPasswordLength)
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// passwordResetTTL is how long a password reset token is valid before it
+// expires unused. Kept short since a leaked reset link is effectively a
+// password-reset capability for that account.
+const passwordResetTTL = 30 * time.Minute
+
+// passwordResetKeyPrefix namespaces password reset tokens in Redis.
+const passwordResetKeyPrefix = "swiftlog:password-reset:"
+
+// PasswordResetService issues and consumes short-lived, single-use
+// password reset tokens, following the same Redis-backed,
+// hashed-at-rest, GetDel-consumed pattern as TicketService.
+type PasswordResetService struct {
+	redisClient *redis.Client
+}
+
+// NewPasswordResetService creates a new password reset service.
+func NewPasswordResetService(redisClient *redis.Client) *PasswordResetService {
+	return &PasswordResetService{redisClient: redisClient}
+}
+
+// IssueResetToken creates a new password reset token for userID, valid for
+// passwordResetTTL and usable exactly once.
+func (s *PasswordResetService) IssueResetToken(ctx context.Context, userID uuid.UUID) (string, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate password reset token: %w", err)
+	}
+
+	if err := s.redisClient.Set(ctx, passwordResetKeyPrefix+HashToken(token), userID.String(), passwordResetTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store password reset token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ConsumeResetToken atomically fetches and deletes the reset token, so a
+// second attempt to use it (a replay) is rejected once the first has
+// claimed it. Returns the user ID it was issued for.
+func (s *PasswordResetService) ConsumeResetToken(ctx context.Context, token string) (uuid.UUID, error) {
+	rawUserID, err := s.redisClient.GetDel(ctx, passwordResetKeyPrefix+HashToken(token)).Result()
+	if err == redis.Nil {
+		return uuid.Nil, fmt.Errorf("invalid, expired, or already-used reset token")
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to consume password reset token: %w", err)
+	}
+
+	userID, err := uuid.Parse(rawUserID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("corrupt password reset token data: %w", err)
+	}
+
+	return userID, nil
+}
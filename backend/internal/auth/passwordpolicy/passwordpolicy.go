@@ -0,0 +1,158 @@
+// Package passwordpolicy evaluates a candidate password against a
+// models.PasswordPolicy: required character classes, username reuse, a
+// built-in common-password list, and a strength score. The score is a
+// hand-rolled entropy estimate on the same 0-4 scale as zxcvbn, not
+// zxcvbn itself, since that library isn't a dependency of this project.
+package passwordpolicy
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/aliancn/swiftlog/backend/internal/models"
+)
+
+// Result is the structured feedback returned to the caller (and, via the
+// API, the frontend strength meter).
+type Result struct {
+	Valid       bool     `json:"valid"`
+	Score       int      `json:"score"` // 0 (weakest) to 4 (strongest), same scale as zxcvbn
+	Warnings    []string `json:"warnings"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// commonPasswords is a small built-in list of the most common passwords
+// seen in breach corpora. It's intentionally short - it exists to catch
+// the obviously bad cases ("password", "123456"), not to be a full
+// breach-corpus check.
+var commonPasswords = map[string]struct{}{
+	"password": {}, "123456": {}, "123456789": {}, "12345678": {},
+	"qwerty": {}, "111111": {}, "abc123": {}, "password1": {},
+	"iloveyou": {}, "admin": {}, "welcome": {}, "monkey": {},
+	"letmein": {}, "dragon": {}, "sunshine": {}, "master": {},
+	"football": {}, "shadow": {}, "superman": {}, "trustno1": {},
+	"1234567890": {}, "qwerty123": {}, "changeme": {}, "passw0rd": {},
+}
+
+// Evaluate checks password against policy for username, and returns
+// structured pass/fail feedback. An empty policy is treated as "no
+// requirements beyond a strength score of 0" - callers that want the
+// project's baseline defaults should pass models.DefaultPasswordPolicy().
+func Evaluate(policy models.PasswordPolicy, password, username string) Result {
+	result := Result{Valid: true, Warnings: []string{}, Suggestions: []string{}}
+
+	if policy.MinLength > 0 && len(password) < policy.MinLength {
+		result.Valid = false
+		result.Warnings = append(result.Warnings, "Password is too short")
+		result.Suggestions = append(result.Suggestions, "Use at least "+strconv.Itoa(policy.MinLength)+" characters")
+	}
+
+	classes := classify(password)
+	if policy.RequireUppercase && !classes.upper {
+		result.Valid = false
+		result.Warnings = append(result.Warnings, "Password needs an uppercase letter")
+	}
+	if policy.RequireLowercase && !classes.lower {
+		result.Valid = false
+		result.Warnings = append(result.Warnings, "Password needs a lowercase letter")
+	}
+	if policy.RequireDigit && !classes.digit {
+		result.Valid = false
+		result.Warnings = append(result.Warnings, "Password needs a digit")
+	}
+	if policy.RequireSymbol && !classes.symbol {
+		result.Valid = false
+		result.Warnings = append(result.Warnings, "Password needs a symbol")
+	}
+
+	if policy.RejectUsername && username != "" && len(username) >= 3 &&
+		strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		result.Valid = false
+		result.Warnings = append(result.Warnings, "Password must not contain your username")
+		result.Suggestions = append(result.Suggestions, "Avoid using your username or account name in your password")
+	}
+
+	if policy.RejectCommonPasswords {
+		if _, common := commonPasswords[strings.ToLower(password)]; common {
+			result.Valid = false
+			result.Warnings = append(result.Warnings, "This password is one of the most commonly used passwords")
+			result.Suggestions = append(result.Suggestions, "Choose something less predictable")
+		}
+	}
+
+	result.Score = score(password, classes)
+	if result.Score < policy.MinScore {
+		result.Valid = false
+		result.Warnings = append(result.Warnings, "Password is too weak")
+		result.Suggestions = append(result.Suggestions, "Make it longer, or mix in numbers and symbols")
+	}
+
+	return result
+}
+
+type charClasses struct {
+	lower, upper, digit, symbol bool
+}
+
+func classify(password string) charClasses {
+	var c charClasses
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			c.lower = true
+		case r >= 'A' && r <= 'Z':
+			c.upper = true
+		case r >= '0' && r <= '9':
+			c.digit = true
+		default:
+			c.symbol = true
+		}
+	}
+	return c
+}
+
+// score estimates password strength on a 0-4 scale by treating the
+// password as a uniform random string over whatever character classes it
+// actually uses, computing its entropy in bits, and bucketing that
+// against thresholds in the same neighborhood zxcvbn uses to draw its
+// score boundaries. It intentionally doesn't try to detect dictionary
+// words, l33t-speak substitutions, or keyboard patterns the way zxcvbn
+// does - it's a cheap approximation, not a replacement.
+func score(password string, classes charClasses) int {
+	if password == "" {
+		return 0
+	}
+
+	charsetSize := 0
+	if classes.lower {
+		charsetSize += 26
+	}
+	if classes.upper {
+		charsetSize += 26
+	}
+	if classes.digit {
+		charsetSize += 10
+	}
+	if classes.symbol {
+		charsetSize += 32
+	}
+	if charsetSize == 0 {
+		charsetSize = 1
+	}
+
+	bits := float64(len(password)) * math.Log2(float64(charsetSize))
+
+	switch {
+	case bits < 28:
+		return 0
+	case bits < 36:
+		return 1
+	case bits < 60:
+		return 2
+	case bits < 128:
+		return 3
+	default:
+		return 4
+	}
+}
@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// sessionTTL is how long an access session token is valid before the
+	// client must use its refresh token to get a new one.
+	sessionTTL = 4 * time.Hour
+	// refreshTokenTTL is how long a refresh token may be used to mint new
+	// sessions before the user has to log in again.
+	refreshTokenTTL = 30 * 24 * time.Hour
+
+	sessionKeyPrefix = "swiftlog:session:"
+	refreshKeyPrefix = "swiftlog:refresh:"
+
+	// userSessionsKeyPrefix indexes a user's outstanding hashed session and
+	// refresh tokens in a Redis set, purely so RevokeAllSessions has
+	// something to iterate; entries aren't individually expired out of the
+	// set, so a long-idle set may retain a few already-expired hashes,
+	// which RevokeAllSessions harmlessly no-ops on.
+	userSessionsKeyPrefix = "swiftlog:user-sessions:"
+)
+
+// SessionService issues and validates short-lived, Redis-backed web
+// sessions, kept separate from the long-lived api_tokens table so that CLI
+// and CI credentials aren't subject to session expiry, and a browser
+// session can't be used to mint another API token. Session and refresh
+// tokens are stored hashed, same as api_tokens, since unlike a WebSocket
+// ticket (see TicketService) they live long enough to be worth protecting
+// against exposure of the Redis dataset itself.
+type SessionService struct {
+	redisClient *redis.Client
+}
+
+// NewSessionService creates a new session service
+func NewSessionService(redisClient *redis.Client) *SessionService {
+	return &SessionService{redisClient: redisClient}
+}
+
+// sessionMeta is what's actually stored (JSON-encoded) at a session or
+// refresh token's Redis key. PairHash cross-references the hash of the
+// other half of the pair issued in the same CreateSession call (the
+// refresh token's hash on a session entry, and vice versa), so
+// RevokeSessionsExcept can recognize both halves of the caller's own
+// session as "current" and leave them alone.
+type sessionMeta struct {
+	UserID     uuid.UUID `json:"user_id"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	PairHash   string    `json:"pair_hash"`
+}
+
+// SessionInfo is a session entry as surfaced to a user reviewing their
+// active sessions (see AuthHandler.ListTokens).
+type SessionInfo struct {
+	Hash       string    `json:"hash"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// CreateSession issues a new session token and refresh token pair for
+// userID, for use right after login or registration. ip is recorded for
+// display in the user's session list; pass "" if unknown.
+func (s *SessionService) CreateSession(ctx context.Context, userID uuid.UUID, ip string) (sessionToken, refreshToken string, expiresAt time.Time, err error) {
+	sessionToken, err = GenerateToken()
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to generate session token: %w", err)
+	}
+	refreshToken, err = GenerateToken()
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt = now.Add(sessionTTL)
+	sessionHash := HashToken(sessionToken)
+	refreshHash := HashToken(refreshToken)
+
+	sessionData, err := json.Marshal(sessionMeta{UserID: userID, IP: ip, CreatedAt: now, LastUsedAt: now, PairHash: refreshHash})
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to encode session: %w", err)
+	}
+	refreshData, err := json.Marshal(sessionMeta{UserID: userID, IP: ip, CreatedAt: now, LastUsedAt: now, PairHash: sessionHash})
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to encode refresh token: %w", err)
+	}
+
+	if err := s.redisClient.Set(ctx, sessionKeyPrefix+sessionHash, sessionData, sessionTTL).Err(); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to store session: %w", err)
+	}
+	if err := s.redisClient.Set(ctx, refreshKeyPrefix+refreshHash, refreshData, refreshTokenTTL).Err(); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	if err := s.redisClient.SAdd(ctx, userSessionsKeyPrefix+userID.String(), sessionHash, refreshHash).Err(); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to index session for user: %w", err)
+	}
+
+	return sessionToken, refreshToken, expiresAt, nil
+}
+
+// ValidateSession validates a session token, touches its last-used time and
+// IP, and returns the user ID it was issued for along with the session's
+// hash (its identity in ListSessions/RevokeSessionsExcept).
+func (s *SessionService) ValidateSession(ctx context.Context, rawToken, ip string) (userID uuid.UUID, sessionHash string, err error) {
+	sessionHash = HashToken(rawToken)
+	key := sessionKeyPrefix + sessionHash
+
+	raw, err := s.redisClient.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return uuid.Nil, "", fmt.Errorf("invalid or expired session")
+	}
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("failed to validate session: %w", err)
+	}
+
+	var meta sessionMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return uuid.Nil, "", fmt.Errorf("corrupt session data: %w", err)
+	}
+
+	// Touch last-used/IP without resetting the session's remaining TTL.
+	ttl, err := s.redisClient.PTTL(ctx, key).Result()
+	if err == nil && ttl > 0 {
+		meta.LastUsedAt = time.Now()
+		if ip != "" {
+			meta.IP = ip
+		}
+		if data, err := json.Marshal(meta); err == nil {
+			s.redisClient.Set(ctx, key, data, ttl)
+		}
+	}
+
+	return meta.UserID, sessionHash, nil
+}
+
+// RefreshSession consumes rawRefreshToken and issues a new session/refresh
+// pair, rotating the refresh token so a stolen-and-reused one is rejected:
+// the old refresh token is deleted atomically on use, so a second attempt
+// to refresh with it fails even if the first attempt was by an attacker.
+func (s *SessionService) RefreshSession(ctx context.Context, rawRefreshToken, ip string) (sessionToken, refreshToken string, expiresAt time.Time, err error) {
+	raw, err := s.redisClient.GetDel(ctx, refreshKeyPrefix+HashToken(rawRefreshToken)).Bytes()
+	if err == redis.Nil {
+		return "", "", time.Time{}, fmt.Errorf("invalid, expired, or already-used refresh token")
+	}
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to consume refresh token: %w", err)
+	}
+
+	var meta sessionMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("corrupt refresh token data: %w", err)
+	}
+
+	return s.CreateSession(ctx, meta.UserID, ip)
+}
+
+// ListSessions returns every active web session for userID (not including
+// their paired refresh tokens, which aren't independently useful to show).
+func (s *SessionService) ListSessions(ctx context.Context, userID uuid.UUID) ([]SessionInfo, error) {
+	hashes, err := s.redisClient.SMembers(ctx, userSessionsKeyPrefix+userID.String()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions for user: %w", err)
+	}
+
+	sessions := make([]SessionInfo, 0, len(hashes))
+	for _, hash := range hashes {
+		raw, err := s.redisClient.Get(ctx, sessionKeyPrefix+hash).Bytes()
+		if err == redis.Nil {
+			continue // expired, or this hash is a refresh token's
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load session: %w", err)
+		}
+		var meta sessionMeta
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			continue
+		}
+		sessions = append(sessions, SessionInfo{Hash: hash, IP: meta.IP, CreatedAt: meta.CreatedAt, LastUsedAt: meta.LastUsedAt})
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession deletes a session token, for logout. It doesn't touch the
+// paired refresh token; a client that still holds it can silently mint a
+// new session, so callers that want a hard logout should also discard the
+// refresh token client-side.
+func (s *SessionService) RevokeSession(ctx context.Context, rawToken string) error {
+	if err := s.redisClient.Del(ctx, sessionKeyPrefix+HashToken(rawToken)).Err(); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllSessions deletes every session and refresh token issued to
+// userID, for use after a password reset so anyone who was already logged
+// in is forced to authenticate again with the new password.
+func (s *SessionService) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	indexKey := userSessionsKeyPrefix + userID.String()
+	hashes, err := s.redisClient.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list sessions for user: %w", err)
+	}
+
+	keys := make([]string, 0, len(hashes)*2)
+	for _, hash := range hashes {
+		keys = append(keys, sessionKeyPrefix+hash, refreshKeyPrefix+hash)
+	}
+	keys = append(keys, indexKey)
+
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := s.redisClient.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to revoke sessions for user: %w", err)
+	}
+	return nil
+}
+
+// RevokeSessionsExcept deletes every session and refresh token issued to
+// userID except the pair the caller is currently using (identified by
+// currentSessionHash), for a "log out everywhere else" button. It returns
+// the number of session/refresh entries revoked (two per other login).
+func (s *SessionService) RevokeSessionsExcept(ctx context.Context, userID uuid.UUID, currentSessionHash string) (int, error) {
+	indexKey := userSessionsKeyPrefix + userID.String()
+	hashes, err := s.redisClient.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions for user: %w", err)
+	}
+
+	keep := map[string]bool{currentSessionHash: true}
+	if raw, err := s.redisClient.Get(ctx, sessionKeyPrefix+currentSessionHash).Bytes(); err == nil {
+		var meta sessionMeta
+		if err := json.Unmarshal(raw, &meta); err == nil {
+			keep[meta.PairHash] = true
+		}
+	}
+
+	revoked := 0
+	keys := make([]string, 0, len(hashes)*2)
+	for _, hash := range hashes {
+		if keep[hash] {
+			continue
+		}
+		keys = append(keys, sessionKeyPrefix+hash, refreshKeyPrefix+hash)
+		if err := s.redisClient.SRem(ctx, indexKey, hash).Err(); err != nil {
+			return revoked, fmt.Errorf("failed to unindex session: %w", err)
+		}
+		revoked++
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if err := s.redisClient.Del(ctx, keys...).Err(); err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions for user: %w", err)
+	}
+	return revoked, nil
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// wsTicketTTL is how long a WebSocket ticket is valid for before it
+// expires unused.
+const wsTicketTTL = 30 * time.Second
+
+// wsTicketKeyPrefix namespaces WebSocket tickets in Redis.
+const wsTicketKeyPrefix = "swiftlog:ws-ticket:"
+
+// TicketService issues and consumes short-lived, single-use WebSocket
+// tickets, so a browser client doesn't need to put its long-lived API
+// token in the ws:// URL where it leaks into proxy logs, browser history,
+// and Referer headers.
+type TicketService struct {
+	redisClient *redis.Client
+}
+
+// NewTicketService creates a new ticket service
+func NewTicketService(redisClient *redis.Client) *TicketService {
+	return &TicketService{redisClient: redisClient}
+}
+
+// IssueTicket creates a new ticket for userID, valid for wsTicketTTL and
+// usable exactly once, for the caller to embed in a WebSocket URL in place
+// of its API token.
+func (s *TicketService) IssueTicket(ctx context.Context, userID uuid.UUID) (string, error) {
+	ticket, err := GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate ws ticket: %w", err)
+	}
+
+	if err := s.redisClient.Set(ctx, wsTicketKeyPrefix+ticket, userID.String(), wsTicketTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store ws ticket: %w", err)
+	}
+
+	return ticket, nil
+}
+
+// ConsumeTicket atomically fetches and deletes ticket, so a second
+// attempt to use the same ticket (a replay) is rejected once the first
+// consumer has claimed it. Returns the user ID it was issued for.
+func (s *TicketService) ConsumeTicket(ctx context.Context, ticket string) (uuid.UUID, error) {
+	rawUserID, err := s.redisClient.GetDel(ctx, wsTicketKeyPrefix+ticket).Result()
+	if err == redis.Nil {
+		return uuid.Nil, fmt.Errorf("invalid, expired, or already-used ticket")
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to consume ws ticket: %w", err)
+	}
+
+	userID, err := uuid.Parse(rawUserID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("corrupt ws ticket data: %w", err)
+	}
+
+	return userID, nil
+}
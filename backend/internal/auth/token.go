@@ -5,37 +5,194 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
-	"encoding/base64"
 	"fmt"
+	"hash/crc32"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/aliancn/swiftlog/backend/internal/models"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 )
 
 const (
-	// TokenByteLength is the length of the raw token in bytes (32 bytes = 256 bits)
-	TokenByteLength = 32
-	// TokenStringLength is the length of the base64-encoded token (44 characters)
-	TokenStringLength = 44
+	// TokenPrefix identifies a token as a SwiftLog token in a CI log or
+	// secret scanner. Tokens issued before this format existed have no
+	// prefix; ValidateToken still accepts them.
+	TokenPrefix = "slg_"
+	// tokenBodyLength is the number of random base62 characters in a token,
+	// not counting the prefix or checksum.
+	tokenBodyLength = 32
+	// tokenChecksumLength is the number of trailing checksum characters used
+	// to reject an obviously mistyped token before it reaches the database.
+	tokenChecksumLength = 4
+
+	base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// defaultRotationOverlap is how long a rotated-out token keeps
+	// validating after RotateToken issues its replacement, so a caller
+	// mid-flight with the old token gets a grace window to pick up the new
+	// one. Overridable via SetRotationOverlap.
+	defaultRotationOverlap = 1 * time.Hour
+
+	// tokenInvalidationChannel is the Redis pub/sub channel TokenService
+	// broadcasts on when a token is revoked, so other nodes evict it from
+	// their local cache instead of waiting for tokenCacheTTL to expire it.
+	tokenInvalidationChannel = "swiftlog:token:invalidate"
 )
 
 // TokenService handles API token operations
 type TokenService struct {
-	db *sql.DB
+	db              *sql.DB
+	rotationOverlap time.Duration
+	cache           *tokenCache
+	redisClient     *redis.Client
 }
 
 // NewTokenService creates a new token service
 func NewTokenService(db *sql.DB) *TokenService {
-	return &TokenService{db: db}
+	return &TokenService{
+		db:              db,
+		rotationOverlap: defaultRotationOverlap,
+		cache:           newTokenCache(tokenCacheCapacity),
+	}
 }
 
-// GenerateToken generates a new random API token
-func GenerateToken() (string, error) {
-	bytes := make([]byte, TokenByteLength)
-	if _, err := rand.Read(bytes); err != nil {
+// SetRotationOverlap overrides how long a rotated-out token keeps validating
+// after RotateToken issues its replacement.
+func (s *TokenService) SetRotationOverlap(d time.Duration) {
+	s.rotationOverlap = d
+}
+
+// SetRedisClient enables cross-node cache invalidation: revocations on this
+// node are broadcast on tokenInvalidationChannel, and StartInvalidationListener
+// can be called to apply broadcasts from other nodes to this node's cache.
+// Without it, ValidateToken's local cache still self-expires within
+// tokenCacheTTL/tokenCacheNegativeTTL - invalidation is just not immediate
+// across nodes.
+func (s *TokenService) SetRedisClient(redisClient *redis.Client) {
+	s.redisClient = redisClient
+}
+
+// StartInvalidationListener listens for token-cache invalidations broadcast
+// by other nodes (see invalidate) and evicts them from this node's local
+// cache. Call once per process for the service's lifetime; a no-op unless
+// SetRedisClient has been called. It blocks until the subscription is
+// actually live, so an invalidate published right after this call returns
+// can't be silently dropped by a SUBSCRIBE that hasn't reached Redis yet -
+// pub/sub has no backlog or redelivery to fall back on.
+func (s *TokenService) StartInvalidationListener(ctx context.Context) {
+	if s.redisClient == nil {
+		return
+	}
+
+	pubsub := s.redisClient.Subscribe(ctx, tokenInvalidationChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		log.Printf("Failed to establish token invalidation subscription: %v", err)
+	}
+
+	go func() {
+		defer pubsub.Close()
+
+		msgCh := pubsub.Channel()
+		for {
+			select {
+			case msg := <-msgCh:
+				if msg == nil {
+					return
+				}
+				s.cache.delete(msg.Payload)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// invalidate evicts tokenHash from the local cache immediately and, if
+// SetRedisClient has been called, broadcasts the invalidation so other
+// nodes drop it too rather than serving a revoked token until its cache
+// entry naturally expires.
+func (s *TokenService) invalidate(ctx context.Context, tokenHash string) {
+	s.cache.delete(tokenHash)
+	if s.redisClient == nil {
+		return
+	}
+	if err := s.redisClient.Publish(ctx, tokenInvalidationChannel, tokenHash).Err(); err != nil {
+		log.Printf("Failed to broadcast token invalidation: %v", err)
+	}
+}
+
+func randomBase62(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
 		return "", fmt.Errorf("failed to generate random token: %w", err)
 	}
-	return base64.URLEncoding.EncodeToString(bytes), nil
+	out := make([]byte, n)
+	for i, b := range raw {
+		out[i] = base62Alphabet[int(b)%len(base62Alphabet)]
+	}
+	return string(out), nil
+}
+
+// tokenChecksum derives a short base62 checksum from a token body, so a
+// mistyped token can be rejected without a database round trip.
+func tokenChecksum(body string) string {
+	sum := crc32.ChecksumIEEE([]byte(body))
+	buf := make([]byte, tokenChecksumLength)
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = base62Alphabet[sum%uint32(len(base62Alphabet))]
+		sum /= uint32(len(base62Alphabet))
+	}
+	return string(buf)
+}
+
+// GenerateToken generates a new random API token in the
+// slg_<body><checksum> format.
+func GenerateToken() (string, error) {
+	body, err := randomBase62(tokenBodyLength)
+	if err != nil {
+		return "", err
+	}
+	return TokenPrefix + body + tokenChecksum(body), nil
+}
+
+// csrfTokenLength is the number of random base62 characters in a CSRF
+// double-submit token; it doesn't need the API token's prefix/checksum
+// since it's never typed or logged, only echoed by the frontend.
+const csrfTokenLength = 32
+
+// GenerateCSRFToken generates a new random double-submit CSRF token, for
+// middleware.RequireCSRFToken.
+func GenerateCSRFToken() (string, error) {
+	return randomBase62(csrfTokenLength)
+}
+
+// IsWellFormed reports whether token has a valid slg_ prefix and checksum.
+// Tokens issued before this format existed have neither; they're accepted
+// here so ValidateToken remains the sole source of truth on whether they're
+// actually valid, during the transition period.
+func IsWellFormed(token string) bool {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return true
+	}
+	rest := token[len(TokenPrefix):]
+	if len(rest) != tokenBodyLength+tokenChecksumLength {
+		return false
+	}
+	body, checksum := rest[:tokenBodyLength], rest[tokenBodyLength:]
+	return checksum == tokenChecksum(body)
+}
+
+// DisplayHint returns the first 8 and last 4 characters of a raw token,
+// safe to store and show back to a user so they can tell their tokens
+// apart in ListTokens without re-exposing the secret.
+func DisplayHint(rawToken string) string {
+	if len(rawToken) <= 12 {
+		return rawToken
+	}
+	return rawToken[:8] + "..." + rawToken[len(rawToken)-4:]
 }
 
 // HashToken creates a SHA-256 hash of the token for storage
@@ -54,18 +211,20 @@ func (s *TokenService) CreateToken(ctx context.Context, userID uuid.UUID, name s
 
 	// Hash the token for storage
 	tokenHash := HashToken(rawToken)
+	tokenHint := DisplayHint(rawToken)
 
 	// Store the token
 	token := &models.APIToken{}
 	query := `
-		INSERT INTO api_tokens (user_id, token_hash, name)
-		VALUES ($1, $2, $3)
-		RETURNING id, user_id, token_hash, name, created_at
+		INSERT INTO api_tokens (user_id, token_hash, token_hint, name)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, user_id, token_hash, token_hint, name, created_at
 	`
-	err = s.db.QueryRowContext(ctx, query, userID, tokenHash, name).Scan(
+	err = s.db.QueryRowContext(ctx, query, userID, tokenHash, tokenHint, name).Scan(
 		&token.ID,
 		&token.UserID,
 		&token.TokenHash,
+		&token.TokenHint,
 		&token.Name,
 		&token.CreatedAt,
 	)
@@ -76,39 +235,74 @@ func (s *TokenService) CreateToken(ctx context.Context, userID uuid.UUID, name s
 	return rawToken, token, nil
 }
 
-// ValidateToken validates an API token and returns the associated user ID
-func (s *TokenService) ValidateToken(ctx context.Context, rawToken string) (uuid.UUID, error) {
+// ValidateToken validates an API token and returns the associated user and
+// token IDs. A malformed slg_-prefixed token (bad checksum, wrong length)
+// is rejected before it reaches the database. ip is recorded as the
+// token's last-used IP for display in the user's token list; pass "" if
+// unknown.
+//
+// A recent result (positive or negative) is served from an in-process
+// cache instead of hitting the database again; see tokenCache. Revoking or
+// rotating a token evicts it from the cache immediately (invalidate), so
+// this doesn't delay a revocation taking effect - it only avoids repeat
+// database round trips for tokens that haven't changed. On a cache hit,
+// the last_used_at/last_used_ip tracking write is skipped, so those fields
+// can lag by up to tokenCacheTTL under sustained traffic from the same
+// token.
+func (s *TokenService) ValidateToken(ctx context.Context, rawToken, ip string) (userID, tokenID uuid.UUID, err error) {
+	if !IsWellFormed(rawToken) {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("invalid token")
+	}
+
 	tokenHash := HashToken(rawToken)
 
-	var userID uuid.UUID
+	if cached, ok := s.cache.get(tokenHash); ok {
+		if !cached.found {
+			return uuid.Nil, uuid.Nil, fmt.Errorf("invalid token")
+		}
+		return cached.userID, cached.tokenID, nil
+	}
+
 	query := `
-		SELECT user_id
+		SELECT id, user_id
 		FROM api_tokens
-		WHERE token_hash = $1
+		WHERE token_hash = $1 AND (expires_at IS NULL OR expires_at > NOW())
 	`
-	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&userID)
+	err = s.db.QueryRowContext(ctx, query, tokenHash).Scan(&tokenID, &userID)
 	if err == sql.ErrNoRows {
-		return uuid.Nil, fmt.Errorf("invalid token")
+		s.cache.set(tokenHash, tokenCacheResult{found: false, expiresAt: time.Now().Add(tokenCacheNegativeTTL)})
+		return uuid.Nil, uuid.Nil, fmt.Errorf("invalid token")
 	}
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("failed to validate token: %w", err)
+		return uuid.Nil, uuid.Nil, fmt.Errorf("failed to validate token: %w", err)
 	}
 
-	return userID, nil
+	s.cache.set(tokenHash, tokenCacheResult{userID: userID, tokenID: tokenID, found: true, expiresAt: time.Now().Add(tokenCacheTTL)})
+
+	// Best-effort; a failure here shouldn't fail the request it's serving.
+	if _, err := s.db.ExecContext(ctx, `UPDATE api_tokens SET last_used_at = NOW(), last_used_ip = $1 WHERE token_hash = $2`, ip, tokenHash); err != nil {
+		log.Printf("Failed to record token last-used: %v", err)
+	}
+
+	return userID, tokenID, nil
 }
 
 // GetTokenByID retrieves a token by ID
 func (s *TokenService) GetTokenByID(ctx context.Context, tokenID uuid.UUID) (*models.APIToken, error) {
 	token := &models.APIToken{}
 	query := `
-		SELECT id, user_id, name, created_at
+		SELECT id, user_id, token_hint, name, expires_at, last_used_at, last_used_ip, created_at
 		FROM api_tokens
 		WHERE id = $1
 	`
 	err := s.db.QueryRowContext(ctx, query, tokenID).Scan(
 		&token.ID,
 		&token.UserID,
+		&token.TokenHint,
 		&token.Name,
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.LastUsedIP,
 		&token.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -121,30 +315,132 @@ func (s *TokenService) GetTokenByID(ctx context.Context, tokenID uuid.UUID) (*mo
 	return token, nil
 }
 
+// RotateToken issues a replacement for tokenID under the same name and
+// schedules the old token to stop validating after the configured overlap,
+// rather than revoking it immediately - giving a caller mid-flight with the
+// old token a grace window to pick up the new one.
+func (s *TokenService) RotateToken(ctx context.Context, tokenID uuid.UUID) (string, *models.APIToken, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to begin rotation: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID uuid.UUID
+	var name string
+	err = tx.QueryRowContext(ctx, `SELECT user_id, name FROM api_tokens WHERE id = $1`, tokenID).Scan(&userID, &name)
+	if err == sql.ErrNoRows {
+		return "", nil, fmt.Errorf("token not found")
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load token: %w", err)
+	}
+
+	rawToken, err := GenerateToken()
+	if err != nil {
+		return "", nil, err
+	}
+	tokenHash := HashToken(rawToken)
+	tokenHint := DisplayHint(rawToken)
+
+	newToken := &models.APIToken{}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO api_tokens (user_id, token_hash, token_hint, name)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, user_id, token_hash, token_hint, name, created_at
+	`, userID, tokenHash, tokenHint, name).Scan(
+		&newToken.ID,
+		&newToken.UserID,
+		&newToken.TokenHash,
+		&newToken.TokenHint,
+		&newToken.Name,
+		&newToken.CreatedAt,
+	)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create replacement token: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.rotationOverlap)
+	if _, err := tx.ExecContext(ctx, `UPDATE api_tokens SET expires_at = $1 WHERE id = $2`, expiresAt, tokenID); err != nil {
+		return "", nil, fmt.Errorf("failed to schedule old token expiry: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", nil, fmt.Errorf("failed to commit rotation: %w", err)
+	}
+
+	return rawToken, newToken, nil
+}
+
 // RevokeToken deletes an API token
 func (s *TokenService) RevokeToken(ctx context.Context, tokenID uuid.UUID) error {
-	query := `DELETE FROM api_tokens WHERE id = $1`
-	result, err := s.db.ExecContext(ctx, query, tokenID)
+	var tokenHash string
+	err := s.db.QueryRowContext(ctx, `DELETE FROM api_tokens WHERE id = $1 RETURNING token_hash`, tokenID).Scan(&tokenHash)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("token not found")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to revoke token: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	s.invalidate(ctx, tokenHash)
+	return nil
+}
+
+// RevokeAllTokensForUser deletes every API token belonging to userID, for
+// use after a password reset so a stolen password can't be paired with a
+// still-valid long-lived token.
+func (s *TokenService) RevokeAllTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	hashes, err := s.deleteTokens(ctx, `DELETE FROM api_tokens WHERE user_id = $1 RETURNING token_hash`, userID)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to revoke tokens for user: %w", err)
+	}
+	for _, hash := range hashes {
+		s.invalidate(ctx, hash)
 	}
+	return nil
+}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("token not found")
+// RevokeTokensExcept deletes every API token belonging to userID except
+// exceptTokenID, for a "log out everywhere else" button that should also
+// invalidate the user's other CLI/CI tokens, not just their web sessions.
+// It returns the number of tokens revoked.
+func (s *TokenService) RevokeTokensExcept(ctx context.Context, userID, exceptTokenID uuid.UUID) (int, error) {
+	hashes, err := s.deleteTokens(ctx, `DELETE FROM api_tokens WHERE user_id = $1 AND id != $2 RETURNING token_hash`, userID, exceptTokenID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke tokens for user: %w", err)
+	}
+	for _, hash := range hashes {
+		s.invalidate(ctx, hash)
 	}
+	return len(hashes), nil
+}
 
-	return nil
+// deleteTokens runs a DELETE ... RETURNING token_hash query and collects
+// the hashes of the rows it removed, so callers can evict each one from
+// the validation cache.
+func (s *TokenService) deleteTokens(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
 }
 
 // ListTokensByUserID retrieves all tokens for a user (without the token hash)
 func (s *TokenService) ListTokensByUserID(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error) {
 	query := `
-		SELECT id, user_id, name, created_at
+		SELECT id, user_id, token_hint, name, expires_at, last_used_at, last_used_ip, created_at
 		FROM api_tokens
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -161,7 +457,11 @@ func (s *TokenService) ListTokensByUserID(ctx context.Context, userID uuid.UUID)
 		err := rows.Scan(
 			&token.ID,
 			&token.UserID,
+			&token.TokenHint,
 			&token.Name,
+			&token.ExpiresAt,
+			&token.LastUsedAt,
+			&token.LastUsedIP,
 			&token.CreatedAt,
 		)
 		if err != nil {
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// tokenCacheCapacity bounds how many distinct token hashes TokenService
+	// keeps validation results for at once; least-recently-used entries are
+	// evicted once it's full.
+	tokenCacheCapacity = 4096
+	// tokenCacheTTL is how long a successful validation stays cached before
+	// it's treated as stale and re-checked against the database.
+	tokenCacheTTL = 45 * time.Second
+	// tokenCacheNegativeTTL is how long an unknown/expired token is
+	// remembered as invalid, so brute-force probing with bad tokens doesn't
+	// hit the database on every attempt either. Short, since a token that's
+	// merely not-yet-created shouldn't stay rejected long.
+	tokenCacheNegativeTTL = 10 * time.Second
+)
+
+// tokenCacheResult is a cached ValidateToken outcome, keyed by token hash.
+// found is false for a negative result (unknown or expired token).
+type tokenCacheResult struct {
+	userID    uuid.UUID
+	tokenID   uuid.UUID
+	found     bool
+	expiresAt time.Time
+}
+
+// tokenCache is a small in-process LRU of recent ValidateToken results. It
+// exists to keep the SELECT that runs on every authenticated request from
+// becoming the hottest query in the database; entries expire quickly and
+// are actively evicted on revoke/rotate (see TokenService.invalidate) so a
+// security-sensitive change still takes effect immediately on the node
+// that made it.
+type tokenCache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // front = most recently used
+}
+
+type tokenCacheEntry struct {
+	key    string
+	result tokenCacheResult
+}
+
+func newTokenCache(capacity int) *tokenCache {
+	return &tokenCache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *tokenCache) get(key string) (tokenCacheResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return tokenCacheResult{}, false
+	}
+	entry := el.Value.(*tokenCacheEntry)
+	if time.Now().After(entry.result.expiresAt) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return tokenCacheResult{}, false
+	}
+	c.order.MoveToFront(el)
+	return entry.result, true
+}
+
+func (c *tokenCache) set(key string, result tokenCacheResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*tokenCacheEntry).result = result
+		c.order.MoveToFront(el)
+		return
+	}
+
+	el := c.order.PushFront(&tokenCacheEntry{key: key, result: result})
+	c.items[key] = el
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*tokenCacheEntry).key)
+	}
+}
+
+func (c *tokenCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
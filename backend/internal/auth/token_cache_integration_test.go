@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestTokenService(t *testing.T) (*TokenService, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewTokenService(db), mock
+}
+
+// TestValidateToken_CachesResultAndSkipsSecondQuery is the cache's whole
+// point: a second ValidateToken call for the same token within the TTL
+// must not run another SELECT.
+func TestValidateToken_CachesResultAndSkipsSecondQuery(t *testing.T) {
+	s, mock := newTestTokenService(t)
+	raw, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	hash := HashToken(raw)
+	userID, tokenID := uuid.New(), uuid.New()
+
+	mock.ExpectQuery("SELECT id, user_id FROM api_tokens").
+		WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(tokenID, userID))
+	mock.ExpectExec("UPDATE api_tokens SET last_used_at").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	gotUser, gotToken, err := s.ValidateToken(context.Background(), raw, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("first ValidateToken failed: %v", err)
+	}
+	if gotUser != userID || gotToken != tokenID {
+		t.Fatalf("first ValidateToken = (%s, %s), want (%s, %s)", gotUser, gotToken, userID, tokenID)
+	}
+
+	// A second call must be served entirely from cache: no new expectations
+	// are registered, so an unexpected query would fail ExpectationsWereMet.
+	gotUser, gotToken, err = s.ValidateToken(context.Background(), raw, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("second (cached) ValidateToken failed: %v", err)
+	}
+	if gotUser != userID || gotToken != tokenID {
+		t.Fatalf("second ValidateToken = (%s, %s), want (%s, %s)", gotUser, gotToken, userID, tokenID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet or unexpected database calls: %v", err)
+	}
+}
+
+// TestValidateToken_CachesNegativeResult covers brute-force-probing
+// protection: repeated lookups of an unknown token must not each hit the
+// database either.
+func TestValidateToken_CachesNegativeResult(t *testing.T) {
+	s, mock := newTestTokenService(t)
+	raw, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	hash := HashToken(raw)
+
+	mock.ExpectQuery("SELECT id, user_id FROM api_tokens").
+		WithArgs(hash).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, _, err := s.ValidateToken(context.Background(), raw, "127.0.0.1"); err == nil {
+		t.Fatal("ValidateToken succeeded for an unknown token")
+	}
+	if _, _, err := s.ValidateToken(context.Background(), raw, "127.0.0.1"); err == nil {
+		t.Fatal("second ValidateToken succeeded for an unknown token")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("negative result was not cached, expected a single query: %v", err)
+	}
+}
+
+// TestRevokeToken_InvalidatesCacheImmediately covers the security-sensitive
+// path: a revoked token must stop validating right away, not linger until
+// its cache TTL naturally expires.
+func TestRevokeToken_InvalidatesCacheImmediately(t *testing.T) {
+	s, mock := newTestTokenService(t)
+	raw, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	hash := HashToken(raw)
+	userID, tokenID := uuid.New(), uuid.New()
+
+	mock.ExpectQuery("SELECT id, user_id FROM api_tokens").
+		WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(tokenID, userID))
+	mock.ExpectExec("UPDATE api_tokens SET last_used_at").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, _, err := s.ValidateToken(context.Background(), raw, "127.0.0.1"); err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+
+	mock.ExpectQuery("DELETE FROM api_tokens WHERE id = \\$1 RETURNING token_hash").
+		WithArgs(tokenID).
+		WillReturnRows(sqlmock.NewRows([]string{"token_hash"}).AddRow(hash))
+
+	if err := s.RevokeToken(context.Background(), tokenID); err != nil {
+		t.Fatalf("RevokeToken failed: %v", err)
+	}
+
+	// The cache entry must be gone, so validating the same raw token again
+	// has to hit the database - and find nothing, since it was deleted.
+	mock.ExpectQuery("SELECT id, user_id FROM api_tokens").
+		WithArgs(hash).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, _, err := s.ValidateToken(context.Background(), raw, "127.0.0.1"); err == nil {
+		t.Fatal("ValidateToken succeeded for a token revoked immediately before")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet or unexpected database calls: %v", err)
+	}
+}
+
+// TestInvalidate_BroadcastsToOtherNodes covers the cross-node case: a
+// revocation on one TokenService must, via Redis pub/sub, evict the same
+// token from another TokenService's local cache without that second node
+// ever being told about the revocation directly.
+func TestInvalidate_BroadcastsToOtherNodes(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	newNode := func(t *testing.T) (*TokenService, sqlmock.Sqlmock) {
+		s, mock := newTestTokenService(t)
+		redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { redisClient.Close() })
+		s.SetRedisClient(redisClient)
+		return s, mock
+	}
+
+	nodeA, mockA := newNode(t)
+	nodeB, mockB := newNode(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodeB.StartInvalidationListener(ctx)
+
+	raw, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	hash := HashToken(raw)
+	userID, tokenID := uuid.New(), uuid.New()
+
+	// Warm both nodes' local caches with the same token, as if it had been
+	// validated on each independently.
+	mockA.ExpectQuery("SELECT id, user_id FROM api_tokens").WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(tokenID, userID))
+	mockA.ExpectExec("UPDATE api_tokens SET last_used_at").WillReturnResult(sqlmock.NewResult(0, 1))
+	if _, _, err := nodeA.ValidateToken(ctx, raw, "127.0.0.1"); err != nil {
+		t.Fatalf("nodeA ValidateToken failed: %v", err)
+	}
+
+	mockB.ExpectQuery("SELECT id, user_id FROM api_tokens").WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(tokenID, userID))
+	mockB.ExpectExec("UPDATE api_tokens SET last_used_at").WillReturnResult(sqlmock.NewResult(0, 1))
+	if _, _, err := nodeB.ValidateToken(ctx, raw, "127.0.0.1"); err != nil {
+		t.Fatalf("nodeB ValidateToken failed: %v", err)
+	}
+
+	// Revoking on nodeA must not touch nodeB's database at all - the cache
+	// eviction has to arrive over the pub/sub broadcast.
+	mockA.ExpectQuery("DELETE FROM api_tokens WHERE id = \\$1 RETURNING token_hash").
+		WithArgs(tokenID).
+		WillReturnRows(sqlmock.NewRows([]string{"token_hash"}).AddRow(hash))
+	if err := nodeA.RevokeToken(ctx, tokenID); err != nil {
+		t.Fatalf("RevokeToken on nodeA failed: %v", err)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		if _, ok := nodeB.cache.get(hash); !ok {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for nodeB's cache to be invalidated by the broadcast")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if err := mockA.ExpectationsWereMet(); err != nil {
+		t.Fatalf("nodeA: unmet or unexpected database calls: %v", err)
+	}
+	if err := mockB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("nodeB: unmet or unexpected database calls (broadcast should not touch the database): %v", err)
+	}
+}
+
+// BenchmarkValidateToken_CacheHit measures the cost ValidateToken settles
+// into once a token is warm in cache - no DB round trip on the hot path.
+func BenchmarkValidateToken_CacheHit(b *testing.B) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		b.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	s := NewTokenService(db)
+
+	raw, err := GenerateToken()
+	if err != nil {
+		b.Fatalf("GenerateToken failed: %v", err)
+	}
+	hash := HashToken(raw)
+	userID, tokenID := uuid.New(), uuid.New()
+
+	mock.ExpectQuery("SELECT id, user_id FROM api_tokens").
+		WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(tokenID, userID))
+	mock.ExpectExec("UPDATE api_tokens SET last_used_at").WillReturnResult(sqlmock.NewResult(0, 1))
+	if _, _, err := s.ValidateToken(context.Background(), raw, "127.0.0.1"); err != nil {
+		b.Fatalf("warm-up ValidateToken failed: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := s.ValidateToken(context.Background(), raw, "127.0.0.1"); err != nil {
+			b.Fatalf("ValidateToken failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkValidateToken_DatabaseRoundTrip measures the uncached cost - one
+// SELECT and one best-effort UPDATE per call - for comparison against the
+// cache-hit benchmark above.
+func BenchmarkValidateToken_DatabaseRoundTrip(b *testing.B) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		b.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	s := NewTokenService(db)
+
+	raw, err := GenerateToken()
+	if err != nil {
+		b.Fatalf("GenerateToken failed: %v", err)
+	}
+	hash := HashToken(raw)
+	userID, tokenID := uuid.New(), uuid.New()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Bypass the cache entirely, as if every request were the first for
+		// a distinct token, to isolate the database round trip's cost.
+		s.cache.delete(hash)
+		mock.ExpectQuery("SELECT id, user_id FROM api_tokens").
+			WithArgs(hash).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(tokenID, userID))
+		mock.ExpectExec("UPDATE api_tokens SET last_used_at").WillReturnResult(sqlmock.NewResult(0, 1))
+		if _, _, err := s.ValidateToken(context.Background(), raw, "127.0.0.1"); err != nil {
+			b.Fatalf("ValidateToken failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTokenCache_SetGet(t *testing.T) {
+	c := newTokenCache(4)
+	want := tokenCacheResult{userID: uuid.New(), tokenID: uuid.New(), found: true, expiresAt: time.Now().Add(time.Minute)}
+	c.set("hash-a", want)
+
+	got, ok := c.get("hash-a")
+	if !ok {
+		t.Fatal("get returned ok=false for a key that was just set")
+	}
+	if got.userID != want.userID || got.tokenID != want.tokenID {
+		t.Fatalf("get returned %+v, want %+v", got, want)
+	}
+}
+
+func TestTokenCache_MissOnUnknownKey(t *testing.T) {
+	c := newTokenCache(4)
+	if _, ok := c.get("never-set"); ok {
+		t.Fatal("get returned ok=true for a key that was never set")
+	}
+}
+
+func TestTokenCache_ExpiredEntryIsEvictedOnGet(t *testing.T) {
+	c := newTokenCache(4)
+	c.set("hash-a", tokenCacheResult{found: true, expiresAt: time.Now().Add(-time.Second)})
+
+	if _, ok := c.get("hash-a"); ok {
+		t.Fatal("get returned ok=true for an already-expired entry")
+	}
+	// The expired entry must actually be removed, not just skipped, so it
+	// doesn't keep occupying capacity.
+	c.mu.Lock()
+	_, stillPresent := c.items["hash-a"]
+	c.mu.Unlock()
+	if stillPresent {
+		t.Fatal("expired entry was not evicted from the backing map")
+	}
+}
+
+func TestTokenCache_NegativeResultCaches(t *testing.T) {
+	c := newTokenCache(4)
+	c.set("bad-hash", tokenCacheResult{found: false, expiresAt: time.Now().Add(time.Minute)})
+
+	got, ok := c.get("bad-hash")
+	if !ok {
+		t.Fatal("get returned ok=false for a cached negative result")
+	}
+	if got.found {
+		t.Fatal("cached negative result reported found=true")
+	}
+}
+
+func TestTokenCache_EvictsLeastRecentlyUsedWhenFull(t *testing.T) {
+	c := newTokenCache(2)
+	future := time.Now().Add(time.Minute)
+	c.set("a", tokenCacheResult{found: true, expiresAt: future})
+	c.set("b", tokenCacheResult{found: true, expiresAt: future})
+
+	// Touch "a" so "b" becomes the least recently used.
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("get(a) unexpectedly missed")
+	}
+	c.set("c", tokenCacheResult{found: true, expiresAt: future})
+
+	if _, ok := c.get("b"); ok {
+		t.Fatal("get(b) hit after it should have been evicted as least recently used")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("get(a) missed after it was the most recently used entry")
+	}
+	if _, ok := c.get("c"); !ok {
+		t.Fatal("get(c) missed right after being set")
+	}
+}
+
+func TestTokenCache_Delete(t *testing.T) {
+	c := newTokenCache(4)
+	c.set("hash-a", tokenCacheResult{found: true, expiresAt: time.Now().Add(time.Minute)})
+	c.delete("hash-a")
+
+	if _, ok := c.get("hash-a"); ok {
+		t.Fatal("get returned ok=true for a deleted entry")
+	}
+	// Deleting a key that was never present must not panic.
+	c.delete("never-set")
+}
@@ -0,0 +1,182 @@
+package config
+
+import "time"
+
+// OIDCConfig is the API server's optional single sign-on configuration.
+// Enabled is derived, not read directly, from OIDC_ISSUER/OIDC_CLIENT_ID
+// both being set.
+type OIDCConfig struct {
+	Enabled         bool
+	Issuer          string
+	ClientID        string
+	ClientSecret    string
+	RedirectURL     string
+	DomainAllowlist []string
+	AdminGroupClaim string
+	AdminGroups     []string
+	Only            bool
+}
+
+func (c OIDCConfig) Summary() map[string]string {
+	return map[string]string{
+		"OIDC_ISSUER":            c.Issuer,
+		"OIDC_CLIENT_ID":         c.ClientID,
+		"OIDC_CLIENT_SECRET":     redactSecret(c.ClientSecret),
+		"OIDC_REDIRECT_URL":      c.RedirectURL,
+		"OIDC_DOMAIN_ALLOWLIST":  joinOrNone(c.DomainAllowlist),
+		"OIDC_ADMIN_GROUP_CLAIM": c.AdminGroupClaim,
+		"OIDC_ADMIN_GROUPS":      joinOrNone(c.AdminGroups),
+		"OIDC_ONLY":              boolStr(c.Only),
+	}
+}
+
+// SMTPConfig is the API server's outgoing-mail configuration, used for
+// password reset and invite emails.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (c SMTPConfig) Summary() map[string]string {
+	return map[string]string{
+		"SMTP_HOST":     c.Host,
+		"SMTP_PORT":     c.Port,
+		"SMTP_USERNAME": c.Username,
+		"SMTP_PASSWORD": redactSecret(c.Password),
+		"SMTP_FROM":     c.From,
+	}
+}
+
+// AdminConfig seeds the initial admin account on first boot. An empty
+// Password means "generate one at startup" rather than falling back to a
+// well-known default; see cmd/api's initializeAdmin.
+type AdminConfig struct {
+	Username     string
+	Password     string
+	PasswordFile string
+}
+
+func (c AdminConfig) Summary() map[string]string {
+	return map[string]string{
+		"ADMIN_USERNAME":      c.Username,
+		"ADMIN_PASSWORD":      redactSecret(c.Password),
+		"ADMIN_PASSWORD_FILE": c.PasswordFile,
+	}
+}
+
+// APIConfig is the api binary's full effective configuration.
+type APIConfig struct {
+	DB          DBConfig
+	Redis       RedisConfig
+	LokiURL     string
+	Port        string
+	Environment string
+	FrontendURL string
+	// CORSOrigins is the set of origins the browser API is served to. A
+	// single hardcoded origin used to be baked into main() directly; this
+	// makes it configurable per deployment instead.
+	CORSOrigins          []string
+	TokenRotationOverlap time.Duration
+	OIDC                 OIDCConfig
+	SMTP                 SMTPConfig
+	Admin                AdminConfig
+	// CSRFTrustedOrigins is checked against a state-changing,
+	// cookie-authenticated request's Origin header by
+	// middleware.RequireCSRFToken. Defaults to CORSOrigins, since a browser
+	// origin allowed to call the API cross-origin is the same set that
+	// should be trusted to carry the CSRF cookie.
+	CSRFTrustedOrigins []string
+}
+
+// LoadAPIConfig reads and validates the api binary's configuration from
+// the environment, returning every invalid or missing variable together
+// rather than failing on the first one found.
+func LoadAPIConfig() (*APIConfig, error) {
+	l := NewLoader()
+
+	oidcIssuer := l.String("OIDC_ISSUER", "")
+	oidcClientID := l.String("OIDC_CLIENT_ID", "")
+	corsOrigins := l.StringList("CORS_ORIGINS", []string{"http://localhost:3000"})
+
+	cfg := &APIConfig{
+		DB:                   l.dbConfig("API"),
+		Redis:                l.redisConfig(),
+		LokiURL:              l.URL("LOKI_URL", "http://localhost:3100"),
+		Port:                 l.Port("API_PORT", "8080"),
+		Environment:          l.String("ENVIRONMENT", "development"),
+		FrontendURL:          l.URL("FRONTEND_URL", "http://localhost:3000"),
+		CORSOrigins:          corsOrigins,
+		TokenRotationOverlap: l.Duration("TOKEN_ROTATION_OVERLAP", 1*time.Hour),
+		CSRFTrustedOrigins:   l.StringList("CSRF_TRUSTED_ORIGINS", corsOrigins),
+		OIDC: OIDCConfig{
+			Enabled:         oidcIssuer != "" && oidcClientID != "",
+			Issuer:          oidcIssuer,
+			ClientID:        oidcClientID,
+			ClientSecret:    l.String("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:     l.String("OIDC_REDIRECT_URL", ""),
+			DomainAllowlist: l.StringList("OIDC_DOMAIN_ALLOWLIST", nil),
+			AdminGroupClaim: l.String("OIDC_ADMIN_GROUP_CLAIM", "groups"),
+			AdminGroups:     l.StringList("OIDC_ADMIN_GROUPS", nil),
+			Only:            l.Bool("OIDC_ONLY", false),
+		},
+		SMTP: SMTPConfig{
+			Host:     l.String("SMTP_HOST", ""),
+			Port:     l.String("SMTP_PORT", "587"),
+			Username: l.String("SMTP_USERNAME", ""),
+			Password: l.String("SMTP_PASSWORD", ""),
+			From:     l.String("SMTP_FROM", ""),
+		},
+		Admin: AdminConfig{
+			Username:     l.String("ADMIN_USERNAME", "admin"),
+			Password:     l.String("ADMIN_PASSWORD", ""),
+			PasswordFile: l.String("ADMIN_PASSWORD_FILE", ""),
+		},
+	}
+
+	return cfg, l.Err()
+}
+
+// Summary returns the effective configuration for --print-config, with
+// every secret-shaped field redacted.
+func (c *APIConfig) Summary() map[string]string {
+	s := map[string]string{
+		"LOKI_URL":               c.LokiURL,
+		"API_PORT":               c.Port,
+		"ENVIRONMENT":            c.Environment,
+		"FRONTEND_URL":           c.FrontendURL,
+		"CORS_ORIGINS":           joinOrNone(c.CORSOrigins),
+		"CSRF_TRUSTED_ORIGINS":   joinOrNone(c.CSRFTrustedOrigins),
+		"TOKEN_ROTATION_OVERLAP": c.TokenRotationOverlap.String(),
+	}
+	merge(s, c.DB.Summary(), c.Redis.Summary(), c.OIDC.Summary(), c.SMTP.Summary(), c.Admin.Summary())
+	return s
+}
+
+func joinOrNone(ss []string) string {
+	if len(ss) == 0 {
+		return "(none)"
+	}
+	out := ss[0]
+	for _, s := range ss[1:] {
+		out += "," + s
+	}
+	return out
+}
+
+func boolStr(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func merge(dst map[string]string, srcs ...map[string]string) {
+	for _, src := range srcs {
+		for k, v := range src {
+			dst[k] = v
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+// IngestorConfig is the ingestor binary's full effective configuration.
+type IngestorConfig struct {
+	DB           DBConfig
+	Redis        RedisConfig
+	LokiURL      string
+	GRPCPort     string
+	LegacyPubsub bool
+
+	// GRPCTLSCert and GRPCTLSKey point to a PEM certificate/key pair the
+	// gRPC server should present. Both must be set to enable TLS; leaving
+	// them empty keeps the server on plaintext, as before.
+	GRPCTLSCert string
+	GRPCTLSKey  string
+}
+
+// LoadIngestorConfig reads and validates the ingestor binary's
+// configuration from the environment.
+func LoadIngestorConfig() (*IngestorConfig, error) {
+	l := NewLoader()
+
+	cfg := &IngestorConfig{
+		DB:       l.dbConfig("INGESTOR"),
+		Redis:    l.redisConfig(),
+		LokiURL:  l.URL("LOKI_URL", "http://localhost:3100"),
+		GRPCPort: l.Port("GRPC_PORT", "50051"),
+		// Once every websocket Hub instance has rolled out per-run pub/sub
+		// subscriptions, set WS_LEGACY_PUBSUB=false to stop publishing to
+		// the old global channel.
+		LegacyPubsub: l.Bool("WS_LEGACY_PUBSUB", true),
+		GRPCTLSCert:  l.String("GRPC_TLS_CERT", ""),
+		GRPCTLSKey:   l.String("GRPC_TLS_KEY", ""),
+	}
+
+	if (cfg.GRPCTLSCert == "") != (cfg.GRPCTLSKey == "") {
+		l.fail("GRPC_TLS_CERT/GRPC_TLS_KEY", "must both be set to enable TLS, or both left empty")
+	}
+
+	return cfg, l.Err()
+}
+
+// Summary returns the effective configuration for --print-config.
+func (c *IngestorConfig) Summary() map[string]string {
+	s := map[string]string{
+		"LOKI_URL":         c.LokiURL,
+		"GRPC_PORT":        c.GRPCPort,
+		"WS_LEGACY_PUBSUB": boolStr(c.LegacyPubsub),
+		"GRPC_TLS_CERT":    c.GRPCTLSCert,
+		"GRPC_TLS_KEY":     c.GRPCTLSKey,
+	}
+	merge(s, c.DB.Summary(), c.Redis.Summary())
+	return s
+}
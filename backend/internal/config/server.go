@@ -0,0 +1,105 @@
+package config
+
+// ServerConfig is the swiftlog-server binary's full effective
+// configuration: one shared DB/Redis/Loki connection plus each
+// component's own settings, reused as-is from the standalone binaries so
+// the combined process behaves identically to running api, ingestor,
+// websocket, and ai-worker separately.
+type ServerConfig struct {
+	DB      DBConfig
+	Redis   RedisConfig
+	LokiURL string
+
+	// EnableAPI, EnableIngestor, EnableWebsocket, and EnableAIWorker let a
+	// deployment run any subset of components in this process, so a
+	// small self-hosted install can start with all four and later split
+	// one back out into its own binary/replica without changing how the
+	// rest are configured.
+	EnableAPI       bool
+	EnableIngestor  bool
+	EnableWebsocket bool
+	EnableAIWorker  bool
+
+	API      *APIConfig
+	Ingestor *IngestorConfig
+	WS       *WSConfig
+	Worker   *WorkerConfig
+}
+
+// LoadServerConfig reads and validates the swiftlog-server binary's
+// configuration from the environment. It loads each component's own
+// config the same way its standalone binary does (so e.g. API_PORT and
+// WS_PORT still pick distinct ports in one process), then overrides
+// DB/Redis/LokiURL on all of them with a single set of values, so every
+// component shares one connection pool and one Redis client rather than
+// each opening its own as it would standalone.
+func LoadServerConfig() (*ServerConfig, error) {
+	l := NewLoader()
+
+	db := l.dbConfig("")
+	redis := l.redisConfig()
+	lokiURL := l.URL("LOKI_URL", "http://localhost:3100")
+
+	cfg := &ServerConfig{
+		DB:      db,
+		Redis:   redis,
+		LokiURL: lokiURL,
+
+		EnableAPI:       l.Bool("ENABLE_API", true),
+		EnableIngestor:  l.Bool("ENABLE_INGESTOR", true),
+		EnableWebsocket: l.Bool("ENABLE_WEBSOCKET", true),
+		EnableAIWorker:  l.Bool("ENABLE_AI_WORKER", true),
+	}
+
+	var errs Errors
+	if e := l.Err(); e != nil {
+		errs = append(errs, e.(Errors)...)
+	}
+
+	apiCfg, err := LoadAPIConfig()
+	if err != nil {
+		errs = append(errs, err.(Errors)...)
+	}
+	ingestorCfg, err := LoadIngestorConfig()
+	if err != nil {
+		errs = append(errs, err.(Errors)...)
+	}
+	wsCfg, err := LoadWSConfig()
+	if err != nil {
+		errs = append(errs, err.(Errors)...)
+	}
+	workerCfg, err := LoadWorkerConfig()
+	if err != nil {
+		errs = append(errs, err.(Errors)...)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	apiCfg.DB, apiCfg.Redis, apiCfg.LokiURL = db, redis, lokiURL
+	ingestorCfg.DB, ingestorCfg.Redis, ingestorCfg.LokiURL = db, redis, lokiURL
+	wsCfg.DB, wsCfg.Redis, wsCfg.LokiURL = db, redis, lokiURL
+	workerCfg.DB, workerCfg.Redis, workerCfg.LokiURL = db, redis, lokiURL
+
+	cfg.API = apiCfg
+	cfg.Ingestor = ingestorCfg
+	cfg.WS = wsCfg
+	cfg.Worker = workerCfg
+
+	return cfg, nil
+}
+
+// Summary returns the effective configuration for --print-config,
+// merging every enabled component's own summary under the shared
+// DB/Redis/Loki values.
+func (c *ServerConfig) Summary() map[string]string {
+	s := map[string]string{
+		"LOKI_URL":         c.LokiURL,
+		"ENABLE_API":       boolStr(c.EnableAPI),
+		"ENABLE_INGESTOR":  boolStr(c.EnableIngestor),
+		"ENABLE_WEBSOCKET": boolStr(c.EnableWebsocket),
+		"ENABLE_AI_WORKER": boolStr(c.EnableAIWorker),
+	}
+	merge(s, c.DB.Summary(), c.Redis.Summary(), c.API.Summary(), c.Ingestor.Summary(), c.WS.Summary(), c.Worker.Summary())
+	return s
+}
@@ -0,0 +1,95 @@
+package config
+
+import "time"
+
+// WSConfig is the websocket binary's full effective configuration.
+type WSConfig struct {
+	DB          DBConfig
+	Redis       RedisConfig
+	LokiURL     string
+	Port        string
+	Environment string
+	CORSOrigins []string
+
+	// AllowQueryToken controls whether ?token= in the WebSocket URL is
+	// still accepted, alongside the Authorization header and ?ticket=.
+	AllowQueryToken bool
+
+	PingPeriod time.Duration
+	PongWait   time.Duration
+	WriteWait  time.Duration
+
+	EnableCompression bool
+	CompressionLevel  int
+
+	BatchWindow time.Duration
+	BatchSize   int
+
+	MaxConnectionsPerUser int
+	MaxConnections        int
+
+	CloseGracePeriod              time.Duration
+	MaxSubscriptionsPerConnection int
+	ShutdownGrace                 time.Duration
+}
+
+// LoadWSConfig reads and validates the websocket binary's configuration
+// from the environment.
+func LoadWSConfig() (*WSConfig, error) {
+	l := NewLoader()
+
+	cfg := &WSConfig{
+		DB:          l.dbConfig("WS"),
+		Redis:       l.redisConfig(),
+		LokiURL:     l.URL("LOKI_URL", "http://localhost:3100"),
+		Port:        l.Port("WS_PORT", "8081"),
+		Environment: l.String("ENVIRONMENT", "development"),
+		CORSOrigins: l.StringList("CORS_ORIGINS", []string{"http://localhost:3000"}),
+
+		AllowQueryToken: l.Bool("WS_ALLOW_QUERY_TOKEN", true),
+
+		PingPeriod: l.Duration("WS_PING_PERIOD", 54*time.Second),
+		PongWait:   l.Duration("WS_PONG_WAIT", 60*time.Second),
+		WriteWait:  l.Duration("WS_WRITE_WAIT", 10*time.Second),
+
+		EnableCompression: l.Bool("WS_ENABLE_COMPRESSION", false),
+		CompressionLevel:  l.Int("WS_COMPRESSION_LEVEL", -1), // flate.DefaultCompression
+
+		BatchWindow: l.Duration("WS_BATCH_WINDOW", 50*time.Millisecond),
+		BatchSize:   l.Int("WS_BATCH_SIZE", 100),
+
+		MaxConnectionsPerUser: l.Int("WS_MAX_CONNECTIONS_PER_USER", 50),
+		MaxConnections:        l.Int("WS_MAX_CONNECTIONS", 5000),
+
+		CloseGracePeriod:              l.Duration("WS_CLOSE_GRACE_PERIOD", 5*time.Minute),
+		MaxSubscriptionsPerConnection: l.Int("WS_MAX_SUBSCRIPTIONS_PER_CONNECTION", 50),
+		ShutdownGrace:                 l.Duration("WS_SHUTDOWN_GRACE", 5*time.Second),
+	}
+
+	return cfg, l.Err()
+}
+
+// Summary returns the effective configuration for --print-config.
+func (c *WSConfig) Summary() map[string]string {
+	s := map[string]string{
+		"LOKI_URL":                            c.LokiURL,
+		"WS_PORT":                             c.Port,
+		"ENVIRONMENT":                         c.Environment,
+		"CORS_ORIGINS":                        joinOrNone(c.CORSOrigins),
+		"WS_ALLOW_QUERY_TOKEN":                boolStr(c.AllowQueryToken),
+		"WS_PING_PERIOD":                      c.PingPeriod.String(),
+		"WS_PONG_WAIT":                        c.PongWait.String(),
+		"WS_WRITE_WAIT":                       c.WriteWait.String(),
+		"WS_ENABLE_COMPRESSION":               boolStr(c.EnableCompression),
+		"WS_COMPRESSION_LEVEL":                itoa(c.CompressionLevel),
+		"WS_BATCH_WINDOW":                     c.BatchWindow.String(),
+		"WS_BATCH_SIZE":                       itoa(c.BatchSize),
+		"WS_MAX_CONNECTIONS_PER_USER":         itoa(c.MaxConnectionsPerUser),
+		"WS_MAX_CONNECTIONS":                  itoa(c.MaxConnections),
+		"WS_CLOSE_GRACE_PERIOD":               c.CloseGracePeriod.String(),
+		"WS_MAX_SUBSCRIPTIONS_PER_CONNECTION": itoa(c.MaxSubscriptionsPerConnection),
+		"WS_SHUTDOWN_GRACE":                   c.ShutdownGrace.String(),
+	}
+	merge(s, c.DB.Summary(), c.Redis.Summary())
+	return s
+}
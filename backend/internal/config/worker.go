@@ -0,0 +1,119 @@
+package config
+
+import "time"
+
+// AutoRetryConfig controls the ai-worker's optional sweep that re-queues
+// recently failed analyses.
+type AutoRetryConfig struct {
+	Enabled   bool
+	Interval  time.Duration
+	Window    time.Duration
+	BatchSize int
+}
+
+// RetentionConfig controls the ai-worker's optional log/run retention
+// cleanup sweep.
+type RetentionConfig struct {
+	Enabled  bool
+	Days     int
+	Interval time.Duration
+}
+
+// WorkerConfig is the ai-worker binary's full effective configuration.
+type WorkerConfig struct {
+	DB           DBConfig
+	Redis        RedisConfig
+	LokiURL      string
+	LegacyPubsub bool
+
+	TaskMaxAge time.Duration
+
+	ReconcileStuckThreshold time.Duration
+	ReconcileInterval       time.Duration
+	ReconcileMaxAttempts    int
+	ReconcileBatchSize      int
+
+	QueueVisibilityTimeout time.Duration
+	QueueReclaimInterval   time.Duration
+
+	AutoRetry AutoRetryConfig
+	Retention RetentionConfig
+
+	// QueueBackend selects the generic task queue's storage: "list" (the
+	// default, a plain Redis list) or "streams" (Redis Streams with a
+	// consumer group, for per-entry delivery tracking across replicas).
+	// AI analysis tasks always use the dedicated list queue regardless.
+	QueueBackend string
+
+	ShutdownGracePeriod time.Duration
+}
+
+// LoadWorkerConfig reads and validates the ai-worker binary's
+// configuration from the environment.
+func LoadWorkerConfig() (*WorkerConfig, error) {
+	l := NewLoader()
+
+	autoRetryEnabled := l.Bool("AI_AUTO_RETRY_FAILED", false)
+	retentionEnabled := l.Bool("AI_RETENTION_CLEANUP_ENABLED", false)
+
+	cfg := &WorkerConfig{
+		DB:           l.dbConfig("AI"),
+		Redis:        l.redisConfig(),
+		LokiURL:      l.URL("LOKI_URL", "http://localhost:3100"),
+		LegacyPubsub: l.Bool("WS_LEGACY_PUBSUB", true),
+
+		TaskMaxAge: l.Duration("AI_TASK_MAX_AGE", 48*time.Hour),
+
+		ReconcileStuckThreshold: l.Duration("AI_RECONCILE_STUCK_THRESHOLD", 15*time.Minute),
+		ReconcileInterval:       l.Duration("AI_RECONCILE_INTERVAL", 5*time.Minute),
+		ReconcileMaxAttempts:    l.Int("AI_RECONCILE_MAX_ATTEMPTS", 3),
+		ReconcileBatchSize:      l.Int("AI_RECONCILE_BATCH_SIZE", 50),
+
+		QueueVisibilityTimeout: l.Duration("AI_QUEUE_VISIBILITY_TIMEOUT", 10*time.Minute),
+		QueueReclaimInterval:   l.Duration("AI_QUEUE_RECLAIM_INTERVAL", 5*time.Minute),
+
+		AutoRetry: AutoRetryConfig{
+			Enabled:   autoRetryEnabled,
+			Interval:  l.Duration("AI_AUTO_RETRY_INTERVAL", 24*time.Hour),
+			Window:    l.Duration("AI_AUTO_RETRY_WINDOW", 24*time.Hour),
+			BatchSize: l.Int("AI_AUTO_RETRY_BATCH_SIZE", 100),
+		},
+		Retention: RetentionConfig{
+			Enabled:  retentionEnabled,
+			Days:     l.Int("AI_RETENTION_DAYS", 90),
+			Interval: l.Duration("AI_RETENTION_CLEANUP_INTERVAL", 24*time.Hour),
+		},
+
+		QueueBackend: l.String("QUEUE_BACKEND", "list"),
+
+		ShutdownGracePeriod: l.Duration("AI_SHUTDOWN_GRACE_PERIOD", 30*time.Second),
+	}
+
+	return cfg, l.Err()
+}
+
+// Summary returns the effective configuration for --print-config.
+func (c *WorkerConfig) Summary() map[string]string {
+	s := map[string]string{
+		"LOKI_URL":                      c.LokiURL,
+		"WS_LEGACY_PUBSUB":              boolStr(c.LegacyPubsub),
+		"AI_TASK_MAX_AGE":               c.TaskMaxAge.String(),
+		"AI_RECONCILE_STUCK_THRESHOLD":  c.ReconcileStuckThreshold.String(),
+		"AI_RECONCILE_INTERVAL":         c.ReconcileInterval.String(),
+		"AI_RECONCILE_MAX_ATTEMPTS":     itoa(c.ReconcileMaxAttempts),
+		"AI_RECONCILE_BATCH_SIZE":       itoa(c.ReconcileBatchSize),
+		"AI_QUEUE_VISIBILITY_TIMEOUT":   c.QueueVisibilityTimeout.String(),
+		"AI_QUEUE_RECLAIM_INTERVAL":     c.QueueReclaimInterval.String(),
+		"AI_AUTO_RETRY_FAILED":          boolStr(c.AutoRetry.Enabled),
+		"AI_AUTO_RETRY_INTERVAL":        c.AutoRetry.Interval.String(),
+		"AI_AUTO_RETRY_WINDOW":          c.AutoRetry.Window.String(),
+		"AI_AUTO_RETRY_BATCH_SIZE":      itoa(c.AutoRetry.BatchSize),
+		"AI_RETENTION_CLEANUP_ENABLED":  boolStr(c.Retention.Enabled),
+		"AI_RETENTION_DAYS":             itoa(c.Retention.Days),
+		"AI_RETENTION_CLEANUP_INTERVAL": c.Retention.Interval.String(),
+		"QUEUE_BACKEND":                 c.QueueBackend,
+		"AI_SHUTDOWN_GRACE_PERIOD":      c.ShutdownGracePeriod.String(),
+	}
+	merge(s, c.DB.Summary(), c.Redis.Summary())
+	return s
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -76,3 +77,91 @@ func New(ctx context.Context, cfg *Config) (*DB, error) {
 func (db *DB) Close() error {
 	return db.DB.Close()
 }
+
+// PoolConfig holds connection pool tuning shared by every binary that opens
+// a *sql.DB from a DSN, so pool sizing lives in one place instead of being
+// hardcoded per binary.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// NewFromURL opens a database connection from a DSN (as opposed to New,
+// which builds one from discrete Config fields), applies pool, and verifies
+// it with a ping. This is the constructor every cmd/*/main.go should use
+// instead of hand-rolling sql.Open plus pool settings locally.
+func NewFromURL(ctx context.Context, dsn string, pool PoolConfig) (*DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &DB{DB: db}, nil
+}
+
+// PoolStatsSnapshot is sql.DBStats reshaped into the gauge names each
+// service's /metrics endpoint (or, for the binaries without one, the log
+// line from WatchPoolStats) reports.
+type PoolStatsSnapshot struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMs     int64 `json:"wait_duration_ms"`
+}
+
+// StatsSnapshot reports the pool's current sql.DBStats, for a /metrics
+// endpoint or a one-off diagnostic.
+func (db *DB) StatsSnapshot() PoolStatsSnapshot {
+	s := db.DB.Stats()
+	return PoolStatsSnapshot{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDurationMs:     s.WaitDuration.Milliseconds(),
+	}
+}
+
+// WatchPoolStats logs the pool's sql.DBStats every interval, and warns
+// whenever WaitDuration grew since the previous tick, which is the early
+// signal that the pool is undersized for the current load (requests are
+// queuing on a connection instead of failing outright) well before
+// exhaustion shows up as request latency elsewhere. It runs until ctx is
+// canceled, so callers can fire-and-forget it alongside a binary's other
+// background loops.
+func (db *DB) WatchPoolStats(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastWait := db.DB.Stats().WaitDuration
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := db.DB.Stats()
+			log.Printf("db pool: open=%d inUse=%d idle=%d maxOpen=%d waitCount=%d waitDuration=%s",
+				stats.OpenConnections, stats.InUse, stats.Idle, stats.MaxOpenConnections, stats.WaitCount, stats.WaitDuration)
+
+			if grew := stats.WaitDuration - lastWait; grew > 0 {
+				log.Printf("Warning: db pool wait duration grew by %s in the last %s (waitCount=%d) — consider raising DB_MAX_OPEN_CONNS or finding the slow query", grew, interval, stats.WaitCount)
+			}
+			lastWait = stats.WaitDuration
+		}
+	}
+}
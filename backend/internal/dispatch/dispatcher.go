@@ -0,0 +1,80 @@
+// Package dispatch provides a generic dispatcher for background jobs
+// published through queue.TaskEnvelope: retention cleanup, bulk delete,
+// webhook delivery, scheduled re-analysis, and future job kinds that don't
+// need their own dedicated queue. AI analysis keeps its own purpose-built
+// dispatch loop in cmd/ai-worker, built around AIAnalysisTask's
+// priority-split queue, DLQ, and reclaim machinery.
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aliancn/swiftlog/backend/internal/queue"
+)
+
+// Handler processes one task envelope for the type it's registered under.
+type Handler func(ctx context.Context, envelope queue.TaskEnvelope) error
+
+// Dispatcher polls the generic task queue and routes each envelope to the
+// handler registered for its Type. It's backend-agnostic: q may be a
+// *queue.Queue (Redis list, the default) or a *queue.StreamsQueue (Redis
+// Streams with a consumer group, selected via QUEUE_BACKEND=streams).
+type Dispatcher struct {
+	queue    queue.TaskQueue
+	handlers map[string]Handler
+}
+
+// NewDispatcher creates a Dispatcher backed by q.
+func NewDispatcher(q queue.TaskQueue) *Dispatcher {
+	return &Dispatcher{queue: q, handlers: make(map[string]Handler)}
+}
+
+// Register associates taskType with handler. An envelope arriving with a
+// Type that has no registered handler is logged and moved straight to the
+// dead-letter queue instead of being dispatched.
+func (d *Dispatcher) Register(taskType string, handler Handler) {
+	d.handlers[taskType] = handler
+}
+
+// Run polls for tasks until ctx is cancelled, dispatching each to its
+// registered handler. A handler error requeues the envelope with Attempts
+// incremented; once that exceeds the queue's retry limit, it lands in the
+// dead-letter queue instead.
+func (d *Dispatcher) Run(ctx context.Context) {
+	const pollTimeout = 5 * time.Second
+
+	for ctx.Err() == nil {
+		envelope, err := d.queue.ConsumeTask(ctx, pollTimeout)
+		if err != nil {
+			log.Printf("Dispatcher: failed to consume task: %v", err)
+			continue
+		}
+		if envelope == nil {
+			continue
+		}
+
+		handler, ok := d.handlers[envelope.Type]
+		if !ok {
+			log.Printf("Dispatcher: no handler registered for task type %q (id %s), moving to dead-letter queue", envelope.Type, envelope.ID)
+			if err := d.queue.PushGenericDLQEntry(ctx, *envelope, fmt.Sprintf("no handler registered for type %q", envelope.Type)); err != nil {
+				log.Printf("Dispatcher: failed to move unroutable task to dead-letter queue: %v", err)
+			}
+			continue
+		}
+
+		if err := handler(ctx, *envelope); err != nil {
+			log.Printf("Dispatcher: handler for %q (id %s) failed: %v", envelope.Type, envelope.ID, err)
+			if err := d.queue.RequeueTask(ctx, *envelope, err.Error()); err != nil {
+				log.Printf("Dispatcher: failed to requeue task %q (id %s): %v", envelope.Type, envelope.ID, err)
+			}
+			continue
+		}
+
+		if err := d.queue.AckTask(ctx, *envelope); err != nil {
+			log.Printf("Dispatcher: failed to ack task %q (id %s): %v", envelope.Type, envelope.ID, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Package email sends transactional email (currently just password reset
+// links) over plain SMTP using the standard library, so instances that
+// configure an SMTP relay don't need the out-of-band admin flow.
+package email
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// Config holds SMTP relay settings, all sourced from environment
+// variables. An instance without SMTP_HOST configured has no email
+// delivery; callers should check Configured() and fall back to handing
+// the user a link out-of-band.
+type Config struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Configured reports whether enough SMTP settings are present to attempt
+// delivery.
+func (c Config) Configured() bool {
+	return c.Host != "" && c.From != ""
+}
+
+// Sender sends email over the configured SMTP relay.
+type Sender struct {
+	cfg Config
+}
+
+// NewSender creates a new SMTP sender.
+func NewSender(cfg Config) *Sender {
+	return &Sender{cfg: cfg}
+}
+
+// SendPasswordReset emails a password reset link to to. It's a no-op error
+// if the sender isn't configured; callers should check Config.Configured
+// before deciding whether to offer this path at all.
+func (s *Sender) SendPasswordReset(to, resetURL string) error {
+	if !s.cfg.Configured() {
+		return fmt.Errorf("smtp is not configured")
+	}
+
+	subject := "Reset your SwiftLog password"
+	body := fmt.Sprintf(
+		"A password reset was requested for your SwiftLog account.\r\n\r\n"+
+			"Reset your password: %s\r\n\r\n"+
+			"This link expires shortly and can only be used once. If you didn't request this, you can ignore this email.\r\n",
+		resetURL,
+	)
+
+	msg := strings.Join([]string{
+		"From: " + s.cfg.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+		"",
+		body,
+	}, "\r\n")
+
+	addr := s.cfg.Host + ":" + s.cfg.Port
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	if err := smtp.SendMail(addr, auth, s.cfg.From, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+	return nil
+}
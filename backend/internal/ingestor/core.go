@@ -0,0 +1,313 @@
+package ingestor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aliancn/swiftlog/backend/internal/loki"
+	"github.com/aliancn/swiftlog/backend/internal/models"
+	"github.com/aliancn/swiftlog/backend/internal/queue"
+	"github.com/aliancn/swiftlog/backend/internal/repository"
+	ws "github.com/aliancn/swiftlog/backend/internal/websocket"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxTags and maxTagKeyLen bound the run tags a client may attach at
+// creation time, so a run can be found later without letting tags grow
+// into an unbounded free-form store.
+const (
+	maxTags      = 20
+	maxTagKeyLen = 64
+)
+
+// ValidateTags checks run tags against the ingestor's limits. Both
+// transports (gRPC StreamMetadata.tags and the HTTP JSON ingest endpoint)
+// call this before StartRun, so an oversized tag set is rejected with a
+// transport-appropriate error (InvalidArgument / 400) instead of being
+// silently truncated or failing deeper in the stack.
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("too many tags: got %d, max %d", len(tags), maxTags)
+	}
+	for key := range tags {
+		if len(key) > maxTagKeyLen {
+			return fmt.Errorf("tag key %q exceeds %d characters", key, maxTagKeyLen)
+		}
+	}
+	return nil
+}
+
+// maxRunNameLen bounds the optional human-friendly name a client may give a
+// run. Unlike the provenance strings below, an oversized name is rejected
+// outright rather than silently truncated, since a truncated name is likely
+// to be confused with a different, shorter one a user actually chose.
+const maxRunNameLen = 200
+
+// ValidateName checks a run's optional name against maxRunNameLen. Both
+// transports call this before StartRun, so an oversized name is rejected
+// with a transport-appropriate error (InvalidArgument / 400) instead of
+// being silently truncated.
+func ValidateName(name string) error {
+	if len([]rune(name)) > maxRunNameLen {
+		return fmt.Errorf("run name exceeds %d characters", maxRunNameLen)
+	}
+	return nil
+}
+
+// maxHostnameLen, maxWorkingDirLen and maxCommandLineLen bound the
+// provenance strings a client may attach to a run, so a client sending an
+// oversized argv or an absurd $PWD can't blow out storage.
+const (
+	maxHostnameLen    = 255
+	maxWorkingDirLen  = 1024
+	maxCommandLineLen = 2048
+)
+
+// sanitizeMetadataString strips control characters (which could otherwise
+// inject terminal escapes into anything that later prints this value) and
+// truncates to maxLen runes, so client-supplied provenance is safe to store
+// and display as-is.
+func sanitizeMetadataString(s string, maxLen int) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	out := b.String()
+	if runes := []rune(out); len(runes) > maxLen {
+		out = string(runes[:maxLen])
+	}
+	return out
+}
+
+// maxLogLineContentLen bounds a single log line's stored content. Clients
+// are expected to split oversized lines into continuation chunks
+// themselves (see the CLI's streamOutput), but the HTTP/JSON ingest
+// endpoint has no such client-side guarantee, so AppendLines truncates
+// rather than rejects — a truncated line is still useful, a dropped batch
+// isn't.
+const maxLogLineContentLen = 1 << 20 // 1MB
+
+// sanitizeLogContent repairs invalid UTF-8 in content by replacing each
+// invalid byte sequence with U+FFFD. This runs server-side, independent of
+// whatever sanitization the CLI already did (see the CLI's
+// sanitizeOutputLine), so an old CLI build or the HTTP/JSON ingest endpoint
+// can't poison a Loki batch with content that fails validation downstream.
+func sanitizeLogContent(content string) string {
+	return strings.ToValidUTF8(content, "\uFFFD")
+}
+
+// truncateLogLine caps content at maxLen runes, appending a marker so a
+// truncated line is distinguishable from one that just happened to end
+// there.
+func truncateLogLine(content string, maxLen int) string {
+	runes := []rune(content)
+	if len(runes) <= maxLen {
+		return content
+	}
+	return string(runes[:maxLen]) + " [truncated]"
+}
+
+// LogLine is one line of a run's output, transport-agnostic: the gRPC
+// stream decodes a pb.LogLine into this, and the HTTP/JSON ingest
+// endpoints decode a JSON object into this, before handing it to Core.
+type LogLine struct {
+	Timestamp time.Time
+	Level     string
+	Content   string
+}
+
+// Core holds the ingestion logic shared by every transport — run creation,
+// log-line batching, and completion handling — so the gRPC LogStreamer
+// service and the HTTP/JSON ingest endpoints can't drift apart.
+type Core struct {
+	logRunRepo   *repository.LogRunRepository
+	projectRepo  *repository.ProjectRepository
+	groupRepo    *repository.LogGroupRepository
+	settingsRepo *repository.SettingsRepository
+	lokiClient   *loki.Client
+	redisClient  *redis.Client
+	taskQueue    *queue.Queue
+	batchSize    int
+}
+
+// NewCore creates an ingest core from Config.
+func NewCore(cfg *Config) *Core {
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 100 // Default from research.md
+	}
+
+	return &Core{
+		logRunRepo:   cfg.LogRunRepo,
+		projectRepo:  cfg.ProjectRepo,
+		groupRepo:    cfg.GroupRepo,
+		settingsRepo: cfg.SettingsRepo,
+		lokiClient:   cfg.LokiClient,
+		redisClient:  cfg.RedisClient,
+		taskQueue:    cfg.TaskQueue,
+		batchSize:    cfg.BatchSize,
+	}
+}
+
+// BatchSize returns the configured Loki flush threshold, so a
+// streaming transport (gRPC) knows when to call AppendLines instead of
+// growing its own buffer forever.
+func (c *Core) BatchSize() int {
+	return c.batchSize
+}
+
+// StartRun resolves (or creates) the project/group named by projectName
+// and groupName, and creates a new log run under it with the AI status
+// implied by the project's effective settings.
+func (c *Core) StartRun(ctx context.Context, userID uuid.UUID, projectName, groupName string, tags map[string]string, hostname, workingDir, commandLine, name string) (*models.LogRun, error) {
+	if projectName == "" {
+		projectName = "default"
+	}
+	if groupName == "" {
+		groupName = "default"
+	}
+
+	project, err := c.projectRepo.GetOrCreate(ctx, userID, projectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get/create project: %w", err)
+	}
+
+	group, err := c.groupRepo.GetOrCreate(ctx, project.ID, groupName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get/create group: %w", err)
+	}
+
+	effectiveSettings, err := c.settingsRepo.GetEffectiveSettings(ctx, project.ID, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to get effective settings, using AIStatusNone", "user_id", userID, "project_id", project.ID, "error", err)
+		effectiveSettings = nil
+	}
+
+	initialAIStatus := models.AIStatusNone
+	if effectiveSettings != nil && effectiveSettings.AIEnabled && effectiveSettings.AIAutoAnalyze {
+		initialAIStatus = models.AIStatusPending
+	}
+
+	hostname = sanitizeMetadataString(hostname, maxHostnameLen)
+	workingDir = sanitizeMetadataString(workingDir, maxWorkingDirLen)
+	commandLine = sanitizeMetadataString(commandLine, maxCommandLineLen)
+	name = sanitizeMetadataString(name, maxRunNameLen)
+
+	logRun, err := c.logRunRepo.Create(ctx, group.ID, initialAIStatus, models.Tags(tags), hostname, workingDir, commandLine, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log run: %w", err)
+	}
+
+	runningStatus := string(models.RunStatusRunning)
+	_ = ws.PublishRunUpdate(ctx, c.redisClient, logRun.ID, &runningStatus, nil, nil, nil)
+
+	return logRun, nil
+}
+
+// AppendLines pushes lines to Loki as a single batch and publishes each one
+// to Redis for real-time WebSocket streaming. The per-run sequence number
+// (which disambiguates lines that land on the same nanosecond timestamp,
+// so WebSocket cursors stay strictly ordered) is allocated from Redis
+// rather than kept in memory, since unlike the gRPC stream — one
+// long-lived call per run — the HTTP ingest endpoint appends to the same
+// run across many independent requests.
+func (c *Core) AppendLines(ctx context.Context, run *models.LogRun, userID uuid.UUID, projectName string, lines []LogLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	seq, err := c.allocateSeq(ctx, run.ID, int64(len(lines)))
+	if err != nil {
+		return err
+	}
+
+	entries := make([]loki.LogEntry, len(lines))
+	for i, line := range lines {
+		content := truncateLogLine(sanitizeLogContent(line.Content), maxLogLineContentLen)
+
+		entries[i] = loki.LogEntry{
+			Timestamp: line.Timestamp,
+			Line:      fmt.Sprintf("[%s:%d] %s", line.Level, seq, content),
+		}
+
+		_ = ws.PublishLog(ctx, c.redisClient, run.ID, line.Timestamp.Format(time.RFC3339Nano), line.Level, content, seq)
+		seq++
+	}
+
+	if err := c.lokiClient.PushLogs(ctx, run.ID, userID, projectName, entries); err != nil {
+		return fmt.Errorf("failed to push logs to Loki: %w", err)
+	}
+
+	return nil
+}
+
+// allocateSeq atomically reserves a contiguous block of n sequence numbers
+// for run and returns the first one.
+func (c *Core) allocateSeq(ctx context.Context, runID uuid.UUID, n int64) (int64, error) {
+	key := fmt.Sprintf("swiftlog:runs:%s:seq", runID)
+	next, err := c.redisClient.IncrBy(ctx, key, n).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate log sequence: %w", err)
+	}
+	return next - n, nil
+}
+
+// signalExitCodeBase and signalExitCodeMax bound the "128+signal" exit code
+// range shells (and the CLI's 'run' command - see cli/cmd/run.go) use to
+// report a process killed by a signal, so CompleteRun can tell that apart
+// from a normal nonzero exit.
+const (
+	signalExitCodeBase = 128
+	signalExitCodeMax  = signalExitCodeBase + 64
+)
+
+// CompleteRun marks run finished — models.RunStatusCompleted if exitCode is
+// 0, models.RunStatusAborted if exitCode falls in the 128+signal range (the
+// command was killed by a forwarded signal rather than exiting on its own),
+// otherwise models.RunStatusFailed — publishes the status change, and kicks
+// off auto-analysis if run's AI status came out of StartRun as pending.
+func (c *Core) CompleteRun(ctx context.Context, run *models.LogRun, userID uuid.UUID, exitCode int32) error {
+	runStatus := models.RunStatusCompleted
+	switch {
+	case exitCode > signalExitCodeBase && exitCode <= signalExitCodeMax:
+		runStatus = models.RunStatusAborted
+	case exitCode != 0:
+		runStatus = models.RunStatusFailed
+	}
+
+	if err := c.logRunRepo.UpdateStatus(ctx, run.ID, runStatus, &exitCode); err != nil {
+		return fmt.Errorf("failed to update run status: %w", err)
+	}
+
+	statusStr := string(runStatus)
+	_ = ws.PublishRunUpdate(ctx, c.redisClient, run.ID, &statusStr, &exitCode, nil, nil)
+
+	if run.AIStatus == models.AIStatusPending && c.taskQueue != nil {
+		slog.InfoContext(ctx, "auto-triggering AI analysis", "run_id", run.ID, "user_id", userID)
+		if err := c.taskQueue.PublishAITask(context.Background(), run.ID, userID, false, "", "", 0, false); err != nil {
+			slog.WarnContext(ctx, "failed to publish AI task", "run_id", run.ID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// AbortRun marks run aborted — the ingestion connection dropped mid-stream
+// without a completion message — and publishes the status change.
+func (c *Core) AbortRun(ctx context.Context, run *models.LogRun) {
+	_ = c.logRunRepo.UpdateStatus(ctx, run.ID, models.RunStatusAborted, nil)
+	statusStr := string(models.RunStatusAborted)
+	_ = ws.PublishRunUpdate(ctx, c.redisClient, run.ID, &statusStr, nil, nil, nil)
+}
+
+// TouchLastSeen records that a message (line, batch, or heartbeat) just
+// arrived on run's stream, without changing anything else about it.
+func (c *Core) TouchLastSeen(ctx context.Context, run *models.LogRun) error {
+	return c.logRunRepo.TouchLastSeen(ctx, run.ID)
+}
@@ -2,35 +2,28 @@ package ingestor
 
 import (
 	"context"
-	"fmt"
 	"io"
-	"log"
 	"time"
 
 	"github.com/aliancn/swiftlog/backend/internal/auth"
 	"github.com/aliancn/swiftlog/backend/internal/loki"
-	"github.com/aliancn/swiftlog/backend/internal/models"
 	"github.com/aliancn/swiftlog/backend/internal/queue"
 	"github.com/aliancn/swiftlog/backend/internal/repository"
-	ws "github.com/aliancn/swiftlog/backend/internal/websocket"
 	pb "github.com/aliancn/swiftlog/backend/proto"
 	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-// Service implements the LogStreamer gRPC service
+// Service implements the LogStreamer gRPC service. All of its ingestion
+// logic (run creation, log batching, completion handling) lives in Core, so
+// the HTTP/JSON ingest endpoints (internal/api/handlers.IngestHandler) stay
+// in lockstep with it instead of reimplementing it.
 type Service struct {
 	pb.UnimplementedLogStreamerServer
-	logRunRepo    *repository.LogRunRepository
-	projectRepo   *repository.ProjectRepository
-	groupRepo     *repository.LogGroupRepository
-	settingsRepo  *repository.SettingsRepository
-	lokiClient    *loki.Client
-	redisClient   *redis.Client
-	taskQueue     *queue.Queue
-	batchSize     int
+	core          *Core
 	batchInterval time.Duration
+	idleTimeout   time.Duration
 }
 
 // Config holds ingestor service configuration
@@ -44,30 +37,35 @@ type Config struct {
 	TaskQueue     *queue.Queue
 	BatchSize     int           // Number of log lines to batch before sending to Loki
 	BatchInterval time.Duration // Maximum time to wait before sending a batch
+	IdleTimeout   time.Duration // How long a stream can go without any message before its run is aborted
 }
 
 // NewService creates a new ingestor service
 func NewService(cfg *Config) *Service {
-	if cfg.BatchSize == 0 {
-		cfg.BatchSize = 100 // Default from research.md
-	}
 	if cfg.BatchInterval == 0 {
 		cfg.BatchInterval = 1 * time.Second
 	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = 90 * time.Second
+	}
 
 	return &Service{
-		logRunRepo:    cfg.LogRunRepo,
-		projectRepo:   cfg.ProjectRepo,
-		groupRepo:     cfg.GroupRepo,
-		settingsRepo:  cfg.SettingsRepo,
-		lokiClient:    cfg.LokiClient,
-		redisClient:   cfg.RedisClient,
-		taskQueue:     cfg.TaskQueue,
-		batchSize:     cfg.BatchSize,
+		core:          NewCore(cfg),
 		batchInterval: cfg.BatchInterval,
+		idleTimeout:   cfg.IdleTimeout,
 	}
 }
 
+// recvResult carries the outcome of a single stream.Recv() call back to
+// StreamLog's select loop, so receiving can run in its own goroutine and
+// leave the loop free to also wait on a batch ticker and an idle timer.
+// gRPC streams have no read-deadline API, so this is the only way to notice
+// "nothing has arrived in a while" without blocking on Recv() forever.
+type recvResult struct {
+	req *pb.StreamLogRequest
+	err error
+}
+
 // StreamLog implements the bidirectional streaming RPC
 func (s *Service) StreamLog(stream pb.LogStreamer_StreamLogServer) error {
 	ctx := stream.Context()
@@ -89,43 +87,22 @@ func (s *Service) StreamLog(stream pb.LogStreamer_StreamLogServer) error {
 		return status.Errorf(codes.InvalidArgument, "first message must contain metadata")
 	}
 
-	// Get or create project and group
 	projectName := metadata.ProjectName
 	if projectName == "" {
 		projectName = "default"
 	}
-	groupName := metadata.GroupName
-	if groupName == "" {
-		groupName = "default"
-	}
-
-	project, err := s.projectRepo.GetOrCreate(ctx, userID, projectName)
-	if err != nil {
-		return status.Errorf(codes.Internal, "failed to get/create project: %v", err)
-	}
-
-	group, err := s.groupRepo.GetOrCreate(ctx, project.ID, groupName)
-	if err != nil {
-		return status.Errorf(codes.Internal, "failed to get/create group: %v", err)
-	}
 
-	// Get effective settings for this user/project to determine initial AI status
-	effectiveSettings, err := s.settingsRepo.GetEffectiveSettings(ctx, project.ID, userID)
-	if err != nil {
-		log.Printf("Warning: failed to get effective settings for user %s, project %s: %v. Using AIStatusNone.", userID, project.ID, err)
-		effectiveSettings = nil
+	if err := ValidateTags(metadata.Tags); err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
 	}
 
-	// Determine initial AI status based on auto-analyze setting
-	initialAIStatus := models.AIStatusNone
-	if effectiveSettings != nil && effectiveSettings.AIEnabled && effectiveSettings.AIAutoAnalyze {
-		initialAIStatus = models.AIStatusPending
+	if err := ValidateName(metadata.Name); err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
 	}
 
-	// Create log run with appropriate AI status
-	logRun, err := s.logRunRepo.Create(ctx, group.ID, initialAIStatus)
+	logRun, err := s.core.StartRun(ctx, userID, projectName, metadata.GroupName, metadata.Tags, metadata.Hostname, metadata.WorkingDir, metadata.CommandLine, metadata.Name)
 	if err != nil {
-		return status.Errorf(codes.Internal, "failed to create log run: %v", err)
+		return status.Errorf(codes.Internal, "%v", err)
 	}
 
 	// Send StreamStarted response
@@ -141,7 +118,7 @@ func (s *Service) StreamLog(stream pb.LogStreamer_StreamLogServer) error {
 	}
 
 	// Start receiving log lines
-	logBatch := make([]loki.LogEntry, 0, s.batchSize)
+	logBatch := make([]LogLine, 0, s.core.BatchSize())
 	batchTicker := time.NewTicker(s.batchInterval)
 	defer batchTicker.Stop()
 
@@ -149,96 +126,142 @@ func (s *Service) StreamLog(stream pb.LogStreamer_StreamLogServer) error {
 		if len(logBatch) == 0 {
 			return nil
 		}
-		if err := s.lokiClient.PushLogs(ctx, logRun.ID, userID, projectName, logBatch); err != nil {
-			return fmt.Errorf("failed to push logs to Loki: %w", err)
+		if err := s.core.AppendLines(ctx, logRun, userID, projectName, logBatch); err != nil {
+			return err
 		}
 		logBatch = logBatch[:0] // Clear batch
 		return nil
 	}
 
+	// Recv() has no read-deadline, so it runs on its own goroutine and hands
+	// each result back over a channel; that lets the select loop below also
+	// watch an idle timer without blocking indefinitely on a quiet stream.
+	recvCh := make(chan recvResult)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			select {
+			case recvCh <- recvResult{req: req, err: err}:
+			case <-ctx.Done():
+				// StreamLog already returned and no one is reading recvCh
+				// anymore; give up instead of blocking forever.
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	idleTimer := time.NewTimer(s.idleTimeout)
+	defer idleTimer.Stop()
+
 	// Process incoming log lines
 	for {
 		select {
 		case <-ctx.Done():
-			// Context cancelled, flush remaining logs
+			// Context cancelled (client disconnected, server shutting down)
+			// without a completion message; flush what's buffered and mark
+			// the run aborted the same way a dropped connection or idle
+			// timeout already does. ctx is already done, so AbortRun's
+			// repository/publish calls use context.Background() instead -
+			// the same trade-off CompleteRun makes for its post-completion
+			// AI task publish.
 			_ = flushBatch()
+			s.core.AbortRun(context.Background(), logRun)
 			return status.Errorf(codes.Canceled, "stream cancelled")
 		case <-batchTicker.C:
 			// Flush batch on timer
 			if err := flushBatch(); err != nil {
 				return status.Errorf(codes.Internal, "failed to flush batch: %v", err)
 			}
-		default:
-			// Receive next message
-			req, err := stream.Recv()
+		case <-idleTimer.C:
+			// No message of any kind (line, batch, or heartbeat) for
+			// idleTimeout - the client is gone without ever telling us so.
+			_ = flushBatch()
+			s.core.AbortRun(ctx, logRun)
+			return status.Errorf(codes.DeadlineExceeded, "no activity for %s, aborting run", s.idleTimeout)
+		case res := <-recvCh:
+			if !idleTimer.Stop() {
+				<-idleTimer.C
+			}
+			idleTimer.Reset(s.idleTimeout)
+
+			req, err := res.req, res.err
 			if err == io.EOF {
-				// Client closed stream, flush remaining logs
+				// Client closed the stream without ever sending a
+				// completion message - the same "gone without telling us"
+				// case AbortRun already covers for ctx.Done() and the idle
+				// timeout, so the run doesn't stay stuck at "running"
+				// forever.
 				_ = flushBatch()
+				s.core.AbortRun(ctx, logRun)
 				return nil
 			}
 			if err != nil {
 				// Stream error, mark run as aborted
 				_ = flushBatch()
-				_ = s.logRunRepo.UpdateStatus(ctx, logRun.ID, models.RunStatusAborted, nil)
-
-				// Publish run status update event
-				statusStr := string(models.RunStatusAborted)
-				_ = ws.PublishRunUpdate(ctx, s.redisClient, logRun.ID, &statusStr, nil, nil, nil)
-
+				s.core.AbortRun(ctx, logRun)
 				return status.Errorf(codes.Internal, "stream error: %v", err)
 			}
 
 			// Handle different message types
 			if line := req.GetLine(); line != nil {
-				// Add log line to batch
-				entry := loki.LogEntry{
+				logBatch = append(logBatch, LogLine{
 					Timestamp: line.Timestamp.AsTime(),
-					Line:      fmt.Sprintf("[%s] %s", line.Level.String(), line.Content),
-				}
-				logBatch = append(logBatch, entry)
-
-				// Publish log to Redis for real-time WebSocket streaming
-				_ = ws.PublishLog(ctx, s.redisClient, logRun.ID,
-					line.Timestamp.AsTime().Format(time.RFC3339Nano),
-					line.Level.String(),
-					line.Content)
+					Level:     line.Level.String(),
+					Content:   line.Content,
+				})
 
 				// Flush if batch is full
-				if len(logBatch) >= s.batchSize {
+				if len(logBatch) >= s.core.BatchSize() {
 					if err := flushBatch(); err != nil {
 						return status.Errorf(codes.Internal, "failed to flush batch: %v", err)
 					}
 				}
+			} else if batch := req.GetBatch(); batch != nil {
+				// A coalesced batch from a client that's buffering lines
+				// client-side (see StreamSession in
+				// cli/internal/client/grpc_client.go); every line in it goes
+				// into the same Loki batch a 'line' event would.
+				for _, line := range batch.Lines {
+					logBatch = append(logBatch, LogLine{
+						Timestamp: line.Timestamp.AsTime(),
+						Level:     line.Level.String(),
+						Content:   line.Content,
+					})
+				}
+
+				if len(logBatch) >= s.core.BatchSize() {
+					if err := flushBatch(); err != nil {
+						return status.Errorf(codes.Internal, "failed to flush batch: %v", err)
+					}
+				}
+			} else if hb := req.GetHeartbeat(); hb != nil {
+				// A keepalive with no payload; it only resets the idle timer
+				// above and records that the run is still alive. It never
+				// produces a Loki log line.
+				if err := s.core.TouchLastSeen(ctx, logRun); err != nil {
+					return status.Errorf(codes.Internal, "failed to record heartbeat: %v", err)
+				}
 			} else if completion := req.GetCompletion(); completion != nil {
 				// Script completed, flush remaining logs
 				if err := flushBatch(); err != nil {
 					return status.Errorf(codes.Internal, "failed to flush final batch: %v", err)
 				}
 
-				// Update run status based on exit code
-				exitCode := completion.ExitCode
-				var runStatus models.RunStatus
-				if exitCode == 0 {
-					runStatus = models.RunStatusCompleted
-				} else {
-					runStatus = models.RunStatusFailed
+				if err := s.core.CompleteRun(ctx, logRun, userID, completion.ExitCode); err != nil {
+					return status.Errorf(codes.Internal, "%v", err)
 				}
 
-				if err := s.logRunRepo.UpdateStatus(ctx, logRun.ID, runStatus, &exitCode); err != nil {
-					return status.Errorf(codes.Internal, "failed to update run status: %v", err)
-				}
-
-				// Publish run status update event
-				statusStr := string(runStatus)
-				_ = ws.PublishRunUpdate(ctx, s.redisClient, logRun.ID, &statusStr, &exitCode, nil, nil)
-
-				// Trigger AI analysis if auto-analyze is enabled and AI status is pending
-				if logRun.AIStatus == models.AIStatusPending && s.taskQueue != nil {
-					log.Printf("Auto-triggering AI analysis for run %s (user %s)", logRun.ID, userID)
-					if err := s.taskQueue.PublishAITask(context.Background(), logRun.ID, userID); err != nil {
-						log.Printf("Warning: failed to publish AI task for run %s: %v", logRun.ID, err)
-					}
-				}
+				// Acknowledge the run is terminal, so a client's
+				// WaitForCompletion can tell "acked" apart from "gave up
+				// waiting" if the stream then goes quiet.
+				_ = stream.Send(&pb.StreamLogResponse{
+					Event: &pb.StreamLogResponse_Completed{
+						Completed: &pb.StreamCompleted{RunId: logRun.ID.String()},
+					},
+				})
 
 				return nil
 			}
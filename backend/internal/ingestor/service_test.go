@@ -0,0 +1,466 @@
+package ingestor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aliancn/swiftlog/backend/internal/auth"
+	"github.com/aliancn/swiftlog/backend/internal/loki"
+	"github.com/aliancn/swiftlog/backend/internal/repository"
+	ws "github.com/aliancn/swiftlog/backend/internal/websocket"
+	pb "github.com/aliancn/swiftlog/backend/proto"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newTestService wires a Service up to sqlmock-backed repositories, a
+// miniredis instance, and an httptest Loki server that just counts pushes,
+// so StreamLog's DB/Redis/Loki side effects are all inspectable without a
+// real database. The returned *redis.Client is the same instance the
+// service publishes through, so a test can also point a websocket.Hub (or
+// a raw Subscribe call) at it to observe those publishes.
+func newTestService(t *testing.T, batchInterval time.Duration) (*Service, sqlmock.Sqlmock, *int32, *redis.Client) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	var pushCount int32
+	lokiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pushCount, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(lokiServer.Close)
+
+	svc := NewService(&Config{
+		LogRunRepo:    repository.NewLogRunRepository(db),
+		ProjectRepo:   repository.NewProjectRepository(db),
+		GroupRepo:     repository.NewLogGroupRepository(db),
+		SettingsRepo:  repository.NewSettingsRepository(db),
+		LokiClient:    loki.NewClient(&loki.Config{URL: lokiServer.URL}),
+		RedisClient:   redisClient,
+		BatchInterval: batchInterval,
+		IdleTimeout:   time.Minute,
+	})
+	return svc, mock, &pushCount, redisClient
+}
+
+// expectStartRun sets up the sqlmock expectations StartRun issues for a
+// brand-new project/group: a miss-then-create for each, and a failing
+// GetEffectiveSettings lookup (StartRun tolerates that and falls back to
+// AIStatusNone, so it doesn't need the full user/project settings merge
+// mocked out).
+func expectStartRun(mock sqlmock.Sqlmock, runID uuid.UUID) {
+	projectID, groupID := uuid.New(), uuid.New()
+
+	mock.ExpectQuery("SELECT id, user_id, name, created_at FROM projects").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO projects").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "created_at"}).
+			AddRow(projectID, uuid.New(), "proj", time.Now()))
+
+	mock.ExpectQuery("SELECT id, project_id, name, created_at FROM log_groups").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO log_groups").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "created_at"}).
+			AddRow(groupID, projectID, "group", time.Now()))
+
+	mock.ExpectQuery("SELECT id, user_id, ai_enabled").
+		WillReturnError(sql.ErrNoRows)
+
+	mock.ExpectQuery("INSERT INTO log_runs").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "group_id", "start_time", "end_time", "status", "exit_code", "ai_report",
+			"ai_status", "ai_metadata", "tags", "hostname", "working_dir", "command_line", "name",
+			"created_at", "updated_at",
+		}).AddRow(
+			runID, groupID, time.Now(), nil, "running", nil, nil,
+			"none", nil, []byte("{}"), nil, nil, nil, nil,
+			time.Now(), time.Now(),
+		))
+}
+
+// fakeStreamClient dials svc over bufconn and returns a real gRPC client
+// stream, with a fixed test user ID already injected into the server-side
+// context in place of real token authentication.
+func fakeStreamClient(t *testing.T, svc *Service) pb.LogStreamer_StreamLogClient {
+	t.Helper()
+	stream, _ := fakeStreamClientConn(t, svc)
+	return stream
+}
+
+// fakeStreamClientConn is fakeStreamClient plus the underlying
+// *grpc.ClientConn, for a test that needs to simulate an abrupt disconnect
+// by closing the connection itself rather than calling CloseSend.
+func fakeStreamClientConn(t *testing.T, svc *Service) (pb.LogStreamer_StreamLogClient, *grpc.ClientConn) {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+
+	grpcServer := grpc.NewServer(grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &userIDStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), auth.UserIDKey, uuid.New())})
+	}))
+	pb.RegisterLogStreamerServer(grpcServer, svc)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	stream, err := pb.NewLogStreamerClient(conn).StreamLog(context.Background())
+	if err != nil {
+		t.Fatalf("StreamLog failed: %v", err)
+	}
+	return stream, conn
+}
+
+// userIDStream overrides Context() so the handler sees a context carrying
+// auth.UserIDKey, standing in for the real GRPCAuthStreamInterceptor.
+type userIDStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *userIDStream) Context() context.Context { return s.ctx }
+
+// TestStreamLog_FlushesOnTickerWhileRecvBlocks is the regression test for
+// the batch ticker never firing while the receive loop's select had a
+// default branch that called the blocking stream.Recv() itself: five lines
+// arrive, the client then goes quiet without a completion, and the batch
+// ticker (not a full batch or EOF) must still flush them to Loki well
+// within the ticker interval.
+func TestStreamLog_FlushesOnTickerWhileRecvBlocks(t *testing.T) {
+	svc, mock, pushCount, _ := newTestService(t, 100*time.Millisecond)
+	runID := uuid.New()
+	expectStartRun(mock, runID)
+
+	stream := fakeStreamClient(t, svc)
+
+	if err := stream.Send(&pb.StreamLogRequest{
+		Event: &pb.StreamLogRequest_Metadata{Metadata: &pb.StreamMetadata{ProjectName: "proj", GroupName: "group"}},
+	}); err != nil {
+		t.Fatalf("failed to send metadata: %v", err)
+	}
+	started, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("failed to receive Started response: %v", err)
+	}
+	if started.GetStarted() == nil {
+		t.Fatalf("first response = %+v, want a Started event", started)
+	}
+
+	for i := 0; i < 5; i++ {
+		err := stream.Send(&pb.StreamLogRequest{
+			Event: &pb.StreamLogRequest_Line{Line: &pb.LogLine{
+				Timestamp: timestamppb.Now(),
+				Level:     pb.LogLine_STDOUT,
+				Content:   "quiet line",
+			}},
+		})
+		if err != nil {
+			t.Fatalf("failed to send line %d: %v", i, err)
+		}
+	}
+
+	// Now go quiet - no more lines, no completion - and wait for the batch
+	// ticker to flush on its own, well within the 1s the request asks for.
+	deadline := time.After(time.Second)
+	for {
+		if atomic.LoadInt32(pushCount) > 0 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("PushLogs was not called within 1s of the stream going quiet")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// dialHubClient starts an httptest server that upgrades its one incoming
+// connection into a websocket.Client registered with hub under runID, and
+// dials it with a real client-side *websocket.Conn - standing in for a
+// browser's WebSocket connection so a test can observe exactly what a live
+// viewer would receive over the wire.
+func dialHubClient(t *testing.T, hub *ws.Hub, runID uuid.UUID) *websocket.Conn {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("failed to upgrade websocket: %v", err)
+			return
+		}
+		client := ws.NewClient(hub, conn, runID, uuid.New(), 1)
+		client.Register()
+		client.Start()
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to dial hub client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// waitForRunSubscription blocks until redisClient reports at least one
+// subscriber on runID's channel. Hub.SubscribedRunCount alone isn't enough
+// to wait on here: it flips as soon as the client registers, but the
+// Redis SUBSCRIBE it triggers (see Hub.subscribeRun) is issued
+// asynchronously, so a publish right after registering can still race
+// ahead of the subscription actually taking effect.
+func waitForRunSubscription(t *testing.T, redisClient *redis.Client, runID uuid.UUID) {
+	t.Helper()
+	channel := fmt.Sprintf("swiftlog:runs:%s", runID)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		counts, err := redisClient.PubSubNumSub(context.Background(), channel).Result()
+		if err == nil && counts[channel] > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("hub never subscribed to %s", channel)
+}
+
+// readMessageOfType reads frames off conn, skipping any whose "type" field
+// doesn't match want (e.g. the "running" run_update StartRun publishes
+// before the message under test), and fails the test if none arrives before
+// conn's read deadline.
+func readMessageOfType(t *testing.T, conn *websocket.Conn, want string) map[string]any {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read %q message: %v", want, err)
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("failed to unmarshal message %s: %v", data, err)
+		}
+		if msg["type"] == want {
+			return msg
+		}
+	}
+}
+
+// TestStreamLog_PublishesLogsToHubBeforeCompletion is the end-to-end
+// regression test for the ingestor publishing log lines (and status
+// updates) to Redis as they arrive, rather than only once a run finishes: a
+// real websocket.Hub client, subscribed to the run before it starts, must
+// see the line's content over the wire before the completion round-trip
+// finishes.
+func TestStreamLog_PublishesLogsToHubBeforeCompletion(t *testing.T) {
+	svc, mock, _, redisClient := newTestService(t, 50*time.Millisecond)
+	runID := uuid.New()
+	expectStartRun(mock, runID)
+	mock.ExpectExec("UPDATE log_runs").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub := ws.NewHub(ctx, redisClient)
+	go hub.Run()
+
+	conn := dialHubClient(t, hub, runID)
+	waitForRunSubscription(t, redisClient, runID)
+
+	stream := fakeStreamClient(t, svc)
+	if err := stream.Send(&pb.StreamLogRequest{
+		Event: &pb.StreamLogRequest_Metadata{Metadata: &pb.StreamMetadata{ProjectName: "proj", GroupName: "group"}},
+	}); err != nil {
+		t.Fatalf("failed to send metadata: %v", err)
+	}
+	if started, err := stream.Recv(); err != nil || started.GetStarted() == nil {
+		t.Fatalf("failed to receive Started response: %+v, %v", started, err)
+	}
+
+	if err := stream.Send(&pb.StreamLogRequest{
+		Event: &pb.StreamLogRequest_Line{Line: &pb.LogLine{
+			Timestamp: timestamppb.Now(),
+			Level:     pb.LogLine_STDOUT,
+			Content:   "hello from the run",
+		}},
+	}); err != nil {
+		t.Fatalf("failed to send line: %v", err)
+	}
+
+	logMsg := readMessageOfType(t, conn, "log")
+	if content, _ := logMsg["content"].(string); content != "hello from the run" {
+		t.Fatalf("log message content = %v, want %q", logMsg["content"], "hello from the run")
+	}
+
+	if err := stream.Send(&pb.StreamLogRequest{
+		Event: &pb.StreamLogRequest_Completion{Completion: &pb.StreamCompletion{ExitCode: 0}},
+	}); err != nil {
+		t.Fatalf("failed to send completion: %v", err)
+	}
+	if completed, err := stream.Recv(); err != nil || completed.GetCompleted() == nil {
+		t.Fatalf("failed to receive Completed response: %+v, %v", completed, err)
+	}
+
+	runUpdate := readMessageOfType(t, conn, "run_update")
+	if status, _ := runUpdate["status"].(string); status != "completed" {
+		t.Fatalf("run_update status = %v, want %q", runUpdate["status"], "completed")
+	}
+}
+
+// collectRunUpdates reads frames off conn, collecting every "run_update"
+// payload seen, until a read blocks for longer than idle - i.e. until the
+// stream under test has gone quiet, which is as close as a test can get to
+// asserting "no further update was published".
+func collectRunUpdates(t *testing.T, conn *websocket.Conn, idle time.Duration) []map[string]any {
+	t.Helper()
+	var updates []map[string]any
+	for {
+		conn.SetReadDeadline(time.Now().Add(idle))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return updates
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("failed to unmarshal message %s: %v", data, err)
+		}
+		if msg["type"] == "run_update" {
+			updates = append(updates, msg)
+		}
+	}
+}
+
+// startedStream wires up a fresh service/hub/client for one of the
+// terminal-path tests below and drives the stream through StartRun,
+// returning it right after the "running" update (consumed here so each
+// test's own assertions only see what it triggers).
+func startedStream(t *testing.T, runID uuid.UUID) (pb.LogStreamer_StreamLogClient, *grpc.ClientConn, *websocket.Conn, sqlmock.Sqlmock) {
+	t.Helper()
+	svc, mock, _, redisClient := newTestService(t, time.Minute)
+	expectStartRun(mock, runID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	hub := ws.NewHub(ctx, redisClient)
+	go hub.Run()
+
+	conn := dialHubClient(t, hub, runID)
+	waitForRunSubscription(t, redisClient, runID)
+
+	stream, clientConn := fakeStreamClientConn(t, svc)
+	if err := stream.Send(&pb.StreamLogRequest{
+		Event: &pb.StreamLogRequest_Metadata{Metadata: &pb.StreamMetadata{ProjectName: "proj", GroupName: "group"}},
+	}); err != nil {
+		t.Fatalf("failed to send metadata: %v", err)
+	}
+	if started, err := stream.Recv(); err != nil || started.GetStarted() == nil {
+		t.Fatalf("failed to receive Started response: %+v, %v", started, err)
+	}
+	readMessageOfType(t, conn, "run_update") // the initial "running" update
+
+	return stream, clientConn, conn, mock
+}
+
+// TestStreamLog_PublishesExactlyOneRunUpdatePerTerminalPath covers the
+// terminal paths StreamLog's select loop can end on: an explicit completion,
+// the client hanging up without one (io.EOF), and an abrupt disconnect
+// (surfaced as either a Recv() error or ctx.Done(), depending on exactly
+// when the server notices - both call Core.AbortRun the same way, so either
+// is a pass). Each must publish exactly one run_update with the right
+// status, not zero and not a duplicate.
+func TestStreamLog_PublishesExactlyOneRunUpdatePerTerminalPath(t *testing.T) {
+	t.Run("completion", func(t *testing.T) {
+		runID := uuid.New()
+		stream, _, conn, mock := startedStream(t, runID)
+		mock.ExpectExec("UPDATE log_runs").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		if err := stream.Send(&pb.StreamLogRequest{
+			Event: &pb.StreamLogRequest_Completion{Completion: &pb.StreamCompletion{ExitCode: 0}},
+		}); err != nil {
+			t.Fatalf("failed to send completion: %v", err)
+		}
+		if completed, err := stream.Recv(); err != nil || completed.GetCompleted() == nil {
+			t.Fatalf("failed to receive Completed response: %+v, %v", completed, err)
+		}
+
+		updates := collectRunUpdates(t, conn, 500*time.Millisecond)
+		if len(updates) != 1 {
+			t.Fatalf("got %d run_update(s), want exactly 1: %+v", len(updates), updates)
+		}
+		if status, _ := updates[0]["status"].(string); status != "completed" {
+			t.Fatalf("run_update status = %v, want %q", updates[0]["status"], "completed")
+		}
+	})
+
+	t.Run("client EOF without completion", func(t *testing.T) {
+		runID := uuid.New()
+		stream, _, conn, mock := startedStream(t, runID)
+		mock.ExpectExec("UPDATE log_runs").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		if err := stream.CloseSend(); err != nil {
+			t.Fatalf("failed to close send side of stream: %v", err)
+		}
+		if _, err := stream.Recv(); err == nil {
+			t.Fatal("expected the server to close the stream after EOF, got a response instead")
+		}
+
+		updates := collectRunUpdates(t, conn, 500*time.Millisecond)
+		if len(updates) != 1 {
+			t.Fatalf("got %d run_update(s), want exactly 1: %+v", len(updates), updates)
+		}
+		if status, _ := updates[0]["status"].(string); status != "aborted" {
+			t.Fatalf("run_update status = %v, want %q", updates[0]["status"], "aborted")
+		}
+	})
+
+	t.Run("abrupt disconnect", func(t *testing.T) {
+		runID := uuid.New()
+		_, clientConn, conn, mock := startedStream(t, runID)
+		mock.ExpectExec("UPDATE log_runs").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		if err := clientConn.Close(); err != nil {
+			t.Fatalf("failed to close client connection: %v", err)
+		}
+
+		updates := collectRunUpdates(t, conn, 500*time.Millisecond)
+		if len(updates) != 1 {
+			t.Fatalf("got %d run_update(s), want exactly 1: %+v", len(updates), updates)
+		}
+		if status, _ := updates[0]["status"].(string); status != "aborted" {
+			t.Fatalf("run_update status = %v, want %q", updates[0]["status"], "aborted")
+		}
+	})
+}
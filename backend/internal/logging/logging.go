@@ -0,0 +1,49 @@
+// Package logging builds the shared slog.Logger used by all four SwiftLog
+// binaries, so a run's or request's log lines can be correlated across
+// services by grepping a shared JSON attribute (run_id, user_id,
+// request_id, worker_id) instead of eyeballing unstructured strings.
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// New builds the process-wide logger for service (e.g. "api", "ingestor",
+// "ai-worker", "websocket"). Output is JSON when ENVIRONMENT=production
+// (or LOG_FORMAT=json is set explicitly), and human-readable text
+// otherwise; the level is controlled by LOG_LEVEL (debug/info/warn/error,
+// default info). Every record carries a "service" attribute.
+func New(service string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(os.Getenv("LOG_LEVEL"))}
+
+	var handler slog.Handler
+	if useJSON() {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+
+	return slog.New(handler).With("service", service)
+}
+
+func useJSON() bool {
+	if format := strings.ToLower(os.Getenv("LOG_FORMAT")); format != "" {
+		return format == "json"
+	}
+	return strings.ToLower(os.Getenv("ENVIRONMENT")) == "production"
+}
+
+func parseLevel(raw string) slog.Level {
+	switch strings.ToLower(raw) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
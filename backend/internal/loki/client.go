@@ -7,11 +7,18 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/aliancn/swiftlog/backend/internal/loki")
+
 // Client is a Loki HTTP client
 type Client struct {
 	baseURL    string
@@ -55,22 +62,56 @@ type LogEntry struct {
 	Line      string    `json:"-"`
 }
 
-// MarshalJSON implements custom JSON serialization for LogEntry
-func (e LogEntry) MarshalJSON() ([]byte, error) {
-	// Extract level from line (e.g., "[STDOUT]" or "[STDERR]")
-	level := "STDOUT"
-	content := e.Line
-
-	if len(e.Line) > 8 && e.Line[0] == '[' {
-		if len(e.Line) > 9 && e.Line[1:9] == "STDOUT] " {
-			level = "STDOUT"
-			content = e.Line[9:]
-		} else if len(e.Line) > 9 && e.Line[1:9] == "STDERR] " {
-			level = "STDERR"
-			content = e.Line[9:]
+// LevelAndContent splits Line into its STDOUT/STDERR level and the actual
+// log content, undoing the "[LEVEL] " (or "[LEVEL:SEQ] ") prefix PushLogs
+// stores it with. Defaults to STDOUT if there's no recognizable prefix.
+func (e LogEntry) LevelAndContent() (level, content string) {
+	level, _, content = e.Parts()
+	return level, content
+}
+
+// Parts splits Line into its STDOUT/STDERR level, per-run monotonic
+// sequence number, and the actual log content, undoing the
+// "[LEVEL:SEQ] " prefix PushLogs stores it with. Older lines pushed before
+// sequence numbers existed use a plain "[LEVEL] " prefix and parse with
+// sequence 0. Defaults to STDOUT/0 if there's no recognizable prefix.
+func (e LogEntry) Parts() (level string, sequence int64, content string) {
+	level = "STDOUT"
+	content = e.Line
+
+	if len(e.Line) < 2 || e.Line[0] != '[' {
+		return level, sequence, content
+	}
+
+	end := strings.Index(e.Line, "] ")
+	if end < 0 {
+		return level, sequence, content
+	}
+
+	header := e.Line[1:end]
+	rest := e.Line[end+2:]
+
+	headerLevel := header
+	if colon := strings.IndexByte(header, ':'); colon >= 0 {
+		headerLevel = header[:colon]
+		if n, err := strconv.ParseInt(header[colon+1:], 10, 64); err == nil {
+			sequence = n
 		}
 	}
 
+	switch headerLevel {
+	case "STDOUT", "STDERR":
+		level = headerLevel
+		content = rest
+	}
+
+	return level, sequence, content
+}
+
+// MarshalJSON implements custom JSON serialization for LogEntry
+func (e LogEntry) MarshalJSON() ([]byte, error) {
+	level, content := e.LevelAndContent()
+
 	return json.Marshal(&struct {
 		Timestamp time.Time `json:"timestamp"`
 		Level     string    `json:"level"`
@@ -164,14 +205,22 @@ type QueryResponse struct {
 	} `json:"data"`
 }
 
-// QueryLogs queries logs from Loki
+// QueryLogs queries the last 7 days of logs from Loki for runID.
 func (c *Client) QueryLogs(ctx context.Context, runID uuid.UUID) ([]LogEntry, error) {
-	// Build LogQL query
-	query := fmt.Sprintf(`{run_id="%s"}`, runID.String())
-
-	// Set time range to last 7 days to ensure we get historical logs
 	end := time.Now()
 	start := end.Add(-7 * 24 * time.Hour)
+	return c.QueryLogsRange(ctx, runID, start, end)
+}
+
+// QueryLogsRange queries logs from Loki for runID within [start, end],
+// oldest first.
+func (c *Client) QueryLogsRange(ctx context.Context, runID uuid.UUID, start, end time.Time) ([]LogEntry, error) {
+	ctx, span := tracer.Start(ctx, "loki.query_logs",
+		trace.WithAttributes(attribute.String("ai.run_id", runID.String())))
+	defer span.End()
+
+	// Build LogQL query
+	query := fmt.Sprintf(`{run_id="%s"}`, runID.String())
 
 	// Build query URL with time range
 	url := fmt.Sprintf("%s/loki/api/v1/query_range?query=%s&start=%d&end=%d&direction=forward&limit=10000",
@@ -222,3 +271,127 @@ func (c *Client) QueryLogs(ctx context.Context, runID uuid.UUID) ([]LogEntry, er
 
 	return entries, nil
 }
+
+// QueryRecentLogs returns runID's most recent limit log lines, oldest
+// first, for a WebSocket client replaying backlog on connect. Unlike
+// QueryLogs (which fetches everything from the last 7 days forward and can
+// be huge for a long-running job), this queries backward from now so a
+// result capped at limit is still the newest lines rather than the oldest.
+func (c *Client) QueryRecentLogs(ctx context.Context, runID uuid.UUID, limit int) ([]LogEntry, error) {
+	ctx, span := tracer.Start(ctx, "loki.query_recent_logs",
+		trace.WithAttributes(attribute.String("ai.run_id", runID.String()), attribute.Int("ai.limit", limit)))
+	defer span.End()
+
+	query := fmt.Sprintf(`{run_id="%s"}`, runID.String())
+
+	end := time.Now()
+	start := end.Add(-7 * 24 * time.Hour)
+
+	url := fmt.Sprintf("%s/loki/api/v1/query_range?query=%s&start=%d&end=%d&direction=backward&limit=%d",
+		c.baseURL, query, start.UnixNano(), end.UnixNano(), limit)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Loki: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Loki query failed: status=%d, body=%s", resp.StatusCode, string(body))
+	}
+
+	var queryResp QueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&queryResp); err != nil {
+		return nil, fmt.Errorf("failed to decode query response: %w", err)
+	}
+
+	var entries []LogEntry
+	for _, result := range queryResp.Data.Result {
+		for _, value := range result.Values {
+			if len(value) != 2 {
+				continue
+			}
+			var ts int64
+			fmt.Sscanf(value[0], "%d", &ts)
+
+			entries = append(entries, LogEntry{
+				Timestamp: time.Unix(0, ts),
+				Line:      value[1],
+			})
+		}
+	}
+
+	// Loki returns backward-direction results newest first; reverse so the
+	// caller gets them in chronological (replay) order.
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
+
+// QueryLogsSince returns runID's log entries strictly after since, oldest
+// first, for a WebSocket client resuming from a cursor after a reconnect.
+// It fetches one more than limit so a truncated result can be detected: if
+// more than limit entries exist after since, only the first limit are
+// returned and gap is true, meaning the caller was too far behind for an
+// exact resume and should fall back to a fresh backlog fetch.
+func (c *Client) QueryLogsSince(ctx context.Context, runID uuid.UUID, since time.Time, limit int) (entries []LogEntry, gap bool, err error) {
+	ctx, span := tracer.Start(ctx, "loki.query_logs_since",
+		trace.WithAttributes(attribute.String("ai.run_id", runID.String()), attribute.Int("ai.limit", limit)))
+	defer span.End()
+
+	query := fmt.Sprintf(`{run_id="%s"}`, runID.String())
+
+	url := fmt.Sprintf("%s/loki/api/v1/query_range?query=%s&start=%d&end=%d&direction=forward&limit=%d",
+		c.baseURL, query, since.UnixNano(), time.Now().UnixNano(), limit+1)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create query request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query Loki: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, false, fmt.Errorf("Loki query failed: status=%d, body=%s", resp.StatusCode, string(body))
+	}
+
+	var queryResp QueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&queryResp); err != nil {
+		return nil, false, fmt.Errorf("failed to decode query response: %w", err)
+	}
+
+	for _, result := range queryResp.Data.Result {
+		for _, value := range result.Values {
+			if len(value) != 2 {
+				continue
+			}
+			var ts int64
+			fmt.Sscanf(value[0], "%d", &ts)
+
+			entries = append(entries, LogEntry{
+				Timestamp: time.Unix(0, ts),
+				Line:      value[1],
+			})
+		}
+	}
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+		gap = true
+	}
+
+	return entries, gap, nil
+}
@@ -2,28 +2,58 @@ package models
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // User represents an authenticated user of the platform
 type User struct {
-	ID           uuid.UUID `json:"id" db:"id"`
-	Username     string    `json:"username" db:"username"`
-	PasswordHash string    `json:"-" db:"password_hash"` // Never expose in JSON
-	IsAdmin      bool      `json:"is_admin" db:"is_admin"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	ID                 uuid.UUID      `json:"id" db:"id"`
+	Username           string         `json:"username" db:"username"`
+	PasswordHash       string         `json:"-" db:"password_hash"` // Never expose in JSON
+	Email              sql.NullString `json:"-" db:"email"`
+	OIDCSubject        sql.NullString `json:"-" db:"oidc_subject"`
+	IsAdmin            bool           `json:"is_admin" db:"is_admin"`
+	MustChangePassword bool           `json:"must_change_password" db:"must_change_password"`
+	CreatedAt          time.Time      `json:"created_at" db:"created_at"`
 }
 
 // APIToken stores API tokens for authenticating the CLI and other clients
 type APIToken struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	UserID    uuid.UUID `json:"user_id" db:"user_id"`
-	TokenHash string    `json:"-" db:"token_hash"` // Never expose in JSON
-	Name      string    `json:"name" db:"name"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ID         uuid.UUID      `json:"id" db:"id"`
+	UserID     uuid.UUID      `json:"user_id" db:"user_id"`
+	TokenHash  string         `json:"-" db:"token_hash"` // Never expose in JSON
+	TokenHint  string         `json:"token_hint" db:"token_hint"`
+	Name       string         `json:"name" db:"name"`
+	ExpiresAt  sql.NullTime   `json:"-" db:"expires_at"`
+	LastUsedAt sql.NullTime   `json:"-" db:"last_used_at"`
+	LastUsedIP sql.NullString `json:"-" db:"last_used_ip"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+}
+
+// MarshalJSON implements custom JSON serialization for APIToken, surfacing
+// the nullable fields as plain pointers instead of their raw sql.Null*
+// types. ExpiresAt is set only while a rotated-out token is still in its
+// overlap window.
+func (t APIToken) MarshalJSON() ([]byte, error) {
+	type Alias APIToken
+	return json.Marshal(&struct {
+		*Alias
+		ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+		LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+		LastUsedIP *string    `json:"last_used_ip,omitempty"`
+	}{
+		Alias:      (*Alias)(&t),
+		ExpiresAt:  nullTimeToPtr(t.ExpiresAt),
+		LastUsedAt: nullTimeToPtr(t.LastUsedAt),
+		LastUsedIP: nullStringToPtr(t.LastUsedIP),
+	})
 }
 
 // Project is a top-level container for organizing logs, owned by a user
@@ -52,29 +82,117 @@ const (
 	RunStatusAborted   RunStatus = "aborted"
 )
 
+// IsTerminal reports whether s is a final state a run won't leave on its
+// own - completed, failed, or aborted - as opposed to RunStatusRunning.
+func (s RunStatus) IsTerminal() bool {
+	switch s {
+	case RunStatusCompleted, RunStatusFailed, RunStatusAborted:
+		return true
+	default:
+		return false
+	}
+}
+
 // AIStatus represents the status of AI report generation
 type AIStatus string
 
 const (
-	AIStatusNone       AIStatus = "none"       // AI analysis is disabled
+	AIStatusNone       AIStatus = "none" // AI analysis is disabled
 	AIStatusPending    AIStatus = "pending"
 	AIStatusProcessing AIStatus = "processing"
 	AIStatusCompleted  AIStatus = "completed"
 	AIStatusFailed     AIStatus = "failed"
+	AIStatusCancelled  AIStatus = "cancelled" // Analysis was cancelled by the user before or while running
+	AIStatusPartial    AIStatus = "partial"   // Provisional report for a run still in progress; a completed analysis will supersede it
 )
 
+// ErrorCategory classifies the root cause of a failed run, as determined by
+// AI analysis. It's a fixed taxonomy so runs can be faceted/filtered on it;
+// unrecognized model output is mapped to ErrorCategoryUnknown rather than
+// stored verbatim.
+type ErrorCategory string
+
+const (
+	ErrorCategoryOOM           ErrorCategory = "oom"
+	ErrorCategoryNetwork       ErrorCategory = "network"
+	ErrorCategoryPermission    ErrorCategory = "permission"
+	ErrorCategoryDependency    ErrorCategory = "dependency"
+	ErrorCategorySyntax        ErrorCategory = "syntax"
+	ErrorCategoryTimeout       ErrorCategory = "timeout"
+	ErrorCategoryConfiguration ErrorCategory = "configuration"
+	ErrorCategoryFlakyTest     ErrorCategory = "flaky-test"
+	ErrorCategoryUnknown       ErrorCategory = "unknown"
+)
+
+// validErrorCategories is used to validate/normalize model output against
+// the fixed taxonomy.
+var validErrorCategories = map[ErrorCategory]bool{
+	ErrorCategoryOOM:           true,
+	ErrorCategoryNetwork:       true,
+	ErrorCategoryPermission:    true,
+	ErrorCategoryDependency:    true,
+	ErrorCategorySyntax:        true,
+	ErrorCategoryTimeout:       true,
+	ErrorCategoryConfiguration: true,
+	ErrorCategoryFlakyTest:     true,
+	ErrorCategoryUnknown:       true,
+}
+
+// NormalizeErrorCategory maps raw model output onto the fixed taxonomy,
+// falling back to ErrorCategoryUnknown for anything it doesn't recognize.
+func NormalizeErrorCategory(raw string) ErrorCategory {
+	category := ErrorCategory(strings.ToLower(strings.TrimSpace(raw)))
+	if validErrorCategories[category] {
+		return category
+	}
+	return ErrorCategoryUnknown
+}
+
 // LogRun represents a single execution of a logged script
 type LogRun struct {
-	ID        uuid.UUID      `json:"id" db:"id"`
-	GroupID   uuid.UUID      `json:"group_id" db:"group_id"`
-	StartTime time.Time      `json:"start_time" db:"start_time"`
-	EndTime   sql.NullTime   `json:"-" db:"end_time"`
-	Status    RunStatus      `json:"status" db:"status"`
-	ExitCode  sql.NullInt32  `json:"-" db:"exit_code"`
-	AIReport  sql.NullString `json:"-" db:"ai_report"`
-	AIStatus  AIStatus       `json:"ai_status" db:"ai_status"`
-	CreatedAt time.Time      `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+	ID            uuid.UUID      `json:"id" db:"id"`
+	GroupID       uuid.UUID      `json:"group_id" db:"group_id"`
+	StartTime     time.Time      `json:"start_time" db:"start_time"`
+	EndTime       sql.NullTime   `json:"-" db:"end_time"`
+	Status        RunStatus      `json:"status" db:"status"`
+	ExitCode      sql.NullInt32  `json:"-" db:"exit_code"`
+	AIReport      sql.NullString `json:"-" db:"ai_report"`
+	AIStatus      AIStatus       `json:"ai_status" db:"ai_status"`
+	AIMetadata    JSONMap        `json:"ai_metadata,omitempty" db:"ai_metadata"`
+	AIContentHash sql.NullString `json:"-" db:"ai_content_hash"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at" db:"updated_at"`
+
+	// ProcessingStartedAt is when ai_status last transitioned to processing;
+	// null once it moves to any other status. Used by the stuck-run
+	// reconciler to detect analyses abandoned by a crashed worker.
+	ProcessingStartedAt sql.NullTime `json:"-" db:"processing_started_at"`
+
+	// ErrorCategory is the AI-classified root cause taxonomy label (e.g.
+	// "oom", "dependency"), set once analysis completes; null until then.
+	ErrorCategory sql.NullString `json:"-" db:"error_category"`
+
+	// Tags are user-supplied key/value labels set at run creation (e.g.
+	// env=staging, commit=abc123).
+	Tags Tags `json:"tags,omitempty" db:"tags"`
+
+	// Hostname, WorkingDir and CommandLine are provenance captured by the
+	// client at run creation, so a run can still be traced back to its
+	// origin later; null for runs started before this was tracked or by
+	// clients that don't send it.
+	Hostname    sql.NullString `json:"-" db:"hostname"`
+	WorkingDir  sql.NullString `json:"-" db:"working_dir"`
+	CommandLine sql.NullString `json:"-" db:"command_line"`
+
+	// Name is an optional human-friendly name for the run (e.g. "nightly
+	// backup 2024-06-01"), set by the client at run creation; null for runs
+	// started without one.
+	Name sql.NullString `json:"-" db:"name"`
+
+	// LastSeenAt is when the ingestor last received a message (a log line,
+	// batch, or heartbeat) on this run's stream; null for runs that haven't
+	// reported any activity yet.
+	LastSeenAt sql.NullTime `json:"-" db:"last_seen_at"`
 }
 
 // MarshalJSON implements custom JSON serialization for LogRun
@@ -82,14 +200,26 @@ func (r LogRun) MarshalJSON() ([]byte, error) {
 	type Alias LogRun
 	return json.Marshal(&struct {
 		*Alias
-		EndTime  *time.Time `json:"end_time,omitempty"`
-		ExitCode *int32     `json:"exit_code,omitempty"`
-		AIReport *string    `json:"ai_report,omitempty"`
+		EndTime       *time.Time `json:"end_time,omitempty"`
+		ExitCode      *int32     `json:"exit_code,omitempty"`
+		AIReport      *string    `json:"ai_report,omitempty"`
+		ErrorCategory *string    `json:"error_category,omitempty"`
+		Hostname      *string    `json:"hostname,omitempty"`
+		WorkingDir    *string    `json:"working_dir,omitempty"`
+		CommandLine   *string    `json:"command_line,omitempty"`
+		Name          *string    `json:"name,omitempty"`
+		LastSeenAt    *time.Time `json:"last_seen_at,omitempty"`
 	}{
-		Alias:    (*Alias)(&r),
-		EndTime:  nullTimeToPtr(r.EndTime),
-		ExitCode: nullInt32ToPtr(r.ExitCode),
-		AIReport: nullStringToPtr(r.AIReport),
+		Alias:         (*Alias)(&r),
+		EndTime:       nullTimeToPtr(r.EndTime),
+		ExitCode:      nullInt32ToPtr(r.ExitCode),
+		AIReport:      nullStringToPtr(r.AIReport),
+		ErrorCategory: nullStringToPtr(r.ErrorCategory),
+		Hostname:      nullStringToPtr(r.Hostname),
+		WorkingDir:    nullStringToPtr(r.WorkingDir),
+		CommandLine:   nullStringToPtr(r.CommandLine),
+		Name:          nullStringToPtr(r.Name),
+		LastSeenAt:    nullTimeToPtr(r.LastSeenAt),
 	})
 }
 
@@ -114,6 +244,205 @@ func nullStringToPtr(ns sql.NullString) *string {
 	return nil
 }
 
+// JSONMap is a free-form JSON object stored in a JSONB column, used for
+// AI analysis metadata that doesn't warrant its own dedicated columns
+// (redaction counts, prompt-rendering warnings, cost estimates, and so on).
+type JSONMap map[string]interface{}
+
+// Value implements driver.Valuer for storing JSONMap in a JSONB column.
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(m)
+}
+
+// Scan implements sql.Scanner for reading a JSONB column into JSONMap.
+func (m *JSONMap) Scan(value interface{}) error {
+	if value == nil {
+		*m = JSONMap{}
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for JSONMap: %T", value)
+	}
+	if len(data) == 0 {
+		*m = JSONMap{}
+		return nil
+	}
+	return json.Unmarshal(data, m)
+}
+
+// Tags holds user-supplied key/value labels attached to a run (e.g.
+// env=staging, commit=abc123), stored in a JSONB column since a run only
+// ever carries a handful of them.
+type Tags map[string]string
+
+// Value implements driver.Valuer for storing Tags in a JSONB column.
+func (t Tags) Value() (driver.Value, error) {
+	if t == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(t)
+}
+
+// Scan implements sql.Scanner for reading a JSONB column into Tags.
+func (t *Tags) Scan(value interface{}) error {
+	if value == nil {
+		*t = Tags{}
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for Tags: %T", value)
+	}
+	if len(data) == 0 {
+		*t = Tags{}
+		return nil
+	}
+	return json.Unmarshal(data, t)
+}
+
+// InstanceSettings holds admin-configured, instance-wide configuration that
+// doesn't belong to any single user or project — AI pricing overrides for
+// proxies and models not in the built-in pricing table, and the password
+// policy enforced at registration, change-password, and password reset.
+// It's a singleton row (id is always InstanceSettingsID).
+type InstanceSettings struct {
+	ID                uuid.UUID      `json:"id" db:"id"`
+	AIPricingOverride JSONMap        `json:"ai_pricing_overrides" db:"ai_pricing_overrides"`
+	PasswordPolicy    PasswordPolicy `json:"password_policy" db:"password_policy"`
+	CreatedAt         time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// PasswordPolicy configures the rules a new or changed password must
+// satisfy. MinScore is a 0-4 strength score, same scale as zxcvbn, judged
+// by passwordpolicy.Evaluate against a hand-rolled entropy estimate rather
+// than the real zxcvbn library, which isn't a dependency of this project.
+type PasswordPolicy struct {
+	MinLength             int  `json:"min_length"`
+	RequireUppercase      bool `json:"require_uppercase"`
+	RequireLowercase      bool `json:"require_lowercase"`
+	RequireDigit          bool `json:"require_digit"`
+	RequireSymbol         bool `json:"require_symbol"`
+	RejectUsername        bool `json:"reject_username"`
+	RejectCommonPasswords bool `json:"reject_common_passwords"`
+	MinScore              int  `json:"min_score"`
+}
+
+// DefaultPasswordPolicy is applied wherever the admin hasn't configured an
+// override, and matches the strictness this codebase already had before
+// the policy became configurable, plus the two checks that cost nothing
+// to always run.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:             8,
+		RejectUsername:        true,
+		RejectCommonPasswords: true,
+		MinScore:              2,
+	}
+}
+
+// Value implements driver.Valuer for storing PasswordPolicy in a JSONB
+// column.
+func (p PasswordPolicy) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan implements sql.Scanner for reading a JSONB column into
+// PasswordPolicy. An empty/null column scans to the zero value; callers
+// that want defaults applied should use DefaultPasswordPolicy explicitly
+// rather than relying on the zero value.
+func (p *PasswordPolicy) Scan(value interface{}) error {
+	if value == nil {
+		*p = PasswordPolicy{}
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for PasswordPolicy: %T", value)
+	}
+	if len(data) == 0 || string(data) == "{}" {
+		*p = PasswordPolicy{}
+		return nil
+	}
+	return json.Unmarshal(data, p)
+}
+
+// IsZero reports whether p is the unconfigured zero value, in which case
+// callers should fall back to DefaultPasswordPolicy.
+func (p PasswordPolicy) IsZero() bool {
+	return p == PasswordPolicy{}
+}
+
+// InstanceSettingsID is the fixed primary key of the singleton instance_settings row.
+var InstanceSettingsID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// RunAnalysisVersion is a one-off analysis triggered with a custom prompt
+// and/or max-tokens override. It's kept separate from a run's standard
+// AIReport rather than overwriting it, so a run can accumulate several
+// custom-prompt analyses over time.
+type RunAnalysisVersion struct {
+	ID                uuid.UUID      `json:"id" db:"id"`
+	RunID             uuid.UUID      `json:"run_id" db:"run_id"`
+	UserID            uuid.UUID      `json:"user_id" db:"user_id"`
+	Report            string         `json:"report" db:"report"`
+	PromptOverride    sql.NullString `json:"prompt_override,omitempty" db:"prompt_override"`
+	MaxTokensOverride sql.NullInt32  `json:"max_tokens_override,omitempty" db:"max_tokens_override"`
+	ErrorCategory     sql.NullString `json:"error_category,omitempty" db:"error_category"`
+	Metadata          JSONMap        `json:"metadata,omitempty" db:"metadata"`
+	TokensUsed        int            `json:"tokens_used" db:"tokens_used"`
+	CreatedAt         time.Time      `json:"created_at" db:"created_at"`
+}
+
+// MarshalJSON renders the nullable override/category fields as omitted
+// rather than as raw sql.Null* structs.
+func (v RunAnalysisVersion) MarshalJSON() ([]byte, error) {
+	type Alias RunAnalysisVersion
+	return json.Marshal(&struct {
+		*Alias
+		PromptOverride    *string `json:"prompt_override,omitempty"`
+		MaxTokensOverride *int32  `json:"max_tokens_override,omitempty"`
+		ErrorCategory     *string `json:"error_category,omitempty"`
+	}{
+		Alias:             (*Alias)(&v),
+		PromptOverride:    nullStringToPtr(v.PromptOverride),
+		MaxTokensOverride: nullInt32ToPtr(v.MaxTokensOverride),
+		ErrorCategory:     nullStringToPtr(v.ErrorCategory),
+	})
+}
+
+// RunConversation is a single follow-up question/answer exchange about a
+// run's AI report. Exchanges sharing a ConversationID form one conversation
+// thread; ConversationID equals ID for the first exchange in a thread.
+type RunConversation struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
+	RunID          uuid.UUID `json:"run_id" db:"run_id"`
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	Question       string    `json:"question" db:"question"`
+	Answer         string    `json:"answer" db:"answer"`
+	TokensUsed     int       `json:"tokens_used" db:"tokens_used"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
 // LogLine represents a single log line (stored in Loki, not PostgreSQL)
 type LogLine struct {
 	Timestamp time.Time `json:"timestamp"`
@@ -134,15 +463,20 @@ type StatusStatistics struct {
 	AIProcessingCount int `json:"ai_processing_count"`
 	AICompletedCount  int `json:"ai_completed_count"`
 	AIFailedCount     int `json:"ai_failed_count"`
+
+	// ErrorCategoryCounts tallies completed analyses by ErrorCategory, for
+	// faceted "runs by failure type" browsing.
+	ErrorCategoryCounts map[ErrorCategory]int `json:"error_category_counts"`
 }
 
 // TruncateStrategy defines how to handle log truncation
 type TruncateStrategy string
 
 const (
-	TruncateHead  TruncateStrategy = "head"  // Keep first N lines
-	TruncateTail  TruncateStrategy = "tail"  // Keep last N lines
-	TruncateSmart TruncateStrategy = "smart" // Keep head + tail with summary
+	TruncateHead    TruncateStrategy = "head"     // Keep first N lines
+	TruncateTail    TruncateStrategy = "tail"     // Keep last N lines
+	TruncateSmart   TruncateStrategy = "smart"    // Keep head + tail with summary
+	TruncateSmartV2 TruncateStrategy = "smart_v2" // Score lines by error signal and keep the highest-scoring chunks
 )
 
 // UserSettings represents user-specific configuration
@@ -151,16 +485,22 @@ type UserSettings struct {
 	UserID uuid.UUID `json:"user_id" db:"user_id"`
 
 	// AI Configuration
-	AIEnabled             bool             `json:"ai_enabled" db:"ai_enabled"`
-	AIBaseURL             string           `json:"ai_base_url" db:"ai_base_url"`
-	AIAPIKey              sql.NullString   `json:"-" db:"ai_api_key"` // Never expose in JSON
-	AIModel               string           `json:"ai_model" db:"ai_model"`
-	AIMaxTokens           int              `json:"ai_max_tokens" db:"ai_max_tokens"`
-	AIAutoAnalyze         bool             `json:"ai_auto_analyze" db:"ai_auto_analyze"`
-	AIMaxLogLines         int              `json:"ai_max_log_lines" db:"ai_max_log_lines"`
-	AILogTruncateStrategy TruncateStrategy `json:"ai_log_truncate_strategy" db:"ai_log_truncate_strategy"`
-	AISystemPrompt        string           `json:"ai_system_prompt" db:"ai_system_prompt"`
-	AIMaxConcurrent       int              `json:"ai_max_concurrent" db:"ai_max_concurrent"`
+	AIEnabled               bool             `json:"ai_enabled" db:"ai_enabled"`
+	AIBaseURL               string           `json:"ai_base_url" db:"ai_base_url"`
+	AIAPIKey                sql.NullString   `json:"-" db:"ai_api_key"` // Never expose in JSON
+	AIModel                 string           `json:"ai_model" db:"ai_model"`
+	AIMaxTokens             int              `json:"ai_max_tokens" db:"ai_max_tokens"`
+	AIAutoAnalyze           bool             `json:"ai_auto_analyze" db:"ai_auto_analyze"`
+	AIMaxLogLines           int              `json:"ai_max_log_lines" db:"ai_max_log_lines"`
+	AILogTruncateStrategy   TruncateStrategy `json:"ai_log_truncate_strategy" db:"ai_log_truncate_strategy"`
+	AISystemPrompt          string           `json:"ai_system_prompt" db:"ai_system_prompt"`
+	AIUserPromptTemplate    string           `json:"ai_user_prompt_template" db:"ai_user_prompt_template"`
+	AIReportLanguage        string           `json:"ai_report_language" db:"ai_report_language"`
+	AIMonthlyTokenQuota     sql.NullInt64    `json:"ai_monthly_token_quota" db:"ai_monthly_token_quota"` // null = unlimited
+	AIMaxConcurrent         int              `json:"ai_max_concurrent" db:"ai_max_concurrent"`
+	AIRequestTimeoutSeconds int              `json:"ai_request_timeout_seconds" db:"ai_request_timeout_seconds"`
+	AIRedactSecrets         bool             `json:"ai_redact_secrets" db:"ai_redact_secrets"`
+	AIRedactExtraPatterns   pq.StringArray   `json:"ai_redact_extra_patterns" db:"ai_redact_extra_patterns"`
 
 	// Metadata
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
@@ -173,16 +513,21 @@ type ProjectSettings struct {
 	ProjectID uuid.UUID `json:"project_id" db:"project_id"`
 
 	// AI Configuration (nullable = inherit from user settings)
-	AIEnabled             *bool             `json:"ai_enabled,omitempty" db:"ai_enabled"`
-	AIBaseURL             *string           `json:"ai_base_url,omitempty" db:"ai_base_url"`
-	AIAPIKey              sql.NullString    `json:"-" db:"ai_api_key"`
-	AIModel               *string           `json:"ai_model,omitempty" db:"ai_model"`
-	AIMaxTokens           *int              `json:"ai_max_tokens,omitempty" db:"ai_max_tokens"`
-	AIAutoAnalyze         *bool             `json:"ai_auto_analyze,omitempty" db:"ai_auto_analyze"`
-	AIMaxLogLines         *int              `json:"ai_max_log_lines,omitempty" db:"ai_max_log_lines"`
-	AILogTruncateStrategy *TruncateStrategy `json:"ai_log_truncate_strategy,omitempty" db:"ai_log_truncate_strategy"`
-	AISystemPrompt        *string           `json:"ai_system_prompt,omitempty" db:"ai_system_prompt"`
-	AIMaxConcurrent       *int              `json:"ai_max_concurrent,omitempty" db:"ai_max_concurrent"`
+	AIEnabled               *bool             `json:"ai_enabled,omitempty" db:"ai_enabled"`
+	AIBaseURL               *string           `json:"ai_base_url,omitempty" db:"ai_base_url"`
+	AIAPIKey                sql.NullString    `json:"-" db:"ai_api_key"`
+	AIModel                 *string           `json:"ai_model,omitempty" db:"ai_model"`
+	AIMaxTokens             *int              `json:"ai_max_tokens,omitempty" db:"ai_max_tokens"`
+	AIAutoAnalyze           *bool             `json:"ai_auto_analyze,omitempty" db:"ai_auto_analyze"`
+	AIMaxLogLines           *int              `json:"ai_max_log_lines,omitempty" db:"ai_max_log_lines"`
+	AILogTruncateStrategy   *TruncateStrategy `json:"ai_log_truncate_strategy,omitempty" db:"ai_log_truncate_strategy"`
+	AISystemPrompt          *string           `json:"ai_system_prompt,omitempty" db:"ai_system_prompt"`
+	AIUserPromptTemplate    *string           `json:"ai_user_prompt_template,omitempty" db:"ai_user_prompt_template"`
+	AIReportLanguage        *string           `json:"ai_report_language,omitempty" db:"ai_report_language"`
+	AIMaxConcurrent         *int              `json:"ai_max_concurrent,omitempty" db:"ai_max_concurrent"`
+	AIRequestTimeoutSeconds *int              `json:"ai_request_timeout_seconds,omitempty" db:"ai_request_timeout_seconds"`
+	AIRedactSecrets         *bool             `json:"ai_redact_secrets,omitempty" db:"ai_redact_secrets"`
+	AIRedactExtraPatterns   pq.StringArray    `json:"ai_redact_extra_patterns,omitempty" db:"ai_redact_extra_patterns"`
 
 	// Metadata
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
@@ -192,16 +537,22 @@ type ProjectSettings struct {
 // EffectiveSettings represents the merged configuration (project overrides user)
 type EffectiveSettings struct {
 	// AI Configuration
-	AIEnabled             bool             `json:"ai_enabled"`
-	AIBaseURL             string           `json:"ai_base_url"`
-	AIAPIKey              string           `json:"-"` // Never expose
-	AIModel               string           `json:"ai_model"`
-	AIMaxTokens           int              `json:"ai_max_tokens"`
-	AIAutoAnalyze         bool             `json:"ai_auto_analyze"`
-	AIMaxLogLines         int              `json:"ai_max_log_lines"`
-	AILogTruncateStrategy TruncateStrategy `json:"ai_log_truncate_strategy"`
-	AISystemPrompt        string           `json:"ai_system_prompt"`
-	AIMaxConcurrent       int              `json:"ai_max_concurrent"`
+	AIEnabled               bool             `json:"ai_enabled"`
+	AIBaseURL               string           `json:"ai_base_url"`
+	AIAPIKey                string           `json:"-"` // Never expose
+	AIModel                 string           `json:"ai_model"`
+	AIMaxTokens             int              `json:"ai_max_tokens"`
+	AIAutoAnalyze           bool             `json:"ai_auto_analyze"`
+	AIMaxLogLines           int              `json:"ai_max_log_lines"`
+	AILogTruncateStrategy   TruncateStrategy `json:"ai_log_truncate_strategy"`
+	AISystemPrompt          string           `json:"ai_system_prompt"`
+	AIUserPromptTemplate    string           `json:"ai_user_prompt_template"`
+	AIReportLanguage        string           `json:"ai_report_language"`
+	AIMonthlyTokenQuota     *int64           `json:"ai_monthly_token_quota"` // nil = unlimited
+	AIMaxConcurrent         int              `json:"ai_max_concurrent"`
+	AIRequestTimeoutSeconds int              `json:"ai_request_timeout_seconds"`
+	AIRedactSecrets         bool             `json:"ai_redact_secrets"`
+	AIRedactExtraPatterns   []string         `json:"ai_redact_extra_patterns"`
 
 	// Source indicator
 	Source string `json:"source"` // "user", "project", "merged"
@@ -0,0 +1,164 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Task type identifiers used with PublishTask/ConsumeTask.
+const (
+	// TaskTypeRetentionCleanup deletes log runs older than a configured
+	// retention window; see cmd/ai-worker's retention cleanup handler.
+	TaskTypeRetentionCleanup = "retention_cleanup"
+)
+
+const (
+	// genericQueueKey is the Redis list backing PublishTask/ConsumeTask,
+	// shared by job kinds that don't need their own dedicated queue.
+	genericQueueKey = "swiftlog:tasks:queue"
+	// genericDLQKey holds generic envelopes that either exhausted their
+	// requeue attempts or arrived with a Type no handler was registered
+	// for.
+	genericDLQKey = "swiftlog:tasks:dlq"
+	// genericDLQMaxLen mirrors aiDLQMaxLen's oldest-eviction cap, kept
+	// separate since the generic and AI-analysis DLQs are unrelated lists.
+	genericDLQMaxLen = 500
+	// genericDLQEvictionsKey counts entries evicted from the generic DLQ
+	// for exceeding genericDLQMaxLen.
+	genericDLQEvictionsKey = "swiftlog:tasks:dlq:evictions"
+)
+
+// TaskEnvelope is the generic wire format for background jobs that don't
+// need AI analysis's specialized queue — its priority split, processing-list
+// reclaim, per-run dedup guard, and per-user stats. New job kinds (retention
+// cleanup, bulk delete, webhook delivery, scheduled re-analysis, ...) publish
+// and consume through this envelope and a worker.Dispatcher instead of each
+// growing its own queue plumbing. PublishAITask/ConsumeAITask remain their
+// own thin wrappers around AIAnalysisTask rather than routing through this
+// envelope, since AI analysis already depends on that specialized machinery.
+type TaskEnvelope struct {
+	Type      string          `json:"type"`
+	ID        uuid.UUID       `json:"id"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Attempts  int             `json:"attempts,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// genericDLQEntry is a TaskEnvelope enriched with why and when it landed in
+// the generic dead-letter queue, mirroring DLQEntry for the AI analysis one.
+type genericDLQEntry struct {
+	TaskEnvelope
+	LastError string    `json:"last_error,omitempty"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// PublishTask enqueues a generic background job of taskType, identified by
+// id (typically the entity the job operates on), with payload marshaled into
+// the envelope's Payload field.
+func (q *Queue) PublishTask(ctx context.Context, taskType string, id uuid.UUID, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	raw, err := json.Marshal(TaskEnvelope{
+		Type:      taskType,
+		ID:        id,
+		Payload:   data,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal task envelope: %w", err)
+	}
+
+	if err := q.client.LPush(ctx, genericQueueKey, raw).Err(); err != nil {
+		return fmt.Errorf("failed to publish task: %w", err)
+	}
+	return nil
+}
+
+// ConsumeTask waits up to timeout for the next generic background job,
+// returning nil if none arrives before the timeout or ctx is cancelled.
+func (q *Queue) ConsumeTask(ctx context.Context, timeout time.Duration) (*TaskEnvelope, error) {
+	result, err := q.client.BRPop(ctx, timeout, genericQueueKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to consume task: %w", err)
+	}
+
+	// BRPop returns [key, value].
+	var envelope TaskEnvelope
+	if err := json.Unmarshal([]byte(result[1]), &envelope); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task envelope: %w", err)
+	}
+	return &envelope, nil
+}
+
+// RequeueTask re-publishes envelope with Attempts incremented, or moves it to
+// the generic dead-letter queue if that now exceeds maxRequeueAttempts —
+// the same limit RequeueAITask enforces for the AI analysis queue.
+func (q *Queue) RequeueTask(ctx context.Context, envelope TaskEnvelope, lastError string) error {
+	envelope.Attempts++
+	if envelope.Attempts > maxRequeueAttempts {
+		return pushGenericDLQ(ctx, q.client, envelope, lastError)
+	}
+
+	raw, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task envelope: %w", err)
+	}
+	if err := q.client.LPush(ctx, genericQueueKey, raw).Err(); err != nil {
+		return fmt.Errorf("failed to requeue task: %w", err)
+	}
+	return nil
+}
+
+// PushGenericDLQEntry moves envelope straight to the generic dead-letter
+// queue, e.g. because its Type has no registered handler.
+func (q *Queue) PushGenericDLQEntry(ctx context.Context, envelope TaskEnvelope, lastError string) error {
+	return pushGenericDLQ(ctx, q.client, envelope, lastError)
+}
+
+// pushGenericDLQ is shared by Queue and StreamsQueue: the generic
+// dead-letter queue is always a plain Redis list regardless of which
+// backend the live task queue uses.
+func pushGenericDLQ(ctx context.Context, client *redis.Client, envelope TaskEnvelope, lastError string) error {
+	data, err := json.Marshal(genericDLQEntry{
+		TaskEnvelope: envelope,
+		LastError:    lastError,
+		FailedAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal DLQ entry: %w", err)
+	}
+
+	if err := client.LPush(ctx, genericDLQKey, data).Err(); err != nil {
+		return fmt.Errorf("failed to push DLQ entry: %w", err)
+	}
+
+	length, err := client.LLen(ctx, genericDLQKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read DLQ length: %w", err)
+	}
+	if length > genericDLQMaxLen {
+		evicted := length - genericDLQMaxLen
+		if err := client.LTrim(ctx, genericDLQKey, 0, genericDLQMaxLen-1).Err(); err != nil {
+			return fmt.Errorf("failed to trim DLQ: %w", err)
+		}
+		if err := client.IncrBy(ctx, genericDLQEvictionsKey, evicted).Err(); err != nil {
+			return fmt.Errorf("failed to record DLQ eviction: %w", err)
+		}
+	}
+
+	return nil
+}
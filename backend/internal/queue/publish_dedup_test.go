@@ -0,0 +1,93 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestQueue(t *testing.T) (*Queue, *miniredis.Miniredis) {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewQueue(client), mr
+}
+
+// TestPublishAITask_DedupesStandardTasks covers the duplicate-task guard:
+// clicking "Analyze" twice for the same run must not enqueue it twice.
+func TestPublishAITask_DedupesStandardTasks(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ctx := context.Background()
+	runID, userID := uuid.New(), uuid.New()
+
+	if err := q.PublishAITask(ctx, runID, userID, false, "", "", 0, false); err != nil {
+		t.Fatalf("first publish failed: %v", err)
+	}
+	if err := q.PublishAITask(ctx, runID, userID, false, "", "", 0, false); err != ErrAlreadyQueued {
+		t.Fatalf("second publish = %v, want ErrAlreadyQueued", err)
+	}
+
+	length, err := q.GetQueueLength(ctx)
+	if err != nil {
+		t.Fatalf("GetQueueLength failed: %v", err)
+	}
+	if length != 1 {
+		t.Fatalf("queue length = %d, want 1 (duplicate task must not be enqueued)", length)
+	}
+}
+
+// TestPublishAITask_ClearInFlightAllowsRequeue covers the guard's release
+// path: once a task's in-flight lock is cleared, a fresh standard task for
+// the same run can be queued again.
+func TestPublishAITask_ClearInFlightAllowsRequeue(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ctx := context.Background()
+	runID, userID := uuid.New(), uuid.New()
+
+	if err := q.PublishAITask(ctx, runID, userID, false, "", "", 0, false); err != nil {
+		t.Fatalf("first publish failed: %v", err)
+	}
+	if err := q.ClearInFlight(ctx, runID); err != nil {
+		t.Fatalf("ClearInFlight failed: %v", err)
+	}
+	if err := q.PublishAITask(ctx, runID, userID, false, "", "", 0, false); err != nil {
+		t.Fatalf("publish after clearing in-flight guard failed: %v", err)
+	}
+}
+
+// TestPublishAITask_ForceAndOverrideSkipDedup covers the documented
+// exemptions: force and custom-prompt tasks are allowed alongside a standard
+// task already queued for the same run.
+func TestPublishAITask_ForceAndOverrideSkipDedup(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ctx := context.Background()
+	runID, userID := uuid.New(), uuid.New()
+
+	if err := q.PublishAITask(ctx, runID, userID, false, "", "", 0, false); err != nil {
+		t.Fatalf("standard publish failed: %v", err)
+	}
+	if err := q.PublishAITask(ctx, runID, userID, true, "", "", 0, false); err != nil {
+		t.Fatalf("forced publish should skip the dedup guard: %v", err)
+	}
+	if err := q.PublishAITask(ctx, runID, userID, false, "", "custom prompt", 0, false); err != nil {
+		t.Fatalf("custom-prompt publish should skip the dedup guard: %v", err)
+	}
+
+	length, err := q.GetQueueLength(ctx)
+	if err != nil {
+		t.Fatalf("GetQueueLength failed: %v", err)
+	}
+	if length != 3 {
+		t.Fatalf("queue length = %d, want 3", length)
+	}
+}
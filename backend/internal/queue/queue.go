@@ -3,25 +3,245 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/aliancn/swiftlog/backend/internal/queue")
+
 const (
 	// AIAnalysisQueue is the Redis key for AI analysis task queue
 	AIAnalysisQueue = "swiftlog:ai:queue"
+	// AIAnalysisQueueLow is the Redis key for lower-priority AI analysis
+	// tasks (e.g. bulk retries), only drained once AIAnalysisQueue is empty.
+	AIAnalysisQueueLow = "swiftlog:ai:queue:low"
 	// AIAnalysisNotify is the Redis pub/sub channel for AI analysis notifications
 	AIAnalysisNotify = "swiftlog:ai:notify"
+	// AIAnalysisQueued is the Redis set of run IDs with a task currently
+	// sitting in either queue, used to dedupe re-enqueue requests.
+	AIAnalysisQueued = "swiftlog:ai:queued"
+	// AIAnalysisCancel is the Redis pub/sub channel for cancel signals,
+	// keyed by run ID, asking whichever worker has a run in flight to abort it.
+	AIAnalysisCancel = "swiftlog:ai:cancel"
+	// aiInFlightPrefix namespaces the per-run duplicate-task guard
+	// (aiInFlightPrefix + run ID), held from PublishAITask until the task
+	// finishes, so clicking "Analyze" twice (or auto-analyze racing a manual
+	// trigger) doesn't enqueue and pay for the same run twice.
+	aiInFlightPrefix = "swiftlog:ai:inflight:"
+	// aiInFlightTTL bounds how long a duplicate-task guard can outlive its
+	// task if a worker crashes before clearing it, so a stuck lock doesn't
+	// block analysis for a run forever.
+	aiInFlightTTL = 2 * time.Hour
+	// aiProcessingPrefix namespaces each consumer's processing list
+	// (aiProcessingPrefix + consumer ID): the list a task lives in between
+	// being moved off the main queue and being acknowledged as finished.
+	aiProcessingPrefix = "swiftlog:ai:processing:"
+	// aiProcessingTimesPrefix namespaces the ZSET tracking, per consumer,
+	// when each entry in its processing list was picked up (member: the raw
+	// task JSON, score: pop time as a Unix timestamp), so Reclaim can find
+	// entries that have sat there longer than the visibility timeout.
+	aiProcessingTimesPrefix = "swiftlog:ai:processing:times:"
+	// aiProcessingConsumers is the set of consumer IDs that have ever
+	// consumed a task, so Reclaim can enumerate processing lists to check
+	// without an unbounded KEYS scan.
+	aiProcessingConsumers = "swiftlog:ai:processing:consumers"
+	// defaultVisibilityTimeout bounds how long a task can sit in a
+	// consumer's processing list before Reclaim assumes the consumer died
+	// and puts it back on the main queue.
+	defaultVisibilityTimeout = 10 * time.Minute
+	// aiStatusPrefix namespaces the per-run task status hash
+	// (aiStatusPrefix + run ID) that backs GET /runs/:id/analysis-status.
+	aiStatusPrefix = "swiftlog:ai:status:"
+	// aiStatusTerminalTTL bounds how long a completed/failed/cancelled
+	// task's status hash lingers after the fact, so the UI has time to pick
+	// up the final state without the key living forever.
+	aiStatusTerminalTTL = 24 * time.Hour
+	// aiUserStatsPrefix namespaces the daily per-user queue stats hash
+	// (aiUserStatsPrefix + "YYYY-MM-DD"), holding enqueued/completed counters
+	// and accumulated wait time per user, keyed by field name
+	// ("enqueued:<userID>", "completed:<userID>", "wait_ms:<userID>",
+	// "wait_count:<userID>").
+	aiUserStatsPrefix = "swiftlog:ai:userstats:"
+	// aiUserStatsTTL bounds how long a day's stats bucket lives, so per-user
+	// counters decay naturally instead of accumulating forever.
+	aiUserStatsTTL = 7 * 24 * time.Hour
+	// aiDLQKey is the Redis list of tasks that ran out of requeue attempts,
+	// newest first, so admins can inspect and manually requeue or discard
+	// them instead of them silently vanishing.
+	aiDLQKey = "swiftlog:ai:dlq"
+	// aiDLQMaxLen caps how many entries the DLQ retains; once exceeded, the
+	// oldest entries are trimmed off and counted in aiDLQEvictionsKey so that
+	// loss under sustained overflow is at least observable, not silent.
+	aiDLQMaxLen = 500
+	// aiDLQEvictionsKey counts how many DLQ entries have been evicted for
+	// exceeding aiDLQMaxLen, since Redis's LTRIM otherwise drops them with no
+	// trace.
+	aiDLQEvictionsKey = "swiftlog:ai:dlq:evictions"
+	// maxRequeueAttempts bounds how many times RequeueAITask will put a task
+	// that was popped but never finished (worker crash, shutdown, reclaim)
+	// back on the queue before giving up and moving it to the DLQ instead.
+	maxRequeueAttempts = 5
+	// aiExpiredTasksKey counts tasks ExpireAITask has moved to the DLQ for
+	// sitting past the worker's configured max task age, surfaced through
+	// GetQueueStats so a flood of stale-task expiry after an outage is
+	// visible rather than silent.
+	aiExpiredTasksKey = "swiftlog:ai:expired_count"
 )
 
+// Task status states recorded in the per-run status hash and returned by
+// GetTaskStatus.
+const (
+	TaskStateQueued     = "queued"
+	TaskStateProcessing = "processing"
+	TaskStateCompleted  = "completed"
+	TaskStateFailed     = "failed"
+	TaskStateCancelled  = "cancelled"
+)
+
+// TaskStatus is the queryable status of a run's AI analysis task, backing
+// GET /api/v1/runs/:id/analysis-status.
+type TaskStatus struct {
+	State string `json:"state"`
+	// Priority is "standard" for a task published via PublishAITask, or
+	// "low" for one on the retry/requeue queue.
+	Priority  string     `json:"priority,omitempty"`
+	Attempts  int        `json:"attempts"`
+	WorkerID  string     `json:"worker_id,omitempty"`
+	QueuedAt  *time.Time `json:"queued_at,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	// QueuePosition is a snapshot of how many tasks were ahead of this one
+	// in its queue at the moment it was published; it isn't kept up to date
+	// as other tasks are consumed.
+	QueuePosition *int64 `json:"queue_position,omitempty"`
+}
+
+// UserQueueStats summarizes one user's AI analysis queue activity for a
+// single day: how many tasks they enqueued, how many finished, and how long
+// those finished tasks spent waiting between being queued and picked up.
+type UserQueueStats struct {
+	UserID         uuid.UUID `json:"user_id,omitempty"`
+	Enqueued       int64     `json:"enqueued"`
+	Completed      int64     `json:"completed"`
+	AvgWaitSeconds float64   `json:"avg_wait_seconds"`
+}
+
+// QueueStats is a point-in-time snapshot of AI analysis queue activity,
+// bounded to the busiest users so it stays cheap to compute and render
+// regardless of how many distinct users have used the queue that day.
+type QueueStats struct {
+	Date        string           `json:"date"`
+	QueueLength int64            `json:"queue_length"`
+	TopUsers    []UserQueueStats `json:"top_users"`
+	Aggregate   UserQueueStats   `json:"aggregate"`
+	// ExpiredTasks is the lifetime count of tasks ExpireAITask has moved to
+	// the DLQ for sitting past the worker's max task age, not just today's.
+	ExpiredTasks int64 `json:"expired_tasks"`
+}
+
+// ErrAlreadyQueued is returned by PublishAITask when the run already has a
+// standard analysis queued or processing, so callers can respond without
+// enqueuing a duplicate.
+var ErrAlreadyQueued = errors.New("run already has an AI analysis queued or processing")
+
+// ErrNotInDLQ is returned by RequeueFromDLQ and RemoveDLQ when runID has no
+// entry in the dead-letter queue.
+var ErrNotInDLQ = errors.New("run not found in dead-letter queue")
+
 // AIAnalysisTask represents a task in the AI analysis queue
 type AIAnalysisTask struct {
 	RunID     uuid.UUID `json:"run_id"`
 	UserID    uuid.UUID `json:"user_id"`
 	CreatedAt time.Time `json:"created_at"`
+
+	// Force skips the reused-analysis cache lookup even if a completed run
+	// with a matching content hash exists.
+	Force bool `json:"force,omitempty"`
+
+	// Mode selects the analysis mode. Empty means the standard analysis;
+	// "diff" compares against the last successful run in the same group.
+	Mode string `json:"mode,omitempty"`
+
+	// Attempts counts how many times this task has been requeued after
+	// being popped without finishing (e.g. by a graceful shutdown drain).
+	// It's preserved across requeues rather than reset.
+	Attempts int `json:"attempts,omitempty"`
+
+	// PromptOverride, when set, replaces the user's configured system
+	// prompt for this analysis only. The result is stored as an additional
+	// analysis version rather than overwriting the run's standard AIReport.
+	PromptOverride string `json:"prompt_override,omitempty"`
+
+	// MaxTokensOverride, when non-zero, replaces the user's configured
+	// AIMaxTokens for this analysis only.
+	MaxTokensOverride int `json:"max_tokens_override,omitempty"`
+
+	// Partial marks this as an early analysis of a run that's still in
+	// progress. Its result is stored with AIStatusPartial and is expected to
+	// be superseded by the standard completion-time analysis, so it doesn't
+	// participate in the reused-analysis cache or the duplicate-task guard.
+	Partial bool `json:"partial,omitempty"`
+
+	// TraceCarrier holds the W3C trace context of whatever request or
+	// trigger caused this task to be enqueued, injected by PublishAITask.
+	// ExtractContext restores it in the worker so a run's spans (queue
+	// publish, queue wait, provider call) join the originating trace
+	// instead of starting a new, disconnected one.
+	TraceCarrier map[string]string `json:"trace_carrier,omitempty"`
+
+	// raw holds the exact bytes this task was serialized as when it was
+	// moved into a processing list by ConsumeAITask. Ack and Reclaim use it
+	// to find and remove that same list entry. Not part of the wire format.
+	raw string `json:"-"`
+}
+
+// HasOverride reports whether this task carries a one-off prompt or
+// max-tokens override, meaning its result belongs in the analysis versions
+// table instead of the run's standard AIReport.
+func (t AIAnalysisTask) HasOverride() bool {
+	return t.PromptOverride != "" || t.MaxTokensOverride != 0
+}
+
+// ExtractContext returns ctx enriched with the trace context this task was
+// published under (if any), so spans a worker starts while processing it
+// are children of the originating request's trace rather than roots of
+// their own.
+func (t AIAnalysisTask) ExtractContext(ctx context.Context) context.Context {
+	if len(t.TraceCarrier) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(t.TraceCarrier))
+}
+
+// DLQEntry is a task that exhausted its requeue attempts, enriched with why
+// and when it landed in the dead-letter queue.
+type DLQEntry struct {
+	AIAnalysisTask
+	LastError string    `json:"last_error,omitempty"`
+	FailedAt  time.Time `json:"failed_at"`
+	// Expired marks an entry pushed by ExpireAITask because the task sat
+	// past aiTaskMaxAge before a worker got to it, as opposed to one that
+	// exhausted its requeue attempts after a real error.
+	Expired bool `json:"expired,omitempty"`
+}
+
+// DLQPage is a paginated slice of the dead-letter queue plus its total size
+// and lifetime eviction count.
+type DLQPage struct {
+	Entries   []DLQEntry `json:"entries"`
+	Total     int64      `json:"total"`
+	Evictions int64      `json:"evictions"`
 }
 
 // AIAnalysisResult represents the result notification for AI analysis
@@ -33,63 +253,770 @@ type AIAnalysisResult struct {
 
 // Queue provides Redis-based task queue operations
 type Queue struct {
-	client *redis.Client
+	client            *redis.Client
+	visibilityTimeout time.Duration
 }
 
 // NewQueue creates a new Queue instance
 func NewQueue(client *redis.Client) *Queue {
-	return &Queue{client: client}
+	return &Queue{client: client, visibilityTimeout: defaultVisibilityTimeout}
+}
+
+// SetVisibilityTimeout overrides how long a task may sit in a consumer's
+// processing list before Reclaim assumes the consumer died and puts it back
+// on the main queue. Only the consumer(s) that run Reclaim need to set this;
+// producers can leave it at the default.
+func (q *Queue) SetVisibilityTimeout(d time.Duration) {
+	q.visibilityTimeout = d
 }
 
-// PublishAITask adds a new AI analysis task to the queue
-func (q *Queue) PublishAITask(ctx context.Context, runID, userID uuid.UUID) error {
+// PublishAITask adds a new AI analysis task to the queue. force skips the
+// reused-analysis cache lookup even if a matching completed run exists.
+// mode selects the analysis mode ("" for standard, "diff" to compare
+// against the last successful run in the group). promptOverride and
+// maxTokensOverride ("" and 0 for none) request a one-off analysis whose
+// result is stored as an additional analysis version rather than
+// overwriting the run's standard AIReport. partial requests an early
+// analysis of a run that hasn't finished yet; its result is stored with
+// AIStatusPartial rather than AIStatusCompleted.
+//
+// Standard (non-force, non-override, non-partial) tasks are guarded against
+// duplicates: if the run already has one queued or processing, this returns
+// ErrAlreadyQueued instead of enqueuing a second one. force and
+// custom-prompt tasks skip the guard entirely — force already means "run
+// this regardless of anything cached or in flight", and a custom-prompt
+// analysis is an additional version a user may want alongside the standard
+// one, not a duplicate of it. A partial task skips the guard too: it's
+// expected to be followed by a standard analysis once the run finishes, and
+// that shouldn't be blocked as a "duplicate" of the partial one.
+func (q *Queue) PublishAITask(ctx context.Context, runID, userID uuid.UUID, force bool, mode, promptOverride string, maxTokensOverride int, partial bool) error {
 	task := AIAnalysisTask{
+		RunID:             runID,
+		UserID:            userID,
+		CreatedAt:         time.Now().UTC(),
+		Force:             force,
+		Mode:              mode,
+		PromptOverride:    promptOverride,
+		MaxTokensOverride: maxTokensOverride,
+		Partial:           partial,
+	}
+
+	guarded := !force && !task.HasOverride() && !task.Partial
+	if guarded {
+		acquired, err := q.client.SetNX(ctx, inFlightKey(runID), "1", aiInFlightTTL).Result()
+		if err != nil {
+			return fmt.Errorf("failed to acquire AI analysis lock: %w", err)
+		}
+		if !acquired {
+			return ErrAlreadyQueued
+		}
+	}
+
+	if err := q.publish(ctx, AIAnalysisQueue, task); err != nil {
+		if guarded {
+			_ = q.client.Del(ctx, inFlightKey(runID)).Err()
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ClearInFlight releases the duplicate-task guard for runID, allowing a new
+// standard analysis to be queued for it. It's a no-op if no guard is held
+// (e.g. the finishing task was a forced or custom-prompt analysis, which
+// never acquired one).
+func (q *Queue) ClearInFlight(ctx context.Context, runID uuid.UUID) error {
+	if err := q.client.Del(ctx, inFlightKey(runID)).Err(); err != nil {
+		return fmt.Errorf("failed to clear AI analysis lock: %w", err)
+	}
+	return nil
+}
+
+func inFlightKey(runID uuid.UUID) string {
+	return aiInFlightPrefix + runID.String()
+}
+
+// PublishAIRetryTask re-enqueues a previously-failed run onto the
+// lower-priority queue, so bulk retries don't delay freshly-triggered
+// analyses. It otherwise behaves like a standard (non-force, non-diff) task.
+func (q *Queue) PublishAIRetryTask(ctx context.Context, runID, userID uuid.UUID) error {
+	return q.publish(ctx, AIAnalysisQueueLow, AIAnalysisTask{
 		RunID:     runID,
 		UserID:    userID,
 		CreatedAt: time.Now().UTC(),
-	}
+	})
+}
+
+func (q *Queue) publish(ctx context.Context, queueKey string, task AIAnalysisTask) error {
+	ctx, span := tracer.Start(ctx, "ai.queue.publish", trace.WithAttributes(
+		attribute.String("ai.run_id", task.RunID.String()),
+		attribute.String("ai.queue_key", queueKey),
+	))
+	defer span.End()
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	task.TraceCarrier = carrier
 
 	data, err := json.Marshal(task)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("failed to marshal task: %w", err)
 	}
 
+	// Snapshot the queue's current length before pushing: since LPush adds
+	// to the head and the consumer takes from the tail, this is how many
+	// tasks are ahead of the new one at the moment it's queued.
+	position, err := q.client.LLen(ctx, queueKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read queue length: %w", err)
+	}
+
 	// Use LPUSH to add task to the left of the list
-	if err := q.client.LPush(ctx, AIAnalysisQueue, data).Err(); err != nil {
+	if err := q.client.LPush(ctx, queueKey, data).Err(); err != nil {
 		return fmt.Errorf("failed to publish task: %w", err)
 	}
 
+	if err := q.client.SAdd(ctx, AIAnalysisQueued, task.RunID.String()).Err(); err != nil {
+		return fmt.Errorf("failed to record queued run: %w", err)
+	}
+
+	priority := "standard"
+	if queueKey == AIAnalysisQueueLow {
+		priority = "low"
+	}
+	// Status tracking is observability, not queue correctness: don't fail
+	// the publish over it, just leave the run without a fresher status.
+	_ = q.recordQueuedStatus(ctx, task, priority, position)
+	_ = q.recordUserEnqueued(ctx, task.UserID)
+
+	return nil
+}
+
+// recordUserEnqueued increments task.UserID's enqueued counter in today's
+// per-user stats bucket, refreshing the bucket's TTL so it decays a fixed
+// window after the last activity rather than the first.
+func (q *Queue) recordUserEnqueued(ctx context.Context, userID uuid.UUID) error {
+	key := userStatsKey(time.Now())
+	if err := q.client.HIncrBy(ctx, key, "enqueued:"+userID.String(), 1).Err(); err != nil {
+		return fmt.Errorf("failed to record user enqueue stat: %w", err)
+	}
+	if err := q.client.Expire(ctx, key, aiUserStatsTTL).Err(); err != nil {
+		return fmt.Errorf("failed to set user stats ttl: %w", err)
+	}
+	return nil
+}
+
+// recordQueuedStatus (re)writes runID's status hash for a freshly-queued
+// task, discarding whatever worker_id/started_at a previous attempt left
+// behind so a requeued task reads as "queued", not as still processing.
+func (q *Queue) recordQueuedStatus(ctx context.Context, task AIAnalysisTask, priority string, position int64) error {
+	key := statusKey(task.RunID)
+	if err := q.client.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("failed to reset task status: %w", err)
+	}
+	fields := map[string]interface{}{
+		"state":          TaskStateQueued,
+		"queued_at":      time.Now().UTC().Format(time.RFC3339Nano),
+		"priority":       priority,
+		"attempts":       task.Attempts,
+		"queue_position": position,
+	}
+	if err := q.client.HSet(ctx, key, fields).Err(); err != nil {
+		return fmt.Errorf("failed to record task status: %w", err)
+	}
+	return nil
+}
+
+// MarkTaskTerminal records that userID's task for runID reached a terminal
+// state (completed, failed, or cancelled) and sets a TTL on its status hash
+// so it doesn't linger in Redis indefinitely. A completed state also updates
+// userID's daily queue stats: its completed counter, and (if the status hash
+// still has both timestamps) the wait time between being queued and picked
+// up by a worker.
+func (q *Queue) MarkTaskTerminal(ctx context.Context, runID, userID uuid.UUID, state string) error {
+	key := statusKey(runID)
+	status, _ := q.GetTaskStatus(ctx, runID)
+
+	if err := q.client.HSet(ctx, key, "state", state).Err(); err != nil {
+		return fmt.Errorf("failed to record task status: %w", err)
+	}
+	if err := q.client.Expire(ctx, key, aiStatusTerminalTTL).Err(); err != nil {
+		return fmt.Errorf("failed to set task status ttl: %w", err)
+	}
+
+	if state == TaskStateCompleted {
+		_ = q.recordUserCompleted(ctx, userID, status)
+	}
+
 	return nil
 }
 
-// ConsumeAITask blocks and waits for the next AI analysis task
-// Returns nil task when context is cancelled
-func (q *Queue) ConsumeAITask(ctx context.Context, timeout time.Duration) (*AIAnalysisTask, error) {
-	// Use BRPOP to block and wait for task from the right of the list
-	result, err := q.client.BRPop(ctx, timeout, AIAnalysisQueue).Result()
+// recordUserCompleted increments userID's completed counter in today's
+// per-user stats bucket and, if queuedStatus carries both timestamps, adds
+// this task's wait time (queued_at to started_at) to the running total used
+// to compute AvgWaitSeconds in GetQueueStats.
+func (q *Queue) recordUserCompleted(ctx context.Context, userID uuid.UUID, queuedStatus *TaskStatus) error {
+	key := userStatsKey(time.Now())
+	if err := q.client.HIncrBy(ctx, key, "completed:"+userID.String(), 1).Err(); err != nil {
+		return fmt.Errorf("failed to record user completion stat: %w", err)
+	}
+
+	if queuedStatus != nil && queuedStatus.QueuedAt != nil && queuedStatus.StartedAt != nil {
+		waitMs := queuedStatus.StartedAt.Sub(*queuedStatus.QueuedAt).Milliseconds()
+		if waitMs > 0 {
+			if err := q.client.HIncrBy(ctx, key, "wait_ms:"+userID.String(), waitMs).Err(); err != nil {
+				return fmt.Errorf("failed to record user wait time: %w", err)
+			}
+			if err := q.client.HIncrBy(ctx, key, "wait_count:"+userID.String(), 1).Err(); err != nil {
+				return fmt.Errorf("failed to record user wait count: %w", err)
+			}
+		}
+	}
+
+	if err := q.client.Expire(ctx, key, aiUserStatsTTL).Err(); err != nil {
+		return fmt.Errorf("failed to set user stats ttl: %w", err)
+	}
+	return nil
+}
+
+// GetQueueStats returns today's per-user AI analysis queue activity,
+// aggregated across all users and broken out for the topN busiest (by tasks
+// enqueued) to keep the response bounded regardless of how many distinct
+// users touched the queue today. topN <= 0 returns every user.
+func (q *Queue) GetQueueStats(ctx context.Context, topN int) (*QueueStats, error) {
+	now := time.Now()
+	fields, err := q.client.HGetAll(ctx, userStatsKey(now)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user queue stats: %w", err)
+	}
+
+	byUser := make(map[string]*UserQueueStats)
+	waitMs := make(map[string]int64)
+	waitCount := make(map[string]int64)
+	userOf := func(userID string) *UserQueueStats {
+		s, ok := byUser[userID]
+		if !ok {
+			uid, _ := uuid.Parse(userID)
+			s = &UserQueueStats{UserID: uid}
+			byUser[userID] = s
+		}
+		return s
+	}
+
+	for field, raw := range fields {
+		metric, userID, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch metric {
+		case "enqueued":
+			userOf(userID).Enqueued = n
+		case "completed":
+			userOf(userID).Completed = n
+		case "wait_ms":
+			waitMs[userID] = n
+		case "wait_count":
+			waitCount[userID] = n
+		}
+	}
+
+	var aggregate UserQueueStats
+	var aggregateWaitMs, aggregateWaitCount int64
+	users := make([]UserQueueStats, 0, len(byUser))
+	for userID, s := range byUser {
+		if wc := waitCount[userID]; wc > 0 {
+			s.AvgWaitSeconds = float64(waitMs[userID]) / float64(wc) / 1000
+		}
+		aggregate.Enqueued += s.Enqueued
+		aggregate.Completed += s.Completed
+		aggregateWaitMs += waitMs[userID]
+		aggregateWaitCount += waitCount[userID]
+		users = append(users, *s)
+	}
+	if aggregateWaitCount > 0 {
+		aggregate.AvgWaitSeconds = float64(aggregateWaitMs) / float64(aggregateWaitCount) / 1000
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Enqueued > users[j].Enqueued })
+	if topN > 0 && len(users) > topN {
+		users = users[:topN]
+	}
+
+	queueLength, err := q.GetQueueLength(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	expiredTasks, err := q.client.Get(ctx, aiExpiredTasksKey).Int64()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get expired task count: %w", err)
+	}
+
+	return &QueueStats{
+		Date:         now.UTC().Format("2006-01-02"),
+		QueueLength:  queueLength,
+		TopUsers:     users,
+		Aggregate:    aggregate,
+		ExpiredTasks: expiredTasks,
+	}, nil
+}
+
+func userStatsKey(t time.Time) string {
+	return aiUserStatsPrefix + t.UTC().Format("2006-01-02")
+}
+
+// GetTaskStatus returns runID's current task status, or nil if it has none
+// (never queued, or its status hash already expired).
+func (q *Queue) GetTaskStatus(ctx context.Context, runID uuid.UUID) (*TaskStatus, error) {
+	values, err := q.client.HGetAll(ctx, statusKey(runID)).Result()
 	if err != nil {
-		if err == redis.Nil {
-			// Timeout, no task available
+		return nil, fmt.Errorf("failed to get task status: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	status := &TaskStatus{
+		State:    values["state"],
+		Priority: values["priority"],
+		WorkerID: values["worker_id"],
+	}
+	if attempts, err := strconv.Atoi(values["attempts"]); err == nil {
+		status.Attempts = attempts
+	}
+	if queuedAt, err := time.Parse(time.RFC3339Nano, values["queued_at"]); err == nil {
+		status.QueuedAt = &queuedAt
+	}
+	if startedAt, err := time.Parse(time.RFC3339Nano, values["started_at"]); err == nil {
+		status.StartedAt = &startedAt
+	}
+	if position, err := strconv.ParseInt(values["queue_position"], 10, 64); err == nil {
+		status.QueuePosition = &position
+	}
+
+	return status, nil
+}
+
+func statusKey(runID uuid.UUID) string {
+	return aiStatusPrefix + runID.String()
+}
+
+// RequeueAITask re-enqueues a task that was popped from the queue but never
+// finished (e.g. a worker shutting down mid-analysis), preserving its
+// Force/Mode and incrementing Attempts. It goes onto the low-priority queue
+// so a flood of requeues doesn't delay freshly-triggered analyses. Once
+// Attempts exceeds maxRequeueAttempts — the task keeps getting popped and
+// never finishing — it's moved to the dead-letter queue instead, with
+// lastError recorded as why it gave up.
+func (q *Queue) RequeueAITask(ctx context.Context, task *AIAnalysisTask, lastError string) error {
+	requeued := *task
+	requeued.Attempts++
+
+	if requeued.Attempts > maxRequeueAttempts {
+		return q.PushDLQ(ctx, &requeued, lastError)
+	}
+
+	return q.publish(ctx, AIAnalysisQueueLow, requeued)
+}
+
+// PushDLQ moves task to the dead-letter queue, recording lastError and the
+// current time, and clears its queued-run bookkeeping and status so it stops
+// showing as in-flight. If the DLQ is now over aiDLQMaxLen, the oldest
+// entries are trimmed off and counted in aiDLQEvictionsKey.
+func (q *Queue) PushDLQ(ctx context.Context, task *AIAnalysisTask, lastError string) error {
+	return q.pushDLQEntry(ctx, task, lastError, false)
+}
+
+// ExpireAITask moves task straight to the dead-letter queue flagged as
+// expired rather than errored, because it sat past the worker's configured
+// max task age before being consumed, and increments aiExpiredTasksKey.
+func (q *Queue) ExpireAITask(ctx context.Context, task *AIAnalysisTask, reason string) error {
+	if err := q.pushDLQEntry(ctx, task, reason, true); err != nil {
+		return err
+	}
+	if err := q.client.Incr(ctx, aiExpiredTasksKey).Err(); err != nil {
+		return fmt.Errorf("failed to record expired task metric: %w", err)
+	}
+	return nil
+}
+
+func (q *Queue) pushDLQEntry(ctx context.Context, task *AIAnalysisTask, lastError string, expired bool) error {
+	entry := DLQEntry{
+		AIAnalysisTask: *task,
+		LastError:      lastError,
+		FailedAt:       time.Now().UTC(),
+		Expired:        expired,
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal DLQ entry: %w", err)
+	}
+
+	if err := q.client.LPush(ctx, aiDLQKey, data).Err(); err != nil {
+		return fmt.Errorf("failed to push DLQ entry: %w", err)
+	}
+	if err := q.client.SRem(ctx, AIAnalysisQueued, task.RunID.String()).Err(); err != nil {
+		return fmt.Errorf("failed to clear queued run: %w", err)
+	}
+	_ = q.client.HSet(ctx, statusKey(task.RunID), map[string]interface{}{
+		"state": TaskStateFailed,
+	}).Err()
+
+	length, err := q.client.LLen(ctx, aiDLQKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read DLQ length: %w", err)
+	}
+	if length > aiDLQMaxLen {
+		evicted := length - aiDLQMaxLen
+		if err := q.client.LTrim(ctx, aiDLQKey, 0, aiDLQMaxLen-1).Err(); err != nil {
+			return fmt.Errorf("failed to trim DLQ: %w", err)
+		}
+		if err := q.client.IncrBy(ctx, aiDLQEvictionsKey, evicted).Err(); err != nil {
+			return fmt.Errorf("failed to record DLQ eviction: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListDLQ returns a page of the dead-letter queue (newest first), along with
+// its total size and lifetime eviction count.
+func (q *Queue) ListDLQ(ctx context.Context, offset, limit int) (*DLQPage, error) {
+	total, err := q.client.LLen(ctx, aiDLQKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DLQ length: %w", err)
+	}
+
+	evictions, err := q.client.Get(ctx, aiDLQEvictionsKey).Int64()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get DLQ eviction count: %w", err)
+	}
+
+	raw, err := q.client.LRange(ctx, aiDLQKey, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list DLQ: %w", err)
+	}
+
+	entries := make([]DLQEntry, 0, len(raw))
+	for _, r := range raw {
+		var entry DLQEntry
+		if err := json.Unmarshal([]byte(r), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return &DLQPage{Entries: entries, Total: total, Evictions: evictions}, nil
+}
+
+// RemoveDLQ removes and returns runID's entry from the dead-letter queue, or
+// ErrNotInDLQ if it has none.
+func (q *Queue) RemoveDLQ(ctx context.Context, runID uuid.UUID) (*DLQEntry, error) {
+	raws, err := q.client.LRange(ctx, aiDLQKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan DLQ: %w", err)
+	}
+
+	for _, raw := range raws {
+		var entry DLQEntry
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			continue
+		}
+		if entry.RunID != runID {
+			continue
+		}
+		if err := q.client.LRem(ctx, aiDLQKey, 1, raw).Err(); err != nil {
+			return nil, fmt.Errorf("failed to remove DLQ entry: %w", err)
+		}
+		return &entry, nil
+	}
+
+	return nil, ErrNotInDLQ
+}
+
+// RequeueFromDLQ removes runID's entry from the dead-letter queue and
+// republishes it with its attempt counter reset, giving it a fresh set of
+// requeue attempts. Returns ErrNotInDLQ if runID has no DLQ entry.
+func (q *Queue) RequeueFromDLQ(ctx context.Context, runID uuid.UUID) error {
+	entry, err := q.RemoveDLQ(ctx, runID)
+	if err != nil {
+		return err
+	}
+
+	task := entry.AIAnalysisTask
+	task.Attempts = 0
+	return q.publish(ctx, AIAnalysisQueueLow, task)
+}
+
+// IsQueued reports whether runID already has a task sitting in either queue,
+// so callers can avoid enqueuing duplicate work for the same run (and can
+// check idempotently before calling RemoveQueuedTask). Backed by the
+// AIAnalysisQueued set rather than scanning either list, so it's O(1)
+// instead of needing the same LRANGE paging RemoveQueuedTask does.
+func (q *Queue) IsQueued(ctx context.Context, runID uuid.UUID) (bool, error) {
+	queued, err := q.client.SIsMember(ctx, AIAnalysisQueued, runID.String()).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check queued run: %w", err)
+	}
+	return queued, nil
+}
+
+// ConsumeAITask waits for the next AI analysis task and moves it from
+// whichever queue it's sitting in (AIAnalysisQueue first, then the
+// lower-priority AIAnalysisQueueLow) into consumerID's processing list,
+// rather than removing it outright. It stays there — visible to Reclaim —
+// until Ack confirms the task actually finished, so a consumer that crashes
+// mid-task doesn't lose it. Returns nil task when no task arrives before
+// timeout or the context is cancelled.
+//
+// Redis's LMOVE/BLMOVE only take a single source list, so unlike the old
+// BRPOP-based version this polls both queues rather than blocking on both at
+// once; pollInterval keeps that cheap while still checking a priority queue
+// promptly after the low one comes up empty.
+func (q *Queue) ConsumeAITask(ctx context.Context, consumerID string, timeout time.Duration) (*AIAnalysisTask, error) {
+	const pollInterval = 200 * time.Millisecond
+
+	if err := q.client.SAdd(ctx, aiProcessingConsumers, consumerID).Err(); err != nil {
+		return nil, fmt.Errorf("failed to register consumer: %w", err)
+	}
+	processingKey := processingListKey(consumerID)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		for _, key := range []string{AIAnalysisQueue, AIAnalysisQueueLow} {
+			raw, err := q.client.LMove(ctx, key, processingKey, "RIGHT", "LEFT").Result()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to consume task: %w", err)
+			}
+
+			var task AIAnalysisTask
+			if err := json.Unmarshal([]byte(raw), &task); err != nil {
+				// Malformed entry: drop it from the processing list rather
+				// than leaving it there to be reclaimed forever.
+				_ = q.client.LRem(ctx, processingKey, 1, raw).Err()
+				return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+			}
+			task.raw = raw
+
+			if err := q.client.ZAdd(ctx, processingTimesKey(consumerID), redis.Z{
+				Score:  float64(time.Now().Unix()),
+				Member: raw,
+			}).Err(); err != nil {
+				return nil, fmt.Errorf("failed to record processing time: %w", err)
+			}
+			if err := q.client.SRem(ctx, AIAnalysisQueued, task.RunID.String()).Err(); err != nil {
+				return nil, fmt.Errorf("failed to clear queued run: %w", err)
+			}
+
+			// Status tracking is observability, not queue correctness: don't
+			// fail the consume over it.
+			_ = q.client.HSet(ctx, statusKey(task.RunID), map[string]interface{}{
+				"state":      TaskStateProcessing,
+				"worker_id":  consumerID,
+				"started_at": time.Now().UTC().Format(time.RFC3339Nano),
+			}).Err()
+
+			return &task, nil
+		}
+
+		if ctx.Err() != nil || time.Now().After(deadline) {
 			return nil, nil
 		}
-		if ctx.Err() != nil {
-			// Context cancelled
+		select {
+		case <-ctx.Done():
 			return nil, nil
+		case <-time.After(pollInterval):
 		}
-		return nil, fmt.Errorf("failed to consume task: %w", err)
 	}
+}
 
-	// result[0] is the key, result[1] is the value
-	if len(result) < 2 {
-		return nil, fmt.Errorf("invalid result from BRPOP")
+// Ack confirms that consumerID finished task (successfully or with a
+// terminal failure it isn't going to retry itself), removing it from the
+// processing list and time-tracking ZSET it was consumed into so Reclaim
+// never sees it again.
+func (q *Queue) Ack(ctx context.Context, consumerID string, task *AIAnalysisTask) error {
+	if task.raw == "" {
+		// Not something ConsumeAITask handed out (e.g. a task built by
+		// hand for a test); nothing to clean up.
+		return nil
 	}
+	if err := q.client.LRem(ctx, processingListKey(consumerID), 1, task.raw).Err(); err != nil {
+		return fmt.Errorf("failed to ack task: %w", err)
+	}
+	if err := q.client.ZRem(ctx, processingTimesKey(consumerID), task.raw).Err(); err != nil {
+		return fmt.Errorf("failed to clear processing time: %w", err)
+	}
+	return nil
+}
 
-	var task AIAnalysisTask
-	if err := json.Unmarshal([]byte(result[1]), &task); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+// Reclaim scans every consumer that has ever handled a task and re-enqueues
+// (onto the low-priority queue, with Attempts incremented) any entry that's
+// been sitting in that consumer's processing list longer than the
+// visibility timeout without being Ack'd — almost always because the
+// consumer crashed mid-task. It returns the number of tasks reclaimed.
+func (q *Queue) Reclaim(ctx context.Context) (int, error) {
+	consumers, err := q.client.SMembers(ctx, aiProcessingConsumers).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list consumers: %w", err)
+	}
+
+	cutoff := float64(time.Now().Add(-q.visibilityTimeout).Unix())
+	reclaimed := 0
+
+	for _, consumerID := range consumers {
+		timesKey := processingTimesKey(consumerID)
+		stale, err := q.client.ZRangeByScore(ctx, timesKey, &redis.ZRangeBy{
+			Min: "-inf",
+			Max: fmt.Sprintf("%f", cutoff),
+		}).Result()
+		if err != nil {
+			return reclaimed, fmt.Errorf("failed to scan processing times for %s: %w", consumerID, err)
+		}
+
+		for _, raw := range stale {
+			var task AIAnalysisTask
+			if err := json.Unmarshal([]byte(raw), &task); err != nil {
+				// Can't republish something we can't parse; just drop it so
+				// it stops being picked up as stale on every sweep.
+				_ = q.client.LRem(ctx, processingListKey(consumerID), 1, raw).Err()
+				_ = q.client.ZRem(ctx, timesKey, raw).Err()
+				continue
+			}
+
+			if err := q.client.LRem(ctx, processingListKey(consumerID), 1, raw).Err(); err != nil {
+				return reclaimed, fmt.Errorf("failed to remove stale entry for run %s: %w", task.RunID, err)
+			}
+			if err := q.client.ZRem(ctx, timesKey, raw).Err(); err != nil {
+				return reclaimed, fmt.Errorf("failed to clear stale processing time for run %s: %w", task.RunID, err)
+			}
+
+			if err := q.RequeueAITask(ctx, &task, "reclaimed after visibility timeout: consumer presumed dead"); err != nil {
+				return reclaimed, fmt.Errorf("failed to requeue reclaimed run %s: %w", task.RunID, err)
+			}
+			reclaimed++
+		}
 	}
 
-	return &task, nil
+	return reclaimed, nil
+}
+
+func processingListKey(consumerID string) string {
+	return aiProcessingPrefix + consumerID
+}
+
+func processingTimesKey(consumerID string) string {
+	return aiProcessingTimesPrefix + consumerID
+}
+
+// queueScanPageSize bounds how many entries RemoveQueuedTask reads from a
+// pending list per LRANGE call, so scanning a large queue for one run ID
+// doesn't pull the whole list into memory at once.
+const queueScanPageSize = 200
+
+// RemoveQueuedTask removes runID's task from whichever queue (high or low
+// priority) it's currently sitting in, if any, and clears its dedup entry.
+// It returns true if a queued task was found and removed; false means the
+// run wasn't queued — either it was never queued, or (a benign race with an
+// actively consuming worker) it was already popped into a processing list by
+// the time this ran, in which case the in-flight cancel-signal path is what
+// actually stops it.
+//
+// There's no side index from run ID to list entry, so this pages through
+// each queue with LRANGE rather than reading it in one shot.
+func (q *Queue) RemoveQueuedTask(ctx context.Context, runID uuid.UUID) (bool, error) {
+	for _, queueKey := range []string{AIAnalysisQueue, AIAnalysisQueueLow} {
+		for start := int64(0); ; start += queueScanPageSize {
+			entries, err := q.client.LRange(ctx, queueKey, start, start+queueScanPageSize-1).Result()
+			if err != nil {
+				return false, fmt.Errorf("failed to scan queue %s: %w", queueKey, err)
+			}
+			if len(entries) == 0 {
+				break
+			}
+
+			for _, entry := range entries {
+				var task AIAnalysisTask
+				if err := json.Unmarshal([]byte(entry), &task); err != nil {
+					continue
+				}
+				if task.RunID != runID {
+					continue
+				}
+
+				if err := q.client.LRem(ctx, queueKey, 1, entry).Err(); err != nil {
+					return false, fmt.Errorf("failed to remove queued task: %w", err)
+				}
+				if err := q.client.SRem(ctx, AIAnalysisQueued, runID.String()).Err(); err != nil {
+					return false, fmt.Errorf("failed to clear queued run: %w", err)
+				}
+				return true, nil
+			}
+
+			if int64(len(entries)) < queueScanPageSize {
+				break
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// PublishCancelSignal asks whichever worker currently has runID in flight to
+// abort it. It's a best-effort signal: if the analysis has already finished,
+// nothing is listening for it and it's silently dropped.
+func (q *Queue) PublishCancelSignal(ctx context.Context, runID uuid.UUID) error {
+	if err := q.client.Publish(ctx, AIAnalysisCancel, runID.String()).Err(); err != nil {
+		return fmt.Errorf("failed to publish cancel signal: %w", err)
+	}
+	return nil
+}
+
+// SubscribeCancelSignals subscribes to cancel signals published by
+// PublishCancelSignal.
+func (q *Queue) SubscribeCancelSignals(ctx context.Context) <-chan uuid.UUID {
+	ch := make(chan uuid.UUID, 100)
+
+	go func() {
+		defer close(ch)
+
+		pubsub := q.client.Subscribe(ctx, AIAnalysisCancel)
+		defer pubsub.Close()
+
+		msgCh := pubsub.Channel()
+
+		for {
+			select {
+			case msg := <-msgCh:
+				if msg == nil {
+					return
+				}
+				runID, err := uuid.Parse(msg.Payload)
+				if err != nil {
+					continue
+				}
+				select {
+				case ch <- runID:
+				default:
+					// Channel full, skip
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
 }
 
 // NotifyAIResult publishes an AI analysis result notification
@@ -0,0 +1,126 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestConsumeAck_RemovesFromProcessingList covers the happy path of the
+// reliable-queue handshake: a consumed task sits in the consumer's
+// processing list until Ack'd, then Reclaim has nothing left to find.
+func TestConsumeAck_RemovesFromProcessingList(t *testing.T) {
+	q, _ := newTestQueue(t)
+	q.SetVisibilityTimeout(time.Millisecond)
+	ctx := context.Background()
+	runID, userID := uuid.New(), uuid.New()
+
+	if err := q.PublishAITask(ctx, runID, userID, false, "", "", 0, false); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	task, err := q.ConsumeAITask(ctx, "consumer-1", time.Second)
+	if err != nil {
+		t.Fatalf("ConsumeAITask failed: %v", err)
+	}
+	if task == nil || task.RunID != runID {
+		t.Fatalf("ConsumeAITask returned %+v, want task for %s", task, runID)
+	}
+
+	if err := q.Ack(ctx, "consumer-1", task); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	reclaimed, err := q.Reclaim(ctx)
+	if err != nil {
+		t.Fatalf("Reclaim failed: %v", err)
+	}
+	if reclaimed != 0 {
+		t.Fatalf("Reclaim reclaimed %d tasks, want 0 (task was already Ack'd)", reclaimed)
+	}
+}
+
+// TestReclaim_RedeliversTaskFromCrashedConsumer is the "kill a fake worker
+// mid-task" scenario: a task that's consumed but never Ack'd must be
+// redelivered exactly once, with its attempt count bumped, once the
+// visibility timeout elapses.
+func TestReclaim_RedeliversTaskFromCrashedConsumer(t *testing.T) {
+	q, _ := newTestQueue(t)
+	// Reclaim buckets processing times to whole seconds (ZAdd scores them
+	// with time.Now().Unix()), so the timeout has to be at least a second
+	// for "stale" and "just consumed" to land in different buckets.
+	q.SetVisibilityTimeout(time.Second)
+	ctx := context.Background()
+	runID, userID := uuid.New(), uuid.New()
+
+	if err := q.PublishAITask(ctx, runID, userID, false, "", "", 0, false); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	task, err := q.ConsumeAITask(ctx, "doomed-consumer", time.Second)
+	if err != nil {
+		t.Fatalf("ConsumeAITask failed: %v", err)
+	}
+	if task == nil {
+		t.Fatal("ConsumeAITask returned no task")
+	}
+	// The consumer "crashes" here: no Ack is ever sent.
+
+	time.Sleep(1200 * time.Millisecond)
+	reclaimed, err := q.Reclaim(ctx)
+	if err != nil {
+		t.Fatalf("Reclaim failed: %v", err)
+	}
+	if reclaimed != 1 {
+		t.Fatalf("Reclaim reclaimed %d tasks, want 1", reclaimed)
+	}
+
+	redelivered, err := q.ConsumeAITask(ctx, "replacement-consumer", time.Second)
+	if err != nil {
+		t.Fatalf("ConsumeAITask after reclaim failed: %v", err)
+	}
+	if redelivered == nil || redelivered.RunID != runID {
+		t.Fatalf("redelivered task = %+v, want task for %s", redelivered, runID)
+	}
+	if redelivered.Attempts != 1 {
+		t.Fatalf("redelivered.Attempts = %d, want 1", redelivered.Attempts)
+	}
+
+	// A second reclaim sweep must not find anything else to redeliver: the
+	// task was moved off the crashed consumer's processing list, not
+	// duplicated onto it.
+	reclaimed, err = q.Reclaim(ctx)
+	if err != nil {
+		t.Fatalf("second Reclaim failed: %v", err)
+	}
+	if reclaimed != 0 {
+		t.Fatalf("second Reclaim reclaimed %d tasks, want 0 (no double redelivery)", reclaimed)
+	}
+}
+
+// TestReclaim_LeavesFreshProcessingEntriesAlone covers the negative case: a
+// task consumed well within the visibility timeout must not be reclaimed
+// out from under a consumer that's still working on it.
+func TestReclaim_LeavesFreshProcessingEntriesAlone(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ctx := context.Background()
+	runID, userID := uuid.New(), uuid.New()
+
+	if err := q.PublishAITask(ctx, runID, userID, false, "", "", 0, false); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	if _, err := q.ConsumeAITask(ctx, "busy-consumer", time.Second); err != nil {
+		t.Fatalf("ConsumeAITask failed: %v", err)
+	}
+
+	reclaimed, err := q.Reclaim(ctx)
+	if err != nil {
+		t.Fatalf("Reclaim failed: %v", err)
+	}
+	if reclaimed != 0 {
+		t.Fatalf("Reclaim reclaimed %d tasks, want 0 (still within visibility timeout)", reclaimed)
+	}
+}
@@ -0,0 +1,295 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// TaskQueue is the interface Dispatcher drives, letting the generic
+// background-job queue be backed by either a plain Redis list (Queue,
+// selected by default) or Redis Streams with a consumer group
+// (StreamsQueue, selected via QUEUE_BACKEND=streams). AI analysis is not
+// part of this interface: PublishAITask/ConsumeAITask keep their own
+// specialized queue mechanics regardless of QUEUE_BACKEND.
+type TaskQueue interface {
+	PublishTask(ctx context.Context, taskType string, id uuid.UUID, payload interface{}) error
+	ConsumeTask(ctx context.Context, timeout time.Duration) (*TaskEnvelope, error)
+	AckTask(ctx context.Context, envelope TaskEnvelope) error
+	RequeueTask(ctx context.Context, envelope TaskEnvelope, lastError string) error
+	PushGenericDLQEntry(ctx context.Context, envelope TaskEnvelope, lastError string) error
+}
+
+// AckTask is a no-op for the list backend: BRPop already removed the entry
+// from genericQueueKey the moment ConsumeTask returned it, so there's
+// nothing left to acknowledge. It exists so Dispatcher can call AckTask
+// unconditionally regardless of which TaskQueue backend it's driving.
+func (q *Queue) AckTask(ctx context.Context, envelope TaskEnvelope) error {
+	return nil
+}
+
+const (
+	// genericStreamKey is the Redis Stream backing StreamsQueue, analogous
+	// to genericQueueKey for the list-backed implementation.
+	genericStreamKey = "swiftlog:tasks:stream"
+	// genericStreamGroup is the single consumer group all StreamsQueue
+	// instances share, so tasks are load-balanced across worker replicas
+	// instead of each replica seeing every task.
+	genericStreamGroup = "swiftlog:tasks:dispatch"
+	// genericStreamClaimMinIdle bounds how long an entry can sit pending
+	// under a consumer before AutoClaim treats that consumer as dead and
+	// hands the entry to whichever consumer calls ConsumeTask next.
+	genericStreamClaimMinIdle = 5 * time.Minute
+	// streamPayloadField is the single field name each stream entry is
+	// stored under; the entry's value is the same JSON envelope the list
+	// backend pushes, so both backends share one wire format.
+	streamPayloadField = "envelope"
+)
+
+// StreamsQueue is the Redis Streams implementation of TaskQueue: PublishTask
+// does XADD, ConsumeTask does XREADGROUP (falling back to XAUTOCLAIM for
+// entries abandoned by a dead consumer), and AckTask does XACK+XDEL once the
+// handler finishes. It targets the multi-replica delivery tracking that the
+// list backend's processing-list-per-consumer workaround (built for AI
+// analysis) handles more awkwardly for arbitrary job kinds.
+type StreamsQueue struct {
+	client     *redis.Client
+	consumer   string
+	groupReady bool
+
+	// mu guards pending, which maps a task's envelope ID to the stream
+	// entry ID it arrived as, so AckTask/RequeueTask know what to XACK
+	// without needing the entry ID threaded through Dispatcher's handler
+	// signature.
+	mu      sync.Mutex
+	pending map[uuid.UUID]string
+}
+
+// NewStreamsQueue creates a StreamsQueue backed by client, identifying this
+// process to the consumer group as consumer (typically the same value
+// passed as an AI worker's consumerID, so both queues' logs correlate).
+func NewStreamsQueue(client *redis.Client, consumer string) *StreamsQueue {
+	return &StreamsQueue{
+		client:   client,
+		consumer: consumer,
+		pending:  make(map[uuid.UUID]string),
+	}
+}
+
+// ensureGroup creates the consumer group the first time it's needed,
+// tolerating BUSYGROUP if another replica already created it.
+func (s *StreamsQueue) ensureGroup(ctx context.Context) error {
+	if s.groupReady {
+		return nil
+	}
+	if err := s.client.XGroupCreateMkStream(ctx, genericStreamKey, genericStreamGroup, "0").Err(); err != nil && !isBusyGroup(err) {
+		return fmt.Errorf("failed to create stream consumer group: %w", err)
+	}
+	s.groupReady = true
+	return nil
+}
+
+func isBusyGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP")
+}
+
+// PublishTask enqueues a generic background job onto the stream.
+func (s *StreamsQueue) PublishTask(ctx context.Context, taskType string, id uuid.UUID, payload interface{}) error {
+	if err := s.ensureGroup(ctx); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+	raw, err := json.Marshal(TaskEnvelope{
+		Type:      taskType,
+		ID:        id,
+		Payload:   data,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal task envelope: %w", err)
+	}
+
+	if err := s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: genericStreamKey,
+		Values: map[string]interface{}{streamPayloadField: raw},
+	}).Err(); err != nil {
+		return fmt.Errorf("failed to publish task: %w", err)
+	}
+	return nil
+}
+
+// ConsumeTask reads the next unclaimed stream entry for this consumer,
+// first attempting to claim any entry left pending by a dead consumer for
+// longer than genericStreamClaimMinIdle, then falling back to a fresh read
+// via XREADGROUP. It blocks up to timeout before returning nil, nil. The
+// returned envelope stays "pending" in the consumer group until AckTask or
+// RequeueTask is called for it.
+func (s *StreamsQueue) ConsumeTask(ctx context.Context, timeout time.Duration) (*TaskEnvelope, error) {
+	if err := s.ensureGroup(ctx); err != nil {
+		return nil, err
+	}
+
+	if envelope, entryID, err := s.autoClaimOne(ctx); err != nil {
+		return nil, err
+	} else if envelope != nil {
+		s.trackPending(envelope.ID, entryID)
+		return envelope, nil
+	}
+
+	streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    genericStreamGroup,
+		Consumer: s.consumer,
+		Streams:  []string{genericStreamKey, ">"},
+		Count:    1,
+		Block:    timeout,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read task stream: %w", err)
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, nil
+	}
+
+	msg := streams[0].Messages[0]
+	envelope, err := parseStreamEnvelope(msg)
+	if err != nil {
+		return nil, err
+	}
+	s.trackPending(envelope.ID, msg.ID)
+	return envelope, nil
+}
+
+// autoClaimOne reclaims at most one entry idle for longer than
+// genericStreamClaimMinIdle, treating its previous consumer as dead.
+func (s *StreamsQueue) autoClaimOne(ctx context.Context) (*TaskEnvelope, string, error) {
+	messages, _, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   genericStreamKey,
+		Group:    genericStreamGroup,
+		Consumer: s.consumer,
+		MinIdle:  genericStreamClaimMinIdle,
+		Start:    "0-0",
+		Count:    1,
+	}).Result()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to autoclaim stale task: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, "", nil
+	}
+
+	envelope, err := parseStreamEnvelope(messages[0])
+	if err != nil {
+		return nil, "", err
+	}
+	return envelope, messages[0].ID, nil
+}
+
+func parseStreamEnvelope(msg redis.XMessage) (*TaskEnvelope, error) {
+	raw, ok := msg.Values[streamPayloadField].(string)
+	if !ok {
+		return nil, fmt.Errorf("stream entry %s missing %q field", msg.ID, streamPayloadField)
+	}
+	var envelope TaskEnvelope
+	if err := json.Unmarshal([]byte(raw), &envelope); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task envelope: %w", err)
+	}
+	return &envelope, nil
+}
+
+func (s *StreamsQueue) trackPending(taskID uuid.UUID, entryID string) {
+	s.mu.Lock()
+	s.pending[taskID] = entryID
+	s.mu.Unlock()
+}
+
+// takePending removes and returns the stream entry ID tracked for taskID,
+// if any. It's missing (ok == false) if AckTask/RequeueTask is somehow
+// called twice for the same envelope, or for an envelope this process never
+// consumed itself.
+func (s *StreamsQueue) takePending(taskID uuid.UUID) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entryID, ok := s.pending[taskID]
+	if ok {
+		delete(s.pending, taskID)
+	}
+	return entryID, ok
+}
+
+// AckTask marks envelope's stream entry as delivered, removing it from the
+// consumer group's pending list and from the stream itself.
+func (s *StreamsQueue) AckTask(ctx context.Context, envelope TaskEnvelope) error {
+	entryID, ok := s.takePending(envelope.ID)
+	if !ok {
+		return nil
+	}
+	return s.ackEntry(ctx, entryID)
+}
+
+func (s *StreamsQueue) ackEntry(ctx context.Context, entryID string) error {
+	if err := s.client.XAck(ctx, genericStreamKey, genericStreamGroup, entryID).Err(); err != nil {
+		return fmt.Errorf("failed to ack stream entry: %w", err)
+	}
+	if err := s.client.XDel(ctx, genericStreamKey, entryID).Err(); err != nil {
+		return fmt.Errorf("failed to delete acked stream entry: %w", err)
+	}
+	return nil
+}
+
+// RequeueTask acks envelope's original stream entry (so it stops counting
+// as pending) and re-publishes it with Attempts incremented as a new entry,
+// or moves it to the generic dead-letter queue if that now exceeds
+// maxRequeueAttempts, mirroring Queue.RequeueTask.
+func (s *StreamsQueue) RequeueTask(ctx context.Context, envelope TaskEnvelope, lastError string) error {
+	if entryID, ok := s.takePending(envelope.ID); ok {
+		if err := s.ackEntry(ctx, entryID); err != nil {
+			return err
+		}
+	}
+
+	envelope.Attempts++
+	if envelope.Attempts > maxRequeueAttempts {
+		return pushGenericDLQ(ctx, s.client, envelope, lastError)
+	}
+
+	raw, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task envelope: %w", err)
+	}
+	if err := s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: genericStreamKey,
+		Values: map[string]interface{}{streamPayloadField: raw},
+	}).Err(); err != nil {
+		return fmt.Errorf("failed to requeue task: %w", err)
+	}
+	return nil
+}
+
+// PushGenericDLQEntry acks envelope's original stream entry, if any this
+// process still holds pending, then moves it straight to the generic
+// dead-letter queue, shared with the list backend since both leave the DLQ
+// itself as a plain Redis list.
+func (s *StreamsQueue) PushGenericDLQEntry(ctx context.Context, envelope TaskEnvelope, lastError string) error {
+	if entryID, ok := s.takePending(envelope.ID); ok {
+		if err := s.ackEntry(ctx, entryID); err != nil {
+			return err
+		}
+	}
+	return pushGenericDLQ(ctx, s.client, envelope, lastError)
+}
@@ -0,0 +1,64 @@
+// Package quota tracks per-user AI token consumption in Redis so quota
+// checks are a cheap read/increment instead of a database aggregate on
+// every analysis.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// monthTTL bounds how long a month's usage counter lives in Redis: long
+// enough to cover the whole calendar month plus some slack, short enough
+// not to accumulate keys forever.
+const monthTTL = 32 * 24 * time.Hour
+
+// TokenUsageTracker tracks per-user, per-calendar-month (UTC) AI token
+// consumption.
+type TokenUsageTracker struct {
+	redis *redis.Client
+}
+
+// NewTokenUsageTracker creates a new TokenUsageTracker.
+func NewTokenUsageTracker(redisClient *redis.Client) *TokenUsageTracker {
+	return &TokenUsageTracker{redis: redisClient}
+}
+
+// monthKey returns the Redis key for userID's usage counter in the UTC
+// calendar month containing now. Using UTC keeps month boundaries
+// unambiguous regardless of where the API/worker processes run.
+func monthKey(userID uuid.UUID, now time.Time) string {
+	return fmt.Sprintf("ai_token_usage:%s:%s", userID, now.UTC().Format("2006-01"))
+}
+
+// RecordUsage adds tokens to userID's usage counter for the current UTC
+// calendar month.
+func (t *TokenUsageTracker) RecordUsage(ctx context.Context, userID uuid.UUID, tokens int) error {
+	key := monthKey(userID, time.Now())
+	pipe := t.redis.TxPipeline()
+	pipe.IncrBy(ctx, key, int64(tokens))
+	pipe.Expire(ctx, key, monthTTL)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to record token usage: %w", err)
+	}
+	return nil
+}
+
+// CurrentUsage returns userID's token usage for the current UTC calendar
+// month, or 0 if nothing has been recorded yet.
+func (t *TokenUsageTracker) CurrentUsage(ctx context.Context, userID uuid.UUID) (int64, error) {
+	key := monthKey(userID, time.Now())
+	val, err := t.redis.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read token usage: %w", err)
+	}
+	return val, nil
+}
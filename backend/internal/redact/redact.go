@@ -0,0 +1,124 @@
+// Package redact strips likely secrets out of log content before it is
+// shipped to a third-party AI provider.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// pattern is a single named secret detector.
+type pattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// builtinPatterns covers the secret shapes we see most often in script
+// output: cloud provider keys, bearer/JWT tokens, inline password
+// assignments, PEM private key blocks, and generic high-entropy runs that
+// are almost certainly hashes or encoded keys rather than prose.
+//
+// private_key is handled separately by redactPEMBlocks rather than through
+// this table: callers hand Redact one already line-split log line per slice
+// element (see aiworker.worker's logLines[i] = log.Line), so a real PEM
+// block's BEGIN and END lines never appear in the same string and this
+// pattern's (?s) DOTALL flag has nothing to span. It stays in the table so
+// its name is still recognized as a builtin, but Redact skips it in the
+// per-line loop.
+var builtinPatterns = []pattern{
+	{"aws_access_key", regexp.MustCompile(`AKIA[0-9A-Z]{16}`)},
+	{"private_key", regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{"jwt", regexp.MustCompile(`eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"bearer_token", regexp.MustCompile(`(?i)\bBearer\s+[A-Za-z0-9\-._~+/]{20,}=*`)},
+	{"password_assignment", regexp.MustCompile(`(?i)\b(password|passwd|pwd|secret|api[_-]?key)\s*[:=]\s*\S+`)},
+	{"connection_string", regexp.MustCompile(`(?i)\b\w+://[^:\s]+:[^@\s]+@[^\s]+`)},
+	{"high_entropy_hex", regexp.MustCompile(`\b[0-9a-fA-F]{32,}\b`)},
+	{"high_entropy_base64", regexp.MustCompile(`\b[A-Za-z0-9+/]{40,}={0,2}\b`)},
+}
+
+const privateKeyPattern = "private_key"
+
+var (
+	pemBeginRE = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+	pemEndRE   = regexp.MustCompile(`-----END [A-Z ]*PRIVATE KEY-----`)
+)
+
+// redactPEMBlocks replaces PEM private key blocks in lines in place, even
+// when the BEGIN and END markers fall in different slice elements. It scans
+// for a BEGIN marker, then redacts every line up to and including the one
+// carrying the END marker, so a key never survives split across the
+// individual lines a caller passes to Redact.
+func redactPEMBlocks(lines []string, counts map[string]int) {
+	inBlock := false
+	for i, line := range lines {
+		if !inBlock {
+			if !pemBeginRE.MatchString(line) {
+				continue
+			}
+			inBlock = true
+		}
+		lines[i] = "[REDACTED:" + privateKeyPattern + "]"
+		if pemEndRE.MatchString(line) {
+			inBlock = false
+			counts[privateKeyPattern]++
+		}
+	}
+	if inBlock {
+		// BEGIN with no matching END before the log ends - still redact
+		// everything we found, but count the truncated block too rather
+		// than silently dropping it from the metadata.
+		counts[privateKeyPattern]++
+	}
+}
+
+// Redactor removes secrets from log lines, tracking how many of each
+// pattern it matched so callers can surface the count in analysis metadata.
+type Redactor struct {
+	patterns []pattern
+}
+
+// New builds a Redactor from the built-in pattern set plus any additional
+// user-supplied regexes. Extra patterns are matched under the name
+// "custom_N" (1-indexed) since they don't carry a semantic label.
+func New(extraPatterns []string) (*Redactor, error) {
+	patterns := make([]pattern, len(builtinPatterns))
+	copy(patterns, builtinPatterns)
+
+	for i, raw := range extraPatterns {
+		re, err := regexp.Compile(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", raw, err)
+		}
+		patterns = append(patterns, pattern{name: fmt.Sprintf("custom_%d", i+1), re: re})
+	}
+
+	return &Redactor{patterns: patterns}, nil
+}
+
+// Redact replaces every match of every pattern in lines with
+// "[REDACTED:<name>]", preserving line count and order. It returns the
+// redacted lines and a count of matches per pattern name.
+func (r *Redactor) Redact(lines []string) ([]string, map[string]int) {
+	counts := make(map[string]int)
+	out := make([]string, len(lines))
+	copy(out, lines)
+
+	redactPEMBlocks(out, counts)
+
+	for i, line := range out {
+		for _, p := range r.patterns {
+			if p.name == privateKeyPattern {
+				continue // handled by redactPEMBlocks above
+			}
+			matches := p.re.FindAllString(line, -1)
+			if len(matches) == 0 {
+				continue
+			}
+			counts[p.name] += len(matches)
+			line = p.re.ReplaceAllString(line, "[REDACTED:"+p.name+"]")
+		}
+		out[i] = line
+	}
+
+	return out, counts
+}
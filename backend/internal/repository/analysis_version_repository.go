@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/aliancn/swiftlog/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// AnalysisVersionRepository handles database operations for one-off,
+// custom-prompt analysis versions kept alongside a run's standard AIReport.
+type AnalysisVersionRepository struct {
+	db *sql.DB
+}
+
+// NewAnalysisVersionRepository creates a new analysis version repository
+func NewAnalysisVersionRepository(db *sql.DB) *AnalysisVersionRepository {
+	return &AnalysisVersionRepository{db: db}
+}
+
+// CreateVersion records a custom-prompt analysis for a run. promptOverride
+// and maxTokensOverride are stored empty/zero-valued when the analysis used
+// no override for that field.
+func (r *AnalysisVersionRepository) CreateVersion(ctx context.Context, runID, userID uuid.UUID, report, promptOverride string, maxTokensOverride int, category models.ErrorCategory, metadata models.JSONMap, tokensUsed int) (*models.RunAnalysisVersion, error) {
+	version := &models.RunAnalysisVersion{}
+	query := `
+		INSERT INTO run_analysis_versions (run_id, user_id, report, prompt_override, max_tokens_override, error_category, metadata, tokens_used)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, 0), $6, $7, $8)
+		RETURNING id, run_id, user_id, report, prompt_override, max_tokens_override, error_category, metadata, tokens_used, created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, runID, userID, report, promptOverride, maxTokensOverride, category, metadata, tokensUsed).Scan(
+		&version.ID,
+		&version.RunID,
+		&version.UserID,
+		&version.Report,
+		&version.PromptOverride,
+		&version.MaxTokensOverride,
+		&version.ErrorCategory,
+		&version.Metadata,
+		&version.TokensUsed,
+		&version.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create analysis version: %w", err)
+	}
+
+	return version, nil
+}
+
+// ListByRunID returns all custom-prompt analysis versions for a run, newest
+// first.
+func (r *AnalysisVersionRepository) ListByRunID(ctx context.Context, runID uuid.UUID) ([]*models.RunAnalysisVersion, error) {
+	query := `
+		SELECT id, run_id, user_id, report, prompt_override, max_tokens_override, error_category, metadata, tokens_used, created_at
+		FROM run_analysis_versions
+		WHERE run_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list analysis versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*models.RunAnalysisVersion
+	for rows.Next() {
+		version := &models.RunAnalysisVersion{}
+		if err := rows.Scan(
+			&version.ID,
+			&version.RunID,
+			&version.UserID,
+			&version.Report,
+			&version.PromptOverride,
+			&version.MaxTokensOverride,
+			&version.ErrorCategory,
+			&version.Metadata,
+			&version.TokensUsed,
+			&version.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan analysis version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate analysis versions: %w", err)
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/aliancn/swiftlog/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// ConversationRepository handles database operations for follow-up
+// question/answer exchanges about a run's AI report.
+type ConversationRepository struct {
+	db *sql.DB
+}
+
+// NewConversationRepository creates a new conversation repository
+func NewConversationRepository(db *sql.DB) *ConversationRepository {
+	return &ConversationRepository{db: db}
+}
+
+// CreateExchange records a question/answer exchange. Pass uuid.Nil for
+// conversationID to start a new conversation thread.
+func (r *ConversationRepository) CreateExchange(ctx context.Context, conversationID, runID, userID uuid.UUID, question, answer string, tokensUsed int) (*models.RunConversation, error) {
+	if conversationID == uuid.Nil {
+		conversationID = uuid.New()
+	}
+
+	exchange := &models.RunConversation{}
+	query := `
+		INSERT INTO run_conversations (conversation_id, run_id, user_id, question, answer, tokens_used)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, conversation_id, run_id, user_id, question, answer, tokens_used, created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, conversationID, runID, userID, question, answer, tokensUsed).Scan(
+		&exchange.ID,
+		&exchange.ConversationID,
+		&exchange.RunID,
+		&exchange.UserID,
+		&exchange.Question,
+		&exchange.Answer,
+		&exchange.TokensUsed,
+		&exchange.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conversation exchange: %w", err)
+	}
+
+	return exchange, nil
+}
+
+// ListByConversationID returns all exchanges in a conversation thread,
+// oldest first.
+func (r *ConversationRepository) ListByConversationID(ctx context.Context, conversationID uuid.UUID) ([]*models.RunConversation, error) {
+	query := `
+		SELECT id, conversation_id, run_id, user_id, question, answer, tokens_used, created_at
+		FROM run_conversations
+		WHERE conversation_id = $1
+		ORDER BY created_at ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversation exchanges: %w", err)
+	}
+	defer rows.Close()
+
+	var exchanges []*models.RunConversation
+	for rows.Next() {
+		exchange := &models.RunConversation{}
+		if err := rows.Scan(
+			&exchange.ID,
+			&exchange.ConversationID,
+			&exchange.RunID,
+			&exchange.UserID,
+			&exchange.Question,
+			&exchange.Answer,
+			&exchange.TokensUsed,
+			&exchange.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation exchange: %w", err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate conversation exchanges: %w", err)
+	}
+
+	return exchanges, nil
+}
+
+// CountByConversationID returns the number of exchanges in a conversation
+// thread, used to enforce a per-conversation message limit.
+func (r *ConversationRepository) CountByConversationID(ctx context.Context, conversationID uuid.UUID) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM run_conversations WHERE conversation_id = $1`
+	if err := r.db.QueryRowContext(ctx, query, conversationID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count conversation exchanges: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/aliancn/swiftlog/backend/internal/models"
+)
+
+// InstanceSettingsRepository handles database operations for the singleton
+// instance_settings row.
+type InstanceSettingsRepository struct {
+	db *sql.DB
+}
+
+// NewInstanceSettingsRepository creates a new instance settings repository.
+func NewInstanceSettingsRepository(db *sql.DB) *InstanceSettingsRepository {
+	return &InstanceSettingsRepository{db: db}
+}
+
+// Get retrieves the singleton instance settings row.
+func (r *InstanceSettingsRepository) Get(ctx context.Context) (*models.InstanceSettings, error) {
+	settings := &models.InstanceSettings{}
+	query := `
+		SELECT id, ai_pricing_overrides, password_policy, created_at, updated_at
+		FROM instance_settings
+		WHERE id = $1
+	`
+	err := r.db.QueryRowContext(ctx, query, models.InstanceSettingsID).Scan(
+		&settings.ID,
+		&settings.AIPricingOverride,
+		&settings.PasswordPolicy,
+		&settings.CreatedAt,
+		&settings.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance settings: %w", err)
+	}
+	return settings, nil
+}
+
+// UpdatePricingOverrides replaces the AI pricing overrides map.
+func (r *InstanceSettingsRepository) UpdatePricingOverrides(ctx context.Context, overrides models.JSONMap) error {
+	query := `
+		UPDATE instance_settings
+		SET ai_pricing_overrides = $1, updated_at = NOW()
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, overrides, models.InstanceSettingsID)
+	if err != nil {
+		return fmt.Errorf("failed to update instance settings: %w", err)
+	}
+	return nil
+}
+
+// UpdatePasswordPolicy replaces the configured password policy. Passing
+// the zero value reverts to DefaultPasswordPolicy.
+func (r *InstanceSettingsRepository) UpdatePasswordPolicy(ctx context.Context, policy models.PasswordPolicy) error {
+	query := `
+		UPDATE instance_settings
+		SET password_policy = $1, updated_at = NOW()
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, policy, models.InstanceSettingsID)
+	if err != nil {
+		return fmt.Errorf("failed to update instance settings: %w", err)
+	}
+	return nil
+}
+
+// EffectivePasswordPolicy returns the configured password policy, or
+// DefaultPasswordPolicy if the admin hasn't set one.
+func (r *InstanceSettingsRepository) EffectivePasswordPolicy(ctx context.Context) (models.PasswordPolicy, error) {
+	settings, err := r.Get(ctx)
+	if err != nil {
+		return models.PasswordPolicy{}, err
+	}
+	if settings.PasswordPolicy.IsZero() {
+		return models.DefaultPasswordPolicy(), nil
+	}
+	return settings.PasswordPolicy, nil
+}
@@ -8,8 +8,14 @@ import (
 
 	"github.com/aliancn/swiftlog/backend/internal/models"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/aliancn/swiftlog/backend/internal/repository")
+
 // LogRunRepository handles database operations for log runs
 type LogRunRepository struct {
 	db *sql.DB
@@ -20,15 +26,18 @@ func NewLogRunRepository(db *sql.DB) *LogRunRepository {
 	return &LogRunRepository{db: db}
 }
 
-// Create creates a new log run with the specified initial AI status
-func (r *LogRunRepository) Create(ctx context.Context, groupID uuid.UUID, initialAIStatus models.AIStatus) (*models.LogRun, error) {
+// Create creates a new log run with the specified initial AI status, tags,
+// provenance (hostname/workingDir/commandLine, each blank if the client
+// didn't send it), and optional name (also blank if the client didn't send
+// one).
+func (r *LogRunRepository) Create(ctx context.Context, groupID uuid.UUID, initialAIStatus models.AIStatus, tags models.Tags, hostname, workingDir, commandLine, name string) (*models.LogRun, error) {
 	run := &models.LogRun{}
 	query := `
-		INSERT INTO log_runs (group_id, start_time, status, ai_status)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, group_id, start_time, end_time, status, exit_code, ai_report, ai_status, created_at, updated_at
+		INSERT INTO log_runs (group_id, start_time, status, ai_status, tags, hostname, working_dir, command_line, name)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''))
+		RETURNING id, group_id, start_time, end_time, status, exit_code, ai_report, ai_status, ai_metadata, tags, hostname, working_dir, command_line, name, created_at, updated_at
 	`
-	err := r.db.QueryRowContext(ctx, query, groupID, time.Now(), models.RunStatusRunning, initialAIStatus).Scan(
+	err := r.db.QueryRowContext(ctx, query, groupID, time.Now(), models.RunStatusRunning, initialAIStatus, tags, hostname, workingDir, commandLine, name).Scan(
 		&run.ID,
 		&run.GroupID,
 		&run.StartTime,
@@ -37,6 +46,12 @@ func (r *LogRunRepository) Create(ctx context.Context, groupID uuid.UUID, initia
 		&run.ExitCode,
 		&run.AIReport,
 		&run.AIStatus,
+		&run.AIMetadata,
+		&run.Tags,
+		&run.Hostname,
+		&run.WorkingDir,
+		&run.CommandLine,
+		&run.Name,
 		&run.CreatedAt,
 		&run.UpdatedAt,
 	)
@@ -50,7 +65,7 @@ func (r *LogRunRepository) Create(ctx context.Context, groupID uuid.UUID, initia
 func (r *LogRunRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.LogRun, error) {
 	run := &models.LogRun{}
 	query := `
-		SELECT id, group_id, start_time, end_time, status, exit_code, ai_report, ai_status, created_at, updated_at
+		SELECT id, group_id, start_time, end_time, status, exit_code, ai_report, ai_status, ai_metadata, error_category, tags, hostname, working_dir, command_line, name, last_seen_at, created_at, updated_at
 		FROM log_runs
 		WHERE id = $1
 	`
@@ -63,6 +78,14 @@ func (r *LogRunRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.L
 		&run.ExitCode,
 		&run.AIReport,
 		&run.AIStatus,
+		&run.AIMetadata,
+		&run.ErrorCategory,
+		&run.Tags,
+		&run.Hostname,
+		&run.WorkingDir,
+		&run.CommandLine,
+		&run.Name,
+		&run.LastSeenAt,
 		&run.CreatedAt,
 		&run.UpdatedAt,
 	)
@@ -95,16 +118,51 @@ func (r *LogRunRepository) UpdateStatus(ctx context.Context, id uuid.UUID, statu
 	return nil
 }
 
-// ListByGroupID retrieves all log runs for a specific group
-func (r *LogRunRepository) ListByGroupID(ctx context.Context, groupID uuid.UUID, limit, offset int) ([]*models.LogRun, error) {
+// TouchLastSeen records that the ingestor just received a message (a log
+// line, batch, or heartbeat) on this run's stream, so the UI can show how
+// recently a long-running job has been active.
+func (r *LogRunRepository) TouchLastSeen(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE log_runs SET last_seen_at = $1 WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to touch log run last seen: %w", err)
+	}
+	return nil
+}
+
+// ListByGroupID retrieves log runs for a specific group, optionally filtered
+// to a single error category (nil means no filter), a single tag key/value
+// pair (nil means no filter), and/or a case-insensitive substring of the
+// run's name (nil or empty means no filter).
+func (r *LogRunRepository) ListByGroupID(ctx context.Context, groupID uuid.UUID, category *models.ErrorCategory, tagKey, tagValue, nameContains *string, limit, offset int) ([]*models.LogRun, error) {
 	query := `
-		SELECT id, group_id, start_time, end_time, status, exit_code, ai_report, ai_status, created_at, updated_at
+		SELECT id, group_id, start_time, end_time, status, exit_code, ai_report, ai_status, ai_metadata, error_category, tags, name, created_at, updated_at
 		FROM log_runs
 		WHERE group_id = $1
-		ORDER BY start_time DESC
-		LIMIT $2 OFFSET $3
 	`
-	rows, err := r.db.QueryContext(ctx, query, groupID, limit, offset)
+	args := []interface{}{groupID}
+
+	if category != nil {
+		args = append(args, *category)
+		query += fmt.Sprintf(" AND error_category = $%d", len(args))
+	}
+
+	if tagKey != nil {
+		args = append(args, *tagKey)
+		query += fmt.Sprintf(" AND tags ->> $%d", len(args))
+		args = append(args, *tagValue)
+		query += fmt.Sprintf(" = $%d", len(args))
+	}
+
+	if nameContains != nil && *nameContains != "" {
+		args = append(args, "%"+*nameContains+"%")
+		query += fmt.Sprintf(" AND name ILIKE $%d", len(args))
+	}
+
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(" ORDER BY start_time DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list log runs: %w", err)
 	}
@@ -122,6 +180,10 @@ func (r *LogRunRepository) ListByGroupID(ctx context.Context, groupID uuid.UUID,
 			&run.ExitCode,
 			&run.AIReport,
 			&run.AIStatus,
+			&run.AIMetadata,
+			&run.ErrorCategory,
+			&run.Tags,
+			&run.Name,
 			&run.CreatedAt,
 			&run.UpdatedAt,
 		)
@@ -148,10 +210,100 @@ func (r *LogRunRepository) UpdateAIReport(ctx context.Context, id uuid.UUID, rep
 	return nil
 }
 
-// UpdateAIStatus updates only the AI status for a log run
+// UpdateAIReportWithMetadata is like UpdateAIReport but also records
+// metadata collected while producing the report (e.g. redaction counts) and
+// the AI-classified error category.
+func (r *LogRunRepository) UpdateAIReportWithMetadata(ctx context.Context, id uuid.UUID, report string, status models.AIStatus, metadata models.JSONMap, category models.ErrorCategory) error {
+	query := `
+		UPDATE log_runs
+		SET ai_report = $1, ai_status = $2, ai_metadata = $3, error_category = $4
+		WHERE id = $5
+	`
+	_, err := r.db.ExecContext(ctx, query, report, status, metadata, category, id)
+	if err != nil {
+		return fmt.Errorf("failed to update AI report: %w", err)
+	}
+	return nil
+}
+
+// UpdateAIReportWithContentHash is like UpdateAIReportWithMetadata but also
+// records the content hash of the analyzed prompt, so future identical
+// analyses for the same user can be recognized and reused.
+func (r *LogRunRepository) UpdateAIReportWithContentHash(ctx context.Context, id uuid.UUID, report string, status models.AIStatus, metadata models.JSONMap, category models.ErrorCategory, contentHash string) error {
+	ctx, span := tracer.Start(ctx, "db.update_ai_report", trace.WithAttributes(
+		attribute.String("ai.run_id", id.String()),
+		attribute.String("ai.status", string(status)),
+	))
+	defer span.End()
+
+	query := `
+		UPDATE log_runs
+		SET ai_report = $1, ai_status = $2, ai_metadata = $3, error_category = $4, ai_content_hash = $5
+		WHERE id = $6
+	`
+	_, err := r.db.ExecContext(ctx, query, report, status, metadata, category, contentHash, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("failed to update AI report: %w", err)
+	}
+	return nil
+}
+
+// FindCompletedByContentHash looks for a completed analysis with the given
+// content hash, owned by the same user (via group -> project), other than
+// excludeRunID. It returns nil, nil if no match is found.
+func (r *LogRunRepository) FindCompletedByContentHash(ctx context.Context, userID, excludeRunID uuid.UUID, contentHash string) (*models.LogRun, error) {
+	run := &models.LogRun{}
+	query := `
+		SELECT lr.id, lr.group_id, lr.start_time, lr.end_time, lr.status, lr.exit_code,
+		       lr.ai_report, lr.ai_status, lr.ai_metadata, lr.ai_content_hash, lr.error_category, lr.created_at, lr.updated_at
+		FROM log_runs lr
+		JOIN log_groups lg ON lg.id = lr.group_id
+		JOIN projects p ON p.id = lg.project_id
+		WHERE p.user_id = $1
+		  AND lr.id != $2
+		  AND lr.ai_content_hash = $3
+		  AND lr.ai_status = 'completed'
+		ORDER BY lr.updated_at DESC
+		LIMIT 1
+	`
+	err := r.db.QueryRowContext(ctx, query, userID, excludeRunID, contentHash).Scan(
+		&run.ID,
+		&run.GroupID,
+		&run.StartTime,
+		&run.EndTime,
+		&run.Status,
+		&run.ExitCode,
+		&run.AIReport,
+		&run.AIStatus,
+		&run.AIMetadata,
+		&run.AIContentHash,
+		&run.ErrorCategory,
+		&run.CreatedAt,
+		&run.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find run by content hash: %w", err)
+	}
+	return run, nil
+}
+
+// UpdateAIStatus updates the AI status for a log run. Transitioning to
+// processing stamps processing_started_at; transitioning to anything else
+// clears it, so the stuck-run reconciler only ever sees runs genuinely
+// abandoned mid-analysis.
 func (r *LogRunRepository) UpdateAIStatus(ctx context.Context, id uuid.UUID, status models.AIStatus) error {
-	query := `UPDATE log_runs SET ai_status = $1 WHERE id = $2`
-	_, err := r.db.ExecContext(ctx, query, status, id)
+	query := `
+		UPDATE log_runs
+		SET ai_status = $1,
+		    processing_started_at = CASE WHEN $1 = $3 THEN NOW() ELSE NULL END
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, status, id, models.AIStatusProcessing)
 	if err != nil {
 		return fmt.Errorf("failed to update AI status: %w", err)
 	}
@@ -161,7 +313,7 @@ func (r *LogRunRepository) UpdateAIStatus(ctx context.Context, id uuid.UUID, sta
 // ListPendingAIJobs retrieves runs pending AI analysis
 func (r *LogRunRepository) ListPendingAIJobs(ctx context.Context, limit int) ([]*models.LogRun, error) {
 	query := `
-		SELECT id, group_id, start_time, end_time, status, exit_code, ai_report, ai_status, created_at, updated_at
+		SELECT id, group_id, start_time, end_time, status, exit_code, ai_report, ai_status, ai_metadata, created_at, updated_at
 		FROM log_runs
 		WHERE ai_status = 'pending'
 		  AND status IN ('completed', 'failed', 'aborted')
@@ -186,6 +338,7 @@ func (r *LogRunRepository) ListPendingAIJobs(ctx context.Context, limit int) ([]
 			&run.ExitCode,
 			&run.AIReport,
 			&run.AIStatus,
+			&run.AIMetadata,
 			&run.CreatedAt,
 			&run.UpdatedAt,
 		)
@@ -240,13 +393,39 @@ func (r *LogRunRepository) GetStatusStatistics(ctx context.Context) (*models.Sta
 		return nil, fmt.Errorf("failed to get AI statistics: %w", err)
 	}
 
+	// Get error category counts, for faceted browsing of analyzed failures
+	categoryQuery := `
+		SELECT error_category, COUNT(*)
+		FROM log_runs
+		WHERE error_category IS NOT NULL
+		GROUP BY error_category
+	`
+	rows, err := r.db.QueryContext(ctx, categoryQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get error category statistics: %w", err)
+	}
+	defer rows.Close()
+
+	stats.ErrorCategoryCounts = make(map[models.ErrorCategory]int)
+	for rows.Next() {
+		var category models.ErrorCategory
+		var count int
+		if err := rows.Scan(&category, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan error category statistics: %w", err)
+		}
+		stats.ErrorCategoryCounts[category] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate error category statistics: %w", err)
+	}
+
 	return stats, nil
 }
 
 // ListRecentRuns retrieves the most recent log runs across all groups
 func (r *LogRunRepository) ListRecentRuns(ctx context.Context, limit int) ([]*models.LogRun, error) {
 	query := `
-		SELECT id, group_id, start_time, end_time, status, exit_code, ai_report, ai_status, created_at, updated_at
+		SELECT id, group_id, start_time, end_time, status, exit_code, ai_report, ai_status, ai_metadata, created_at, updated_at
 		FROM log_runs
 		ORDER BY start_time DESC
 		LIMIT $1
@@ -269,6 +448,7 @@ func (r *LogRunRepository) ListRecentRuns(ctx context.Context, limit int) ([]*mo
 			&run.ExitCode,
 			&run.AIReport,
 			&run.AIStatus,
+			&run.AIMetadata,
 			&run.CreatedAt,
 			&run.UpdatedAt,
 		)
@@ -280,3 +460,193 @@ func (r *LogRunRepository) ListRecentRuns(ctx context.Context, limit int) ([]*mo
 
 	return runs, nil
 }
+
+// FindLastSuccessfulRun returns the most recent completed run in groupID
+// other than excludeRunID, or nil if none exists. "Successful" here means
+// the script's own run status was completed, independent of whether AI
+// analysis has run for it.
+func (r *LogRunRepository) FindLastSuccessfulRun(ctx context.Context, groupID, excludeRunID uuid.UUID) (*models.LogRun, error) {
+	run := &models.LogRun{}
+	query := `
+		SELECT id, group_id, start_time, end_time, status, exit_code,
+		       ai_report, ai_status, ai_metadata, ai_content_hash, created_at, updated_at
+		FROM log_runs
+		WHERE group_id = $1 AND id != $2 AND status = $3
+		ORDER BY start_time DESC
+		LIMIT 1
+	`
+	err := r.db.QueryRowContext(ctx, query, groupID, excludeRunID, models.RunStatusCompleted).Scan(
+		&run.ID,
+		&run.GroupID,
+		&run.StartTime,
+		&run.EndTime,
+		&run.Status,
+		&run.ExitCode,
+		&run.AIReport,
+		&run.AIStatus,
+		&run.AIMetadata,
+		&run.AIContentHash,
+		&run.CreatedAt,
+		&run.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find last successful run: %w", err)
+	}
+	return run, nil
+}
+
+// GetTotalAICost sums the "cost_usd" recorded in ai_metadata across all
+// completed analyses, for a simple instance-wide cost dashboard.
+func (r *LogRunRepository) GetTotalAICost(ctx context.Context) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM((ai_metadata->>'cost_usd')::numeric), 0)
+		FROM log_runs
+		WHERE ai_metadata ? 'cost_usd'
+	`
+	var total float64
+	if err := r.db.QueryRowContext(ctx, query).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to sum AI cost: %w", err)
+	}
+	return total, nil
+}
+
+// ListFailedForRetry returns runs with a failed AI status owned (via their
+// project) by userID, oldest first, optionally narrowed to a project,
+// group, and/or minimum creation time. limit caps the result size.
+func (r *LogRunRepository) ListFailedForRetry(ctx context.Context, userID uuid.UUID, projectID, groupID *uuid.UUID, since *time.Time, limit int) ([]*models.LogRun, error) {
+	query := `
+		SELECT lr.id, lr.group_id, lr.start_time, lr.end_time, lr.status, lr.exit_code,
+		       lr.ai_report, lr.ai_status, lr.ai_metadata, lr.ai_content_hash, lr.created_at, lr.updated_at
+		FROM log_runs lr
+		JOIN log_groups lg ON lg.id = lr.group_id
+		JOIN projects p ON p.id = lg.project_id
+		WHERE lr.ai_status = $1 AND p.user_id = $2
+	`
+	args := []interface{}{models.AIStatusFailed, userID}
+
+	if projectID != nil {
+		args = append(args, *projectID)
+		query += fmt.Sprintf(" AND p.id = $%d", len(args))
+	}
+	if groupID != nil {
+		args = append(args, *groupID)
+		query += fmt.Sprintf(" AND lg.id = $%d", len(args))
+	}
+	if since != nil {
+		args = append(args, *since)
+		query += fmt.Sprintf(" AND lr.created_at >= $%d", len(args))
+	}
+
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY lr.created_at ASC LIMIT $%d", len(args))
+
+	return r.queryLogRuns(ctx, query, args...)
+}
+
+// ListRecentFailedForAutoRetry returns instance-wide runs with a failed AI
+// status created since `since`, oldest first, for the worker's scheduled
+// retry sweep. Unlike ListFailedForRetry it isn't scoped to a single user.
+func (r *LogRunRepository) ListRecentFailedForAutoRetry(ctx context.Context, since time.Time, limit int) ([]*models.LogRun, error) {
+	query := `
+		SELECT id, group_id, start_time, end_time, status, exit_code,
+		       ai_report, ai_status, ai_metadata, ai_content_hash, created_at, updated_at
+		FROM log_runs
+		WHERE ai_status = $1 AND created_at >= $2
+		ORDER BY created_at ASC
+		LIMIT $3
+	`
+	return r.queryLogRuns(ctx, query, models.AIStatusFailed, since, limit)
+}
+
+// ListStuckProcessing returns runs whose ai_status is still "processing"
+// even though processing started more than olderThan ago, oldest first.
+// This normally means the worker that picked them up crashed before it
+// could finish; the reconciler decides whether to re-enqueue or fail them.
+func (r *LogRunRepository) ListStuckProcessing(ctx context.Context, olderThan time.Duration, limit int) ([]*models.LogRun, error) {
+	query := `
+		SELECT id, group_id, start_time, end_time, status, exit_code,
+		       ai_report, ai_status, ai_metadata, ai_content_hash, created_at, updated_at
+		FROM log_runs
+		WHERE ai_status = $1 AND processing_started_at IS NOT NULL AND processing_started_at < $2
+		ORDER BY processing_started_at ASC
+		LIMIT $3
+	`
+	return r.queryLogRuns(ctx, query, models.AIStatusProcessing, time.Now().Add(-olderThan), limit)
+}
+
+// IncrementReconcileAttempts atomically increments and returns the
+// reconcile_attempts counter in a run's ai_metadata. The stuck-processing
+// reconciler uses this to bound how many times it will re-enqueue the same
+// run before giving up and marking it permanently failed.
+func (r *LogRunRepository) IncrementReconcileAttempts(ctx context.Context, id uuid.UUID) (int, error) {
+	query := `
+		UPDATE log_runs
+		SET ai_metadata = jsonb_set(
+			COALESCE(ai_metadata, '{}'::jsonb),
+			'{reconcile_attempts}',
+			to_jsonb(COALESCE((ai_metadata->>'reconcile_attempts')::int, 0) + 1)
+		)
+		WHERE id = $1
+		RETURNING (ai_metadata->>'reconcile_attempts')::int
+	`
+	var attempts int
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&attempts); err != nil {
+		return 0, fmt.Errorf("failed to increment reconcile attempts: %w", err)
+	}
+	return attempts, nil
+}
+
+// DeleteOlderThan permanently deletes log runs created before cutoff,
+// returning how many rows were removed. Used by the retention cleanup job.
+func (r *LogRunRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM log_runs WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old log runs: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted log runs: %w", err)
+	}
+	return deleted, nil
+}
+
+// queryLogRuns runs a query expected to return the standard log_runs column
+// set (as used by ListFailedForRetry, ListRecentFailedForAutoRetry, and
+// ListStuckProcessing) and scans the results.
+func (r *LogRunRepository) queryLogRuns(ctx context.Context, query string, args ...interface{}) ([]*models.LogRun, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.LogRun
+	for rows.Next() {
+		run := &models.LogRun{}
+		if err := rows.Scan(
+			&run.ID,
+			&run.GroupID,
+			&run.StartTime,
+			&run.EndTime,
+			&run.Status,
+			&run.ExitCode,
+			&run.AIReport,
+			&run.AIStatus,
+			&run.AIMetadata,
+			&run.AIContentHash,
+			&run.CreatedAt,
+			&run.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan log run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate log runs: %w", err)
+	}
+
+	return runs, nil
+}
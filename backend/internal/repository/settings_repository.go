@@ -25,7 +25,7 @@ func (r *SettingsRepository) GetUserSettings(ctx context.Context, userID uuid.UU
 	query := `
 		SELECT id, user_id, ai_enabled, ai_base_url, ai_api_key, ai_model, ai_max_tokens,
 		       ai_auto_analyze, ai_max_log_lines, ai_log_truncate_strategy,
-		       ai_system_prompt, ai_max_concurrent, created_at, updated_at
+		       ai_system_prompt, ai_user_prompt_template, ai_report_language, ai_monthly_token_quota, ai_max_concurrent, ai_request_timeout_seconds, ai_redact_secrets, ai_redact_extra_patterns, created_at, updated_at
 		FROM user_settings
 		WHERE user_id = $1
 	`
@@ -41,7 +41,13 @@ func (r *SettingsRepository) GetUserSettings(ctx context.Context, userID uuid.UU
 		&settings.AIMaxLogLines,
 		&settings.AILogTruncateStrategy,
 		&settings.AISystemPrompt,
+		&settings.AIUserPromptTemplate,
+		&settings.AIReportLanguage,
+		&settings.AIMonthlyTokenQuota,
 		&settings.AIMaxConcurrent,
+		&settings.AIRequestTimeoutSeconds,
+		&settings.AIRedactSecrets,
+		&settings.AIRedactExtraPatterns,
 		&settings.CreatedAt,
 		&settings.UpdatedAt,
 	)
@@ -64,19 +70,19 @@ func (r *SettingsRepository) CreateDefaultUserSettings(ctx context.Context, user
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id, user_id, ai_enabled, ai_base_url, ai_api_key, ai_model, ai_max_tokens,
 		          ai_auto_analyze, ai_max_log_lines, ai_log_truncate_strategy,
-		          ai_system_prompt, ai_max_concurrent, created_at, updated_at
+		          ai_system_prompt, ai_user_prompt_template, ai_report_language, ai_monthly_token_quota, ai_max_concurrent, ai_request_timeout_seconds, ai_redact_secrets, ai_redact_extra_patterns, created_at, updated_at
 	`
 	err := r.db.QueryRowContext(ctx, query,
 		userID,
-		true,                                         // ai_enabled
-		"https://api.openai.com/v1",                  // ai_base_url
-		"gpt-4o-mini",                                // ai_model
-		500,                                          // ai_max_tokens
-		false,                                        // ai_auto_analyze
-		1000,                                         // ai_max_log_lines
-		models.TruncateTail,                          // ai_log_truncate_strategy
+		true,                        // ai_enabled
+		"https://api.openai.com/v1", // ai_base_url
+		"gpt-4o-mini",               // ai_model
+		500,                         // ai_max_tokens
+		false,                       // ai_auto_analyze
+		1000,                        // ai_max_log_lines
+		models.TruncateTail,         // ai_log_truncate_strategy
 		"You are a helpful assistant analyzing script execution logs. Identify errors, warnings, and provide actionable recommendations.", // ai_system_prompt
-		3,                                            // ai_max_concurrent
+		3, // ai_max_concurrent
 	).Scan(
 		&settings.ID,
 		&settings.UserID,
@@ -89,7 +95,13 @@ func (r *SettingsRepository) CreateDefaultUserSettings(ctx context.Context, user
 		&settings.AIMaxLogLines,
 		&settings.AILogTruncateStrategy,
 		&settings.AISystemPrompt,
+		&settings.AIUserPromptTemplate,
+		&settings.AIReportLanguage,
+		&settings.AIMonthlyTokenQuota,
 		&settings.AIMaxConcurrent,
+		&settings.AIRequestTimeoutSeconds,
+		&settings.AIRedactSecrets,
+		&settings.AIRedactExtraPatterns,
 		&settings.CreatedAt,
 		&settings.UpdatedAt,
 	)
@@ -105,8 +117,10 @@ func (r *SettingsRepository) UpdateUserSettings(ctx context.Context, settings *m
 		UPDATE user_settings
 		SET ai_enabled = $1, ai_base_url = $2, ai_api_key = $3, ai_model = $4,
 		    ai_max_tokens = $5, ai_auto_analyze = $6, ai_max_log_lines = $7,
-		    ai_log_truncate_strategy = $8, ai_system_prompt = $9, ai_max_concurrent = $10
-		WHERE user_id = $11
+		    ai_log_truncate_strategy = $8, ai_system_prompt = $9, ai_user_prompt_template = $10,
+		    ai_report_language = $11, ai_monthly_token_quota = $12, ai_max_concurrent = $13,
+		    ai_request_timeout_seconds = $14, ai_redact_secrets = $15, ai_redact_extra_patterns = $16
+		WHERE user_id = $17
 	`
 	_, err := r.db.ExecContext(ctx, query,
 		settings.AIEnabled,
@@ -118,7 +132,13 @@ func (r *SettingsRepository) UpdateUserSettings(ctx context.Context, settings *m
 		settings.AIMaxLogLines,
 		settings.AILogTruncateStrategy,
 		settings.AISystemPrompt,
+		settings.AIUserPromptTemplate,
+		settings.AIReportLanguage,
+		settings.AIMonthlyTokenQuota,
 		settings.AIMaxConcurrent,
+		settings.AIRequestTimeoutSeconds,
+		settings.AIRedactSecrets,
+		settings.AIRedactExtraPatterns,
 		settings.UserID,
 	)
 	if err != nil {
@@ -133,7 +153,8 @@ func (r *SettingsRepository) GetProjectSettings(ctx context.Context, projectID u
 	query := `
 		SELECT id, project_id, ai_enabled, ai_base_url, ai_api_key, ai_model,
 		       ai_max_tokens, ai_auto_analyze, ai_max_log_lines,
-		       ai_log_truncate_strategy, ai_system_prompt, ai_max_concurrent,
+		       ai_log_truncate_strategy, ai_system_prompt, ai_user_prompt_template, ai_report_language, ai_max_concurrent,
+		       ai_request_timeout_seconds, ai_redact_secrets, ai_redact_extra_patterns,
 		       created_at, updated_at
 		FROM project_settings
 		WHERE project_id = $1
@@ -150,7 +171,12 @@ func (r *SettingsRepository) GetProjectSettings(ctx context.Context, projectID u
 		&settings.AIMaxLogLines,
 		&settings.AILogTruncateStrategy,
 		&settings.AISystemPrompt,
+		&settings.AIUserPromptTemplate,
+		&settings.AIReportLanguage,
 		&settings.AIMaxConcurrent,
+		&settings.AIRequestTimeoutSeconds,
+		&settings.AIRedactSecrets,
+		&settings.AIRedactExtraPatterns,
 		&settings.CreatedAt,
 		&settings.UpdatedAt,
 	)
@@ -169,8 +195,9 @@ func (r *SettingsRepository) UpsertProjectSettings(ctx context.Context, settings
 		INSERT INTO project_settings (
 			project_id, ai_enabled, ai_base_url, ai_api_key, ai_model,
 			ai_max_tokens, ai_auto_analyze, ai_max_log_lines,
-			ai_log_truncate_strategy, ai_system_prompt
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ai_log_truncate_strategy, ai_system_prompt, ai_user_prompt_template, ai_report_language,
+			ai_request_timeout_seconds, ai_redact_secrets, ai_redact_extra_patterns
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		ON CONFLICT (project_id) DO UPDATE SET
 			ai_enabled = EXCLUDED.ai_enabled,
 			ai_base_url = EXCLUDED.ai_base_url,
@@ -180,7 +207,12 @@ func (r *SettingsRepository) UpsertProjectSettings(ctx context.Context, settings
 			ai_auto_analyze = EXCLUDED.ai_auto_analyze,
 			ai_max_log_lines = EXCLUDED.ai_max_log_lines,
 			ai_log_truncate_strategy = EXCLUDED.ai_log_truncate_strategy,
-			ai_system_prompt = EXCLUDED.ai_system_prompt
+			ai_system_prompt = EXCLUDED.ai_system_prompt,
+			ai_user_prompt_template = EXCLUDED.ai_user_prompt_template,
+			ai_report_language = EXCLUDED.ai_report_language,
+			ai_request_timeout_seconds = EXCLUDED.ai_request_timeout_seconds,
+			ai_redact_secrets = EXCLUDED.ai_redact_secrets,
+			ai_redact_extra_patterns = EXCLUDED.ai_redact_extra_patterns
 		RETURNING id, created_at, updated_at
 	`
 	err := r.db.QueryRowContext(ctx, query,
@@ -194,6 +226,11 @@ func (r *SettingsRepository) UpsertProjectSettings(ctx context.Context, settings
 		settings.AIMaxLogLines,
 		settings.AILogTruncateStrategy,
 		settings.AISystemPrompt,
+		settings.AIUserPromptTemplate,
+		settings.AIReportLanguage,
+		settings.AIRequestTimeoutSeconds,
+		settings.AIRedactSecrets,
+		settings.AIRedactExtraPatterns,
 	).Scan(&settings.ID, &settings.CreatedAt, &settings.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to upsert project settings: %w", err)
@@ -227,17 +264,23 @@ func (r *SettingsRepository) GetEffectiveSettings(ctx context.Context, projectID
 
 	// Merge settings (project overrides user)
 	effective := &models.EffectiveSettings{
-		AIEnabled:             user.AIEnabled,
-		AIBaseURL:             user.AIBaseURL,
-		AIAPIKey:              nullStringToString(user.AIAPIKey),
-		AIModel:               user.AIModel,
-		AIMaxTokens:           user.AIMaxTokens,
-		AIAutoAnalyze:         user.AIAutoAnalyze,
-		AIMaxLogLines:         user.AIMaxLogLines,
-		AILogTruncateStrategy: user.AILogTruncateStrategy,
-		AISystemPrompt:        user.AISystemPrompt,
-		AIMaxConcurrent:       user.AIMaxConcurrent,
-		Source:                "user",
+		AIEnabled:               user.AIEnabled,
+		AIBaseURL:               user.AIBaseURL,
+		AIAPIKey:                nullStringToString(user.AIAPIKey),
+		AIModel:                 user.AIModel,
+		AIMaxTokens:             user.AIMaxTokens,
+		AIAutoAnalyze:           user.AIAutoAnalyze,
+		AIMaxLogLines:           user.AIMaxLogLines,
+		AILogTruncateStrategy:   user.AILogTruncateStrategy,
+		AISystemPrompt:          user.AISystemPrompt,
+		AIUserPromptTemplate:    user.AIUserPromptTemplate,
+		AIReportLanguage:        user.AIReportLanguage,
+		AIMonthlyTokenQuota:     nullInt64ToPtr(user.AIMonthlyTokenQuota),
+		AIMaxConcurrent:         user.AIMaxConcurrent,
+		AIRequestTimeoutSeconds: user.AIRequestTimeoutSeconds,
+		AIRedactSecrets:         user.AIRedactSecrets,
+		AIRedactExtraPatterns:   []string(user.AIRedactExtraPatterns),
+		Source:                  "user",
 	}
 
 	// Apply project overrides if they exist
@@ -279,10 +322,30 @@ func (r *SettingsRepository) GetEffectiveSettings(ctx context.Context, projectID
 			effective.AISystemPrompt = *project.AISystemPrompt
 			hasOverrides = true
 		}
+		if project.AIUserPromptTemplate != nil {
+			effective.AIUserPromptTemplate = *project.AIUserPromptTemplate
+			hasOverrides = true
+		}
+		if project.AIReportLanguage != nil {
+			effective.AIReportLanguage = *project.AIReportLanguage
+			hasOverrides = true
+		}
 		if project.AIMaxConcurrent != nil {
 			effective.AIMaxConcurrent = *project.AIMaxConcurrent
 			hasOverrides = true
 		}
+		if project.AIRequestTimeoutSeconds != nil {
+			effective.AIRequestTimeoutSeconds = *project.AIRequestTimeoutSeconds
+			hasOverrides = true
+		}
+		if project.AIRedactSecrets != nil {
+			effective.AIRedactSecrets = *project.AIRedactSecrets
+			hasOverrides = true
+		}
+		if project.AIRedactExtraPatterns != nil {
+			effective.AIRedactExtraPatterns = []string(project.AIRedactExtraPatterns)
+			hasOverrides = true
+		}
 
 		if hasOverrides {
 			effective.Source = "merged"
@@ -298,3 +361,10 @@ func nullStringToString(ns sql.NullString) string {
 	}
 	return ""
 }
+
+func nullInt64ToPtr(ni sql.NullInt64) *int64 {
+	if ni.Valid {
+		return &ni.Int64
+	}
+	return nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
+	"github.com/aliancn/swiftlog/backend/internal/auth"
 	"github.com/aliancn/swiftlog/backend/internal/models"
 	"github.com/google/uuid"
 )
@@ -21,16 +23,25 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 
 // Create creates a new user with password
 func (r *UserRepository) Create(ctx context.Context, username, passwordHash string, isAdmin bool) (*models.User, error) {
+	return r.CreateWithEmail(ctx, username, passwordHash, isAdmin, "")
+}
+
+// CreateWithEmail creates a new user with password and an optional email
+// address (used for password reset delivery, and set automatically for
+// OIDC-provisioned accounts). An empty email is stored as NULL.
+func (r *UserRepository) CreateWithEmail(ctx context.Context, username, passwordHash string, isAdmin bool, email string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		INSERT INTO users (username, password_hash, is_admin)
-		VALUES ($1, $2, $3)
-		RETURNING id, username, is_admin, created_at
+		INSERT INTO users (username, password_hash, is_admin, email)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING id, username, is_admin, email, must_change_password, created_at
 	`
-	err := r.db.QueryRowContext(ctx, query, username, passwordHash, isAdmin).Scan(
+	err := r.db.QueryRowContext(ctx, query, username, passwordHash, isAdmin, email).Scan(
 		&user.ID,
 		&user.Username,
 		&user.IsAdmin,
+		&user.Email,
+		&user.MustChangePassword,
 		&user.CreatedAt,
 	)
 	if err != nil {
@@ -43,12 +54,14 @@ func (r *UserRepository) Create(ctx context.Context, username, passwordHash stri
 // GetByID retrieves a user by ID
 func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	user := &models.User{}
-	query := `SELECT id, username, password_hash, is_admin, created_at FROM users WHERE id = $1`
+	query := `SELECT id, username, password_hash, is_admin, email, must_change_password, created_at FROM users WHERE id = $1`
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Username,
 		&user.PasswordHash,
 		&user.IsAdmin,
+		&user.Email,
+		&user.MustChangePassword,
 		&user.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -63,12 +76,60 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Use
 // GetByUsername retrieves a user by username
 func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	user := &models.User{}
-	query := `SELECT id, username, password_hash, is_admin, created_at FROM users WHERE username = $1`
+	query := `SELECT id, username, password_hash, is_admin, email, must_change_password, created_at FROM users WHERE username = $1`
 	err := r.db.QueryRowContext(ctx, query, username).Scan(
 		&user.ID,
 		&user.Username,
 		&user.PasswordHash,
 		&user.IsAdmin,
+		&user.Email,
+		&user.MustChangePassword,
+		&user.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// GetByEmail retrieves a user by email address
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	user := &models.User{}
+	query := `SELECT id, username, password_hash, is_admin, email, must_change_password, created_at FROM users WHERE email = $1`
+	err := r.db.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.Username,
+		&user.PasswordHash,
+		&user.IsAdmin,
+		&user.Email,
+		&user.MustChangePassword,
+		&user.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// GetByOIDCSubject retrieves a user by the OIDC "sub" claim it was
+// provisioned for. Unlike GetByEmail, this identifies the exact IdP
+// identity a login came from, not just a mailbox - see GetOrCreateOIDCUser.
+func (r *UserRepository) GetByOIDCSubject(ctx context.Context, subject string) (*models.User, error) {
+	user := &models.User{}
+	query := `SELECT id, username, password_hash, is_admin, email, must_change_password, created_at FROM users WHERE oidc_subject = $1`
+	err := r.db.QueryRowContext(ctx, query, subject).Scan(
+		&user.ID,
+		&user.Username,
+		&user.PasswordHash,
+		&user.IsAdmin,
+		&user.Email,
+		&user.MustChangePassword,
 		&user.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -92,6 +153,157 @@ func (r *UserRepository) GetOrCreateUser(ctx context.Context, username, password
 	return r.Create(ctx, username, passwordHash, false)
 }
 
+// ErrOIDCEmailAlreadyLinked is returned by GetOrCreateOIDCUser when the
+// IdP-asserted email already belongs to an account this OIDC subject
+// hasn't previously logged in as - most often a pre-existing
+// local-password account. GetOrCreateOIDCUser never links to that account
+// automatically: an IdP that lets a user assert someone else's verified
+// email (or a misconfigured/malicious IdP) would otherwise let an attacker
+// sign in as that victim purely via SSO, no password needed. Resolving the
+// collision (e.g. an admin merging the accounts) is left as a manual step.
+var ErrOIDCEmailAlreadyLinked = fmt.Errorf("an account with this email already exists and is not linked to SSO")
+
+// GetOrCreateOIDCUser gets the user previously provisioned for subject (the
+// OIDC "sub" claim, which uniquely and stably identifies this IdP
+// identity), or provisions one for a first-time login. subject, not email,
+// is the identity key: emails can be reassigned or spoofed by a
+// misconfigured IdP, so a first login for a given subject whose email
+// collides with an existing, differently-linked account is rejected
+// (ErrOIDCEmailAlreadyLinked) rather than silently signing the caller in
+// as that account.
+//
+// A newly-provisioned account gets a random, never-used password hash,
+// since OIDC accounts don't authenticate locally. The username is derived
+// from the email's local part rather than being the email itself, since
+// usernames are constrained to username_format (alphanumeric, "_", "-",
+// 3-50 chars) and most emails aren't. isAdmin only applies to the
+// newly-created user; an existing user's admin flag isn't touched by a
+// subsequent login even if their group membership has since changed. The
+// returned bool is true when a new user was provisioned, so callers can
+// run first-login-only setup (e.g. default settings) exactly once.
+func (r *UserRepository) GetOrCreateOIDCUser(ctx context.Context, subject, email string, isAdmin bool) (*models.User, bool, error) {
+	user, err := r.GetByOIDCSubject(ctx, subject)
+	if err == nil {
+		return user, false, nil
+	}
+
+	if _, err := r.GetByEmail(ctx, email); err == nil {
+		return nil, false, ErrOIDCEmailAlreadyLinked
+	}
+
+	randomPassword, err := auth.GenerateToken()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to provision oidc user: %w", err)
+	}
+	passwordHash, err := auth.HashPassword(randomPassword)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to provision oidc user: %w", err)
+	}
+
+	user, err = r.createOIDCUser(ctx, usernameFromEmail(email), passwordHash, isAdmin, email, subject)
+	if err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
+
+// createOIDCUser is CreateWithEmail plus the oidc_subject that ties the new
+// row to the IdP identity that provisioned it.
+func (r *UserRepository) createOIDCUser(ctx context.Context, username, passwordHash string, isAdmin bool, email, subject string) (*models.User, error) {
+	user := &models.User{}
+	query := `
+		INSERT INTO users (username, password_hash, is_admin, email, oidc_subject)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''))
+		RETURNING id, username, is_admin, email, must_change_password, created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, username, passwordHash, isAdmin, email, subject).Scan(
+		&user.ID,
+		&user.Username,
+		&user.IsAdmin,
+		&user.Email,
+		&user.MustChangePassword,
+		&user.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create oidc user: %w", err)
+	}
+	user.PasswordHash = passwordHash
+	return user, nil
+}
+
+// usernameFromEmail derives a username_format-compliant username from the
+// local part of an email address, since a raw email address doesn't
+// satisfy that constraint. It doesn't attempt to resolve collisions with
+// an existing username belonging to a different account; that's expected
+// to be rare enough to handle by hand if it ever comes up.
+func usernameFromEmail(email string) string {
+	local := email
+	if at := strings.IndexByte(email, '@'); at >= 0 {
+		local = email[:at]
+	}
+
+	var b strings.Builder
+	for _, r := range local {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		}
+	}
+
+	username := b.String()
+	if len(username) > 50 {
+		username = username[:50]
+	}
+	for len(username) < 3 {
+		username += "0"
+	}
+	return username
+}
+
+// UpdatePassword replaces a user's password hash, for use after a
+// password reset or change-password request. It also clears
+// must_change_password, since setting a new password is exactly what
+// that flag is waiting for.
+func (r *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $1, must_change_password = false WHERE id = $2`
+	result, err := r.db.ExecContext(ctx, query, passwordHash, id)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// SetMustChangePassword sets or clears the must_change_password flag,
+// independent of the password itself. Used to flag a freshly-generated
+// bootstrap admin password as needing to be replaced before the account
+// can do anything else.
+func (r *UserRepository) SetMustChangePassword(ctx context.Context, id uuid.UUID, must bool) error {
+	query := `UPDATE users SET must_change_password = $1 WHERE id = $2`
+	result, err := r.db.ExecContext(ctx, query, must, id)
+	if err != nil {
+		return fmt.Errorf("failed to update must_change_password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
 // Count returns the total number of users
 func (r *UserRepository) Count(ctx context.Context) (int, error) {
 	var count int
@@ -106,7 +318,7 @@ func (r *UserRepository) Count(ctx context.Context) (int, error) {
 // ListAll retrieves all users
 func (r *UserRepository) ListAll(ctx context.Context) ([]*models.User, error) {
 	query := `
-		SELECT id, username, is_admin, created_at
+		SELECT id, username, is_admin, must_change_password, created_at
 		FROM users
 		ORDER BY created_at DESC
 	`
@@ -123,6 +335,7 @@ func (r *UserRepository) ListAll(ctx context.Context) ([]*models.User, error) {
 			&user.ID,
 			&user.Username,
 			&user.IsAdmin,
+			&user.MustChangePassword,
 			&user.CreatedAt,
 		)
 		if err != nil {
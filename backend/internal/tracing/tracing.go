@@ -0,0 +1,89 @@
+// Package tracing builds the shared OpenTelemetry TracerProvider used by
+// the API, ingestor, websocket, and ai-worker binaries, so a single
+// request's spans (Gin handler, queue publish, queue wait, Loki query,
+// provider call) can be joined into one trace regardless of which service
+// emitted them.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
+)
+
+// Shutdown flushes and stops the TracerProvider installed by Init. Callers
+// should defer it from main().
+type Shutdown func(ctx context.Context) error
+
+// Init wires up the process-wide TracerProvider for service (e.g. "api",
+// "ingestor", "ai-worker", "websocket") and installs it as the global
+// tracer/propagator. Spans are exported over OTLP/gRPC to the collector at
+// OTEL_EXPORTER_OTLP_ENDPOINT (default localhost:4317, insecure). Sampling
+// is a parent-based ratio sampler controlled by OTEL_TRACES_SAMPLER_ARG
+// (default 1.0, i.e. sample everything — turn this down in production to
+// control collector volume).
+//
+// If tracing is disabled (OTEL_SDK_DISABLED=true) or the exporter can't be
+// constructed, Init returns a no-op shutdown rather than failing startup:
+// a collector being unreachable shouldn't take down the service.
+func Init(ctx context.Context, service string) (Shutdown, error) {
+	noop := func(context.Context) error { return nil }
+
+	if b, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); b {
+		return noop, nil
+	}
+
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "localhost:4317"
+	}
+
+	exporter, err := otlptracegrpc.New(ctx,
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithInsecure(),
+	)
+	if err != nil {
+		return noop, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(service),
+	))
+	if err != nil {
+		return noop, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio()))),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return tp.Shutdown, nil
+}
+
+func sampleRatio() float64 {
+	raw := os.Getenv("OTEL_TRACES_SAMPLER_ARG")
+	if raw == "" {
+		return 1.0
+	}
+	ratio, err := strconv.ParseFloat(raw, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return 1.0
+	}
+	return ratio
+}
@@ -1,110 +1,521 @@
 package websocket
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
+	"github.com/aliancn/swiftlog/backend/internal/loki"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
 const (
-	// Time allowed to write a message to the peer
-	writeWait = 10 * time.Second
+	// defaultWriteWait is how long a single write to the peer may take
+	// before it's abandoned, used unless Hub.SetKeepalive overrides it.
+	defaultWriteWait = 10 * time.Second
 
-	// Time allowed to read the next pong message from the peer
-	pongWait = 60 * time.Second
+	// defaultPongWait is how long a client's read deadline extends by each
+	// time a pong (or any other read) arrives, used unless
+	// Hub.SetKeepalive overrides it.
+	defaultPongWait = 60 * time.Second
 
-	// Send pings to peer with this period (must be less than pongWait)
-	pingPeriod = (pongWait * 9) / 10
+	// defaultPingPeriod is how often a client pings the peer, used unless
+	// Hub.SetKeepalive overrides it. Must stay less than the configured
+	// pongWait so a ping always lands before the read deadline expires.
+	defaultPingPeriod = (defaultPongWait * 9) / 10
 
 	// Maximum message size allowed from peer
 	maxMessageSize = 512
+
+	// maxControlViolations is how many malformed or invalid control
+	// messages a client may send before readPump gives up and closes the
+	// connection, so a misbehaving client can't be kept alive forever by a
+	// buggy or hostile stream of garbage frames.
+	maxControlViolations = 5
 )
 
+// batchingProtocolVersion is the ?protocol_version= at which a client opts
+// into batched log delivery (see Hub.SetBatching and LogBatchMessage).
+// Anything lower, including the default of 1 for a client that doesn't pass
+// the parameter at all, gets the original one-frame-per-message wire format,
+// so existing consumers aren't broken by the new framing.
+const batchingProtocolVersion = 2
+
 // Client represents a WebSocket client connection
 type Client struct {
 	hub  *Hub
 	conn *websocket.Conn
-	send chan []byte
+	send chan *outboundMessage
 
 	// The run ID this client is subscribed to
 	runID uuid.UUID
+
+	// userID is the authenticated owner of this connection, used to release
+	// its per-user connection reservation (see Hub.TryReserveConnection) on
+	// unregister.
+	userID uuid.UUID
+
+	// batching is whether this client negotiated batchingProtocolVersion or
+	// higher, in which case writePump coalesces its log messages into
+	// LogBatchMessage frames instead of sending one frame per line. Fixed
+	// for the lifetime of the connection.
+	batching bool
+
+	// filterMu guards levelFilter and paused, which are set by control
+	// messages handled in readPump and read from the hub's broadcast
+	// fan-out running on a different goroutine.
+	filterMu    sync.Mutex
+	levelFilter string // "" means no filter; otherwise "stdout" or "stderr"
+	paused      bool
+
+	// subscribedRuns and authorizeSubscribe are set only for a multi-run
+	// subscriber client (see NewSubscriberClient); nil for a single-run
+	// client created with NewClient, whose fixed runID above is its only
+	// subscription. subscribedRuns is the set of run IDs currently
+	// subscribed via "subscribe"/"unsubscribe" control messages; it's only
+	// ever touched by this client's own readPump goroutine (applying
+	// control messages and enumerating it on disconnect), so it needs no
+	// lock of its own. authorizeSubscribe re-checks access for every
+	// subscribe action, since a connection accepted at upgrade time isn't
+	// scoped to any particular run.
+	subscribedRuns     map[uuid.UUID]bool
+	authorizeSubscribe func(runID uuid.UUID) (bool, error)
+}
+
+// outboundMessage is what's queued on Client.send by the hub's broadcast
+// fan-out. raw is the JSON payload, used for the filtering and
+// replay-dedup logic that needs to inspect message fields and cursors.
+// prepared, set when the hub has compression enabled, holds a
+// websocket.PreparedMessage built once per broadcast so N subscribers to
+// the same run share a single compression pass instead of each paying for
+// it on their own connection. When prepared is set, writePump sends it
+// as-is rather than folding it into the write-coalescing loop below, since
+// a PreparedMessage is always a complete, already-framed message.
+type outboundMessage struct {
+	raw      []byte
+	prepared *websocket.PreparedMessage
 }
 
-// NewClient creates a new WebSocket client
-func NewClient(hub *Hub, conn *websocket.Conn, runID uuid.UUID) *Client {
+// NewClient creates a new WebSocket client. If the hub has compression
+// enabled (see Hub.SetCompression), the connection is configured to write
+// with permessage-deflate; whether it's actually used still depends on
+// what the client negotiated at the upgrade (see the upgrader's
+// EnableCompression). protocolVersion is the client's requested
+// ?protocol_version=, which decides whether it receives batched log
+// delivery (see batchingProtocolVersion). userID must already hold a
+// reservation from a prior successful Hub.TryReserveConnection(userID)
+// call, which is released when this client unregisters.
+func NewClient(hub *Hub, conn *websocket.Conn, runID, userID uuid.UUID, protocolVersion int) *Client {
+	if hub.compressionEnabled {
+		conn.EnableWriteCompression(true)
+		conn.SetCompressionLevel(hub.compressionLevel)
+	}
+
 	return &Client{
-		hub:   hub,
-		conn:  conn,
-		send:  make(chan []byte, 256),
-		runID: runID,
+		hub:      hub,
+		conn:     conn,
+		send:     make(chan *outboundMessage, 256),
+		runID:    runID,
+		userID:   userID,
+		batching: protocolVersion >= batchingProtocolVersion,
 	}
 }
 
+// NewSubscriberClient creates a WebSocket client for the multi-run
+// /ws/subscribe mode, where a single connection can follow any number of
+// runs at once via "subscribe"/"unsubscribe" control messages instead of a
+// single fixed run ID picked at upgrade time. authorize is called on every
+// subscribe action to check that userID may watch the requested run, since
+// (unlike NewClient) accepting the connection didn't itself check access to
+// any particular run. As with NewClient, userID must already hold a
+// reservation from a prior successful Hub.TryReserveConnection(userID)
+// call; releasing it is handled by Hub.DisconnectSubscriber instead of the
+// usual unregister path, since this client's run membership changes over
+// its lifetime.
+func NewSubscriberClient(hub *Hub, conn *websocket.Conn, userID uuid.UUID, protocolVersion int, authorize func(runID uuid.UUID) (bool, error)) *Client {
+	if hub.compressionEnabled {
+		conn.EnableWriteCompression(true)
+		conn.SetCompressionLevel(hub.compressionLevel)
+	}
+
+	return &Client{
+		hub:                hub,
+		conn:               conn,
+		send:               make(chan *outboundMessage, 256),
+		userID:             userID,
+		batching:           protocolVersion >= batchingProtocolVersion,
+		subscribedRuns:     make(map[uuid.UUID]bool),
+		authorizeSubscribe: authorize,
+	}
+}
+
+// controlMessage is a client->server frame requesting a change to what this
+// client receives: {"action":"filter","level":"stderr"}, {"action":"filter",
+// "level":""} to clear the filter, {"action":"pause"}, {"action":"resume"},
+// {"action":"keep_open"} to cancel a scheduled terminal-state close (see
+// Hub.scheduleRunClose) for this client's run, or, on a /ws/subscribe
+// connection only, {"action":"subscribe","run_id":"..."} / "unsubscribe" to
+// change which runs this connection follows.
+type controlMessage struct {
+	Action string `json:"action"`
+	Level  string `json:"level"`
+	RunID  string `json:"run_id"`
+}
+
 // readPump pumps messages from the WebSocket connection to the hub
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		if c.subscribedRuns != nil {
+			// A subscriber client's run membership changed over its
+			// lifetime via subscribe/unsubscribe, so there's no single
+			// fixed runID for the usual unregister channel to key off of.
+			c.hub.DisconnectSubscriber(c)
+		} else {
+			c.hub.unregister <- c
+		}
 		c.conn.Close()
 	}()
 
 	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
 		return nil
 	})
 
-	// We don't expect messages from the client, just keep the connection alive
+	violations := 0
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
+
+		if err := c.handleControlMessage(data); err != nil {
+			violations++
+			log.Printf("Invalid control message from client on %s (%d/%d): %v", c.describeRuns(), violations, maxControlViolations, err)
+			if violations >= maxControlViolations {
+				c.writeError(fmt.Sprintf("too many invalid control messages (%d), closing connection", violations))
+				break
+			}
+		}
+	}
+}
+
+// describeRuns identifies which run(s) this client is on, for logging:
+// either its fixed runID (a single-run client from NewClient) or its
+// current subscription count (a multi-run subscriber client from
+// NewSubscriberClient).
+func (c *Client) describeRuns() string {
+	if c.subscribedRuns != nil {
+		return fmt.Sprintf("%d subscribed run(s)", len(c.subscribedRuns))
+	}
+	return c.runID.String()
+}
+
+// handleControlMessage parses and applies a single control frame, writing an
+// error frame back to the client and returning a non-nil error for anything
+// it doesn't understand: invalid JSON, an unknown action, or a filter level
+// other than "stdout"/"stderr"/"".
+func (c *Client) handleControlMessage(data []byte) error {
+	var msg controlMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		c.writeError("malformed control message: not valid JSON")
+		return fmt.Errorf("malformed control message: %w", err)
+	}
+
+	switch msg.Action {
+	case "filter":
+		switch msg.Level {
+		case "stdout", "stderr", "":
+			c.setLevelFilter(msg.Level)
+		default:
+			err := fmt.Errorf("unsupported filter level %q", msg.Level)
+			c.writeError(err.Error())
+			return err
+		}
+
+	case "pause":
+		c.setPaused(true)
+
+	case "resume":
+		c.setPaused(false)
+
+	case "keep_open":
+		c.hub.CancelScheduledClose(c.runID)
+
+	case "subscribe":
+		return c.subscribe(msg.RunID)
+
+	case "unsubscribe":
+		return c.unsubscribe(msg.RunID)
+
+	default:
+		err := fmt.Errorf("unknown action %q", msg.Action)
+		c.writeError(err.Error())
+		return err
 	}
+
+	return nil
+}
+
+// subscribe adds rawRunID to this client's subscriptions, after checking the
+// per-connection cap and re-authorizing access via authorizeSubscribe (a
+// connection accepted at upgrade time isn't scoped to any particular run).
+// Only valid on a client created with NewSubscriberClient; already being
+// subscribed to rawRunID is not an error. Writes an error frame and returns
+// non-nil for anything that fails, without otherwise affecting the
+// connection.
+func (c *Client) subscribe(rawRunID string) error {
+	if c.subscribedRuns == nil {
+		err := fmt.Errorf("subscribe is only supported on a /ws/subscribe connection")
+		c.writeError(err.Error())
+		return err
+	}
+
+	runID, err := uuid.Parse(rawRunID)
+	if err != nil {
+		werr := fmt.Errorf("invalid run_id %q", rawRunID)
+		c.writeError(werr.Error())
+		return werr
+	}
+
+	if c.subscribedRuns[runID] {
+		return nil
+	}
+
+	if len(c.subscribedRuns) >= c.hub.maxSubscriptionsPerConnection {
+		werr := fmt.Errorf("subscription limit reached (%d)", c.hub.maxSubscriptionsPerConnection)
+		c.writeError(werr.Error())
+		return werr
+	}
+
+	allowed, err := c.authorizeSubscribe(runID)
+	if err != nil {
+		log.Printf("Failed to authorize subscribe to run %s: %v", runID, err)
+		werr := fmt.Errorf("failed to check access for run %s", runID)
+		c.writeError(werr.Error())
+		return werr
+	}
+	if !allowed {
+		werr := fmt.Errorf("access denied for run %s", runID)
+		c.writeError(werr.Error())
+		return werr
+	}
+
+	c.subscribedRuns[runID] = true
+	c.hub.SubscribeClient(c, runID)
+	return nil
+}
+
+// unsubscribe removes rawRunID from this client's subscriptions. Not being
+// subscribed to rawRunID is not an error. Only valid on a client created
+// with NewSubscriberClient.
+func (c *Client) unsubscribe(rawRunID string) error {
+	if c.subscribedRuns == nil {
+		err := fmt.Errorf("unsubscribe is only supported on a /ws/subscribe connection")
+		c.writeError(err.Error())
+		return err
+	}
+
+	runID, err := uuid.Parse(rawRunID)
+	if err != nil {
+		werr := fmt.Errorf("invalid run_id %q", rawRunID)
+		c.writeError(werr.Error())
+		return werr
+	}
+
+	if !c.subscribedRuns[runID] {
+		return nil
+	}
+
+	delete(c.subscribedRuns, runID)
+	c.hub.UnsubscribeClient(c, runID)
+	return nil
+}
+
+// setLevelFilter restricts this client to log messages at level, or clears
+// the filter when level is "".
+func (c *Client) setLevelFilter(level string) {
+	c.filterMu.Lock()
+	defer c.filterMu.Unlock()
+	c.levelFilter = level
+}
+
+// setPaused sets whether this client's log messages are currently withheld,
+// e.g. so a viewer can freeze auto-scroll without disconnecting.
+func (c *Client) setPaused(paused bool) {
+	c.filterMu.Lock()
+	defer c.filterMu.Unlock()
+	c.paused = paused
+}
+
+// allowsLog reports whether this client should currently receive a log
+// message at level, given the pause/filter state set by its control
+// messages. Only "log" messages are subject to this; run_update, gap, and
+// error frames are always delivered regardless.
+func (c *Client) allowsLog(level string) bool {
+	c.filterMu.Lock()
+	defer c.filterMu.Unlock()
+	if c.paused {
+		return false
+	}
+	return c.levelFilter == "" || c.levelFilter == level
+}
+
+// writeError writes an ErrorMessage frame directly to the connection, e.g.
+// in response to a control message this client sent that couldn't be
+// understood.
+func (c *Client) writeError(message string) {
+	msg, err := json.Marshal(ErrorMessage{Type: "error", Message: message})
+	if err != nil {
+		return
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+	c.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// SendClose writes a WebSocket close control frame with code and reason
+// directly to the connection, e.g. websocket.CloseGoingAway as part of
+// Hub.Shutdown or websocket.CloseNormalClosure as part of
+// Hub.closeRunConnections, telling a well-behaved client why the server is
+// ending the connection so it can tell a clean close apart from a crash.
+// Best-effort: it doesn't wait for or verify the client's own close frame in
+// response, and ignores write errors since the connection may already be
+// gone.
+func (c *Client) SendClose(code int, reason string) {
+	deadline := time.Now().Add(c.hub.writeWait)
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
 }
 
 // writePump pumps messages from the hub to the WebSocket connection
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.hub.pingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
 	}()
 
+	// pendingBatch and batchTimer are only used for a batching client (see
+	// Client.batching): pendingBatch accumulates raw log message payloads
+	// until either c.hub.batchSize is reached or batchTimer fires after
+	// c.hub.batchWindow, at which point flushBatch sends them as one
+	// LogBatchMessage frame. batchTimerC is nil (and so never selects)
+	// whenever there's nothing pending.
+	var pendingBatch [][]byte
+	var batchTimer *time.Timer
+	var batchTimerC <-chan time.Time
+
+	flushBatch := func() error {
+		if len(pendingBatch) == 0 {
+			return nil
+		}
+		if batchTimer != nil {
+			batchTimer.Stop()
+			batchTimer, batchTimerC = nil, nil
+		}
+
+		entries := make([]json.RawMessage, len(pendingBatch))
+		for i, raw := range pendingBatch {
+			entries[i] = raw
+		}
+		pendingBatch = nil
+
+		msg, err := json.Marshal(LogBatchMessage{Type: "log_batch", Entries: entries})
+		if err != nil {
+			log.Printf("Failed to marshal log batch for client on %s: %v", c.describeRuns(), err)
+			return nil
+		}
+
+		c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+		return c.conn.WriteMessage(websocket.TextMessage, msg)
+	}
+
 	for {
 		select {
 		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// Hub closed the channel
+				flushBatch()
+				c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
 
+			// Batch log lines for a batching client; everything else
+			// (run_update, ai_result, gap, error) flushes whatever's
+			// pending first and then goes out on its own, immediately, so
+			// a client never waits behind a log batch for a status change.
+			if c.batching && message.prepared == nil {
+				if msgType, _, ok := probeTypeAndLevel(message.raw); ok && msgType == "log" {
+					pendingBatch = append(pendingBatch, message.raw)
+					if batchTimer == nil {
+						batchTimer = time.NewTimer(c.hub.batchWindow)
+						batchTimerC = batchTimer.C
+					}
+					if len(pendingBatch) >= c.hub.batchSize {
+						if err := flushBatch(); err != nil {
+							return
+						}
+					}
+					continue
+				}
+			}
+			if err := flushBatch(); err != nil {
+				return
+			}
+
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+			if message.prepared != nil {
+				if err := c.conn.WritePreparedMessage(message.prepared); err != nil {
+					return
+				}
+				continue
+			}
+
 			w, err := c.conn.NextWriter(websocket.TextMessage)
 			if err != nil {
 				return
 			}
-			w.Write(message)
+			w.Write(message.raw)
 
-			// Add queued messages to the current websocket message
+			// Add queued messages to the current websocket message. Prepared
+			// messages are always complete frames of their own, so stop
+			// coalescing as soon as one is next in line and let the next
+			// loop iteration send it via WritePreparedMessage instead.
 			n := len(c.send)
 			for i := 0; i < n; i++ {
+				next := <-c.send
+				if next.prepared != nil {
+					c.send <- next
+					break
+				}
 				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
+				w.Write(next.raw)
 			}
 
 			if err := w.Close(); err != nil {
 				return
 			}
 
+		case <-batchTimerC:
+			batchTimerC = nil
+			if err := flushBatch(); err != nil {
+				return
+			}
+
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := flushBatch(); err != nil {
+				return
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -122,3 +533,174 @@ func (c *Client) Start() {
 func (c *Client) Register() {
 	c.hub.register <- c
 }
+
+// ReplayBacklog fetches up to limit of runID's most recent log lines from
+// Loki and writes them directly to the connection marked as replay, so a
+// client connecting mid-run (or after it finished) doesn't see a blank
+// page while it waits for the next live line. The client must already be
+// Register()ed before calling this, so any line broadcast live while the
+// Loki query is in flight queues into c.send instead of being lost; once
+// the backlog is written, queued lines already covered by it (cursor at or
+// before the newest replayed line) are dropped so they aren't shown twice.
+func (c *Client) ReplayBacklog(ctx context.Context, lokiClient *loki.Client, limit int) error {
+	entries, err := lokiClient.QueryRecentLogs(ctx, c.runID, limit)
+	if err != nil {
+		return err
+	}
+
+	watermarkTs, watermarkSeq := c.writeLogEntries(entries, true)
+	c.dropCoveredQueuedMessages(watermarkTs, watermarkSeq)
+
+	return nil
+}
+
+// ReplaySince replays runID's log entries strictly after cursor (as
+// produced by buildCursor and reported to clients on LogMessage.Cursor),
+// for a client resuming after a reconnect that already has everything up
+// to that point. If more than limit entries exist after cursor, only the
+// first limit are replayed (oldest first) and a "gap" message precedes
+// them, telling the client this wasn't a complete resume and it should
+// fall back to ReplayBacklog (i.e. reconnect without ?since=) instead of
+// assuming it's caught up. As with ReplayBacklog, the client must already
+// be Register()ed so live broadcasts during the Loki query queue into
+// c.send rather than being lost.
+func (c *Client) ReplaySince(ctx context.Context, lokiClient *loki.Client, cursor string, limit int) error {
+	sinceTs, sinceSeq, err := ParseCursor(cursor)
+	if err != nil {
+		return err
+	}
+
+	entries, gap, err := lokiClient.QueryLogsSince(ctx, c.runID, sinceTs, limit)
+	if err != nil {
+		return err
+	}
+
+	if gap {
+		msg, err := json.Marshal(GapMessage{
+			Type:  "gap",
+			RunID: c.runID.String(),
+			Message: fmt.Sprintf("more than %d lines behind since=%s; replaying only the oldest %d — reconnect without ?since= for a full backlog",
+				limit, cursor, limit),
+		})
+		if err == nil {
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+			c.conn.WriteMessage(websocket.TextMessage, msg)
+		}
+	}
+
+	// QueryLogsSince's start bound is inclusive on timestamp, so the entry
+	// at exactly sinceTs the client already has (or older ones sharing that
+	// nanosecond) needs dropping by sequence.
+	filtered := entries[:0]
+	for _, entry := range entries {
+		_, seq, _ := entry.Parts()
+		if entry.Timestamp.Equal(sinceTs) && seq <= sinceSeq {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+
+	watermarkTs, watermarkSeq := c.writeLogEntries(filtered, false)
+	if watermarkTs.IsZero() {
+		watermarkTs, watermarkSeq = sinceTs, sinceSeq
+	}
+	c.dropCoveredQueuedMessages(watermarkTs, watermarkSeq)
+
+	return nil
+}
+
+// writeLogEntries marshals and writes each entry directly to the
+// connection as a LogMessage (marked replay if requested) and returns the
+// cursor (timestamp, sequence) of the last entry written, for the caller
+// to use as a watermark against queued live messages.
+func (c *Client) writeLogEntries(entries []loki.LogEntry, replay bool) (watermarkTs time.Time, watermarkSeq int64) {
+	for _, entry := range entries {
+		level, seq, content := entry.Parts()
+		if entry.Timestamp.After(watermarkTs) || (entry.Timestamp.Equal(watermarkTs) && seq > watermarkSeq) {
+			watermarkTs, watermarkSeq = entry.Timestamp, seq
+		}
+
+		msg, err := json.Marshal(LogMessage{
+			Type:      "log",
+			RunID:     c.runID.String(),
+			Timestamp: entry.Timestamp.Format(time.RFC3339Nano),
+			Level:     level,
+			Content:   content,
+			Replay:    replay,
+			Cursor:    buildCursor(entry.Timestamp, seq),
+		})
+		if err != nil {
+			continue
+		}
+
+		c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+		if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			break
+		}
+	}
+
+	return watermarkTs, watermarkSeq
+}
+
+// dropCoveredQueuedMessages drains c.send, re-queuing only the messages
+// whose cursor is after (watermarkTs, watermarkSeq). It's used right after
+// a replay to discard live lines the replay already sent.
+func (c *Client) dropCoveredQueuedMessages(watermarkTs time.Time, watermarkSeq int64) {
+	if watermarkTs.IsZero() {
+		return
+	}
+
+	n := len(c.send)
+	for i := 0; i < n; i++ {
+		queued := <-c.send
+		if !isCoveredByReplay(queued.raw, watermarkTs, watermarkSeq) {
+			c.send <- queued
+		}
+	}
+}
+
+// isCoveredByReplay reports whether a queued LogMessage's cursor is at or
+// before (watermarkTs, watermarkSeq), meaning a replay already delivered
+// it. Messages without a parseable cursor (e.g. non-log message types)
+// fall back to comparing by timestamp alone.
+func isCoveredByReplay(raw []byte, watermarkTs time.Time, watermarkSeq int64) bool {
+	var msg LogMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return false
+	}
+
+	ts, seq, err := ParseCursor(msg.Cursor)
+	if err != nil {
+		parsed, err := time.Parse(time.RFC3339Nano, msg.Timestamp)
+		if err != nil {
+			return false
+		}
+		return !parsed.After(watermarkTs)
+	}
+
+	if !ts.Equal(watermarkTs) {
+		return ts.Before(watermarkTs)
+	}
+	return seq <= watermarkSeq
+}
+
+// SendRunUpdate writes a run_update message directly to this client's
+// connection, bypassing the hub's broadcast. Used to tell a client whose
+// run had already finished before it connected, since it won't receive a
+// live broadcast that already happened.
+func (c *Client) SendRunUpdate(status *string, exitCode *int32, aiStatus, aiReport *string) error {
+	msg, err := json.Marshal(RunUpdateMessage{
+		Type:     "run_update",
+		RunID:    c.runID.String(),
+		Status:   status,
+		ExitCode: exitCode,
+		AIStatus: aiStatus,
+		AIReport: aiReport,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+	return c.conn.WriteMessage(websocket.TextMessage, msg)
+}
@@ -1,15 +1,90 @@
 package websocket
 
 import (
+	"compress/flate"
 	"context"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/redis/go-redis/v9"
 )
 
+// legacyChannel is the old single global pub/sub channel every run's log
+// and run-update events used to be published to. PublishLog and
+// PublishRunUpdate keep publishing here too, gated by
+// legacyChannelEnabled, so an older Hub still mid-PSUBSCRIBE-rollout (or a
+// consumer that hasn't migrated yet) keeps receiving events during a
+// rolling upgrade; the current Hub itself never subscribes to it.
+const legacyChannel = "swiftlog:logs"
+
+// defaultBatchWindow and defaultBatchSize are the batching client defaults
+// used unless SetBatching overrides them: flush after 50ms or 100 lines,
+// whichever comes first.
+const (
+	defaultBatchWindow = 50 * time.Millisecond
+	defaultBatchSize   = 100
+)
+
+// defaultCloseGracePeriod is how long Run() waits after a run reaches a
+// terminal state before closing its remaining connections, unless
+// SetCloseGracePeriod overrides it.
+const defaultCloseGracePeriod = 5 * time.Minute
+
+// defaultMaxSubscriptionsPerConnection bounds how many runs a single
+// /ws/subscribe connection may follow at once, unless SetSubscriptionLimit
+// overrides it.
+const defaultMaxSubscriptionsPerConnection = 50
+
+// legacyChannelEnabled controls whether PublishLog/PublishRunUpdate also
+// publish to legacyChannel. Defaults to enabled so upgrading the
+// publisher's binary alone doesn't strand any not-yet-upgraded Hub;
+// EnableLegacyChannel turns it off once every Hub instance has rolled out
+// per-run subscriptions.
+var legacyChannelEnabled atomic.Bool
+
+func init() {
+	legacyChannelEnabled.Store(true)
+}
+
+// EnableLegacyChannel sets whether PublishLog and PublishRunUpdate also
+// publish to the legacy global channel, alongside their per-run
+// swiftlog:runs:<run_id> channel.
+func EnableLegacyChannel(enabled bool) {
+	legacyChannelEnabled.Store(enabled)
+}
+
+// runChannel returns the per-run Redis pub/sub channel a Hub subscribes to
+// only while it has at least one client watching that run, so an instance
+// with a couple of viewers doesn't decode the whole platform's log volume.
+func runChannel(runID uuid.UUID) string {
+	return fmt.Sprintf("swiftlog:runs:%s", runID.String())
+}
+
+// publishToRun publishes data to runID's per-run channel and, if
+// EnableLegacyChannel(true) is in effect, also to the legacy global
+// channel.
+func publishToRun(ctx context.Context, redisClient *redis.Client, runID uuid.UUID, data []byte) error {
+	if err := redisClient.Publish(ctx, runChannel(runID), data).Err(); err != nil {
+		return err
+	}
+
+	if legacyChannelEnabled.Load() {
+		if err := redisClient.Publish(ctx, legacyChannel, data).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Hub maintains the set of active clients and broadcasts messages
 type Hub struct {
 	// Registered clients per run ID
@@ -21,17 +96,165 @@ type Hub struct {
 	// Unregister requests from clients
 	unregister chan *Client
 
+	// subscribeCh and unsubscribeCh add/remove a client under one run key
+	// without touching its send channel, for a multi-run subscriber client
+	// (see NewSubscriberClient) whose run membership changes over its
+	// lifetime instead of being fixed at register/unregister time. A
+	// subscriber client's send channel is only closed once, on full
+	// disconnect, by Hub.DisconnectSubscriber.
+	subscribeCh   chan subscribeRequest
+	unsubscribeCh chan subscribeRequest
+
+	// maxSubscriptionsPerConnection bounds how many runs a single
+	// /ws/subscribe connection may follow at once, overridable with
+	// SetSubscriptionLimit. Zero means unlimited.
+	maxSubscriptionsPerConnection int
+
 	// Broadcast messages to clients
 	broadcast chan *BroadcastMessage
 
 	// Redis client for pub/sub
 	redisClient *redis.Client
 
+	// subs tracks each run's active Redis subscription, keyed by run ID.
+	// Only ever touched from the Run() goroutine, so it needs no lock of
+	// its own.
+	subs map[uuid.UUID]*redis.PubSub
+
 	// Mutex for thread-safe operations
 	mu sync.RWMutex
 
 	// Context for cancellation
 	ctx context.Context
+
+	// writeWait, pongWait, and pingPeriod configure every Client's
+	// keepalive, defaulted to defaultWriteWait/defaultPongWait/
+	// defaultPingPeriod and overridable with SetKeepalive.
+	writeWait  time.Duration
+	pongWait   time.Duration
+	pingPeriod time.Duration
+
+	// compressionEnabled and compressionLevel configure permessage-deflate
+	// for broadcast log traffic, overridable with SetCompression. Disabled
+	// by default so a deployment has to opt in.
+	compressionEnabled bool
+	compressionLevel   int
+
+	// batchWindow and batchSize bound how long and how many log lines a
+	// batching client's writePump accumulates before flushing a
+	// LogBatchMessage, overridable with SetBatching. Only clients that
+	// negotiate batchingProtocolVersion or higher are affected.
+	batchWindow time.Duration
+	batchSize   int
+
+	// maxConnectionsPerUser and maxConnections bound TryReserveConnection,
+	// overridable with SetConnectionLimits. Zero means unlimited. Guarded by
+	// mu, same as userConns and totalConns.
+	maxConnectionsPerUser int
+	maxConnections        int
+	userConns             map[uuid.UUID]int
+	totalConns            int
+
+	// runStateMu guards lastAIStatus and lastRunStatus, which record each
+	// run's most recently broadcast status and ai_status (from a
+	// run_update), so BroadcastAIResult can skip re-announcing an outcome
+	// the client already saw and isRunTerminal can tell once a run won't
+	// produce any further message.
+	runStateMu    sync.Mutex
+	lastAIStatus  map[uuid.UUID]string
+	lastRunStatus map[uuid.UUID]string
+
+	// closeGracePeriod is how long Run() waits, once isRunTerminal reports
+	// a run has finished for good, before closing its remaining
+	// connections with code 1000 ("run finished"), unless a client sends a
+	// keep_open control message first. Overridable with
+	// SetCloseGracePeriod. closeTimers holds the pending timer per run and
+	// is guarded by mu, same as clients, since it's touched from the
+	// subscribeRun forwarder goroutines rather than only Run() itself.
+	closeGracePeriod time.Duration
+	closeTimers      map[uuid.UUID]*time.Timer
+
+	// metrics accumulates cumulative counters for /metrics, see Metrics.
+	metrics Metrics
+}
+
+// Metrics holds the hub's cumulative counters, safe for concurrent use from
+// the Run() goroutine and its subscribeRun forwarders. Combine with
+// Hub.ConnectionCount and Hub.SubscribedRunCount, which are gauges rather
+// than counters, for a full /metrics snapshot.
+type Metrics struct {
+	// MessagesBroadcast counts individual (message, client) deliveries
+	// successfully queued onto a client's send channel.
+	MessagesBroadcast atomic.Int64
+	// MessagesDropped counts individual queued log messages evicted (oldest
+	// first) to make room in a client's send buffer once it's full, under
+	// the drop-oldest backpressure policy in Hub.deliver. It no longer means
+	// the connection was closed — see LaggedMessage for what the affected
+	// client is told instead. This is the signal that a viewer is
+	// consuming slower than the run is producing.
+	MessagesDropped atomic.Int64
+	// Registrations and Unregistrations count clients joining and leaving
+	// across the hub's lifetime.
+	Registrations   atomic.Int64
+	Unregistrations atomic.Int64
+	// PubSubReceived counts messages received from Redis across all
+	// per-run subscriptions. PubSubDecoded and PubSubDecodeErrors split
+	// that total by whether the broadcast fan-out could parse the
+	// message's type and level.
+	PubSubReceived     atomic.Int64
+	PubSubDecoded      atomic.Int64
+	PubSubDecodeErrors atomic.Int64
+}
+
+// MetricsSnapshot is a point-in-time copy of Hub.metrics plus its current
+// gauges, suitable for marshaling onto a /metrics endpoint.
+type MetricsSnapshot struct {
+	Connections        int   `json:"connections"`
+	SubscribedRuns     int   `json:"subscribed_runs"`
+	MessagesBroadcast  int64 `json:"messages_broadcast"`
+	MessagesDropped    int64 `json:"messages_dropped"`
+	Registrations      int64 `json:"registrations"`
+	Unregistrations    int64 `json:"unregistrations"`
+	PubSubReceived     int64 `json:"pubsub_received"`
+	PubSubDecoded      int64 `json:"pubsub_decoded"`
+	PubSubDecodeErrors int64 `json:"pubsub_decode_errors"`
+}
+
+// MetricsSnapshot returns a point-in-time copy of the hub's counters and
+// gauges.
+func (h *Hub) MetricsSnapshot() MetricsSnapshot {
+	return MetricsSnapshot{
+		Connections:        h.ConnectionCount(),
+		SubscribedRuns:     h.SubscribedRunCount(),
+		MessagesBroadcast:  h.metrics.MessagesBroadcast.Load(),
+		MessagesDropped:    h.metrics.MessagesDropped.Load(),
+		Registrations:      h.metrics.Registrations.Load(),
+		Unregistrations:    h.metrics.Unregistrations.Load(),
+		PubSubReceived:     h.metrics.PubSubReceived.Load(),
+		PubSubDecoded:      h.metrics.PubSubDecoded.Load(),
+		PubSubDecodeErrors: h.metrics.PubSubDecodeErrors.Load(),
+	}
+}
+
+// SubscribedRunCount returns the number of distinct runs with at least one
+// registered client.
+func (h *Hub) SubscribedRunCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// SubscriberCounts returns the current number of registered clients per
+// run, keyed by run ID string, for ad-hoc debugging via GET /debug/hub.
+func (h *Hub) SubscriberCounts() map[string]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	counts := make(map[string]int, len(h.clients))
+	for runID, clients := range h.clients {
+		counts[runID.String()] = len(clients)
+	}
+	return counts
 }
 
 // BroadcastMessage represents a message to broadcast
@@ -40,6 +263,14 @@ type BroadcastMessage struct {
 	Message []byte
 }
 
+// subscribeRequest is sent on Hub.subscribeCh/unsubscribeCh to add or remove
+// client under runID, for a multi-run subscriber client (see
+// NewSubscriberClient) whose run membership isn't fixed at connection time.
+type subscribeRequest struct {
+	client *Client
+	runID  uuid.UUID
+}
+
 // LogMessage represents a log line message
 type LogMessage struct {
 	Type      string `json:"type"`
@@ -47,6 +278,87 @@ type LogMessage struct {
 	Timestamp string `json:"timestamp"`
 	Level     string `json:"level"`
 	Content   string `json:"content"`
+	// Replay marks a line sent from a client's backlog replay on connect,
+	// as opposed to one broadcast live as it arrived.
+	Replay bool `json:"replay,omitempty"`
+	// Cursor is this line's high-water mark ("<RFC3339Nano timestamp>|<sequence>"),
+	// for the client to persist and pass back as ?since= on reconnect.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// GapMessage tells a client that resumed with ?since= that more entries
+// exist after its cursor than the server was willing to replay, so what it
+// received is not a complete resume and it should fetch a fresh backlog
+// instead of assuming it's caught up.
+type GapMessage struct {
+	Type    string `json:"type"`
+	RunID   string `json:"run_id"`
+	Message string `json:"message"`
+}
+
+// buildCursor encodes a log line's timestamp and per-run sequence number
+// into the opaque cursor string clients persist as their high-water mark.
+func buildCursor(ts time.Time, sequence int64) string {
+	return ts.Format(time.RFC3339Nano) + "|" + strconv.FormatInt(sequence, 10)
+}
+
+// ParseCursor decodes a cursor produced by buildCursor, as passed back by a
+// client via ?since= on reconnect.
+func ParseCursor(cursor string) (ts time.Time, sequence int64, err error) {
+	parts := strings.SplitN(cursor, "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, 0, fmt.Errorf("malformed cursor %q: expected \"<timestamp>|<sequence>\"", cursor)
+	}
+
+	ts, err = time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("malformed cursor timestamp %q: %w", parts[0], err)
+	}
+
+	sequence, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("malformed cursor sequence %q: %w", parts[1], err)
+	}
+
+	return ts, sequence, nil
+}
+
+// ErrorMessage tells a client its last control message couldn't be applied,
+// e.g. an unknown action or an unsupported filter level.
+type ErrorMessage struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// LaggedMessage tells a client that Hub.deliver dropped some of its queued
+// log lines to make room in its send buffer, so the UI can offer "jump to
+// live" or backfill the gap via REST instead of the client silently missing
+// lines (or, under the old policy, being disconnected outright).
+type LaggedMessage struct {
+	Type    string `json:"type"`
+	Dropped int    `json:"dropped"`
+}
+
+// AIResultMessage tells a run's subscribers the terminal outcome of an AI
+// analysis, translated from a queue.AIAnalysisResult notification, so the
+// UI has one clearly-typed, actionable event (including a failure message)
+// instead of having to infer it from run_update's generic status/report
+// fields.
+type AIResultMessage struct {
+	Type    string `json:"type"`
+	RunID   string `json:"run_id"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// LogBatchMessage wraps up to Hub.batchSize log lines a batching client
+// (see batchingProtocolVersion) accumulated within Hub.batchWindow into a
+// single frame, so a high-volume run doesn't cost one WebSocket frame per
+// line. Entries are the exact LogMessage payloads that would otherwise have
+// been sent individually, oldest first.
+type LogBatchMessage struct {
+	Type    string            `json:"type"`
+	Entries []json.RawMessage `json:"entries"`
 }
 
 // RunUpdateMessage represents a run status update message
@@ -62,19 +374,298 @@ type RunUpdateMessage struct {
 // NewHub creates a new WebSocket hub
 func NewHub(ctx context.Context, redisClient *redis.Client) *Hub {
 	return &Hub{
-		clients:     make(map[uuid.UUID]map[*Client]bool),
-		register:    make(chan *Client),
-		unregister:  make(chan *Client),
-		broadcast:   make(chan *BroadcastMessage, 256),
-		redisClient: redisClient,
-		ctx:         ctx,
+		clients:                       make(map[uuid.UUID]map[*Client]bool),
+		register:                      make(chan *Client),
+		unregister:                    make(chan *Client),
+		subscribeCh:                   make(chan subscribeRequest),
+		unsubscribeCh:                 make(chan subscribeRequest),
+		maxSubscriptionsPerConnection: defaultMaxSubscriptionsPerConnection,
+		broadcast:                     make(chan *BroadcastMessage, 256),
+		redisClient:                   redisClient,
+		subs:                          make(map[uuid.UUID]*redis.PubSub),
+		ctx:                           ctx,
+		writeWait:                     defaultWriteWait,
+		pongWait:                      defaultPongWait,
+		pingPeriod:                    defaultPingPeriod,
+		compressionLevel:              flate.DefaultCompression,
+		batchWindow:                   defaultBatchWindow,
+		batchSize:                     defaultBatchSize,
+		userConns:                     make(map[uuid.UUID]int),
+		lastAIStatus:                  make(map[uuid.UUID]string),
+		lastRunStatus:                 make(map[uuid.UUID]string),
+		closeGracePeriod:              defaultCloseGracePeriod,
+		closeTimers:                   make(map[uuid.UUID]*time.Timer),
+	}
+}
+
+// SetKeepalive overrides how often clients ping the peer, how long a client
+// waits for a pong before its read deadline expires, and how long a single
+// write may take before it's abandoned. pingPeriod should be comfortably
+// less than pongWait, matching the relationship between the package's
+// defaults. Only affects clients registered after this call.
+func (h *Hub) SetKeepalive(pingPeriod, pongWait, writeWait time.Duration) {
+	h.pingPeriod = pingPeriod
+	h.pongWait = pongWait
+	h.writeWait = writeWait
+}
+
+// SetCompression sets whether broadcast log traffic is sent with
+// permessage-deflate compression, and at what compression level (see
+// websocket.SetCompressionLevel; websocket.DefaultCompression if unsure).
+// Only affects clients registered after this call. The upgrader used to
+// accept connections must also have EnableCompression set for compression
+// to actually be negotiated with a client.
+func (h *Hub) SetCompression(enabled bool, level int) {
+	h.compressionEnabled = enabled
+	h.compressionLevel = level
+}
+
+// SetBatching overrides how long and how many log lines a batching client
+// (protocol version 2 or higher, see batchingProtocolVersion) accumulates
+// before its writePump flushes them as one LogBatchMessage frame. Only
+// affects clients registered after this call.
+func (h *Hub) SetBatching(window time.Duration, size int) {
+	h.batchWindow = window
+	h.batchSize = size
+}
+
+// SetConnectionLimits overrides the per-user and global caps enforced by
+// TryReserveConnection. Zero disables the corresponding cap. Only affects
+// connections reserved after this call.
+func (h *Hub) SetConnectionLimits(maxConnectionsPerUser, maxConnections int) {
+	h.maxConnectionsPerUser = maxConnectionsPerUser
+	h.maxConnections = maxConnections
+}
+
+// SetCloseGracePeriod overrides how long Run() waits after a run reaches a
+// terminal state before closing its remaining connections. Only affects
+// runs that reach a terminal state after this call.
+func (h *Hub) SetCloseGracePeriod(period time.Duration) {
+	h.closeGracePeriod = period
+}
+
+// SetSubscriptionLimit overrides how many runs a single /ws/subscribe
+// connection may follow at once. Zero disables the cap. Only affects
+// subscribe actions handled after this call.
+func (h *Hub) SetSubscriptionLimit(maxSubscriptionsPerConnection int) {
+	h.maxSubscriptionsPerConnection = maxSubscriptionsPerConnection
+}
+
+// TryReserveConnection attempts to reserve capacity for one new connection
+// from userID against the per-user and global caps set by
+// SetConnectionLimits, before the WebSocket upgrade happens. Returns false,
+// reserving nothing, if either cap is already reached. Every successful
+// reservation must be matched by exactly one ReleaseConnection call, whether
+// the connection goes on to register with the hub (released when it
+// unregisters, including on abnormal close) or the upgrade itself
+// subsequently fails (released immediately by the caller).
+func (h *Hub) TryReserveConnection(userID uuid.UUID) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.maxConnections > 0 && h.totalConns >= h.maxConnections {
+		return false
+	}
+	if h.maxConnectionsPerUser > 0 && h.userConns[userID] >= h.maxConnectionsPerUser {
+		return false
+	}
+
+	h.userConns[userID]++
+	h.totalConns++
+	return true
+}
+
+// ReleaseConnection releases capacity reserved by a prior successful
+// TryReserveConnection call for userID.
+func (h *Hub) ReleaseConnection(userID uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.userConns[userID] > 0 {
+		h.userConns[userID]--
+		if h.userConns[userID] == 0 {
+			delete(h.userConns, userID)
+		}
+	}
+	if h.totalConns > 0 {
+		h.totalConns--
+	}
+}
+
+// UserConnectionCounts returns the current number of reserved connections
+// per user, keyed by user ID string, for ad-hoc debugging via GET
+// /debug/hub. Includes connections mid-upgrade as well as registered ones,
+// since both hold a reservation.
+func (h *Hub) UserConnectionCounts() map[string]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	counts := make(map[string]int, len(h.userConns))
+	for userID, n := range h.userConns {
+		counts[userID.String()] = n
+	}
+	return counts
+}
+
+// ConnectionCount returns the number of distinct WebSocket clients currently
+// registered across all runs, serving as a live-connections gauge. A
+// multi-run subscriber client (see NewSubscriberClient) counts once no
+// matter how many runs it's subscribed to, same as allClients.
+func (h *Hub) ConnectionCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.distinctClientsLocked())
+}
+
+// allClients returns a snapshot of every currently registered client across
+// all runs, deduplicated so a multi-run subscriber client (registered under
+// several run keys at once) appears only once, for the shutdown paths below
+// that need to act on each one without holding mu for the duration of a
+// network write.
+func (h *Hub) allClients() []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := h.distinctClientsLocked()
+	clients := make([]*Client, 0, len(seen))
+	for client := range seen {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// distinctClientsLocked returns the set of distinct clients across every run
+// bucket in h.clients. Callers must hold mu.
+func (h *Hub) distinctClientsLocked() map[*Client]bool {
+	seen := make(map[*Client]bool)
+	for _, runClients := range h.clients {
+		for client := range runClients {
+			seen[client] = true
+		}
+	}
+	return seen
+}
+
+// Shutdown sends a WebSocket close frame (code 1001, going away) with
+// reason to every currently registered client, so a well-behaved client
+// (including the CLI's tail) can tell a clean server shutdown apart from a
+// crash and reconnect with backoff immediately instead of treating it as an
+// error. It does not itself unregister anyone or tear down the hub; a
+// client that acts on the close frame disconnects on its own, which reaches
+// Run()'s unregister case the same way any other disconnect does.
+func (h *Hub) Shutdown(reason string) {
+	for _, client := range h.allClients() {
+		client.SendClose(websocket.CloseGoingAway, reason)
+	}
+}
+
+// ForceDisconnectAll closes the underlying connection of every currently
+// registered client, for use after Shutdown's close frames have had a
+// chance to be acknowledged. Closing the connection unblocks each client's
+// readPump with a read error, which unregisters it the same way any other
+// disconnect does.
+func (h *Hub) ForceDisconnectAll() {
+	for _, client := range h.allClients() {
+		client.conn.Close()
+	}
+}
+
+// SubscribeClient adds client under runID, for a multi-run subscriber
+// client (see NewSubscriberClient) handling a "subscribe" control message.
+// Unlike Register, it doesn't touch client.send, since a subscriber
+// client's send channel is shared across all of its subscriptions and is
+// only closed once, on full disconnect (see DisconnectSubscriber).
+func (h *Hub) SubscribeClient(client *Client, runID uuid.UUID) {
+	h.subscribeCh <- subscribeRequest{client: client, runID: runID}
+}
+
+// UnsubscribeClient removes client from runID, for a multi-run subscriber
+// client (see NewSubscriberClient) handling an "unsubscribe" control
+// message.
+func (h *Hub) UnsubscribeClient(client *Client, runID uuid.UUID) {
+	h.unsubscribeCh <- subscribeRequest{client: client, runID: runID}
+}
+
+// DisconnectSubscriber tears down a multi-run subscriber client (see
+// NewSubscriberClient) on disconnect: removes it from every run it's
+// currently subscribed to, closes its send channel, and releases its
+// connection reservation. Used instead of the register/unregister channels,
+// since a subscriber client's run membership isn't the single fixed runID
+// those key off of.
+func (h *Hub) DisconnectSubscriber(client *Client) {
+	for runID := range client.subscribedRuns {
+		h.UnsubscribeClient(client, runID)
+	}
+
+	h.mu.Lock()
+	close(client.send)
+	h.mu.Unlock()
+
+	h.metrics.Unregistrations.Add(1)
+	h.ReleaseConnection(client.userID)
+}
+
+// scheduleRunClose (re)starts the timer that closes runID's remaining
+// connections after h.closeGracePeriod, once isRunTerminal reports it's
+// reached a terminal state, replacing any timer already pending for it.
+// Called from a subscribeRun forwarder goroutine, not Run() itself, so
+// closeTimers is guarded by mu like the hub's other cross-goroutine state.
+func (h *Hub) scheduleRunClose(runID uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if timer, ok := h.closeTimers[runID]; ok {
+		timer.Stop()
+	}
+	h.closeTimers[runID] = time.AfterFunc(h.closeGracePeriod, func() {
+		h.closeRunConnections(runID)
+	})
+}
+
+// CancelScheduledClose cancels any pending scheduled close for runID, e.g.
+// because a client sent a keep_open control message, or because its last
+// client unregistered and there's nothing left to close. Safe to call when
+// nothing is pending. Note that keep_open cancels the close for the whole
+// run, not just the client that sent it: the hub only tracks one pending
+// close per run (the same granularity as its Redis subscription), not one
+// per client.
+func (h *Hub) CancelScheduledClose(runID uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if timer, ok := h.closeTimers[runID]; ok {
+		timer.Stop()
+		delete(h.closeTimers, runID)
+	}
+}
+
+// closeRunConnections sends every client currently registered for runID a
+// close frame (code 1000, normal closure, reason "run finished") and closes
+// its underlying connection. Unlike Shutdown, there's no separate
+// ForceDisconnectAll step, since the grace period already gave a slow
+// client time to react before this fired. Each client's own
+// readPump/unregister path handles cleanup once its connection ends. Runs
+// on its own timer-fired goroutine, not Run()'s.
+func (h *Hub) closeRunConnections(runID uuid.UUID) {
+	h.mu.Lock()
+	delete(h.closeTimers, runID)
+	runClients := h.clients[runID]
+	clients := make([]*Client, 0, len(runClients))
+	for client := range runClients {
+		clients = append(clients, client)
+	}
+	h.mu.Unlock()
+
+	for _, client := range clients {
+		client.SendClose(websocket.CloseNormalClosure, "run finished")
+		client.conn.Close()
 	}
 }
 
 // Run starts the hub
 func (h *Hub) Run() {
-	// Subscribe to Redis pub/sub for log events
-	go h.subscribeToRedis()
+	defer h.closeAllSubs()
 
 	for {
 		select {
@@ -83,38 +674,106 @@ func (h *Hub) Run() {
 			if h.clients[client.runID] == nil {
 				h.clients[client.runID] = make(map[*Client]bool)
 			}
+			isFirst := len(h.clients[client.runID]) == 0
 			h.clients[client.runID][client] = true
 			h.mu.Unlock()
-			log.Printf("Client registered for run %s", client.runID)
+			h.metrics.Registrations.Add(1)
+			if isFirst {
+				h.subscribeRun(client.runID)
+			}
+			slog.Info("client registered", "run_id", client.runID, "user_id", client.userID)
 
 		case client := <-h.unregister:
 			h.mu.Lock()
+			isLast := false
 			if clients, ok := h.clients[client.runID]; ok {
 				if _, ok := clients[client]; ok {
 					delete(clients, client)
 					close(client.send)
 					if len(clients) == 0 {
 						delete(h.clients, client.runID)
+						isLast = true
 					}
 				}
 			}
 			h.mu.Unlock()
-			log.Printf("Client unregistered for run %s", client.runID)
+			h.metrics.Unregistrations.Add(1)
+			h.ReleaseConnection(client.userID)
+			if isLast {
+				h.unsubscribeRun(client.runID)
+				h.CancelScheduledClose(client.runID)
+			}
+			slog.Info("client unregistered", "run_id", client.runID, "user_id", client.userID)
+
+		case req := <-h.subscribeCh:
+			h.mu.Lock()
+			if h.clients[req.runID] == nil {
+				h.clients[req.runID] = make(map[*Client]bool)
+			}
+			isFirst := len(h.clients[req.runID]) == 0
+			h.clients[req.runID][req.client] = true
+			h.mu.Unlock()
+			h.metrics.Registrations.Add(1)
+			if isFirst {
+				h.subscribeRun(req.runID)
+			}
+			slog.Info("subscriber client added", "run_id", req.runID)
+
+		case req := <-h.unsubscribeCh:
+			h.mu.Lock()
+			isLast := false
+			if clients, ok := h.clients[req.runID]; ok {
+				if _, ok := clients[req.client]; ok {
+					delete(clients, req.client)
+					if len(clients) == 0 {
+						delete(h.clients, req.runID)
+						isLast = true
+					}
+				}
+			}
+			h.mu.Unlock()
+			h.metrics.Unregistrations.Add(1)
+			if isLast {
+				h.unsubscribeRun(req.runID)
+				h.CancelScheduledClose(req.runID)
+			}
+			slog.Info("subscriber client removed", "run_id", req.runID)
 
 		case message := <-h.broadcast:
 			h.mu.RLock()
 			clients := h.clients[message.RunID]
 			h.mu.RUnlock()
 
+			msgType, level, ok := probeTypeAndLevel(message.Message)
+			if ok {
+				h.metrics.PubSubDecoded.Add(1)
+			} else {
+				h.metrics.PubSubDecodeErrors.Add(1)
+			}
+
+			// Built once per broadcast, not per client, so a run with many
+			// subscribers pays for compression once instead of N times: see
+			// outboundMessage's doc comment.
+			outbound := &outboundMessage{raw: message.Message}
+			if h.compressionEnabled {
+				if pm, err := websocket.NewPreparedMessage(websocket.TextMessage, message.Message); err == nil {
+					outbound.prepared = pm
+				} else {
+					slog.Error("failed to prepare compressed message", "error", err)
+				}
+			}
+
 			for client := range clients {
-				select {
-				case client.send <- message.Message:
-				default:
-					// Client send buffer is full, close it
-					h.mu.Lock()
-					close(client.send)
-					delete(h.clients[message.RunID], client)
-					h.mu.Unlock()
+				if msgType == "log" && !client.allowsLog(level) {
+					continue
+				}
+
+				delivered, dropped := h.deliver(client, outbound, msgType == "log")
+				if delivered {
+					h.metrics.MessagesBroadcast.Add(1)
+				}
+				if dropped > 0 {
+					h.metrics.MessagesDropped.Add(int64(dropped))
 				}
 			}
 
@@ -124,6 +783,142 @@ func (h *Hub) Run() {
 	}
 }
 
+// probeTypeAndLevel picks out just the "type" and "level" fields of an
+// already-marshaled message, so the broadcast fan-out can decide per-client
+// whether to deliver a log line (subject to pause/filter state) without
+// fully decoding it. ok is false if data isn't valid JSON.
+func probeTypeAndLevel(data []byte) (msgType, level string, ok bool) {
+	var probe struct {
+		Type  string `json:"type"`
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return "", "", false
+	}
+	return probe.Type, probe.Level, true
+}
+
+// deliver queues outbound onto client.send, called from Run()'s broadcast
+// case. If the buffer is full, it drops the client's oldest queued log
+// messages (never run_update, ai_result, gap, or error) via makeRoom to make
+// room instead of closing the connection, then sends a LaggedMessage
+// reporting how many were dropped. client.send is never closed here — only
+// Run()'s unregister case closes it, so falling behind no longer tears a
+// client down. Returns whether outbound was delivered and how many
+// messages were dropped to make room for it (0 if none were needed).
+func (h *Hub) deliver(client *Client, outbound *outboundMessage, isLog bool) (delivered bool, dropped int) {
+	select {
+	case client.send <- outbound:
+		return true, 0
+	default:
+	}
+
+	dropped = h.makeRoom(client)
+
+	select {
+	case client.send <- outbound:
+		delivered = true
+	default:
+		// Buffer is still full even after dropping everything droppable,
+		// meaning it's saturated with non-log messages; give up on this
+		// one delivery rather than block the hub goroutine.
+	}
+
+	if dropped > 0 {
+		h.sendLagNotice(client, dropped)
+	}
+	return delivered, dropped
+}
+
+// isDroppableMessageType reports whether a queued message of msgType may be
+// evicted under backpressure. "log" is the bulk of traffic and always
+// replaceable by re-fetching from Loki. "lagged" is included too: it's
+// superseded by whatever fresh notice the next drop produces, so losing a
+// stale one only costs some precision in the reported count - but *not*
+// including it here would let a badly backed-up client's own pile of old
+// lagged notices crowd out the buffer and start silently swallowing
+// run_update/ai_result/gap/error events, exactly what those types must
+// never be dropped. Everything else (run_update, ai_result, gap, error) is
+// never dropped.
+func isDroppableMessageType(msgType string) bool {
+	return msgType == "log" || msgType == "lagged"
+}
+
+// makeRoom discards every one of client's queued droppable messages (see
+// isDroppableMessageType), wherever they sit in the backlog, and returns how
+// many were dropped. Everything else is kept, in its original relative
+// order.
+//
+// A channel has no way to peek at (let alone remove from the middle of) its
+// contents without dequeuing everything ahead of the target, so the whole
+// backlog is drained into pending first. Two things depend on that: dropped
+// messages must come from anywhere in the backlog, not just a leading run -
+// stopping at the first non-droppable message would let one un-drained
+// run_update sitting at the front permanently block eviction of every log
+// line queued behind it, silently stalling the whole client (including
+// future run_updates, which would find no room and no evictable messages
+// either) until that one message finally drains. And whatever survives
+// eviction must be re-queued in its original relative order, or a
+// non-droppable message could come back out of order relative to another
+// one still behind it.
+func (h *Hub) makeRoom(client *Client) int {
+	n := len(client.send)
+	pending := make([]*outboundMessage, 0, n)
+	for i := 0; i < n; i++ {
+		select {
+		case queued, ok := <-client.send:
+			if !ok {
+				return 0
+			}
+			pending = append(pending, queued)
+		default:
+			// Someone else drained a slot concurrently; work with what we got.
+		}
+	}
+
+	kept := make([]*outboundMessage, 0, len(pending))
+	dropped := 0
+	for _, m := range pending {
+		if msgType, _, ok := probeTypeAndLevel(m.raw); ok && isDroppableMessageType(msgType) {
+			dropped++
+			continue
+		}
+		kept = append(kept, m)
+	}
+
+	for _, m := range kept {
+		client.send <- m
+	}
+
+	return dropped
+}
+
+// sendLagNotice enqueues a LaggedMessage reporting dropped queued log
+// messages onto client.send, making room via makeRoom if needed since the
+// notice itself must not be lost to the same full-buffer condition it's
+// reporting on.
+func (h *Hub) sendLagNotice(client *Client, dropped int) {
+	data, err := json.Marshal(LaggedMessage{Type: "lagged", Dropped: dropped})
+	if err != nil {
+		slog.Error("failed to marshal lagged notice", "runs", client.describeRuns(), "error", err)
+		return
+	}
+	notice := &outboundMessage{raw: data}
+
+	select {
+	case client.send <- notice:
+		return
+	default:
+	}
+
+	h.makeRoom(client)
+	select {
+	case client.send <- notice:
+	default:
+		slog.Warn("dropped lagged notice: send buffer saturated with non-log messages", "runs", client.describeRuns())
+	}
+}
+
 // Broadcast sends a message to all clients subscribed to a run
 func (h *Hub) Broadcast(runID uuid.UUID, message []byte) {
 	h.broadcast <- &BroadcastMessage{
@@ -132,47 +927,181 @@ func (h *Hub) Broadcast(runID uuid.UUID, message []byte) {
 	}
 }
 
-// subscribeToRedis listens to Redis pub/sub for log events
-func (h *Hub) subscribeToRedis() {
-	pubsub := h.redisClient.Subscribe(h.ctx, "swiftlog:logs")
-	defer pubsub.Close()
+// subscribeRun starts a Redis SUBSCRIBE on runID's per-run channel and a
+// goroutine forwarding whatever arrives to connected clients, so an
+// instance only pays to decode traffic for runs it actually has a viewer
+// for. Called from Run()'s goroutine when a run's first client registers.
+func (h *Hub) subscribeRun(runID uuid.UUID) {
+	pubsub := h.redisClient.Subscribe(h.ctx, runChannel(runID))
+	h.subs[runID] = pubsub
 
-	ch := pubsub.Channel()
-
-	for {
-		select {
-		case msg := <-ch:
-			// Parse the message
-			var logMsg LogMessage
-			if err := json.Unmarshal([]byte(msg.Payload), &logMsg); err != nil {
-				log.Printf("Failed to unmarshal log message: %v", err)
-				continue
+	go func() {
+		ch := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				h.metrics.PubSubReceived.Add(1)
+				if h.noteRunState(runID, []byte(msg.Payload)) && h.isRunTerminal(runID) {
+					h.scheduleRunClose(runID)
+				}
+				h.Broadcast(runID, []byte(msg.Payload))
+			case <-h.ctx.Done():
+				return
 			}
+		}
+	}()
+}
 
-			// Parse run ID
-			runID, err := uuid.Parse(logMsg.RunID)
-			if err != nil {
-				log.Printf("Invalid run ID: %v", err)
-				continue
-			}
+// unsubscribeRun closes runID's Redis subscription. Called from Run()'s
+// goroutine when a run's last client unregisters.
+func (h *Hub) unsubscribeRun(runID uuid.UUID) {
+	if pubsub, ok := h.subs[runID]; ok {
+		pubsub.Close()
+		delete(h.subs, runID)
+	}
 
-			// Broadcast to connected clients
-			h.Broadcast(runID, []byte(msg.Payload))
+	h.runStateMu.Lock()
+	delete(h.lastAIStatus, runID)
+	delete(h.lastRunStatus, runID)
+	h.runStateMu.Unlock()
+}
 
-		case <-h.ctx.Done():
-			return
-		}
+// noteRunState records runID's most recently broadcast status and
+// ai_status from a run_update payload, so a later BroadcastAIResult for the
+// same outcome can recognize it's already been announced and isRunTerminal
+// can tell once a run won't produce any further message. Returns whether
+// payload was actually a run_update.
+func (h *Hub) noteRunState(runID uuid.UUID, payload []byte) bool {
+	var probe struct {
+		Type     string  `json:"type"`
+		Status   *string `json:"status"`
+		AIStatus *string `json:"ai_status"`
+	}
+	if err := json.Unmarshal(payload, &probe); err != nil || probe.Type != "run_update" {
+		return false
+	}
+
+	h.runStateMu.Lock()
+	if probe.Status != nil {
+		h.lastRunStatus[runID] = *probe.Status
+	}
+	if probe.AIStatus != nil {
+		h.lastAIStatus[runID] = *probe.AIStatus
+	}
+	h.runStateMu.Unlock()
+	return true
+}
+
+// terminalRunStatuses and nonTerminalAIStatuses classify the string values
+// PublishRunUpdate carries in its status and ai_status fields, used by
+// isRunTerminal to decide when a run won't produce any further message.
+var terminalRunStatuses = map[string]bool{
+	"completed": true,
+	"failed":    true,
+	"aborted":   true,
+}
+
+var nonTerminalAIStatuses = map[string]bool{
+	"pending":    true,
+	"processing": true,
+	"partial":    true,
+}
+
+// isRunTerminal reports whether runID has reached a terminal state: its
+// most recently broadcast status is one PublishRunUpdate never follows with
+// "running" again, and its AI analysis, if one was ever observed for this
+// run, isn't still pending, processing, or a provisional partial report. A
+// run that never triggers AI analysis at all never gets an ai_status here,
+// so it's treated as terminal on status alone. Kept as plain strings,
+// matching RunUpdateMessage's own fields, rather than importing
+// internal/models, so this package doesn't need to track that package's
+// status enums.
+func (h *Hub) isRunTerminal(runID uuid.UUID) bool {
+	h.runStateMu.Lock()
+	defer h.runStateMu.Unlock()
+
+	if !terminalRunStatuses[h.lastRunStatus[runID]] {
+		return false
+	}
+	if aiStatus, ok := h.lastAIStatus[runID]; ok && nonTerminalAIStatuses[aiStatus] {
+		return false
+	}
+	return true
+}
+
+// NoteRunSnapshot records runID's current status and aiStatus and schedules
+// its connections for closure if that makes it terminal, exactly like a
+// live run_update broadcast would via noteRunState/isRunTerminal. Redis
+// pub/sub doesn't replay past messages, so a client connecting to a run
+// that already finished before it (or before this hub instance had any
+// subscriber for it) never sees that terminal run_update go by live; this
+// lets cmd/websocket's handleWebSocket, which already loads the run's
+// current state to send it directly via Client.SendRunUpdate, feed the same
+// state into the close-scheduling path.
+func (h *Hub) NoteRunSnapshot(runID uuid.UUID, status, aiStatus string) {
+	h.runStateMu.Lock()
+	h.lastRunStatus[runID] = status
+	h.lastAIStatus[runID] = aiStatus
+	h.runStateMu.Unlock()
+
+	if h.isRunTerminal(runID) {
+		h.scheduleRunClose(runID)
+	}
+}
+
+// BroadcastAIResult translates an AI analysis result notification into an
+// ai_result message for runID's subscribers, unless the worker's run_update
+// for this exact terminal status already reached them (see noteRunState),
+// in which case it's skipped so the client doesn't see two events telling
+// it the same outcome.
+func (h *Hub) BroadcastAIResult(runID uuid.UUID, status, message string) {
+	h.runStateMu.Lock()
+	alreadyAnnounced := h.lastAIStatus[runID] == status
+	h.runStateMu.Unlock()
+	if alreadyAnnounced {
+		return
+	}
+
+	data, err := json.Marshal(AIResultMessage{
+		Type:    "ai_result",
+		RunID:   runID.String(),
+		Status:  status,
+		Message: message,
+	})
+	if err != nil {
+		slog.Error("failed to marshal ai_result message", "run_id", runID, "error", err)
+		return
+	}
+
+	h.Broadcast(runID, data)
+}
+
+// closeAllSubs closes every remaining per-run subscription when the hub
+// shuts down.
+func (h *Hub) closeAllSubs() {
+	for runID, pubsub := range h.subs {
+		pubsub.Close()
+		delete(h.subs, runID)
 	}
 }
 
 // PublishLog publishes a log message to Redis (called by Ingestor)
-func PublishLog(ctx context.Context, redisClient *redis.Client, runID uuid.UUID, timestamp, level, content string) error {
+func PublishLog(ctx context.Context, redisClient *redis.Client, runID uuid.UUID, timestamp, level, content string, sequence int64) error {
+	ts, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q: %w", timestamp, err)
+	}
+
 	logMsg := LogMessage{
 		Type:      "log",
 		RunID:     runID.String(),
 		Timestamp: timestamp,
 		Level:     level,
 		Content:   content,
+		Cursor:    buildCursor(ts, sequence),
 	}
 
 	data, err := json.Marshal(logMsg)
@@ -180,7 +1109,7 @@ func PublishLog(ctx context.Context, redisClient *redis.Client, runID uuid.UUID,
 		return err
 	}
 
-	return redisClient.Publish(ctx, "swiftlog:logs", data).Err()
+	return publishToRun(ctx, redisClient, runID, data)
 }
 
 // PublishRunUpdate publishes a run status update to Redis
@@ -199,5 +1128,5 @@ func PublishRunUpdate(ctx context.Context, redisClient *redis.Client, runID uuid
 		return err
 	}
 
-	return redisClient.Publish(ctx, "swiftlog:logs", data).Err()
+	return publishToRun(ctx, redisClient, runID, data)
 }
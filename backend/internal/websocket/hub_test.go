@@ -0,0 +1,275 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// testMessage is a minimal broadcast payload: enough for probeTypeAndLevel
+// to classify it, plus a monotonically increasing seq so a test can check
+// ordering was preserved.
+type testMessage struct {
+	Type string `json:"type"`
+	Seq  int    `json:"seq"`
+}
+
+func mustMarshalTestMessage(t *testing.T, msgType string, seq int) []byte {
+	t.Helper()
+	data, err := json.Marshal(testMessage{Type: msgType, Seq: seq})
+	if err != nil {
+		t.Fatalf("failed to marshal test message: %v", err)
+	}
+	return data
+}
+
+// TestMakeRoom_PreservesOrderOfNonLogMessages pins down the reordering bug:
+// a non-droppable message sitting behind already-dropped log messages must
+// come back out in the same relative position, not get shuffled behind
+// messages that were queued after it. It also pins the fix's broader scope:
+// every droppable message in the backlog is evicted, not just a leading run
+// - otherwise a single un-drained run_update at the front would permanently
+// block eviction of every log line still queued behind it.
+func TestMakeRoom_PreservesOrderOfNonLogMessages(t *testing.T) {
+	client := &Client{send: make(chan *outboundMessage, 5)}
+	client.send <- &outboundMessage{raw: mustMarshalTestMessage(t, "log", 1)}
+	client.send <- &outboundMessage{raw: mustMarshalTestMessage(t, "log", 2)}
+	client.send <- &outboundMessage{raw: mustMarshalTestMessage(t, "run_update", 3)}
+	client.send <- &outboundMessage{raw: mustMarshalTestMessage(t, "log", 4)}
+	client.send <- &outboundMessage{raw: mustMarshalTestMessage(t, "log", 5)}
+
+	h := &Hub{}
+	dropped := h.makeRoom(client)
+
+	if dropped != 4 {
+		t.Fatalf("dropped = %d, want 4", dropped)
+	}
+
+	close(client.send)
+	var seqs []int
+	for m := range client.send {
+		var msg testMessage
+		if err := json.Unmarshal(m.raw, &msg); err != nil {
+			t.Fatalf("failed to unmarshal remaining message: %v", err)
+		}
+		seqs = append(seqs, msg.Seq)
+	}
+
+	want := []int{3}
+	if len(seqs) != len(want) {
+		t.Fatalf("remaining seqs = %v, want %v", seqs, want)
+	}
+	for i, s := range seqs {
+		if s != want[i] {
+			t.Fatalf("remaining seqs = %v, want %v (run_update at seq 3 was reordered)", seqs, want)
+		}
+	}
+}
+
+// TestMakeRoom_DropsLogsFromBehindAStuckNonDroppableMessage covers the case
+// the leading-run-only version of this fix got wrong: a non-droppable
+// message sitting at the very front of a full buffer must not block
+// eviction of droppable messages queued behind it.
+func TestMakeRoom_DropsLogsFromBehindAStuckNonDroppableMessage(t *testing.T) {
+	client := &Client{send: make(chan *outboundMessage, 3)}
+	client.send <- &outboundMessage{raw: mustMarshalTestMessage(t, "run_update", 1)}
+	client.send <- &outboundMessage{raw: mustMarshalTestMessage(t, "log", 2)}
+	client.send <- &outboundMessage{raw: mustMarshalTestMessage(t, "log", 3)}
+
+	h := &Hub{}
+	dropped := h.makeRoom(client)
+
+	if dropped != 2 {
+		t.Fatalf("dropped = %d, want 2", dropped)
+	}
+
+	close(client.send)
+	var seqs []int
+	for m := range client.send {
+		var msg testMessage
+		if err := json.Unmarshal(m.raw, &msg); err != nil {
+			t.Fatalf("failed to unmarshal remaining message: %v", err)
+		}
+		seqs = append(seqs, msg.Seq)
+	}
+
+	if want := []int{1}; len(seqs) != 1 || seqs[0] != want[0] {
+		t.Fatalf("remaining seqs = %v, want %v", seqs, want)
+	}
+}
+
+// newStressHub starts a Hub backed by a fake Redis (miniredis, so
+// Hub.subscribeRun's PSUBSCRIBE has something to talk to) and runs it in
+// the background for the lifetime of the test.
+func newStressHub(t *testing.T) *Hub {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	h := NewHub(ctx, redisClient)
+	go h.Run()
+	return h
+}
+
+// runUpdateSeqs drains client.send (already unregistered, so the channel is
+// closed) and returns the seq of every run_update message found on it, in
+// the order it was queued.
+func runUpdateSeqs(t *testing.T, client *Client) []int {
+	t.Helper()
+	var seqs []int
+	for m := range client.send {
+		var msg testMessage
+		if err := json.Unmarshal(m.raw, &msg); err != nil {
+			continue
+		}
+		if msg.Type == "run_update" {
+			seqs = append(seqs, msg.Seq)
+		}
+	}
+	return seqs
+}
+
+// TestBroadcast_SlowClientNeverLosesOrDropsRunUpdates is the stress test
+// requested for the drop-oldest-log policy: several well-provisioned "fast"
+// clients (generous buffers, standing in for viewers whose connection keeps
+// up) sit alongside one artificially slow client (real, small production
+// buffer, drained by a throttled reader far behind the producer), and a
+// producer hammers the hub with a mix of log and run_update messages. Every
+// client, fast or slow, must see its run_update messages in strictly
+// increasing order with none missing - only "log" messages are allowed to
+// be dropped under backpressure. Fast clients never drain concurrently with
+// the burst (that would make the assertions racy against goroutine
+// scheduling); instead their buffers are sized to never need to.
+func TestBroadcast_SlowClientNeverLosesOrDropsRunUpdates(t *testing.T) {
+	h := newStressHub(t)
+	runID := uuid.New()
+
+	const numFastClients = 8
+	const numMessages = 1500
+	const runUpdateEvery = 30 // one run_update per 30 log messages
+
+	newFastClient := func() *Client {
+		// Same shape NewClient builds, but with headroom for the whole
+		// burst so it never needs makeRoom - this is what "fast" means
+		// here: a consumer whose buffer never has to evict anything.
+		return &Client{hub: h, runID: runID, userID: uuid.New(), send: make(chan *outboundMessage, numMessages+1)}
+	}
+
+	var fastClients []*Client
+	for i := 0; i < numFastClients; i++ {
+		c := newFastClient()
+		fastClients = append(fastClients, c)
+		h.register <- c
+	}
+
+	// The slow client uses NewClient's real, much smaller production buffer
+	// (see NewClient's send: make(..., 256)) and a reader throttled well
+	// below the producer's rate, so it's guaranteed to fall behind and
+	// exercise makeRoom repeatedly while the burst is still in flight.
+	slowClient := NewClient(h, nil, runID, uuid.New(), 1)
+	h.register <- slowClient
+
+	slowSeqCh := make(chan int, numMessages)
+	slowDrainDone := make(chan struct{})
+	go func() {
+		defer close(slowDrainDone)
+		for m := range slowClient.send {
+			var msg testMessage
+			if json.Unmarshal(m.raw, &msg) == nil && msg.Type == "run_update" {
+				slowSeqCh <- msg.Seq
+			}
+			time.Sleep(200 * time.Microsecond)
+		}
+		close(slowSeqCh)
+	}()
+
+	for i := 1; i <= numMessages; i++ {
+		msgType := "log"
+		if i%runUpdateEvery == 0 {
+			msgType = "run_update"
+		}
+		h.Broadcast(runID, mustMarshalTestMessage(t, msgType, i))
+		// Real log traffic is paced by an actual process's output, not a
+		// tight loop; without some pacing here the whole burst would land
+		// before the slow reader's goroutine is even scheduled once, which
+		// would overflow its buffer before it gets a single chance to
+		// drain concurrently with production - a scheduling artifact of
+		// this test, not the backpressure behavior under test.
+		if i%20 == 0 {
+			time.Sleep(50 * time.Microsecond)
+		}
+	}
+
+	// Give the hub's single broadcast goroutine time to fan every queued
+	// message out to every client's send channel before reading the fast
+	// clients (the slow client is already draining concurrently above).
+	deadline := time.After(5 * time.Second)
+	for {
+		h.mu.RLock()
+		pending := len(h.broadcast)
+		h.mu.RUnlock()
+		if pending == 0 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for broadcast queue to drain")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	wantRunUpdates := numMessages / runUpdateEvery
+
+	for i, c := range fastClients {
+		h.unregister <- c
+		got := runUpdateSeqs(t, c)
+		if len(got) != wantRunUpdates {
+			t.Fatalf("fast client %d saw %d run_updates, want %d", i, len(got), wantRunUpdates)
+		}
+		if !sort.IntsAreSorted(got) {
+			t.Fatalf("fast client %d saw run_updates out of order: %v", i, got)
+		}
+	}
+
+	// Unregistering closes slowClient.send, which lets its drain goroutine
+	// finish once it has caught up to the close.
+	h.unregister <- slowClient
+	select {
+	case <-slowDrainDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the slow client's drain goroutine to finish")
+	}
+
+	var slowSeqs []int
+	for seq := range slowSeqCh {
+		slowSeqs = append(slowSeqs, seq)
+	}
+
+	if len(slowSeqs) != wantRunUpdates {
+		t.Fatalf("slow client saw %d run_updates, want %d (a run_update was dropped)", len(slowSeqs), wantRunUpdates)
+	}
+	if !sort.IntsAreSorted(slowSeqs) {
+		t.Fatalf("slow client saw run_updates out of order: %v", slowSeqs)
+	}
+
+	if dropped := h.metrics.MessagesDropped.Load(); dropped == 0 {
+		t.Fatal("expected the slow client to force at least one dropped log message, got 0")
+	} else {
+		t.Logf("dropped %d queued log messages for the slow client, as expected under backpressure", dropped)
+	}
+}
@@ -67,7 +67,7 @@ func (x LogLine_Level) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use LogLine_Level.Descriptor instead.
 func (LogLine_Level) EnumDescriptor() ([]byte, []int) {
-	return file_proto_logstreamer_proto_rawDescGZIP(), []int{4, 0}
+	return file_proto_logstreamer_proto_rawDescGZIP(), []int{7, 0}
 }
 
 // Message from client to server
@@ -78,6 +78,8 @@ type StreamLogRequest struct {
 	//	*StreamLogRequest_Metadata
 	//	*StreamLogRequest_Line
 	//	*StreamLogRequest_Completion
+	//	*StreamLogRequest_Batch
+	//	*StreamLogRequest_Heartbeat
 	Event         isStreamLogRequest_Event `protobuf_oneof:"event"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -147,6 +149,24 @@ func (x *StreamLogRequest) GetCompletion() *StreamCompletion {
 	return nil
 }
 
+func (x *StreamLogRequest) GetBatch() *LogBatch {
+	if x != nil {
+		if x, ok := x.Event.(*StreamLogRequest_Batch); ok {
+			return x.Batch
+		}
+	}
+	return nil
+}
+
+func (x *StreamLogRequest) GetHeartbeat() *Heartbeat {
+	if x != nil {
+		if x, ok := x.Event.(*StreamLogRequest_Heartbeat); ok {
+			return x.Heartbeat
+		}
+	}
+	return nil
+}
+
 type isStreamLogRequest_Event interface {
 	isStreamLogRequest_Event()
 }
@@ -166,12 +186,31 @@ type StreamLogRequest_Completion struct {
 	Completion *StreamCompletion `protobuf:"bytes,3,opt,name=completion,proto3,oneof"`
 }
 
+type StreamLogRequest_Batch struct {
+	// A coalesced batch of log lines, sent instead of individual 'line'
+	// events once the client has buffered enough of them (see
+	// StreamSession's batching in cli/internal/client/grpc_client.go).
+	Batch *LogBatch `protobuf:"bytes,4,opt,name=batch,proto3,oneof"`
+}
+
+type StreamLogRequest_Heartbeat struct {
+	// Sent after 30s without any other message, so intermediate proxies
+	// and the ingestor's own idle timeout don't mistake a long-silent
+	// (but still running) command for a dead connection. Carries no data
+	// and never produces a Loki log line.
+	Heartbeat *Heartbeat `protobuf:"bytes,5,opt,name=heartbeat,proto3,oneof"`
+}
+
 func (*StreamLogRequest_Metadata) isStreamLogRequest_Event() {}
 
 func (*StreamLogRequest_Line) isStreamLogRequest_Event() {}
 
 func (*StreamLogRequest_Completion) isStreamLogRequest_Event() {}
 
+func (*StreamLogRequest_Batch) isStreamLogRequest_Event() {}
+
+func (*StreamLogRequest_Heartbeat) isStreamLogRequest_Event() {}
+
 // Completion message sent by the client.
 type StreamCompletion struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -224,6 +263,7 @@ type StreamLogResponse struct {
 	//
 	//	*StreamLogResponse_Started
 	//	*StreamLogResponse_Error
+	//	*StreamLogResponse_Completed
 	Event         isStreamLogResponse_Event `protobuf_oneof:"event"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -284,6 +324,15 @@ func (x *StreamLogResponse) GetError() string {
 	return ""
 }
 
+func (x *StreamLogResponse) GetCompleted() *StreamCompleted {
+	if x != nil {
+		if x, ok := x.Event.(*StreamLogResponse_Completed); ok {
+			return x.Completed
+		}
+	}
+	return nil
+}
+
 type isStreamLogResponse_Event interface {
 	isStreamLogResponse_Event()
 }
@@ -298,24 +347,94 @@ type StreamLogResponse_Error struct {
 	Error string `protobuf:"bytes,2,opt,name=error,proto3,oneof"`
 }
 
+type StreamLogResponse_Completed struct {
+	// Sent once the server has flushed the completion message's batch and
+	// marked the run terminal, so the client's WaitForCompletion can tell
+	// "acknowledged" apart from "gave up waiting".
+	Completed *StreamCompleted `protobuf:"bytes,3,opt,name=completed,proto3,oneof"`
+}
+
 func (*StreamLogResponse_Started) isStreamLogResponse_Event() {}
 
 func (*StreamLogResponse_Error) isStreamLogResponse_Event() {}
 
+func (*StreamLogResponse_Completed) isStreamLogResponse_Event() {}
+
+// Final acknowledgment that the run has been marked terminal server-side.
+type StreamCompleted struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RunId         string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamCompleted) Reset() {
+	*x = StreamCompleted{}
+	mi := &file_proto_logstreamer_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamCompleted) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamCompleted) ProtoMessage() {}
+
+func (x *StreamCompleted) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_logstreamer_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamCompleted.ProtoReflect.Descriptor instead.
+func (*StreamCompleted) Descriptor() ([]byte, []int) {
+	return file_proto_logstreamer_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamCompleted) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
 // Metadata sent by the client.
 type StreamMetadata struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// If provided, this project name is used. If not, the server
 	// may associate the run with a default project based on the API Token.
-	ProjectName   string `protobuf:"bytes,1,opt,name=project_name,json=projectName,proto3" json:"project_name,omitempty"`
-	GroupName     string `protobuf:"bytes,2,opt,name=group_name,json=groupName,proto3" json:"group_name,omitempty"` // The group name for the run.
+	ProjectName string `protobuf:"bytes,1,opt,name=project_name,json=projectName,proto3" json:"project_name,omitempty"`
+	GroupName   string `protobuf:"bytes,2,opt,name=group_name,json=groupName,proto3" json:"group_name,omitempty"` // The group name for the run.
+	// Optional key/value tags for this run (e.g. env=staging, commit=abc123).
+	// Limited to 20 tags with 64-character keys; the ingestor rejects
+	// anything larger with InvalidArgument.
+	Tags map[string]string `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Optional provenance captured by the client so a run can still be traced
+	// back to its origin later: the machine it ran on, the directory it ran
+	// from, and the command line invoked. All are length-capped and sanitized
+	// server-side before storage.
+	Hostname    string `protobuf:"bytes,4,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	WorkingDir  string `protobuf:"bytes,5,opt,name=working_dir,json=workingDir,proto3" json:"working_dir,omitempty"`
+	CommandLine string `protobuf:"bytes,6,opt,name=command_line,json=commandLine,proto3" json:"command_line,omitempty"`
+	// Optional human-friendly name for this run (e.g. "nightly backup
+	// 2024-06-01"), so it's identifiable in the run list without reading the
+	// UUID. Capped at 200 characters; the ingestor rejects anything longer
+	// with InvalidArgument.
+	Name          string `protobuf:"bytes,7,opt,name=name,proto3" json:"name,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *StreamMetadata) Reset() {
 	*x = StreamMetadata{}
-	mi := &file_proto_logstreamer_proto_msgTypes[3]
+	mi := &file_proto_logstreamer_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -327,7 +446,7 @@ func (x *StreamMetadata) String() string {
 func (*StreamMetadata) ProtoMessage() {}
 
 func (x *StreamMetadata) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_logstreamer_proto_msgTypes[3]
+	mi := &file_proto_logstreamer_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -340,7 +459,7 @@ func (x *StreamMetadata) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamMetadata.ProtoReflect.Descriptor instead.
 func (*StreamMetadata) Descriptor() ([]byte, []int) {
-	return file_proto_logstreamer_proto_rawDescGZIP(), []int{3}
+	return file_proto_logstreamer_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *StreamMetadata) GetProjectName() string {
@@ -357,6 +476,125 @@ func (x *StreamMetadata) GetGroupName() string {
 	return ""
 }
 
+func (x *StreamMetadata) GetTags() map[string]string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *StreamMetadata) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *StreamMetadata) GetWorkingDir() string {
+	if x != nil {
+		return x.WorkingDir
+	}
+	return ""
+}
+
+func (x *StreamMetadata) GetCommandLine() string {
+	if x != nil {
+		return x.CommandLine
+	}
+	return ""
+}
+
+func (x *StreamMetadata) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// A batch of log lines coalesced client-side to amortize per-message
+// overhead for high-throughput commands.
+type LogBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Lines         []*LogLine             `protobuf:"bytes,1,rep,name=lines,proto3" json:"lines,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogBatch) Reset() {
+	*x = LogBatch{}
+	mi := &file_proto_logstreamer_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogBatch) ProtoMessage() {}
+
+func (x *LogBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_logstreamer_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogBatch.ProtoReflect.Descriptor instead.
+func (*LogBatch) Descriptor() ([]byte, []int) {
+	return file_proto_logstreamer_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *LogBatch) GetLines() []*LogLine {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+// A keepalive with no payload. See the heartbeat field on
+// StreamLogRequest.
+type Heartbeat struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Heartbeat) Reset() {
+	*x = Heartbeat{}
+	mi := &file_proto_logstreamer_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Heartbeat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Heartbeat) ProtoMessage() {}
+
+func (x *Heartbeat) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_logstreamer_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Heartbeat.ProtoReflect.Descriptor instead.
+func (*Heartbeat) Descriptor() ([]byte, []int) {
+	return file_proto_logstreamer_proto_rawDescGZIP(), []int{6}
+}
+
 // A single log line sent by the client.
 type LogLine struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -369,7 +607,7 @@ type LogLine struct {
 
 func (x *LogLine) Reset() {
 	*x = LogLine{}
-	mi := &file_proto_logstreamer_proto_msgTypes[4]
+	mi := &file_proto_logstreamer_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -381,7 +619,7 @@ func (x *LogLine) String() string {
 func (*LogLine) ProtoMessage() {}
 
 func (x *LogLine) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_logstreamer_proto_msgTypes[4]
+	mi := &file_proto_logstreamer_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -394,7 +632,7 @@ func (x *LogLine) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LogLine.ProtoReflect.Descriptor instead.
 func (*LogLine) Descriptor() ([]byte, []int) {
-	return file_proto_logstreamer_proto_rawDescGZIP(), []int{4}
+	return file_proto_logstreamer_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *LogLine) GetTimestamp() *timestamppb.Timestamp {
@@ -428,7 +666,7 @@ type StreamStarted struct {
 
 func (x *StreamStarted) Reset() {
 	*x = StreamStarted{}
-	mi := &file_proto_logstreamer_proto_msgTypes[5]
+	mi := &file_proto_logstreamer_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -440,7 +678,7 @@ func (x *StreamStarted) String() string {
 func (*StreamStarted) ProtoMessage() {}
 
 func (x *StreamStarted) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_logstreamer_proto_msgTypes[5]
+	mi := &file_proto_logstreamer_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -453,7 +691,7 @@ func (x *StreamStarted) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamStarted.ProtoReflect.Descriptor instead.
 func (*StreamStarted) Descriptor() ([]byte, []int) {
-	return file_proto_logstreamer_proto_rawDescGZIP(), []int{5}
+	return file_proto_logstreamer_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *StreamStarted) GetRunId() string {
@@ -467,24 +705,41 @@ var File_proto_logstreamer_proto protoreflect.FileDescriptor
 
 const file_proto_logstreamer_proto_rawDesc = "" +
 	"\n" +
-	"\x17proto/logstreamer.proto\x12\vswiftlog.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xc3\x01\n" +
+	"\x17proto/logstreamer.proto\x12\vswiftlog.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xaa\x02\n" +
 	"\x10StreamLogRequest\x129\n" +
 	"\bmetadata\x18\x01 \x01(\v2\x1b.swiftlog.v1.StreamMetadataH\x00R\bmetadata\x12*\n" +
 	"\x04line\x18\x02 \x01(\v2\x14.swiftlog.v1.LogLineH\x00R\x04line\x12?\n" +
 	"\n" +
 	"completion\x18\x03 \x01(\v2\x1d.swiftlog.v1.StreamCompletionH\x00R\n" +
-	"completionB\a\n" +
+	"completion\x12-\n" +
+	"\x05batch\x18\x04 \x01(\v2\x15.swiftlog.v1.LogBatchH\x00R\x05batch\x126\n" +
+	"\theartbeat\x18\x05 \x01(\v2\x16.swiftlog.v1.HeartbeatH\x00R\theartbeatB\a\n" +
 	"\x05event\"/\n" +
 	"\x10StreamCompletion\x12\x1b\n" +
-	"\texit_code\x18\x01 \x01(\x05R\bexitCode\"l\n" +
+	"\texit_code\x18\x01 \x01(\x05R\bexitCode\"\xaa\x01\n" +
 	"\x11StreamLogResponse\x126\n" +
 	"\astarted\x18\x01 \x01(\v2\x1a.swiftlog.v1.StreamStartedH\x00R\astarted\x12\x16\n" +
-	"\x05error\x18\x02 \x01(\tH\x00R\x05errorB\a\n" +
-	"\x05event\"R\n" +
+	"\x05error\x18\x02 \x01(\tH\x00R\x05error\x12<\n" +
+	"\tcompleted\x18\x03 \x01(\v2\x1c.swiftlog.v1.StreamCompletedH\x00R\tcompletedB\a\n" +
+	"\x05event\"(\n" +
+	"\x0fStreamCompleted\x12\x15\n" +
+	"\x06run_id\x18\x01 \x01(\tR\x05runId\"\xba\x02\n" +
 	"\x0eStreamMetadata\x12!\n" +
 	"\fproject_name\x18\x01 \x01(\tR\vprojectName\x12\x1d\n" +
 	"\n" +
-	"group_name\x18\x02 \x01(\tR\tgroupName\"\xb0\x01\n" +
+	"group_name\x18\x02 \x01(\tR\tgroupName\x129\n" +
+	"\x04tags\x18\x03 \x03(\v2%.swiftlog.v1.StreamMetadata.TagsEntryR\x04tags\x12\x1a\n" +
+	"\bhostname\x18\x04 \x01(\tR\bhostname\x12\x1f\n" +
+	"\vworking_dir\x18\x05 \x01(\tR\n" +
+	"workingDir\x12!\n" +
+	"\fcommand_line\x18\x06 \x01(\tR\vcommandLine\x12\x12\n" +
+	"\x04name\x18\a \x01(\tR\x04name\x1a7\n" +
+	"\tTagsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"6\n" +
+	"\bLogBatch\x12*\n" +
+	"\x05lines\x18\x01 \x03(\v2\x14.swiftlog.v1.LogLineR\x05lines\"\v\n" +
+	"\tHeartbeat\"\xb0\x01\n" +
 	"\aLogLine\x128\n" +
 	"\ttimestamp\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x120\n" +
 	"\x05level\x18\x02 \x01(\x0e2\x1a.swiftlog.v1.LogLine.LevelR\x05level\x12\x18\n" +
@@ -512,31 +767,40 @@ func file_proto_logstreamer_proto_rawDescGZIP() []byte {
 }
 
 var file_proto_logstreamer_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_proto_logstreamer_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_proto_logstreamer_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
 var file_proto_logstreamer_proto_goTypes = []any{
 	(LogLine_Level)(0),            // 0: swiftlog.v1.LogLine.Level
 	(*StreamLogRequest)(nil),      // 1: swiftlog.v1.StreamLogRequest
 	(*StreamCompletion)(nil),      // 2: swiftlog.v1.StreamCompletion
 	(*StreamLogResponse)(nil),     // 3: swiftlog.v1.StreamLogResponse
-	(*StreamMetadata)(nil),        // 4: swiftlog.v1.StreamMetadata
-	(*LogLine)(nil),               // 5: swiftlog.v1.LogLine
-	(*StreamStarted)(nil),         // 6: swiftlog.v1.StreamStarted
-	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
+	(*StreamCompleted)(nil),       // 4: swiftlog.v1.StreamCompleted
+	(*StreamMetadata)(nil),        // 5: swiftlog.v1.StreamMetadata
+	(*LogBatch)(nil),              // 6: swiftlog.v1.LogBatch
+	(*Heartbeat)(nil),             // 7: swiftlog.v1.Heartbeat
+	(*LogLine)(nil),               // 8: swiftlog.v1.LogLine
+	(*StreamStarted)(nil),         // 9: swiftlog.v1.StreamStarted
+	nil,                           // 10: swiftlog.v1.StreamMetadata.TagsEntry
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
 }
 var file_proto_logstreamer_proto_depIdxs = []int32{
-	4, // 0: swiftlog.v1.StreamLogRequest.metadata:type_name -> swiftlog.v1.StreamMetadata
-	5, // 1: swiftlog.v1.StreamLogRequest.line:type_name -> swiftlog.v1.LogLine
-	2, // 2: swiftlog.v1.StreamLogRequest.completion:type_name -> swiftlog.v1.StreamCompletion
-	6, // 3: swiftlog.v1.StreamLogResponse.started:type_name -> swiftlog.v1.StreamStarted
-	7, // 4: swiftlog.v1.LogLine.timestamp:type_name -> google.protobuf.Timestamp
-	0, // 5: swiftlog.v1.LogLine.level:type_name -> swiftlog.v1.LogLine.Level
-	1, // 6: swiftlog.v1.LogStreamer.StreamLog:input_type -> swiftlog.v1.StreamLogRequest
-	3, // 7: swiftlog.v1.LogStreamer.StreamLog:output_type -> swiftlog.v1.StreamLogResponse
-	7, // [7:8] is the sub-list for method output_type
-	6, // [6:7] is the sub-list for method input_type
-	6, // [6:6] is the sub-list for extension type_name
-	6, // [6:6] is the sub-list for extension extendee
-	0, // [0:6] is the sub-list for field type_name
+	5,  // 0: swiftlog.v1.StreamLogRequest.metadata:type_name -> swiftlog.v1.StreamMetadata
+	8,  // 1: swiftlog.v1.StreamLogRequest.line:type_name -> swiftlog.v1.LogLine
+	2,  // 2: swiftlog.v1.StreamLogRequest.completion:type_name -> swiftlog.v1.StreamCompletion
+	6,  // 3: swiftlog.v1.StreamLogRequest.batch:type_name -> swiftlog.v1.LogBatch
+	7,  // 4: swiftlog.v1.StreamLogRequest.heartbeat:type_name -> swiftlog.v1.Heartbeat
+	9,  // 5: swiftlog.v1.StreamLogResponse.started:type_name -> swiftlog.v1.StreamStarted
+	4,  // 6: swiftlog.v1.StreamLogResponse.completed:type_name -> swiftlog.v1.StreamCompleted
+	10, // 7: swiftlog.v1.StreamMetadata.tags:type_name -> swiftlog.v1.StreamMetadata.TagsEntry
+	8,  // 8: swiftlog.v1.LogBatch.lines:type_name -> swiftlog.v1.LogLine
+	11, // 9: swiftlog.v1.LogLine.timestamp:type_name -> google.protobuf.Timestamp
+	0,  // 10: swiftlog.v1.LogLine.level:type_name -> swiftlog.v1.LogLine.Level
+	1,  // 11: swiftlog.v1.LogStreamer.StreamLog:input_type -> swiftlog.v1.StreamLogRequest
+	3,  // 12: swiftlog.v1.LogStreamer.StreamLog:output_type -> swiftlog.v1.StreamLogResponse
+	12, // [12:13] is the sub-list for method output_type
+	11, // [11:12] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_proto_logstreamer_proto_init() }
@@ -548,10 +812,13 @@ func file_proto_logstreamer_proto_init() {
 		(*StreamLogRequest_Metadata)(nil),
 		(*StreamLogRequest_Line)(nil),
 		(*StreamLogRequest_Completion)(nil),
+		(*StreamLogRequest_Batch)(nil),
+		(*StreamLogRequest_Heartbeat)(nil),
 	}
 	file_proto_logstreamer_proto_msgTypes[2].OneofWrappers = []any{
 		(*StreamLogResponse_Started)(nil),
 		(*StreamLogResponse_Error)(nil),
+		(*StreamLogResponse_Completed)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -559,7 +826,7 @@ func file_proto_logstreamer_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_logstreamer_proto_rawDesc), len(file_proto_logstreamer_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   6,
+			NumMessages:   10,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aliancn/swiftlog/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var abortCmd = &cobra.Command{
+	Use:   "abort <run_id>",
+	Short: "Force-close a run stuck in \"running\"",
+	Long: `Mark a run aborted, e.g. when the CLI that started it crashed before
+sending a completion message and it's stuck showing as "running" forever.
+Fails with an error if the run has already reached a terminal state.
+
+Example:
+  swiftlog abort 3f29c1e0-...`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAbort,
+}
+
+func init() {
+	rootCmd.AddCommand(abortCmd)
+}
+
+type abortRunResponse struct {
+	Status string `json:"status"`
+}
+
+func runAbort(cmd *cobra.Command, args []string) error {
+	runID := args[0]
+
+	cfg, err := config.Load(resolveProfile(cmd))
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w (run 'swiftlog config set --token YOUR_TOKEN' first)", err)
+	}
+	if token, _ := cmd.Flags().GetString("token"); token != "" {
+		cfg.Token = token
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("no API token configured. Run 'swiftlog config set --token YOUR_TOKEN' first")
+	}
+
+	apiAddr := strings.TrimRight(cfg.APIAddr, "/")
+	abortURL := fmt.Sprintf("%s/api/v1/runs/%s/abort", apiAddr, url.PathEscape(runID))
+
+	req, err := http.NewRequest(http.MethodPost, abortURL, bytes.NewReader([]byte("{}")))
+	if err != nil {
+		return fmt.Errorf("failed to build abort request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := &http.Client{}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach API server at %s: %w", cfg.APIAddr, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if err := statusToError(resp.StatusCode, body, runID); err != nil {
+		return err
+	}
+
+	var result abortRunResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	fmt.Printf("Run %s: %s\n", runID, result.Status)
+	return nil
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliancn/swiftlog/cli/internal/client"
+	"github.com/aliancn/swiftlog/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// completionCacheTTL bounds how often repeated tab presses re-query the
+// API: long enough that mashing Tab doesn't hammer it, short enough that a
+// project or group created moments ago shows up without restarting the
+// shell.
+const completionCacheTTL = 5 * time.Second
+
+type completionCache struct {
+	mu       sync.Mutex
+	projects map[string]cachedProjects
+	groups   map[string]cachedGroups
+}
+
+type cachedProjects struct {
+	expires  time.Time
+	projects []client.Project
+}
+
+type cachedGroups struct {
+	expires time.Time
+	groups  []client.Group
+}
+
+var shellCompletionCache = &completionCache{
+	projects: make(map[string]cachedProjects),
+	groups:   make(map[string]cachedGroups),
+}
+
+func (c *completionCache) listProjects(rest *client.RESTClient) []client.Project {
+	key := rest.APIAddr + "|" + rest.Token
+
+	c.mu.Lock()
+	if entry, ok := c.projects[key]; ok && time.Now().Before(entry.expires) {
+		c.mu.Unlock()
+		return entry.projects
+	}
+	c.mu.Unlock()
+
+	projects, err := rest.ListProjects()
+	if err != nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.projects[key] = cachedProjects{expires: time.Now().Add(completionCacheTTL), projects: projects}
+	c.mu.Unlock()
+
+	return projects
+}
+
+func (c *completionCache) listGroups(rest *client.RESTClient, projectID string) []client.Group {
+	key := rest.APIAddr + "|" + rest.Token + "|" + projectID
+
+	c.mu.Lock()
+	if entry, ok := c.groups[key]; ok && time.Now().Before(entry.expires) {
+		c.mu.Unlock()
+		return entry.groups
+	}
+	c.mu.Unlock()
+
+	groups, err := rest.ListGroups(projectID)
+	if err != nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.groups[key] = cachedGroups{expires: time.Now().Add(completionCacheTTL), groups: groups}
+	c.mu.Unlock()
+
+	return groups
+}
+
+// completionRESTClient builds a RESTClient from the active profile for
+// completion purposes, or nil if config can't be loaded (e.g. no config
+// file yet) - completion degrades to no suggestions rather than erroring.
+func completionRESTClient(cmd *cobra.Command) *client.RESTClient {
+	cfg, err := config.Load(resolveProfile(cmd))
+	if err != nil {
+		return nil
+	}
+	return client.NewRESTClient(cfg.APIAddr, cfg.Token)
+}
+
+// completeProjectNames is the ValidArgsFunction for --project: it lists
+// projects from the API and filters to those matching what's typed so far.
+// Any failure (API unreachable, config missing) yields no suggestions
+// rather than an error, since shell completion runs on every keystroke and
+// an error there would spam the terminal.
+func completeProjectNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	rest := completionRESTClient(cmd)
+	if rest == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, p := range shellCompletionCache.listProjects(rest) {
+		if strings.HasPrefix(p.Name, toComplete) {
+			names = append(names, p.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeGroupNames is the ValidArgsFunction for --group: it resolves the
+// project named by this same invocation's --project flag (falling back to
+// the active profile's default project), looks up that project's ID, then
+// lists and filters its groups.
+func completeGroupNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	rest := completionRESTClient(cmd)
+	if rest == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	project, _ := cmd.Flags().GetString("project")
+	if project == "" {
+		cfg, err := config.Load(resolveProfile(cmd))
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		project = resolveProjectName(cmd, cfg)
+	}
+
+	var projectID string
+	for _, p := range shellCompletionCache.listProjects(rest) {
+		if p.Name == project {
+			projectID = p.ID
+			break
+		}
+	}
+	if projectID == "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, g := range shellCompletionCache.listGroups(rest, projectID) {
+		if strings.HasPrefix(g.Name, toComplete) {
+			names = append(names, g.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	for _, c := range []*cobra.Command{runCmd, pipeCmd} {
+		_ = c.RegisterFlagCompletionFunc("project", completeProjectNames)
+		_ = c.RegisterFlagCompletionFunc("group", completeGroupNames)
+	}
+}
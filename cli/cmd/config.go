@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"regexp"
+	"strings"
 
 	"github.com/aliancn/swiftlog/cli/internal/config"
 	"github.com/spf13/cobra"
@@ -36,9 +39,43 @@ var configPathCmd = &cobra.Command{
 	},
 }
 
+var configUseCmd = &cobra.Command{
+	Use:   "use <profile>",
+	Short: "Switch the active configuration profile",
+	Long: `Make <profile> the profile future commands use when they aren't given
+an explicit --profile flag. <profile> must already exist - "default", or
+a name created with 'config set --profile <name> --token ...'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runConfigUse,
+}
+
+var configMigrateKeyringCmd = &cobra.Command{
+	Use:   "migrate-keyring",
+	Short: "Move the active profile's token from config.json into the OS keyring",
+	Long: `Move the active profile's plaintext API token out of config.json and
+into the OS keychain (via the same credential_store: keyring mode as
+'config set --credential-store keyring'), scrubbing it from the file.
+No-op if the profile is already using the keyring.`,
+	RunE: runConfigMigrateKeyring,
+}
+
 var (
-	setToken  string
-	setServer string
+	setToken              string
+	setServer             string
+	setAPIAddr            string
+	setWSAddr             string
+	setTLS                bool
+	setCACert             string
+	setClientCert         string
+	setClientKey          string
+	setInsecureSkipVerify bool
+	setStripANSI          bool
+	setRedactAdd          string
+	setProject            string
+	setGroup              string
+	setNoCompress         bool
+	setWebURL             string
+	setCredentialStore    string
 )
 
 func init() {
@@ -46,15 +83,36 @@ func init() {
 	configCmd.AddCommand(configSetCmd)
 	configCmd.AddCommand(configGetCmd)
 	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configUseCmd)
+	configCmd.AddCommand(configMigrateKeyringCmd)
 
 	// Flags for config set
 	configSetCmd.Flags().StringVar(&setToken, "token", "", "API token")
 	configSetCmd.Flags().StringVar(&setServer, "server", "", "Server address (e.g., localhost:50051)")
+	configSetCmd.Flags().StringVar(&setAPIAddr, "api-addr", "", "HTTP API server base URL (e.g., http://localhost:8080), used by 'swiftlog logs'")
+	configSetCmd.Flags().StringVar(&setWSAddr, "ws-addr", "", "WebSocket server base URL (e.g., ws://localhost:8081), used by 'swiftlog tail'")
+	configSetCmd.Flags().BoolVar(&setTLS, "tls", false, "Enable TLS for the gRPC connection")
+	configSetCmd.Flags().StringVar(&setCACert, "ca-cert", "", "Path to a CA certificate used to verify the server")
+	configSetCmd.Flags().StringVar(&setClientCert, "client-cert", "", "Path to a client certificate (for mTLS)")
+	configSetCmd.Flags().StringVar(&setClientKey, "client-key", "", "Path to a client private key (for mTLS)")
+	configSetCmd.Flags().BoolVar(&setInsecureSkipVerify, "insecure-skip-verify", false, "Skip TLS certificate verification (not recommended)")
+	configSetCmd.Flags().BoolVar(&setStripANSI, "strip-ansi", false, "Default 'run'/'pipe' to stripping ANSI codes from stored log lines")
+	configSetCmd.Flags().StringVar(&setRedactAdd, "redact-add", "", "Add a custom regex pattern to redact from stored log lines (repeatable)")
+	configSetCmd.Flags().StringVar(&setProject, "project", "", "Default project for 'run'/'pipe' (overridable by SWIFTLOG_PROJECT or --project)")
+	configSetCmd.Flags().StringVar(&setGroup, "group", "", "Default group for 'run'/'pipe' (overridable by SWIFTLOG_GROUP or --group)")
+	configSetCmd.Flags().BoolVar(&setNoCompress, "no-compress", false, "Default 'run'/'pipe' to disabling gzip compression of the log stream")
+	configSetCmd.Flags().StringVar(&setWebURL, "web-url", "", "Base URL of the web UI (e.g. https://swiftlog.example.com), used by 'run'/'pipe''s --print-url")
+	configSetCmd.Flags().StringVar(&setCredentialStore, "credential-store", "", "Where to store the API token: \"plaintext\" (default, in config.json) or \"keyring\" (OS keychain)")
 }
 
 func runConfigSet(cmd *cobra.Command, args []string) error {
-	// Load existing config or create new one
-	cfg, err := config.Load()
+	profile := resolveProfile(cmd)
+
+	// Load the target profile if it already exists, or start fresh - this
+	// is also how a brand new profile gets created: 'config set --profile
+	// prod --token ...' with no prior "prod" entry hits this fallback
+	// rather than an "unknown profile" error.
+	cfg, err := config.Load(profile)
 	if err != nil {
 		cfg = &config.Config{
 			ServerAddr: "localhost:50051",
@@ -64,41 +122,203 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 	// Update values if provided
 	if setToken != "" {
 		cfg.Token = setToken
+		cfg.TokenID = "" // manually-pasted token isn't the one 'login' minted
 		fmt.Println("✓ Token updated")
 	}
 	if setServer != "" {
 		cfg.ServerAddr = setServer
 		fmt.Println("✓ Server address updated")
 	}
+	if setAPIAddr != "" {
+		cfg.APIAddr = setAPIAddr
+		fmt.Println("✓ API address updated")
+	}
+	if setWSAddr != "" {
+		cfg.WSAddr = setWSAddr
+		fmt.Println("✓ WebSocket address updated")
+	}
+	if cmd.Flags().Changed("tls") {
+		cfg.TLS = setTLS
+		fmt.Printf("✓ TLS %s\n", map[bool]string{true: "enabled", false: "disabled"}[setTLS])
+	}
+	if setCACert != "" {
+		cfg.CACert = setCACert
+		fmt.Println("✓ CA certificate updated")
+	}
+	if setClientCert != "" {
+		cfg.ClientCert = setClientCert
+		fmt.Println("✓ Client certificate updated")
+	}
+	if setClientKey != "" {
+		cfg.ClientKey = setClientKey
+		fmt.Println("✓ Client key updated")
+	}
+	if cmd.Flags().Changed("insecure-skip-verify") {
+		cfg.InsecureSkipVerify = setInsecureSkipVerify
+		fmt.Printf("✓ TLS certificate verification %s\n", map[bool]string{true: "disabled", false: "enabled"}[setInsecureSkipVerify])
+	}
+	if cmd.Flags().Changed("strip-ansi") {
+		cfg.StripANSI = setStripANSI
+		fmt.Printf("✓ ANSI stripping default %s\n", map[bool]string{true: "enabled", false: "disabled"}[setStripANSI])
+	}
+	if setRedactAdd != "" {
+		if _, err := regexp.Compile(setRedactAdd); err != nil {
+			return fmt.Errorf("invalid --redact-add pattern: %w", err)
+		}
+		cfg.Redact = append(cfg.Redact, setRedactAdd)
+		fmt.Printf("✓ Added redact pattern: %s\n", setRedactAdd)
+	}
+	if setProject != "" {
+		cfg.Project = setProject
+		fmt.Println("✓ Default project updated")
+	}
+	if setGroup != "" {
+		cfg.Group = setGroup
+		fmt.Println("✓ Default group updated")
+	}
+	if cmd.Flags().Changed("no-compress") {
+		cfg.DisableCompression = setNoCompress
+		fmt.Printf("✓ Log stream compression default %s\n", map[bool]string{true: "disabled", false: "enabled"}[setNoCompress])
+	}
+	if setWebURL != "" {
+		cfg.WebURL = setWebURL
+		fmt.Println("✓ Web URL updated")
+	}
+	if setCredentialStore != "" {
+		switch setCredentialStore {
+		case config.CredentialStorePlaintext, config.CredentialStoreKeyring:
+			cfg.CredentialStore = setCredentialStore
+			fmt.Printf("✓ Credential store set to %q\n", setCredentialStore)
+		default:
+			return fmt.Errorf("invalid --credential-store %q: must be \"plaintext\" or \"keyring\"", setCredentialStore)
+		}
+	}
 
 	// Save config
-	if err := config.Save(cfg); err != nil {
+	if err := config.Save(cfg, profile); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
-	fmt.Printf("\nConfiguration saved to: %s\n", config.GetConfigPath())
+	profileLabel := profile
+	if profileLabel == "" {
+		profileLabel = "default"
+	}
+	fmt.Printf("\nConfiguration for profile %q saved to: %s\n", profileLabel, config.GetConfigPath())
+	return nil
+}
+
+func runConfigMigrateKeyring(cmd *cobra.Command, args []string) error {
+	profile := resolveProfile(cmd)
+	cfg, err := config.Load(profile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if cfg.CredentialStore == config.CredentialStoreKeyring {
+		fmt.Println("Already using the system keyring for this profile.")
+		return nil
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("no token configured for this profile to migrate")
+	}
+
+	cfg.CredentialStore = config.CredentialStoreKeyring
+	if err := config.Save(cfg, profile); err != nil {
+		return fmt.Errorf("failed to migrate token to system keyring: %w", err)
+	}
+
+	fmt.Println("✓ Token moved to the system keyring; config.json no longer stores it in plaintext")
+	return nil
+}
+
+func runConfigUse(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := config.UseProfile(name); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Active profile set to %q\n", name)
 	return nil
 }
 
 func runConfigGet(cmd *cobra.Command, args []string) error {
-	cfg, err := config.Load()
+	profile := resolveProfile(cmd)
+	cfg, err := config.Load(profile)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	names, active, err := config.ListProfiles()
+	if err != nil {
+		return fmt.Errorf("failed to list profiles: %w", err)
+	}
+	shown := profile
+	if shown == "" {
+		shown = active
+	}
+	fmt.Printf("Profiles: %s (active: %s, shown: %s)\n", strings.Join(names, ", "), active, shown)
+
 	fmt.Println("Current configuration:")
-	fmt.Printf("  Server Address: %s\n", cfg.ServerAddr)
-	if cfg.Token != "" {
-		// Mask token for security
-		maskedToken := cfg.Token
-		if len(maskedToken) > 8 {
-			maskedToken = maskedToken[:4] + "..." + maskedToken[len(maskedToken)-4:]
+	fmt.Printf("  Server Address: %s (%s)\n", cfg.ServerAddr, valueSource("SWIFTLOG_SERVER", cfg.ServerAddr, "localhost:50051"))
+	fmt.Printf("  API Address:    %s\n", cfg.APIAddr)
+	fmt.Printf("  WS Address:     %s\n", cfg.WSAddr)
+	fmt.Printf("  Web URL:        %s\n", displayOrUnset(cfg.WebURL))
+	fmt.Printf("  Project:        %s (%s)\n", displayOrUnset(cfg.Project), valueSource("SWIFTLOG_PROJECT", cfg.Project, ""))
+	fmt.Printf("  Group:          %s (%s)\n", displayOrUnset(cfg.Group), valueSource("SWIFTLOG_GROUP", cfg.Group, ""))
+	fmt.Printf("  Strip ANSI:     %t\n", cfg.StripANSI)
+	fmt.Printf("  Compression:    %t\n", !cfg.DisableCompression)
+	if len(cfg.Redact) > 0 {
+		fmt.Println("  Redact Patterns:")
+		for _, p := range cfg.Redact {
+			fmt.Printf("    - %s\n", p)
 		}
-		fmt.Printf("  API Token:      %s\n", maskedToken)
 	} else {
+		fmt.Println("  Redact Patterns: (none)")
+	}
+	switch {
+	case cfg.CredentialStore == config.CredentialStoreKeyring && cfg.Token != "":
+		fmt.Println("  API Token:      stored in system keyring")
+	case cfg.Token != "":
+		// Mask token for security, matching the server's display hint
+		// format (first 8, last 4 characters)
+		maskedToken := cfg.Token
+		if len(maskedToken) > 12 {
+			maskedToken = maskedToken[:8] + "..." + maskedToken[len(maskedToken)-4:]
+		}
+		fmt.Printf("  API Token:      %s (%s)\n", maskedToken, valueSource("SWIFTLOG_TOKEN", cfg.Token, ""))
+	default:
 		fmt.Println("  API Token:      (not set)")
 	}
+	fmt.Printf("  TLS:            %t\n", cfg.TLS)
+	if cfg.TLS {
+		fmt.Printf("  CA Cert:        %s\n", displayOrUnset(cfg.CACert))
+		fmt.Printf("  Client Cert:    %s\n", displayOrUnset(cfg.ClientCert))
+		fmt.Printf("  Client Key:     %s\n", displayOrUnset(cfg.ClientKey))
+		fmt.Printf("  Skip Verify:    %t\n", cfg.InsecureSkipVerify)
+	}
 	fmt.Printf("\nConfig file: %s\n", config.GetConfigPath())
 
 	return nil
 }
+
+// valueSource reports which precedence tier produced the effective value of
+// a config field that has an environment variable counterpart: "env" if
+// envVar is set in the process environment (which always wins once Load
+// applies it), "config file" if the effective value differs from the
+// documented default with no env override, or "default" otherwise. Used by
+// 'config get' so it's clear why a value looks the way it does.
+func valueSource(envVar, effective, defaultValue string) string {
+	if os.Getenv(envVar) != "" {
+		return "env"
+	}
+	if effective != defaultValue {
+		return "config file"
+	}
+	return "default"
+}
+
+func displayOrUnset(v string) string {
+	if v == "" {
+		return "(not set)"
+	}
+	return v
+}
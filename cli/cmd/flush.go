@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aliancn/swiftlog/cli/internal/client"
+	"github.com/aliancn/swiftlog/cli/internal/config"
+	"github.com/aliancn/swiftlog/cli/internal/spool"
+	"github.com/spf13/cobra"
+)
+
+var flushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Replay spooled offline logs to the server",
+	Long: `Replay logs that 'run' or 'pipe' spooled to disk because the server
+was unreachable. Each spooled run is sent as a new run to the server (there's
+no way to resume the original run ID after a drop), so it will show up under
+its original project/group with a new Run ID once flushed.
+
+'run' and 'pipe' also flush automatically at startup, so this is mainly
+useful to check on or retry spooled logs without starting a new run.`,
+	RunE: runFlushCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(flushCmd)
+}
+
+func runFlushCmd(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load(resolveProfile(cmd))
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w (run 'swiftlog config set --token YOUR_TOKEN' first)", err)
+	}
+	if token, _ := cmd.Flags().GetString("token"); token != "" {
+		cfg.Token = token
+	}
+	if server, _ := cmd.Flags().GetString("server"); server != "" {
+		cfg.ServerAddr = server
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("no API token configured. Run 'swiftlog config set --token YOUR_TOKEN' first")
+	}
+
+	grpcClient, err := client.NewClient(&client.Config{
+		ServerAddr:         cfg.ServerAddr,
+		Token:              cfg.Token,
+		TLS:                cfg.TLS,
+		CACertPath:         cfg.CACert,
+		ClientCertPath:     cfg.ClientCert,
+		ClientKeyPath:      cfg.ClientKey,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer grpcClient.Close()
+
+	return flushSpool(context.Background(), grpcClient, true)
+}
+
+// flushSpool replays every spooled run to cl, deleting each run's spool
+// files once it replays cleanly. A run that fails to flush (server still
+// unreachable) is left on disk so a later flush can retry it. verbose
+// controls whether progress is printed to stdout, so the automatic flush
+// 'run'/'pipe' does at startup stays quiet unless there's something to do.
+func flushSpool(ctx context.Context, cl *client.Client, verbose bool) error {
+	runIDs, err := spool.PendingRuns()
+	if err != nil {
+		return fmt.Errorf("failed to list spooled runs: %w", err)
+	}
+	if len(runIDs) == 0 {
+		if verbose {
+			fmt.Println("No spooled logs to flush.")
+		}
+		return nil
+	}
+
+	if verbose {
+		fmt.Printf("Flushing %d spooled run(s)...\n", len(runIDs))
+	}
+	for _, runID := range runIDs {
+		newRunID, err := flushRun(ctx, cl, runID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to flush spooled run %s (will retry on next flush): %v\n", runID, err)
+			continue
+		}
+		if verbose {
+			fmt.Printf("✓ Flushed spooled run %s as new Run ID %s\n", runID, newRunID)
+		}
+	}
+	return nil
+}
+
+// flushRun replays runID's spooled records onto a fresh stream and deletes
+// its spool files on success, returning the new run ID it was replayed as.
+func flushRun(ctx context.Context, cl *client.Client, runID string) (string, error) {
+	records, err := spool.ReadRun(runID)
+	if err != nil {
+		return "", err
+	}
+	if len(records) == 0 || records[0].Type != spool.RecordMetadata {
+		return "", fmt.Errorf("spool for run %s is missing its metadata header", runID)
+	}
+	meta := records[0]
+
+	session, err := cl.StartStream(ctx, meta.ProjectName, meta.GroupName, nil, "", "", "", "")
+	if err != nil {
+		return "", fmt.Errorf("failed to start replay stream: %w", err)
+	}
+	defer session.Close()
+
+	var exitCode int32
+	haveCompletion := false
+	for _, rec := range records[1:] {
+		switch rec.Type {
+		case spool.RecordLine:
+			if err := session.SendLogLine(rec.Level == "STDERR", rec.Content); err != nil {
+				return "", fmt.Errorf("failed to replay log line: %w", err)
+			}
+		case spool.RecordCompletion:
+			exitCode = rec.ExitCode
+			haveCompletion = true
+		}
+	}
+	if err := session.Flush(); err != nil {
+		return "", fmt.Errorf("failed to flush replayed log lines: %w", err)
+	}
+	if haveCompletion {
+		if err := session.SendCompletion(exitCode); err != nil {
+			return "", fmt.Errorf("failed to replay completion: %w", err)
+		}
+	}
+	if err := session.WaitForCompletion(client.DefaultCompletionTimeout); errors.Is(err, client.ErrCompletionTimeout) {
+		fmt.Fprintf(os.Stderr, "Warning: did not receive server acknowledgment within %s\n", client.DefaultCompletionTimeout)
+	}
+
+	if err := spool.DeleteRun(runID); err != nil {
+		return "", fmt.Errorf("replayed successfully but failed to clean up spool files: %w", err)
+	}
+	return session.GetRunID(), nil
+}
+
+// spoolFallback lazily opens a spool.Writer the first time a log line or
+// completion fails to send, so a run that never has trouble talking to the
+// server never touches disk. It's shared between 'run' (whose stdout/stderr
+// streaming goroutines can fail concurrently) and 'pipe'.
+type spoolFallback struct {
+	mu                            sync.Mutex
+	w                             *spool.Writer
+	runID, projectName, groupName string
+	warned                        bool
+}
+
+func newSpoolFallback(runID, projectName, groupName string) *spoolFallback {
+	return &spoolFallback{runID: runID, projectName: projectName, groupName: groupName}
+}
+
+func (f *spoolFallback) writer() *spool.Writer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.w == nil {
+		w, err := spool.NewWriter(f.runID, f.projectName, f.groupName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to open offline spool, log lines will be dropped: %v\n", err)
+			return nil
+		}
+		f.w = w
+	}
+	if !f.warned {
+		f.warned = true
+		fmt.Fprintln(os.Stderr, "Warning: server unreachable, spooling logs to disk (run 'swiftlog flush' once reconnected)")
+	}
+	return f.w
+}
+
+func (f *spoolFallback) line(isStderr bool, content string) {
+	w := f.writer()
+	if w == nil {
+		return
+	}
+	if err := w.WriteLine(isStderr, content, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to spool log line: %v\n", err)
+	}
+}
+
+func (f *spoolFallback) completion(exitCode int32) {
+	w := f.writer()
+	if w == nil {
+		return
+	}
+	if err := w.WriteCompletion(exitCode); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to spool completion: %v\n", err)
+	}
+}
+
+func (f *spoolFallback) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.w != nil {
+		f.w.Close()
+	}
+}
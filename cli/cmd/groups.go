@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aliancn/swiftlog/cli/internal/client"
+	"github.com/aliancn/swiftlog/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var groupsCmd = &cobra.Command{
+	Use:   "groups",
+	Short: "Manage log groups",
+}
+
+var groupsLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List groups within a project",
+	Long: `List the log groups within a project, for discovering what --group
+accepts. --project accepts either a project name or its ID.
+
+Example:
+  swiftlog groups ls --project infra
+  swiftlog groups ls --project infra -o json`,
+	RunE: runGroupsLs,
+}
+
+var (
+	groupsProject string
+	groupsOutput  string
+)
+
+func init() {
+	rootCmd.AddCommand(groupsCmd)
+	groupsCmd.AddCommand(groupsLsCmd)
+
+	groupsLsCmd.Flags().StringVar(&groupsProject, "project", "", "Project name or ID to list groups for (required)")
+	groupsLsCmd.Flags().StringVarP(&groupsOutput, "output", "o", "text", "Output format: text or json")
+	_ = groupsLsCmd.MarkFlagRequired("project")
+	_ = groupsLsCmd.RegisterFlagCompletionFunc("project", completeProjectNames)
+}
+
+func runGroupsLs(cmd *cobra.Command, args []string) error {
+	if groupsOutput != "text" && groupsOutput != "json" {
+		return fmt.Errorf("invalid --output %q: must be \"text\" or \"json\"", groupsOutput)
+	}
+
+	cfg, err := config.Load(resolveProfile(cmd))
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w (run 'swiftlog config set --token YOUR_TOKEN' first)", err)
+	}
+	if token, _ := cmd.Flags().GetString("token"); token != "" {
+		cfg.Token = token
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("no API token configured. Run 'swiftlog config set --token YOUR_TOKEN' first")
+	}
+
+	rest := client.NewRESTClient(cfg.APIAddr, cfg.Token)
+	project, err := rest.FindProject(groupsProject)
+	if err != nil {
+		return err
+	}
+
+	groups, err := rest.ListGroups(project.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list groups for project %q: %w", project.Name, err)
+	}
+
+	if groupsOutput == "json" {
+		return printJSON(groups)
+	}
+
+	rows := make([][]string, 0, len(groups))
+	for _, g := range groups {
+		rows = append(rows, []string{g.Name, g.ID, g.CreatedAt.UTC().Format(time.RFC3339)})
+	}
+	printTable([]string{"NAME", "ID", "CREATED_AT"}, rows)
+	return nil
+}
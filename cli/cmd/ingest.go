@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+
+	"os/signal"
+
+	"github.com/aliancn/swiftlog/cli/internal/ansi"
+	"github.com/aliancn/swiftlog/cli/internal/client"
+	"github.com/aliancn/swiftlog/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var ingestCmd = &cobra.Command{
+	Use:   "ingest <file>",
+	Short: "Import an existing log file as a run",
+	Long: `Read a file that already exists on disk (or stdin with "-") and stream
+it into SwiftLog as a run, for logs a cron job or another process already
+wrote before you thought to capture them with SwiftLog.
+
+Example:
+  swiftlog ingest /var/log/backup.log --project infra --group backup
+  swiftlog ingest /var/log/app.log --follow --timestamp-format "2006-01-02 15:04:05"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runIngest,
+}
+
+var (
+	ingestName            string
+	ingestTimestampFormat string
+	ingestExitCode        int
+	ingestFollow          bool
+)
+
+func init() {
+	rootCmd.AddCommand(ingestCmd)
+
+	ingestCmd.Flags().StringVar(&projectName, "project", "", "Project name (default: SWIFTLOG_PROJECT env var, then \"default\")")
+	ingestCmd.Flags().StringVar(&groupName, "group", "", "Group name (default: SWIFTLOG_GROUP env var, then \"default\")")
+	ingestCmd.Flags().StringVar(&ingestName, "name", "", "Human-friendly name for this run, shown in the run list; max 200 characters")
+	ingestCmd.Flags().StringVar(&ingestTimestampFormat, "timestamp-format", "", `Go reference-time layout (e.g. "2006-01-02T15:04:05") matching a leading timestamp on each line; a line whose prefix doesn't parse against this layout is stored with the ingestion time instead`)
+	ingestCmd.Flags().IntVar(&ingestExitCode, "exit-code", 0, "Exit code to report for the resulting run")
+	ingestCmd.Flags().BoolVar(&ingestFollow, "follow", false, "Keep watching the file for appended lines after reaching EOF, like tail -f, until interrupted (not supported when reading from stdin)")
+	ingestCmd.Flags().BoolVar(&stripANSI, "strip-ansi", false, "Strip ANSI color/cursor codes from stored log lines")
+	ingestCmd.Flags().BoolVar(&noRedact, "no-redact", false, "Disable secret redaction (enabled by default; see 'swiftlog config set --redact-add')")
+	ingestCmd.Flags().BoolVar(&runNoCompress, "no-compress", false, "Disable gzip compression of the log stream sent to the server (enabled by default; see 'swiftlog config set --no-compress')")
+}
+
+func runIngest(cmd *cobra.Command, args []string) error {
+	filePath := args[0]
+	if filePath == "-" && ingestFollow {
+		return fmt.Errorf("--follow is not supported when reading from stdin")
+	}
+
+	var reader io.Reader
+	if filePath == "-" {
+		reader = os.Stdin
+	} else {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", filePath, err)
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	// Load configuration
+	cfg, err := config.Load(resolveProfile(cmd))
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w (run 'swiftlog config set --token YOUR_TOKEN' first)", err)
+	}
+
+	// Override with flags if provided
+	if token, _ := cmd.Flags().GetString("token"); token != "" {
+		cfg.Token = token
+	}
+	if server, _ := cmd.Flags().GetString("server"); server != "" {
+		cfg.ServerAddr = server
+	}
+
+	if cfg.Token == "" {
+		return fmt.Errorf("no API token configured. Run 'swiftlog config set --token YOUR_TOKEN' first")
+	}
+
+	stripANSIEnabled := resolveStripANSI(cmd, cfg)
+	activeRedactor, err := buildActiveRedactor(cfg)
+	if err != nil {
+		return err
+	}
+
+	projectName = resolveProjectName(cmd, cfg)
+	groupName = resolveGroupName(cmd, cfg)
+
+	// Create gRPC client
+	grpcClient, err := client.NewClient(&client.Config{
+		ServerAddr:         cfg.ServerAddr,
+		Token:              cfg.Token,
+		TLS:                cfg.TLS,
+		CACertPath:         cfg.CACert,
+		ClientCertPath:     cfg.ClientCert,
+		ClientKeyPath:      cfg.ClientKey,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		Compression:        resolveCompression(cmd, cfg),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer grpcClient.Close()
+
+	ctx := context.Background()
+
+	// Replay anything a previous run couldn't deliver before starting a new
+	// one, so spooled logs don't just pile up until someone remembers to
+	// run 'swiftlog flush'.
+	if err := flushSpool(ctx, grpcClient, false); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush spooled logs: %v\n", err)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	// Start streaming session
+	session, err := grpcClient.StartStream(ctx, projectName, groupName, nil, "", "", "", ingestName)
+	if err != nil {
+		return fmt.Errorf("failed to start stream: %w", err)
+	}
+	defer session.Close()
+
+	fmt.Printf("📝 Ingesting %s into SwiftLog (Run ID: %s)\n", filePath, session.GetRunID())
+	fmt.Printf("Project: %s, Group: %s\n", projectName, groupName)
+	fmt.Println(strings.Repeat("-", 60))
+
+	fallback := newSpoolFallback(session.GetRunID(), projectName, groupName)
+	defer fallback.close()
+
+	emit := func(line string) {
+		ts := time.Now()
+		stored := line
+		if ingestTimestampFormat != "" {
+			if parsed, rest, ok := splitLeadingTimestamp(line, ingestTimestampFormat); ok {
+				ts = parsed
+				stored = rest
+			}
+		}
+		if stripANSIEnabled {
+			stored = ansi.Strip(stored)
+		}
+		if activeRedactor != nil {
+			stored = activeRedactor.Redact(stored)
+		}
+		if err := session.SendLogLineAt(false, ts, stored); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to send log line: %v\n", err)
+			fallback.line(false, stored)
+		}
+	}
+
+	var readErr error
+	if ingestFollow {
+		readErr = followLines(reader.(*os.File), defaultMaxLineLength, sigCh, emit)
+	} else {
+		readErr = readLines(reader, defaultMaxLineLength, emit)
+	}
+	if readErr != nil {
+		return fmt.Errorf("error reading %s: %w", filePath, readErr)
+	}
+
+	// Flush any lines still buffered for batching before completion, so
+	// they aren't left unsent behind the completion message.
+	if err := session.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush buffered log lines: %v\n", err)
+	}
+
+	// Send completion message. If the server is still unreachable, spool it
+	// rather than failing the command outright.
+	if err := session.SendCompletion(int32(ingestExitCode)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to send completion: %v\n", err)
+		fallback.completion(int32(ingestExitCode))
+	}
+
+	// Wait for server acknowledgment
+	if err := session.WaitForCompletion(client.DefaultCompletionTimeout); errors.Is(err, client.ErrCompletionTimeout) {
+		fmt.Fprintf(os.Stderr, "Warning: did not receive server acknowledgment within %s\n", client.DefaultCompletionTimeout)
+	}
+
+	// Print summary
+	fmt.Println(strings.Repeat("-", 60))
+	if ingestExitCode == 0 {
+		fmt.Printf("✅ Run completed (Exit Code: %d)\n", ingestExitCode)
+	} else {
+		fmt.Printf("❌ Run failed (Exit Code: %d)\n", ingestExitCode)
+	}
+	fmt.Printf("Logs saved to Project[%s], Group[%s]\n", projectName, groupName)
+	fmt.Printf("Run ID: %s\n", session.GetRunID())
+
+	return nil
+}
+
+// followInterval is how often --follow polls for newly appended data once
+// it reaches EOF, mirroring tail -f's polling behavior rather than a
+// filesystem watcher, so it needs no new dependency and works the same way
+// on every platform swiftlog already supports.
+const followInterval = 500 * time.Millisecond
+
+// followLines reads f like tail -f: it emits each complete line as soon as
+// a trailing newline arrives, then polls for more data every followInterval
+// once it hits EOF, until sigCh receives an interrupt. Any line still
+// pending (no trailing newline yet) when interrupted is emitted as-is
+// rather than discarded.
+func followLines(f *os.File, maxLineLen int, sigCh <-chan os.Signal, emit func(line string)) error {
+	if maxLineLen <= 0 {
+		maxLineLen = defaultMaxLineLength
+	}
+
+	var pending []byte
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			pending = append(pending, buf[:n]...)
+			for {
+				idx := bytes.IndexByte(pending, '\n')
+				if idx < 0 {
+					if len(pending) > maxLineLen {
+						emit(string(pending[:maxLineLen]) + lineContinuationSuffix)
+						pending = pending[maxLineLen:]
+						continue
+					}
+					break
+				}
+				line := bytes.TrimSuffix(pending[:idx], []byte("\r"))
+				emit(string(line))
+				pending = pending[idx+1:]
+			}
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if err == io.EOF || n == 0 {
+			select {
+			case <-sigCh:
+				if len(pending) > 0 {
+					emit(string(pending))
+				}
+				return nil
+			case <-time.After(followInterval):
+			}
+		}
+	}
+}
+
+// splitLeadingTimestamp tries to parse a timestamp off the front of line
+// using layout, trying progressively longer whitespace-delimited prefixes
+// (most reference-time layouts span a handful of space-separated fields,
+// e.g. "2006-01-02 15:04:05" or "Mon Jan _2 15:04:05 2006") since a
+// layout's formatted width isn't fixed - fields like day-of-month vary.
+// It returns the parsed time, the line with the matched prefix and one
+// run of following whitespace removed, and whether a prefix matched. If
+// nothing matches within the first few fields, it returns line unchanged.
+func splitLeadingTimestamp(line, layout string) (time.Time, string, bool) {
+	type span struct{ start, end int }
+	var fields []span
+	inField := false
+	fieldStart := 0
+	for i, r := range line {
+		switch {
+		case unicode.IsSpace(r):
+			if inField {
+				fields = append(fields, span{fieldStart, i})
+				inField = false
+			}
+		case !inField:
+			fieldStart = i
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, span{fieldStart, len(line)})
+	}
+
+	const maxTimestampFields = 6
+	limit := maxTimestampFields
+	if len(fields) < limit {
+		limit = len(fields)
+	}
+	for i := 1; i <= limit; i++ {
+		end := fields[i-1].end
+		candidate := line[:end]
+		ts, err := time.Parse(layout, candidate)
+		if err != nil {
+			continue
+		}
+		rest := strings.TrimLeft(line[end:], " \t")
+		return ts, rest, true
+	}
+	return time.Time{}, line, false
+}
@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aliancn/swiftlog/cli/internal/config"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Exchange a username and password for an API token",
+	Long: `Prompt for a username and password, authenticate against the
+SwiftLog API, and mint a dedicated long-lived API token for this CLI
+install, saving it to the active profile. This replaces creating a
+token in the web UI and pasting it in by hand.
+
+The token is never printed - only its name and creation time are.
+
+Example:
+  swiftlog login
+  swiftlog login --api-url http://swiftlog.internal:8080`,
+	RunE: runLogin,
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Revoke this CLI's API token and clear it from config",
+	Long: `Revoke the API token 'swiftlog login' created and remove it from the
+active profile. The server-side token is deleted, not just forgotten
+locally - a stolen config file can't be replayed after this.`,
+	RunE: runLogout,
+}
+
+var loginAPIURL string
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+
+	loginCmd.Flags().StringVar(&loginAPIURL, "api-url", "", "HTTP API server base URL (default: the active profile's api_addr)")
+}
+
+// authRequest is the shared shape of POST /api/v1/auth/login's body.
+type authRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func runLogin(cmd *cobra.Command, args []string) error {
+	profile := resolveProfile(cmd)
+	cfg, err := config.Load(profile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	apiAddr := loginAPIURL
+	if apiAddr == "" {
+		apiAddr = cfg.APIAddr
+	}
+	apiAddr = strings.TrimRight(apiAddr, "/")
+
+	username, err := promptLine("Username: ")
+	if err != nil {
+		return fmt.Errorf("failed to read username: %w", err)
+	}
+
+	password, err := promptPassword("Password: ")
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+
+	sessionToken, err := postAuthLogin(apiAddr, username, password)
+	if err != nil {
+		return err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown-host"
+	}
+	tokenName := "cli-" + hostname
+
+	rawToken, tokenInfo, err := postAuthTokens(apiAddr, sessionToken, tokenName)
+	if err != nil {
+		return err
+	}
+
+	cfg.Token = rawToken
+	cfg.TokenID = tokenInfo.ID
+	if apiAddr != "" {
+		cfg.APIAddr = apiAddr
+	}
+	if err := config.Save(cfg, profile); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	fmt.Printf("✓ Logged in as %s\n", username)
+	fmt.Printf("Created token %q at %s\n", tokenInfo.Name, tokenInfo.CreatedAt)
+	return nil
+}
+
+func runLogout(cmd *cobra.Command, args []string) error {
+	profile := resolveProfile(cmd)
+	cfg, err := config.Load(profile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if cfg.Token == "" || cfg.TokenID == "" {
+		return fmt.Errorf("not logged in (no token from 'swiftlog login' found in this profile)")
+	}
+
+	apiAddr := strings.TrimRight(cfg.APIAddr, "/")
+	if err := deleteAuthToken(apiAddr, cfg.Token, cfg.TokenID); err != nil {
+		return err
+	}
+
+	cfg.Token = ""
+	cfg.TokenID = ""
+	if err := config.Save(cfg, profile); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	fmt.Println("✓ Logged out and revoked the CLI's API token")
+	return nil
+}
+
+// promptLine writes prompt to stdout and reads a single line from stdin.
+func promptLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// promptPassword writes prompt to stdout and reads a password from stdin
+// without echoing it back to the terminal.
+func promptPassword(prompt string) (string, error) {
+	fmt.Print(prompt)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// postAuthLogin calls POST /api/v1/auth/login and returns the resulting
+// short-lived session token, which is only used long enough to mint a
+// dedicated CLI token - it's never saved.
+func postAuthLogin(apiAddr, username, password string) (string, error) {
+	body, err := json.Marshal(authRequest{Username: username, Password: password})
+	if err != nil {
+		return "", fmt.Errorf("failed to build login request: %w", err)
+	}
+
+	resp, respBody, err := postJSON(apiAddr+"/api/v1/auth/login", "", body)
+	if err != nil {
+		return "", err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// fall through
+	case http.StatusUnauthorized:
+		return "", fmt.Errorf("login failed: invalid username or password")
+	case http.StatusTooManyRequests:
+		return "", fmt.Errorf("login failed: too many attempts, try again later")
+	default:
+		return "", fmt.Errorf("login failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var loginResp struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(respBody, &loginResp); err != nil {
+		return "", fmt.Errorf("failed to parse login response: %w", err)
+	}
+
+	return loginResp.Token, nil
+}
+
+// createdToken is the subset of the server's APIToken JSON that 'login'
+// needs to report back to the user and persist for a later 'logout'.
+type createdToken struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+// postAuthTokens calls POST /api/v1/auth/tokens, authenticated with the
+// session token from postAuthLogin, and returns the new raw API token
+// along with its metadata.
+func postAuthTokens(apiAddr, sessionToken, name string) (string, createdToken, error) {
+	body, err := json.Marshal(struct {
+		Name string `json:"name"`
+	}{Name: name})
+	if err != nil {
+		return "", createdToken{}, fmt.Errorf("failed to build token request: %w", err)
+	}
+
+	resp, respBody, err := postJSON(apiAddr+"/api/v1/auth/tokens", sessionToken, body)
+	if err != nil {
+		return "", createdToken{}, err
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", createdToken{}, fmt.Errorf("failed to create API token (status %d): %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var tokenResp struct {
+		Token     string       `json:"token"`
+		TokenInfo createdToken `json:"token_info"`
+	}
+	if err := json.Unmarshal(respBody, &tokenResp); err != nil {
+		return "", createdToken{}, fmt.Errorf("failed to parse token response: %w", err)
+	}
+
+	return tokenResp.Token, tokenResp.TokenInfo, nil
+}
+
+// deleteAuthToken calls DELETE /api/v1/auth/tokens/:id, authenticated with
+// the token being revoked itself (the server allows a credential to revoke
+// its own token).
+func deleteAuthToken(apiAddr, token, tokenID string) error {
+	req, err := http.NewRequest(http.MethodDelete, apiAddr+"/api/v1/auth/tokens/"+tokenID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	httpClient := &http.Client{}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach API server at %s: %w", apiAddr, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to revoke token (status %d): %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
+
+// postJSON POSTs body to url, setting a Bearer authorization header when
+// token is non-empty, and returns the raw response and body for the
+// caller to interpret status-code-by-status-code.
+func postJSON(url, token string, body []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	httpClient := &http.Client{}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to reach API server at %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return resp, respBody, nil
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aliancn/swiftlog/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <run_id>",
+	Short: "Fetch stored logs for a run",
+	Long: `Fetch the logs captured for a run from the SwiftLog API and print
+them to the terminal.
+
+Example:
+  swiftlog logs 3f29c1e0-...
+  swiftlog logs 3f29c1e0-... --level stderr --raw | grep -i panic`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLogs,
+}
+
+var (
+	logsLevel string
+	logsSince string
+	logsUntil string
+	logsRaw   bool
+)
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().StringVar(&logsLevel, "level", "", "Only show lines at this level (stdout or stderr)")
+	logsCmd.Flags().StringVar(&logsSince, "since", "", "Only show lines at or after this time (RFC3339)")
+	logsCmd.Flags().StringVar(&logsUntil, "until", "", "Only show lines at or before this time (RFC3339)")
+	logsCmd.Flags().BoolVar(&logsRaw, "raw", false, "Print log content only, with no timestamp/level prefix or color")
+}
+
+// logEntry mirrors the JSON shape written by loki.LogEntry.MarshalJSON.
+type logEntry struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Content   string `json:"content"`
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	runID := args[0]
+
+	cfg, err := config.Load(resolveProfile(cmd))
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w (run 'swiftlog config set --token YOUR_TOKEN' first)", err)
+	}
+
+	// Override with flags if provided
+	if token, _ := cmd.Flags().GetString("token"); token != "" {
+		cfg.Token = token
+	}
+
+	if cfg.Token == "" {
+		return fmt.Errorf("no API token configured. Run 'swiftlog config set --token YOUR_TOKEN' first")
+	}
+
+	if logsLevel != "" && logsLevel != "stdout" && logsLevel != "stderr" {
+		return fmt.Errorf("invalid --level %q: must be \"stdout\" or \"stderr\"", logsLevel)
+	}
+
+	query := url.Values{}
+	if logsLevel != "" {
+		query.Set("level", logsLevel)
+	}
+	if logsSince != "" {
+		query.Set("since", logsSince)
+	}
+	if logsUntil != "" {
+		query.Set("until", logsUntil)
+	}
+
+	reqURL := fmt.Sprintf("%s/api/v1/runs/%s/logs", strings.TrimRight(cfg.APIAddr, "/"), runID)
+	if encoded := query.Encode(); encoded != "" {
+		reqURL += "?" + encoded
+	}
+
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+
+	httpClient := &http.Client{}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach API server at %s: %w", cfg.APIAddr, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// fall through
+	case http.StatusNotFound:
+		return fmt.Errorf("run %s not found", runID)
+	case http.StatusUnauthorized:
+		return fmt.Errorf("authentication failed: check your API token")
+	case http.StatusForbidden:
+		return fmt.Errorf("access denied: token does not have permission to view run %s", runID)
+	default:
+		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var entries []logEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	colorize := !logsRaw && isTerminal(os.Stdout)
+	for _, entry := range entries {
+		if logsRaw {
+			fmt.Println(entry.Content)
+			continue
+		}
+
+		line := fmt.Sprintf("%s [%s] %s", entry.Timestamp, entry.Level, entry.Content)
+		if colorize && entry.Level == "STDERR" {
+			fmt.Println("\033[31m" + line + "\033[0m")
+		} else {
+			fmt.Println(line)
+		}
+	}
+
+	return nil
+}
+
+// isTerminal reports whether f is connected to a terminal, so logs are only
+// colorized when a human is likely watching and not when piped into grep.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
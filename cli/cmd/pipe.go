@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aliancn/swiftlog/cli/internal/ansi"
+	"github.com/aliancn/swiftlog/cli/internal/client"
+	"github.com/aliancn/swiftlog/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var pipeCmd = &cobra.Command{
+	Use:   "pipe [flags]",
+	Short: "Stream stdin to SwiftLog as a run",
+	Long: `Read stdin line by line and stream it to the SwiftLog platform as a
+run, for wrappers (make, nohup, cron) that can't invoke the target
+command through 'swiftlog run -- cmd' directly.
+
+Example:
+  ./backup.sh 2>&1 | swiftlog pipe --project infra --group backup`,
+	RunE: runPipe,
+}
+
+var (
+	pipeStderr   bool
+	pipeExitCode int
+)
+
+func init() {
+	rootCmd.AddCommand(pipeCmd)
+
+	pipeCmd.Flags().StringVar(&projectName, "project", "", "Project name (default: SWIFTLOG_PROJECT env var, then \"default\")")
+	pipeCmd.Flags().StringVar(&groupName, "group", "", "Group name (default: SWIFTLOG_GROUP env var, then \"default\")")
+	pipeCmd.Flags().BoolVar(&pipeStderr, "stderr", false, "Mark every line as STDERR instead of STDOUT")
+	pipeCmd.Flags().IntVar(&pipeExitCode, "exit-code", 0, "Exit code to report when stdin closes")
+	pipeCmd.Flags().BoolVar(&stripANSI, "strip-ansi", false, "Strip ANSI color/cursor codes from stored log lines (local echo keeps the original)")
+	pipeCmd.Flags().BoolVar(&noRedact, "no-redact", false, "Disable secret redaction (enabled by default; see 'swiftlog config set --redact-add')")
+	pipeCmd.Flags().BoolVar(&runNoCompress, "no-compress", false, "Disable gzip compression of the log stream sent to the server (enabled by default; see 'swiftlog config set --no-compress')")
+}
+
+func runPipe(cmd *cobra.Command, args []string) error {
+	// Load configuration
+	cfg, err := config.Load(resolveProfile(cmd))
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w (run 'swiftlog config set --token YOUR_TOKEN' first)", err)
+	}
+
+	// Override with flags if provided
+	if token, _ := cmd.Flags().GetString("token"); token != "" {
+		cfg.Token = token
+	}
+	if server, _ := cmd.Flags().GetString("server"); server != "" {
+		cfg.ServerAddr = server
+	}
+
+	if cfg.Token == "" {
+		return fmt.Errorf("no API token configured. Run 'swiftlog config set --token YOUR_TOKEN' first")
+	}
+
+	stripANSIEnabled := resolveStripANSI(cmd, cfg)
+	activeRedactor, err := buildActiveRedactor(cfg)
+	if err != nil {
+		return err
+	}
+
+	projectName = resolveProjectName(cmd, cfg)
+	groupName = resolveGroupName(cmd, cfg)
+
+	// Create gRPC client
+	grpcClient, err := client.NewClient(&client.Config{
+		ServerAddr:         cfg.ServerAddr,
+		Token:              cfg.Token,
+		TLS:                cfg.TLS,
+		CACertPath:         cfg.CACert,
+		ClientCertPath:     cfg.ClientCert,
+		ClientKeyPath:      cfg.ClientKey,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		Compression:        resolveCompression(cmd, cfg),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer grpcClient.Close()
+
+	ctx := context.Background()
+
+	// Replay anything a previous run couldn't deliver before starting a new
+	// one, so spooled logs don't just pile up until someone remembers to
+	// run 'swiftlog flush'.
+	if err := flushSpool(ctx, grpcClient, false); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush spooled logs: %v\n", err)
+	}
+
+	// Start streaming session
+	session, err := grpcClient.StartStream(ctx, projectName, groupName, nil, "", "", "", "")
+	if err != nil {
+		return fmt.Errorf("failed to start stream: %w", err)
+	}
+	defer session.Close()
+
+	fmt.Printf("📝 Streaming logs to SwiftLog (Run ID: %s)\n", session.GetRunID())
+	fmt.Printf("Project: %s, Group: %s\n", projectName, groupName)
+	fmt.Println(strings.Repeat("-", 60))
+
+	fallback := newSpoolFallback(session.GetRunID(), projectName, groupName)
+	defer fallback.close()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		echoLine := line
+		stored := line
+		if stripANSIEnabled {
+			stored = ansi.Strip(stored)
+		}
+		if activeRedactor != nil {
+			echoLine = activeRedactor.Redact(echoLine)
+			stored = activeRedactor.Redact(stored)
+		}
+
+		fmt.Println(echoLine)
+		if err := session.SendLogLine(pipeStderr, stored); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to send log line: %v\n", err)
+			fallback.line(pipeStderr, stored)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading stdin: %w", err)
+	}
+
+	// Flush any lines still buffered for batching before completion, so
+	// they aren't left unsent behind the completion message.
+	if err := session.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush buffered log lines: %v\n", err)
+	}
+
+	// Send completion message. If the server is still unreachable, spool it
+	// rather than failing the command outright — stdin already closed and
+	// its exit code shouldn't be lost.
+	if err := session.SendCompletion(int32(pipeExitCode)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to send completion: %v\n", err)
+		fallback.completion(int32(pipeExitCode))
+	}
+
+	// Wait for server acknowledgment
+	if err := session.WaitForCompletion(client.DefaultCompletionTimeout); errors.Is(err, client.ErrCompletionTimeout) {
+		fmt.Fprintf(os.Stderr, "Warning: did not receive server acknowledgment within %s\n", client.DefaultCompletionTimeout)
+	}
+
+	// Print summary
+	fmt.Println(strings.Repeat("-", 60))
+	if pipeExitCode == 0 {
+		fmt.Printf("✅ Run completed (Exit Code: %d)\n", pipeExitCode)
+	} else {
+		fmt.Printf("❌ Run failed (Exit Code: %d)\n", pipeExitCode)
+	}
+	fmt.Printf("Logs saved to Project[%s], Group[%s]\n", projectName, groupName)
+	fmt.Printf("Run ID: %s\n", session.GetRunID())
+
+	return nil
+}
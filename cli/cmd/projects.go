@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aliancn/swiftlog/cli/internal/client"
+	"github.com/aliancn/swiftlog/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var projectsCmd = &cobra.Command{
+	Use:   "projects",
+	Short: "Manage projects",
+}
+
+var projectsLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List projects visible to the active token",
+	Long: `List projects visible to the active API token, for discovering what
+--project accepts.
+
+Example:
+  swiftlog projects ls
+  swiftlog projects ls -o json`,
+	RunE: runProjectsLs,
+}
+
+var projectsOutput string
+
+func init() {
+	rootCmd.AddCommand(projectsCmd)
+	projectsCmd.AddCommand(projectsLsCmd)
+
+	projectsLsCmd.Flags().StringVarP(&projectsOutput, "output", "o", "text", "Output format: text or json")
+}
+
+func runProjectsLs(cmd *cobra.Command, args []string) error {
+	if projectsOutput != "text" && projectsOutput != "json" {
+		return fmt.Errorf("invalid --output %q: must be \"text\" or \"json\"", projectsOutput)
+	}
+
+	cfg, err := config.Load(resolveProfile(cmd))
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w (run 'swiftlog config set --token YOUR_TOKEN' first)", err)
+	}
+	if token, _ := cmd.Flags().GetString("token"); token != "" {
+		cfg.Token = token
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("no API token configured. Run 'swiftlog config set --token YOUR_TOKEN' first")
+	}
+
+	rest := client.NewRESTClient(cfg.APIAddr, cfg.Token)
+	projects, err := rest.ListProjects()
+	if err != nil {
+		return fmt.Errorf("failed to list projects: %w", err)
+	}
+
+	if projectsOutput == "json" {
+		return printJSON(projects)
+	}
+
+	rows := make([][]string, 0, len(projects))
+	for _, p := range projects {
+		rows = append(rows, []string{p.Name, p.ID, p.CreatedAt.UTC().Format(time.RFC3339)})
+	}
+	printTable([]string{"NAME", "ID", "CREATED_AT"}, rows)
+	return nil
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aliancn/swiftlog/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report <run_id>",
+	Short: "Fetch (and optionally wait for) the AI report for a run",
+	Long: `Fetch the AI-generated diagnosis for a run and render it to the
+terminal. If the analysis is still pending or processing, --wait polls
+until it reaches a terminal state or --timeout elapses.
+
+Example:
+  swiftlog report 3f29c1e0-...
+  swiftlog report 3f29c1e0-... --trigger --wait --timeout 5m`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReport,
+}
+
+var (
+	reportTrigger bool
+	reportWait    bool
+	reportTimeout time.Duration
+)
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+
+	reportCmd.Flags().BoolVar(&reportTrigger, "trigger", false, "Trigger AI analysis before fetching the report (POST /runs/:id/analyze)")
+	reportCmd.Flags().BoolVar(&reportWait, "wait", false, "Poll until the report is completed or failed")
+	reportCmd.Flags().DurationVar(&reportTimeout, "timeout", 5*time.Minute, "Maximum time to wait with --wait")
+}
+
+// runReportResponse mirrors the JSON fields of models.LogRun relevant here.
+type runReportResponse struct {
+	ID       string `json:"id"`
+	AIStatus string `json:"ai_status"`
+	AIReport string `json:"ai_report"`
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	runID := args[0]
+
+	cfg, err := config.Load(resolveProfile(cmd))
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w (run 'swiftlog config set --token YOUR_TOKEN' first)", err)
+	}
+	if token, _ := cmd.Flags().GetString("token"); token != "" {
+		cfg.Token = token
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("no API token configured. Run 'swiftlog config set --token YOUR_TOKEN' first")
+	}
+
+	apiAddr := strings.TrimRight(cfg.APIAddr, "/")
+	httpClient := &http.Client{}
+
+	if reportTrigger {
+		analyzeURL := fmt.Sprintf("%s/api/v1/runs/%s/analyze", apiAddr, url.PathEscape(runID))
+		req, err := http.NewRequest(http.MethodPost, analyzeURL, bytes.NewReader([]byte("{}")))
+		if err != nil {
+			return fmt.Errorf("failed to build analyze request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to reach API server at %s: %w", cfg.APIAddr, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err := statusToError(resp.StatusCode, body, runID); err != nil {
+			return fmt.Errorf("failed to trigger analysis: %w", err)
+		}
+		fmt.Println("Analysis triggered, fetching report...")
+	}
+
+	deadline := time.Now().Add(reportTimeout)
+	for {
+		run, err := fetchRun(httpClient, apiAddr, cfg.Token, runID)
+		if err != nil {
+			return err
+		}
+
+		switch run.AIStatus {
+		case "completed", "partial":
+			if run.AIReport == "" {
+				fmt.Println("(no report content)")
+				return nil
+			}
+			fmt.Println(run.AIReport)
+			return nil
+		case "failed":
+			return fmt.Errorf("AI analysis failed for run %s", runID)
+		case "none":
+			return fmt.Errorf("AI analysis is not enabled for run %s", runID)
+		case "pending", "processing":
+			if !reportWait {
+				fmt.Printf("Analysis is %s. Re-run with --wait to block until it finishes.\n", run.AIStatus)
+				return nil
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("timed out after %s waiting for analysis on run %s (status: %s)", reportTimeout, runID, run.AIStatus)
+			}
+			time.Sleep(3 * time.Second)
+			continue
+		case "cancelled":
+			return fmt.Errorf("AI analysis for run %s was cancelled", runID)
+		default:
+			return fmt.Errorf("unrecognized ai_status %q for run %s", run.AIStatus, runID)
+		}
+	}
+}
+
+func fetchRun(httpClient *http.Client, apiAddr, token, runID string) (*runReportResponse, error) {
+	reqURL := fmt.Sprintf("%s/api/v1/runs/%s", apiAddr, url.PathEscape(runID))
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach API server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if err := statusToError(resp.StatusCode, body, runID); err != nil {
+		return nil, err
+	}
+
+	var run runReportResponse
+	if err := json.Unmarshal(body, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &run, nil
+}
+
+func statusToError(statusCode int, body []byte, runID string) error {
+	switch statusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("run %s not found", runID)
+	case http.StatusUnauthorized:
+		return fmt.Errorf("authentication failed: check your API token")
+	case http.StatusForbidden:
+		return fmt.Errorf("access denied: token does not have permission to view run %s", runID)
+	case http.StatusConflict:
+		return fmt.Errorf("run %s already finished: %s", runID, strings.TrimSpace(string(body)))
+	default:
+		return fmt.Errorf("API request failed with status %d: %s", statusCode, strings.TrimSpace(string(body)))
+	}
+}
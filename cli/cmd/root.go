@@ -35,4 +35,5 @@ func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringP("server", "s", "", "Server address (overrides config)")
 	rootCmd.PersistentFlags().StringP("token", "t", "", "API token (overrides config)")
+	rootCmd.PersistentFlags().String("profile", "", "Named configuration profile to use (default: the file's current_profile, or \"default\")")
 }
@@ -2,16 +2,35 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/creack/pty"
+	"github.com/google/uuid"
+
+	"github.com/aliancn/swiftlog/cli/internal/ansi"
 	"github.com/aliancn/swiftlog/cli/internal/client"
 	"github.com/aliancn/swiftlog/cli/internal/config"
+	"github.com/aliancn/swiftlog/cli/internal/redact"
 	"github.com/spf13/cobra"
 )
 
@@ -21,6 +40,16 @@ var runCmd = &cobra.Command{
 	Long: `Execute a command and capture its stdout/stderr output, streaming
 the logs in real-time to the SwiftLog platform for storage and analysis.
 
+Exit codes:
+  0        the command exited 0 (or --exit-zero was given)
+  1-123    the command's own exit code, passed through unchanged
+  124      --timeout killed the command
+  125      swiftlog itself failed to connect to the server or start the
+           run - the command never got to execute, so this is
+           distinguishable from any exit code the command could report
+  128+N    the command was killed by signal N (e.g. 130 for SIGINT),
+           matching the $? convention a shell would report
+
 Example:
   swiftlog run --project myapp --group build -- ./build.sh
   swiftlog run --project data -- python train_model.py`,
@@ -30,13 +59,314 @@ Example:
 var (
 	projectName string
 	groupName   string
+
+	runAnalyze    bool
+	runWaitReport bool
+	runTags       []string
+	runName       string
+
+	runQuiet      bool
+	runSilent     bool
+	runEcho       string
+	runMaxLineLen int
+
+	stripANSI bool
+	noRedact  bool
+
+	runTimeout   time.Duration
+	runKillAfter time.Duration
+
+	runOutput     string
+	runResultFile string
+
+	runExitZero bool
+
+	runMaxLinesPerSec int
+	runSample         string
+
+	runMultiline      string
+	runMultilineFlush time.Duration
+
+	runBinary string
+
+	runPTY bool
+
+	runNoCompress bool
+
+	runCompletionTimeout time.Duration
+
+	runIDFile   string
+	runPrintURL bool
+
+	runRetry      int
+	runRetryDelay time.Duration
 )
 
+// connectFailureExitCode is reported when swiftlog itself can't reach the
+// server (or the ingestor rejects the stream) before the command ever
+// starts, so a caller can tell "infrastructure problem, the command's own
+// result is unknown" apart from any exit code the command itself could
+// have produced.
+const connectFailureExitCode = 125
+
+// timeoutExitCode is reported when --timeout kills the command, mirroring
+// GNU coreutils' timeout(1) so scripts and humans reading a run's exit code
+// recognize it as a timeout rather than an application failure.
+const timeoutExitCode = 124
+
+// defaultMaxLineLength bounds how much of a single physical line
+// streamOutput buffers before splitting it into a continuation chunk.
+// 1MB comfortably covers minified JSON dumps and single-line stack traces
+// while still bounding per-line memory use.
+const defaultMaxLineLength = 1 << 20
+
 func init() {
 	rootCmd.AddCommand(runCmd)
 
-	runCmd.Flags().StringVar(&projectName, "project", "", "Project name (default: \"default\")")
-	runCmd.Flags().StringVar(&groupName, "group", "", "Group name (default: \"default\")")
+	runCmd.Flags().StringVar(&projectName, "project", "", "Project name (default: SWIFTLOG_PROJECT env var, then \"default\")")
+	runCmd.Flags().StringVar(&groupName, "group", "", "Group name (default: SWIFTLOG_GROUP env var, then \"default\")")
+	runCmd.Flags().BoolVar(&runAnalyze, "analyze", false, "Request AI analysis for this run, even if ai_auto_analyze is disabled")
+	runCmd.Flags().BoolVar(&runWaitReport, "wait-report", false, "With --analyze, block until the report is ready and print it")
+	runCmd.Flags().StringArrayVar(&runTags, "tag", nil, "Attach a key=value tag to this run (repeatable, max 20, 64-char keys)")
+	runCmd.Flags().StringVar(&runName, "name", "", "Human-friendly name for this run (e.g. \"nightly backup\"), shown in the run list; max 200 characters")
+	runCmd.Flags().BoolVar(&runQuiet, "quiet", false, "Don't echo the wrapped command's output or the banner; still prints the summary")
+	runCmd.Flags().BoolVar(&runSilent, "silent", false, "Like --quiet, but also suppresses the summary")
+	runCmd.Flags().StringVar(&runEcho, "echo", "both", "Which streams to echo locally: stdout, stderr, both, or none")
+	runCmd.Flags().IntVar(&runMaxLineLen, "max-line-length", defaultMaxLineLength, "Split lines longer than this many bytes into continuation chunks")
+	runCmd.Flags().BoolVar(&stripANSI, "strip-ansi", false, "Strip ANSI color/cursor codes from stored log lines (local echo keeps the original)")
+	runCmd.Flags().BoolVar(&noRedact, "no-redact", false, "Disable secret redaction (enabled by default; see 'swiftlog config set --redact-add')")
+	runCmd.Flags().DurationVar(&runTimeout, "timeout", 0, "Kill the command if it runs longer than this (e.g. 30m); 0 disables")
+	runCmd.Flags().DurationVar(&runKillAfter, "kill-after", 10*time.Second, "Grace period after SIGTERM before sending SIGKILL, once --timeout fires")
+	runCmd.Flags().StringVarP(&runOutput, "output", "o", "text", "Output format for swiftlog's own result reporting: text or json")
+	runCmd.Flags().StringVar(&runResultFile, "result-file", "", "With -o json, write the result object here instead of stdout/stderr")
+	runCmd.Flags().BoolVar(&runExitZero, "exit-zero", false, "Always exit 0 regardless of the command's result (the real exit code is still reported to the server and in the summary/-o json output)")
+	runCmd.Flags().IntVar(&runMaxLinesPerSec, "max-lines-per-sec", 0, "Cap stdout lines sent to the server to N/sec via a token bucket; 0 disables (stderr is never limited)")
+	runCmd.Flags().StringVar(&runSample, "sample", "", `Once --max-lines-per-sec is exceeded, keep only every Nth dropped line instead of discarding it outright (form "1/N", e.g. "1/100"); has no effect without --max-lines-per-sec`)
+	runCmd.Flags().StringVar(&runMultiline, "multiline", "", `Merge continuation lines (e.g. stack trace frames) into one stored record: "python", "java", or "regex:<pattern>" matching a continuation line; unset stores each line separately`)
+	runCmd.Flags().DurationVar(&runMultilineFlush, "multiline-flush", 200*time.Millisecond, "With --multiline, flush a buffered record after this long without a new continuation line")
+	runCmd.Flags().StringVar(&runBinary, "binary", "repair", `How to handle non-UTF-8 output before storing it: "repair" replaces invalid sequences with U+FFFD, "skip" instead drops a mostly-non-printable line and substitutes a "[binary data: N bytes omitted]" marker`)
+	runCmd.Flags().BoolVar(&runPTY, "pty", false, "Run the command attached to a pseudo-terminal so it behaves as if interactive (progress bars, color); combines stdout/stderr and forwards terminal resizes")
+	runCmd.Flags().BoolVar(&runNoCompress, "no-compress", false, "Disable gzip compression of the log stream sent to the server (enabled by default; see 'swiftlog config set --no-compress')")
+	runCmd.Flags().DurationVar(&runCompletionTimeout, "completion-timeout", client.DefaultCompletionTimeout, "How long to wait for the server to acknowledge the run as complete before giving up")
+	runCmd.Flags().StringVar(&runIDFile, "run-id-file", "", "Write the run's UUID to this file as soon as the stream starts, before the command runs, so even a crashed run leaves a reference behind")
+	runCmd.Flags().BoolVar(&runPrintURL, "print-url", false, "Print the run's web UI link (requires 'swiftlog config set --web-url')")
+	runCmd.Flags().IntVar(&runRetry, "retry", 0, "Retry the command up to N more times on a non-zero exit, each attempt as its own linked run; 0 disables retries")
+	runCmd.Flags().DurationVar(&runRetryDelay, "retry-delay", 2*time.Second, "How long to wait before starting the next --retry attempt")
+}
+
+// runResult is the JSON object -o json writes on completion, for CI
+// pipelines that want the run's outcome without scraping the human banner.
+type runResult struct {
+	RunID     string              `json:"run_id"`
+	Project   string              `json:"project"`
+	Group     string              `json:"group"`
+	Name      string              `json:"name,omitempty"`
+	URL       string              `json:"url,omitempty"`
+	ExitCode  int                 `json:"exit_code"`
+	Status    string              `json:"status"`
+	StartTime string              `json:"start_time"`
+	EndTime   string              `json:"end_time"`
+	LinesSent int64               `json:"lines_sent"`
+	Attempts  []runAttemptSummary `json:"attempts,omitempty"`
+}
+
+// runAttemptSummary is one --retry attempt's outcome, included in runResult
+// only when --retry made more than one attempt.
+type runAttemptSummary struct {
+	Attempt  int    `json:"attempt"`
+	RunID    string `json:"run_id"`
+	ExitCode int    `json:"exit_code"`
+	Status   string `json:"status"`
+}
+
+// resolveOutputMode validates --output, matching the "-o json" spelling the
+// request asks for while keeping "text" as the default so existing
+// invocations are unaffected.
+func resolveOutputMode(cmd *cobra.Command) (string, error) {
+	switch runOutput {
+	case "text", "json":
+		return runOutput, nil
+	default:
+		return "", fmt.Errorf("invalid --output %q: must be \"text\" or \"json\"", runOutput)
+	}
+}
+
+// writeRunIDFile writes runID to path, so a CI step that needs to reference
+// the run (e.g. to post its web URL in a PR comment) has something to read
+// even if the wrapped command later crashes the CLI itself.
+func writeRunIDFile(path, runID string) error {
+	if err := os.WriteFile(path, []byte(runID+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write --run-id-file %s: %w", path, err)
+	}
+	return nil
+}
+
+// runURL builds the web UI link for runID, or "" if webURL (cfg.WebURL) is
+// unset - there's no way to derive the web UI's address from ServerAddr/
+// APIAddr, so this is opt-in via 'swiftlog config set --web-url'.
+func runURL(webURL, runID string) string {
+	if webURL == "" {
+		return ""
+	}
+	return strings.TrimRight(webURL, "/") + "/runs/" + runID
+}
+
+// runStatus classifies exitCode the same way the server's CompleteRun does
+// (see backend/internal/ingestor/core.go), so the JSON result's "status"
+// field matches what ends up stored for the run.
+func runStatus(exitCode int) string {
+	switch {
+	case exitCode > signalExitCodeBase && exitCode <= signalExitCodeBase+64:
+		return "aborted"
+	case exitCode != 0:
+		return "failed"
+	default:
+		return "completed"
+	}
+}
+
+// writeRunResult emits result as a single JSON line. If resultFile is set,
+// it always goes there. Otherwise it goes to stdout, unless echoMode is
+// already writing the wrapped command's own stdout lines to our stdout - in
+// which case the JSON goes to stderr instead, so a CI pipeline parsing
+// stdout as JSON never sees a command's own output line by accident.
+func writeRunResult(result runResult, resultFile, echoMode string) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	if resultFile != "" {
+		if err := os.WriteFile(resultFile, append(data, '\n'), 0600); err != nil {
+			return fmt.Errorf("failed to write --result-file %s: %w", resultFile, err)
+		}
+		return nil
+	}
+
+	out := os.Stdout
+	if shouldEcho(echoMode, false) {
+		out = os.Stderr
+	}
+	fmt.Fprintln(out, string(data))
+	return nil
+}
+
+// resolveStripANSI reports whether stored log lines should have ANSI codes
+// stripped: an explicit --strip-ansi on this invocation wins, otherwise the
+// configured default applies.
+func resolveStripANSI(cmd *cobra.Command, cfg *config.Config) bool {
+	if cmd.Flags().Changed("strip-ansi") {
+		return stripANSI
+	}
+	return cfg.StripANSI
+}
+
+// resolveCompression reports whether the log stream sent to the server
+// should be gzip-compressed: an explicit --no-compress on this invocation
+// wins, otherwise the configured default applies. Compression is on by
+// default (see config.Config.DisableCompression).
+func resolveCompression(cmd *cobra.Command, cfg *config.Config) bool {
+	if cmd.Flags().Changed("no-compress") {
+		return !runNoCompress
+	}
+	return !cfg.DisableCompression
+}
+
+// buildActiveRedactor builds a redact.Redactor from cfg's custom patterns,
+// or returns nil if --no-redact was passed - redaction is on by default, so
+// callers use a nil check rather than a separate enabled flag.
+func buildActiveRedactor(cfg *config.Config) (*redact.Redactor, error) {
+	if noRedact {
+		return nil, nil
+	}
+	redactor, err := redact.New(cfg.Redact)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build redactor: %w", err)
+	}
+	return redactor, nil
+}
+
+// resolveProfile returns the --profile flag's value (a persistent flag
+// defined on rootCmd), or "" to defer to the config file's active profile
+// (config.Load's CurrentProfile/"default" fallback).
+func resolveProfile(cmd *cobra.Command) string {
+	profile, _ := cmd.Flags().GetString("profile")
+	return profile
+}
+
+// resolveProjectName returns the effective project name for this
+// invocation: an explicit --project flag wins, then SWIFTLOG_PROJECT/the
+// config file (already folded into cfg by config.Load's precedence), then
+// "default".
+func resolveProjectName(cmd *cobra.Command, cfg *config.Config) string {
+	if cmd.Flags().Changed("project") {
+		return projectName
+	}
+	if cfg.Project != "" {
+		return cfg.Project
+	}
+	return "default"
+}
+
+// resolveGroupName is resolveProjectName's counterpart for --group/
+// SWIFTLOG_GROUP.
+func resolveGroupName(cmd *cobra.Command, cfg *config.Config) string {
+	if cmd.Flags().Changed("group") {
+		return groupName
+	}
+	if cfg.Group != "" {
+		return cfg.Group
+	}
+	return "default"
+}
+
+// resolveEchoMode determines which streams streamOutput should echo to the
+// terminal. --quiet/--silent default echoing off so a CI job log isn't
+// duplicated, but an explicit --echo always wins over that default.
+func resolveEchoMode(cmd *cobra.Command) (string, error) {
+	echo := runEcho
+	if !cmd.Flags().Changed("echo") && (runQuiet || runSilent) {
+		echo = "none"
+	}
+	switch echo {
+	case "stdout", "stderr", "both", "none":
+		return echo, nil
+	default:
+		return "", fmt.Errorf("invalid --echo %q: must be one of stdout, stderr, both, none", echo)
+	}
+}
+
+// shouldEcho reports whether a line from the given pipe should be printed
+// locally under echoMode.
+func shouldEcho(echoMode string, isStderr bool) bool {
+	switch echoMode {
+	case "none":
+		return false
+	case "stdout":
+		return !isStderr
+	case "stderr":
+		return isStderr
+	default: // "both"
+		return true
+	}
+}
+
+// parseTags turns repeated --tag key=value flags into a map, so callers get
+// a clear error at the CLI instead of a confusing InvalidArgument from the
+// ingestor for a flag that was never in key=value form to begin with.
+func parseTags(tagFlags []string) (map[string]string, error) {
+	if len(tagFlags) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(tagFlags))
+	for _, tag := range tagFlags {
+		key, value, found := strings.Cut(tag, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid --tag %q: must be in key=value form", tag)
+		}
+		tags[key] = value
+	}
+	return tags, nil
 }
 
 func runRun(cmd *cobra.Command, args []string) error {
@@ -60,7 +390,7 @@ func runRun(cmd *cobra.Command, args []string) error {
 	}
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.Load(resolveProfile(cmd))
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w (run 'swiftlog config set --token YOUR_TOKEN' first)", err)
 	}
@@ -77,142 +407,1196 @@ func runRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no API token configured. Run 'swiftlog config set --token YOUR_TOKEN' first")
 	}
 
-	// Set defaults
-	if projectName == "" {
-		projectName = "default"
+	tags, err := parseTags(runTags)
+	if err != nil {
+		return err
 	}
-	if groupName == "" {
-		groupName = "default"
+	if runPTY {
+		// stdout/stderr separation is lost once the child is attached to a
+		// pty (everything comes back through the one master fd) - recorded
+		// as run metadata so a reader of the stored logs isn't left
+		// wondering why every line looks like stdout.
+		if tags == nil {
+			tags = make(map[string]string, 1)
+		}
+		tags["pty"] = "true"
 	}
 
+	echoMode, err := resolveEchoMode(cmd)
+	if err != nil {
+		return err
+	}
+	outputMode, err := resolveOutputMode(cmd)
+	if err != nil {
+		return err
+	}
+	stripANSIEnabled := resolveStripANSI(cmd, cfg)
+
+	activeRedactor, err := buildActiveRedactor(cfg)
+	if err != nil {
+		return err
+	}
+
+	sampleEvery, err := parseSampleRate(runSample)
+	if err != nil {
+		return err
+	}
+
+	multiline, err := parseMultilineMode(runMultiline)
+	if err != nil {
+		return err
+	}
+
+	if runBinary != "repair" && runBinary != "skip" {
+		return fmt.Errorf(`invalid --binary %q: must be "repair" or "skip"`, runBinary)
+	}
+
+	if runRetry < 0 {
+		return fmt.Errorf("invalid --retry %d: must be >= 0", runRetry)
+	}
+	maxAttempts := runRetry + 1
+
+	hostname, _ := os.Hostname()
+	workingDir, _ := os.Getwd()
+	commandLine := strings.Join(commandArgs, " ")
+
+	projectName = resolveProjectName(cmd, cfg)
+	groupName = resolveGroupName(cmd, cfg)
+
 	// Create gRPC client
 	grpcClient, err := client.NewClient(&client.Config{
-		ServerAddr: cfg.ServerAddr,
-		Token:      cfg.Token,
+		ServerAddr:         cfg.ServerAddr,
+		Token:              cfg.Token,
+		TLS:                cfg.TLS,
+		CACertPath:         cfg.CACert,
+		ClientCertPath:     cfg.ClientCert,
+		ClientKeyPath:      cfg.ClientKey,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		Compression:        resolveCompression(cmd, cfg),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to connect to server: %w", err)
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to server: %v\n", err)
+		os.Exit(connectFailureExitCode)
 	}
 	defer grpcClient.Close()
 
-	// Start streaming session
 	ctx := context.Background()
-	session, err := grpcClient.StartStream(ctx, projectName, groupName)
+
+	// Replay anything a previous run couldn't deliver before starting a new
+	// one, so spooled logs don't just pile up until someone remembers to
+	// run 'swiftlog flush'.
+	if err := flushSpool(ctx, grpcClient, false); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush spooled logs: %v\n", err)
+	}
+
+	// Catch SIGINT/SIGTERM ourselves instead of letting Go's default
+	// handling kill the CLI outright: executeCommand forwards whatever it
+	// receives here to the child's process group and waits for it to exit,
+	// so the run gets a completion (and RunStatusAborted, once the exit
+	// code lands in the 128+signal range) instead of being orphaned and
+	// left "running" forever.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	// retryGroupID ties every attempt of a --retry run together via a shared
+	// tag, so filtering by it in the UI shows the whole sequence; a plain
+	// (non-retrying) run never sets attempt/retry_group tags at all, so its
+	// behavior is unchanged from before --retry existed.
+	var retryGroupID string
+	if maxAttempts > 1 {
+		retryGroupID = uuid.NewString()
+	}
+
+	var attempts []runAttemptSummary
+	var last runAttemptResult
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		attemptTags := tags
+		if maxAttempts > 1 {
+			attemptTags = make(map[string]string, len(tags)+2)
+			for k, v := range tags {
+				attemptTags[k] = v
+			}
+			attemptTags["attempt"] = strconv.Itoa(attempt)
+			attemptTags["retry_group"] = retryGroupID
+		}
+
+		guard := newFloodGuard(runMaxLinesPerSec, sampleEvery)
+		result, err := runAttempt(ctx, cfg, grpcClient, attemptTags, commandArgs, hostname, workingDir, commandLine, echoMode, outputMode, stripANSIEnabled, activeRedactor, guard, multiline, runBinary, sigCh, attempt, maxAttempts)
+		if err != nil {
+			return err
+		}
+
+		attempts = append(attempts, runAttemptSummary{
+			Attempt:  attempt,
+			RunID:    result.runID,
+			ExitCode: result.exitCode,
+			Status:   runStatus(result.exitCode),
+		})
+		last = result
+
+		if result.exitCode == 0 {
+			break
+		}
+		if attempt < maxAttempts {
+			if !runSilent && outputMode != "json" {
+				fmt.Printf("🔁 Attempt %d/%d failed (Exit Code: %d), retrying in %s...\n", attempt, maxAttempts, result.exitCode, runRetryDelay)
+			}
+			time.Sleep(runRetryDelay)
+		}
+	}
+
+	if runAnalyze {
+		requestAnalysis(cfg, last.runID, runWaitReport)
+	}
+
+	// Print summary
+	if !runSilent && outputMode != "json" {
+		fmt.Println(strings.Repeat("-", 60))
+		switch {
+		case last.timedOut:
+			fmt.Printf("⏱️  Run killed after exceeding --timeout %s (Exit Code: %d)\n", runTimeout, last.exitCode)
+		case last.exitCode == 0:
+			fmt.Printf("✅ Run completed (Exit Code: %d)\n", last.exitCode)
+		default:
+			if sig, ok := signalFromExitCode(last.exitCode); ok {
+				fmt.Printf("🛑 Run aborted (signal %d, Exit Code: %d)\n", sig, last.exitCode)
+			} else {
+				fmt.Printf("❌ Run failed (Exit Code: %d)\n", last.exitCode)
+			}
+		}
+		fmt.Printf("Logs saved to Project[%s], Group[%s]\n", projectName, groupName)
+		if len(attempts) > 1 {
+			fmt.Println("Attempts:")
+			for _, a := range attempts {
+				fmt.Printf("  %d. Run ID: %s, Status: %s (Exit Code: %d)\n", a.Attempt, a.RunID, a.Status, a.ExitCode)
+			}
+		} else {
+			fmt.Printf("Run ID: %s\n", last.runID)
+		}
+		if runName != "" {
+			fmt.Printf("Name: %s\n", runName)
+		}
+		if last.dropped > 0 {
+			fmt.Printf("⚠️  Dropped %d line(s) due to rate limiting/sampling\n", last.dropped)
+		}
+		if runPrintURL && last.url != "" {
+			fmt.Printf("URL: %s\n", last.url)
+		}
+	}
+
+	if outputMode == "json" {
+		result := runResult{
+			RunID:     last.runID,
+			Project:   projectName,
+			Group:     groupName,
+			Name:      runName,
+			ExitCode:  last.exitCode,
+			Status:    runStatus(last.exitCode),
+			StartTime: last.startTime.UTC().Format(time.RFC3339),
+			EndTime:   last.endTime.UTC().Format(time.RFC3339),
+			LinesSent: atomic.LoadInt64(&last.linesSent),
+		}
+		if runPrintURL {
+			result.URL = last.url
+		}
+		if len(attempts) > 1 {
+			result.Attempts = attempts
+		}
+		if err := writeRunResult(result, runResultFile, echoMode); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	// Exit with the last attempt's code, unless --exit-zero asked us to
+	// always report success to our own caller regardless - the server
+	// already has the real exit code from SendCompletion above.
+	if runExitZero {
+		os.Exit(0)
+	}
+	os.Exit(last.exitCode)
+	return nil
+}
+
+// runAttemptResult holds one --retry attempt's outcome: enough for the
+// retry loop's decision (exitCode == 0 stops early) and for the final
+// summary/JSON result once every attempt has run.
+type runAttemptResult struct {
+	runID     string
+	exitCode  int
+	timedOut  bool
+	dropped   int64
+	linesSent int64
+	startTime time.Time
+	endTime   time.Time
+	url       string
+}
+
+// runAttempt starts one fresh stream and runs commandArgs against it,
+// reporting completion the same way a non-retrying `swiftlog run` always
+// has. It's what runRun used to do inline before --retry needed to repeat
+// the whole sequence per attempt; attempt/maxAttempts only affect the
+// banner ("attempt 2/3"), so a single-attempt run's output is unchanged.
+func runAttempt(ctx context.Context, cfg *config.Config, grpcClient *client.Client, tags map[string]string, commandArgs []string, hostname, workingDir, commandLine, echoMode, outputMode string, stripANSIEnabled bool, activeRedactor *redact.Redactor, guard *floodGuard, multiline *multilineMode, binaryMode string, sigCh <-chan os.Signal, attempt, maxAttempts int) (runAttemptResult, error) {
+	session, err := grpcClient.StartStream(ctx, projectName, groupName, tags, hostname, workingDir, commandLine, runName)
 	if err != nil {
-		return fmt.Errorf("failed to start stream: %w", err)
+		fmt.Fprintf(os.Stderr, "Error: failed to start stream: %v\n", err)
+		os.Exit(connectFailureExitCode)
 	}
 	defer session.Close()
 
-	fmt.Printf("📝 Streaming logs to SwiftLog (Run ID: %s)\n", session.GetRunID())
-	fmt.Printf("Project: %s, Group: %s\n", projectName, groupName)
-	fmt.Println(strings.Repeat("-", 60))
+	// Written before the child command starts, so even a run that crashes
+	// mid-execution (or a CLI that gets killed outright) leaves a reference
+	// to the run behind for whatever CI step reads this file next. Each
+	// retry attempt overwrites it, so it always points at the
+	// currently-running (or most recently finished) attempt.
+	if runIDFile != "" {
+		if err := writeRunIDFile(runIDFile, session.GetRunID()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	url := runURL(cfg.WebURL, session.GetRunID())
+
+	if !runQuiet && outputMode != "json" {
+		if maxAttempts > 1 {
+			fmt.Printf("📝 Streaming logs to SwiftLog (Run ID: %s, attempt %d/%d)\n", session.GetRunID(), attempt, maxAttempts)
+		} else {
+			fmt.Printf("📝 Streaming logs to SwiftLog (Run ID: %s)\n", session.GetRunID())
+		}
+		if runName != "" {
+			fmt.Printf("Name: %s\n", runName)
+		}
+		fmt.Printf("Project: %s, Group: %s\n", projectName, groupName)
+		if runPrintURL {
+			if url != "" {
+				fmt.Printf("URL: %s\n", url)
+			} else {
+				fmt.Fprintln(os.Stderr, "Warning: --print-url given but no web_url configured (see 'swiftlog config set --web-url')")
+			}
+		}
+		fmt.Println(strings.Repeat("-", 60))
+	}
+
+	fallback := newSpoolFallback(session.GetRunID(), projectName, groupName)
+	defer fallback.close()
 
 	// Execute the command
-	exitCode, err := executeCommand(commandArgs, session)
+	startTime := time.Now()
+	var linesSent int64
+	exitCode, timedOut, dropped, err := executeCommand(commandArgs, session, fallback, echoMode, runMaxLineLen, stripANSIEnabled, activeRedactor, runTimeout, runKillAfter, sigCh, &linesSent, guard, multiline, runMultilineFlush, binaryMode, runPTY)
+	endTime := time.Now()
 	if err != nil {
-		return err
+		return runAttemptResult{}, err
+	}
+
+	// Report how much throttling cost, via a synthetic server-side log line
+	// (mirroring the timeout line above) plus the human summary below - kept
+	// out of the -o json schema since only the summary was asked for.
+	if dropped > 0 {
+		dropLine := fmt.Sprintf("[swiftlog] rate limiting dropped %d line(s) (--max-lines-per-sec %d, --sample %q)", dropped, runMaxLinesPerSec, runSample)
+		if err := session.SendLogLine(true, dropLine); err != nil {
+			fallback.line(true, dropLine)
+		}
+	}
+
+	// Flush any lines still buffered for batching before completion, so
+	// they aren't left unsent behind the completion message.
+	if err := session.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush buffered log lines: %v\n", err)
 	}
 
-	// Send completion message
+	// Send completion message. If the server is still unreachable, spool it
+	// rather than failing the command outright — the command already ran to
+	// completion and its exit code shouldn't be lost.
 	if err := session.SendCompletion(int32(exitCode)); err != nil {
-		return fmt.Errorf("failed to send completion: %w", err)
+		fmt.Fprintf(os.Stderr, "Warning: failed to send completion: %v\n", err)
+		fallback.completion(int32(exitCode))
 	}
 
 	// Wait for server acknowledgment
-	session.WaitForCompletion()
-
-	// Print summary
-	fmt.Println(strings.Repeat("-", 60))
-	if exitCode == 0 {
-		fmt.Printf("✅ Run completed (Exit Code: %d)\n", exitCode)
-	} else {
-		fmt.Printf("❌ Run failed (Exit Code: %d)\n", exitCode)
+	if err := session.WaitForCompletion(runCompletionTimeout); errors.Is(err, client.ErrCompletionTimeout) {
+		fmt.Fprintf(os.Stderr, "Warning: did not receive server acknowledgment within %s\n", runCompletionTimeout)
 	}
-	fmt.Printf("Logs saved to Project[%s], Group[%s]\n", projectName, groupName)
-	fmt.Printf("Run ID: %s\n", session.GetRunID())
 
-	// Exit with the same code as the command
-	os.Exit(exitCode)
-	return nil
+	return runAttemptResult{
+		runID:     session.GetRunID(),
+		exitCode:  exitCode,
+		timedOut:  timedOut,
+		dropped:   dropped,
+		linesSent: atomic.LoadInt64(&linesSent),
+		startTime: startTime,
+		endTime:   endTime,
+		url:       url,
+	}, nil
 }
 
-func executeCommand(args []string, session *client.StreamSession) (int, error) {
-	// Create command
-	command := exec.Command(args[0], args[1:]...)
+// requestAnalysis POSTs /runs/:id/analyze so a run gets an AI report even
+// when ai_auto_analyze is disabled for the user/project. Failures are
+// reported but don't affect the run's own exit code - the command already
+// ran to completion by the time this is called.
+func requestAnalysis(cfg *config.Config, runID string, wait bool) {
+	apiAddr := strings.TrimRight(cfg.APIAddr, "/")
+	httpClient := &http.Client{}
 
-	// Create pipes for stdout and stderr
-	stdoutPipe, err := command.StdoutPipe()
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/v1/runs/%s/analyze", apiAddr, url.PathEscape(runID)), bytes.NewReader([]byte("{}")))
 	if err != nil {
-		return 1, fmt.Errorf("failed to create stdout pipe: %w", err)
+		fmt.Fprintf(os.Stderr, "Warning: failed to build analyze request: %v\n", err)
+		return
 	}
+	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	req.Header.Set("Content-Type", "application/json")
 
-	stderrPipe, err := command.StderrPipe()
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return 1, fmt.Errorf("failed to create stderr pipe: %w", err)
+		fmt.Fprintf(os.Stderr, "Warning: failed to request AI analysis: %v\n", err)
+		return
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err := statusToError(resp.StatusCode, body, runID); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to request AI analysis: %v\n", err)
+		return
+	}
+
+	fmt.Println("AI analysis queued")
+	if !wait {
+		return
+	}
+
+	deadline := time.Now().Add(5 * time.Minute)
+	for {
+		run, err := fetchRun(httpClient, apiAddr, cfg.Token, runID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to check analysis status: %v\n", err)
+			return
+		}
+
+		switch run.AIStatus {
+		case "completed", "partial":
+			if run.AIReport != "" {
+				fmt.Println(run.AIReport)
+			}
+			return
+		case "failed", "cancelled":
+			fmt.Fprintf(os.Stderr, "Warning: AI analysis %s for run %s\n", run.AIStatus, runID)
+			return
+		default:
+			if time.Now().After(deadline) {
+				fmt.Fprintf(os.Stderr, "Warning: timed out waiting for AI analysis on run %s\n", runID)
+				return
+			}
+			time.Sleep(3 * time.Second)
+		}
+	}
+}
+
+// signalExitCodeBase is added to a signal number to form the exit code a
+// command reports when killed by that signal, matching the shell
+// convention ($? == 128+signal) so `swiftlog run` mirrors what running the
+// command directly would have reported.
+const signalExitCodeBase = 128
+
+// signalFromExitCode extracts the signal number from a 128+signal exit
+// code. ok is false for a normal (non-signal) exit code.
+func signalFromExitCode(exitCode int) (sig int, ok bool) {
+	if exitCode > signalExitCodeBase && exitCode < signalExitCodeBase+65 {
+		return exitCode - signalExitCodeBase, true
+	}
+	return 0, false
+}
+
+// pythonTracebackContinuation matches lines that continue a Python
+// traceback: indented frame/source lines, and the final "SomeError: ..."
+// line, which isn't indented but still belongs to the traceback that
+// precedes it.
+var pythonTracebackContinuation = regexp.MustCompile(`^(\s+|[A-Za-z_][\w.]*(Error|Exception|Warning):)`)
+
+// javaStackTraceContinuation matches lines that continue a Java stack
+// trace: "at ...", "Caused by: ...", and the "... N more" frame-elision
+// marker.
+var javaStackTraceContinuation = regexp.MustCompile(`^(\s*at\s|\s*Caused by:\s|\s*\.\.\.\s*\d+\s+more\b)`)
+
+// multilineMode decides, for a given --multiline setting, whether a line
+// continues the record before it rather than starting a new one.
+type multilineMode struct {
+	isContinuation func(line string) bool
+}
+
+// parseMultilineMode parses --multiline's value: "python", "java", or
+// "regex:<pattern>" where any line matching pattern is a continuation. An
+// empty spec means multiline merging is off.
+func parseMultilineMode(spec string) (*multilineMode, error) {
+	switch {
+	case spec == "":
+		return nil, nil
+	case spec == "python":
+		return &multilineMode{isContinuation: pythonTracebackContinuation.MatchString}, nil
+	case spec == "java":
+		return &multilineMode{isContinuation: javaStackTraceContinuation.MatchString}, nil
+	case strings.HasPrefix(spec, "regex:"):
+		pattern := strings.TrimPrefix(spec, "regex:")
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --multiline regex %q: %w", pattern, err)
+		}
+		return &multilineMode{isContinuation: re.MatchString}, nil
+	default:
+		return nil, fmt.Errorf(`invalid --multiline %q: must be "python", "java", or "regex:<pattern>"`, spec)
+	}
+}
+
+// multilineMerger buffers physical lines that mode classifies as
+// continuations of the record before them, joining them with "\n" into a
+// single stored record once a non-continuation line arrives or flushDelay
+// passes with no new input - the latter is what flushes a trace that was
+// the last thing the command ever printed, since there's no following
+// non-continuation line to trigger the join. Local echo doesn't go through
+// this at all (see streamOutput) - only what gets stored/sent is merged.
+type multilineMerger struct {
+	mode       *multilineMode
+	flushDelay time.Duration
+	emit       func(record string)
+
+	mu    sync.Mutex
+	buf   []string
+	timer *time.Timer
+}
+
+func newMultilineMerger(mode *multilineMode, flushDelay time.Duration, emit func(string)) *multilineMerger {
+	return &multilineMerger{mode: mode, flushDelay: flushDelay, emit: emit}
+}
+
+// add processes one physical line: an empty buffer always starts a new
+// record; otherwise a continuation line joins it and anything else flushes
+// what's buffered first.
+func (m *multilineMerger) add(line string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.buf) > 0 && !m.mode.isContinuation(line) {
+		m.flushLocked()
+	}
+	m.buf = append(m.buf, line)
+
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.timer = time.AfterFunc(m.flushDelay, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.flushLocked()
+	})
+}
+
+func (m *multilineMerger) flushLocked() {
+	if len(m.buf) == 0 {
+		return
+	}
+	record := strings.Join(m.buf, "\n")
+	m.buf = nil
+	m.emit(record)
+}
+
+// close flushes whatever's still buffered, for when the underlying stream
+// ends mid-record.
+func (m *multilineMerger) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.flushLocked()
+}
+
+// splitOversizedRecord breaks record into maxLineLen-sized chunks (all but
+// the last suffixed with lineContinuationSuffix), the same convention
+// readLines uses for an overlong physical line. A merged multiline record
+// can exceed maxLineLen even though every physical line that fed it didn't,
+// since joining adds up.
+func splitOversizedRecord(record string, maxLineLen int) []string {
+	if maxLineLen <= 0 {
+		maxLineLen = defaultMaxLineLength
+	}
+	if len(record) <= maxLineLen {
+		return []string{record}
+	}
+
+	var chunks []string
+	for len(record) > maxLineLen {
+		chunks = append(chunks, record[:maxLineLen]+lineContinuationSuffix)
+		record = record[maxLineLen:]
+	}
+	return append(chunks, record)
+}
+
+// floodGuardTailSize bounds how many dropped stdout lines a floodGuard keeps
+// around to resend once the command finishes, so a burst that trips the
+// limiter doesn't lose everything - just everything beyond a reasonable
+// "what just happened" window.
+const floodGuardTailSize = 200
+
+// parseSampleRate parses --sample's "1/N" form into N, the "keep every Nth
+// line" divisor. An empty string means "disabled" (0, nil); anything else
+// that isn't exactly "1/<positive integer>" is an error, since a sample rate
+// user meant something like "1/10" and mistyped is more likely than one who
+// meant some other fraction.
+func parseSampleRate(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	num, denom, ok := strings.Cut(s, "/")
+	if !ok || num != "1" {
+		return 0, fmt.Errorf("invalid --sample %q: must be in \"1/N\" form (e.g. 1/100)", s)
+	}
+	n, err := strconv.Atoi(denom)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid --sample %q: must be in \"1/N\" form (e.g. 1/100)", s)
+	}
+	return n, nil
+}
+
+// tokenBucket is a classic token-bucket rate limiter: tokens refill
+// continuously at ratePerSec and allow() consumes one, capping accumulation
+// at one second's worth so a long idle period can't "bank" a burst larger
+// than the configured rate.
+type tokenBucket struct {
+	mu         sync.Mutex
+	ratePerSec float64
+	tokens     float64
+	last       time.Time
+}
+
+func newTokenBucket(ratePerSec int) *tokenBucket {
+	return &tokenBucket{
+		ratePerSec: float64(ratePerSec),
+		tokens:     float64(ratePerSec),
+		last:       time.Now(),
+	}
+}
+
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.ratePerSec
+	if b.tokens > b.ratePerSec {
+		b.tokens = b.ratePerSec
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// floodGuard throttles the stdout lines a chatty command can push to the
+// server: a tokenBucket admits up to maxLinesPerSec of them outright, and
+// once that's exhausted, --sample keeps only every Nth line instead of
+// dropping everything (sampling is purely a fallback - with no
+// --max-lines-per-sec there's nothing to exceed, so --sample alone is a
+// no-op). Every line it drops is still remembered, up to floodGuardTailSize
+// of the most recent, so flushTail can resend them once the command
+// finishes - stderr is never passed through this at all (see streamOutput).
+type floodGuard struct {
+	mu          sync.Mutex
+	bucket      *tokenBucket
+	sampleEvery int
+	sampleCount int
+	tail        []string
+	dropped     int64
+}
+
+// newFloodGuard returns nil when maxLinesPerSec <= 0, so the zero-config
+// default path (no limiting) costs nothing beyond a nil check.
+func newFloodGuard(maxLinesPerSec, sampleEvery int) *floodGuard {
+	if maxLinesPerSec <= 0 {
+		return nil
+	}
+	return &floodGuard{
+		bucket:      newTokenBucket(maxLinesPerSec),
+		sampleEvery: sampleEvery,
+	}
+}
+
+// admit reports whether stored should be sent now. Lines it declines to
+// admit are appended to the tail buffer (evicting the oldest once full) so
+// flushTail can still recover them later.
+func (g *floodGuard) admit(stored string) bool {
+	if g.bucket.allow() {
+		return true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.sampleEvery > 0 {
+		g.sampleCount++
+		if g.sampleCount%g.sampleEvery == 0 {
+			return true
+		}
 	}
 
-	// Start the command
-	if err := command.Start(); err != nil {
-		return 1, fmt.Errorf("failed to start command: %w", err)
+	g.dropped++
+	g.tail = append(g.tail, stored)
+	if len(g.tail) > floodGuardTailSize {
+		g.tail = g.tail[len(g.tail)-floodGuardTailSize:]
+	}
+	return false
+}
+
+// flushTail best-effort resends the last floodGuardTailSize dropped lines
+// once the command has finished, and returns the total number of lines ever
+// dropped (not reduced by however many of them flushTail managed to
+// recover - it's meant to answer "how much did throttling actually cost",
+// not "how much is unrecoverable").
+func (g *floodGuard) flushTail(session *client.StreamSession, fallback *spoolFallback) int64 {
+	g.mu.Lock()
+	tail := g.tail
+	dropped := g.dropped
+	g.mu.Unlock()
+
+	for _, line := range tail {
+		if err := session.SendLogLine(false, line); err != nil {
+			fallback.line(false, line)
+		}
 	}
 
-	// Channel to signal completion
+	return dropped
+}
+
+// executeCommand runs args, streaming its output through streamOutput, and
+// returns its exit code. If timeout is positive and the command is still
+// running when it elapses, the command's whole process group is sent
+// SIGTERM, escalating to SIGKILL after killAfter if it hasn't exited by
+// then; the returned timedOut is true in that case and exitCode is
+// timeoutExitCode rather than whatever the signal handling left behind.
+//
+// sigCh delivers signals the CLI itself received (see runRun's
+// signal.Notify) - each one is forwarded to the child's process group
+// rather than killing the CLI outright, so the wrapped command gets a
+// chance to shut down and the run still gets a completion instead of being
+// orphaned mid-stream.
+func executeCommand(args []string, session *client.StreamSession, fallback *spoolFallback, echoMode string, maxLineLen int, stripANSIEnabled bool, redactor *redact.Redactor, timeout, killAfter time.Duration, sigCh <-chan os.Signal, linesSent *int64, guard *floodGuard, multiline *multilineMode, multilineFlush time.Duration, binaryMode string, usePTY bool) (int, bool, int64, error) {
+	// Create command
+	command := exec.Command(args[0], args[1:]...)
+
+	// Channel to signal completion; the pty path only ever streams one
+	// combined output, the plain-pipe path streams stdout and stderr
+	// separately, so how many sends to wait for below depends on which.
 	done := make(chan struct{})
+	streamCount := 2
+
+	var err error
+	var ptmx *os.File
+	if usePTY {
+		// pty.Start allocates the pseudo-terminal, wires it up as the
+		// child's stdin/stdout/stderr, sets Setsid+Setctty on its own (so
+		// the child becomes its own session/process-group leader - the
+		// negative-pid signaling below still works the same as with
+		// Setpgid), and starts it.
+		ptmx, err = pty.Start(command)
+		if err != nil {
+			return 1, false, 0, fmt.Errorf("failed to allocate pty: %w", err)
+		}
+		defer ptmx.Close()
+
+		// Match the child's window to ours, and keep it matched as ours
+		// changes (platform-specific: SIGWINCH on Unix, a no-op on Windows -
+		// see watchTerminalResize), so full-screen and progress-bar tools
+		// that query the terminal size render correctly instead of assuming
+		// 80x24.
+		stopResizeWatch := watchTerminalResize(ptmx)
+		defer stopResizeWatch()
+
+		streamCount = 1
+		go streamPTYOutput(ptmx, session, fallback, done, echoMode, maxLineLen, stripANSIEnabled, redactor, linesSent, guard, binaryMode)
+	} else {
+		// Run in its own process group so a timeout can signal the whole
+		// tree (e.g. a shell script's children), not just the immediate
+		// child. See setProcessGroup for the Windows caveat.
+		setProcessGroup(command)
+
+		// Create pipes for stdout and stderr
+		stdoutPipe, err := command.StdoutPipe()
+		if err != nil {
+			return 1, false, 0, fmt.Errorf("failed to create stdout pipe: %w", err)
+		}
+
+		stderrPipe, err := command.StderrPipe()
+		if err != nil {
+			return 1, false, 0, fmt.Errorf("failed to create stderr pipe: %w", err)
+		}
+
+		// Start the command
+		if err := command.Start(); err != nil {
+			return 1, false, 0, fmt.Errorf("failed to start command: %w", err)
+		}
+
+		// Stream stdout
+		go streamOutput(stdoutPipe, false, session, fallback, done, echoMode, maxLineLen, stripANSIEnabled, redactor, linesSent, guard, multiline, multilineFlush, binaryMode)
+
+		// Stream stderr - never passed guard, so stderr is never rate-limited
+		// or sampled even under a flood on stdout.
+		go streamOutput(stderrPipe, true, session, fallback, done, echoMode, maxLineLen, stripANSIEnabled, redactor, linesSent, nil, multiline, multilineFlush, binaryMode)
+	}
+
+	// Forward signals the CLI receives to the child's process group until
+	// the command exits, so Ctrl-C stops the wrapped command instead of
+	// just orphaning it. Since command.Process.Pid is also the process
+	// group id (Setpgid or, in --pty mode, Setsid both make the child its
+	// own group leader), a negative-pid kill reaches the whole group either
+	// way, and command.ProcessState below reports the resulting 128+signal
+	// exit code identically - so Ctrl-C still marks a --pty run aborted.
+	forwardDone := make(chan struct{})
+	defer close(forwardDone)
+	go func() {
+		for {
+			select {
+			case sig := <-sigCh:
+				if unixSig, ok := sig.(syscall.Signal); ok {
+					killProcessGroup(command.Process.Pid, unixSig)
+				}
+			case <-forwardDone:
+				return
+			}
+		}
+	}()
 
-	// Stream stdout
-	go streamOutput(stdoutPipe, false, session, done)
+	var timeoutMu sync.Mutex
+	var timedOut bool
+	var killTimer *time.Timer
 
-	// Stream stderr
-	go streamOutput(stderrPipe, true, session, done)
+	var termTimer *time.Timer
+	if timeout > 0 {
+		termTimer = time.AfterFunc(timeout, func() {
+			timeoutMu.Lock()
+			timedOut = true
+			timeoutMu.Unlock()
+
+			killProcessGroup(command.Process.Pid, syscall.SIGTERM)
+
+			timeoutMu.Lock()
+			killTimer = time.AfterFunc(killAfter, func() {
+				killProcessGroup(command.Process.Pid, syscall.SIGKILL)
+			})
+			timeoutMu.Unlock()
+		})
+	}
 
 	// Wait for command to complete
 	err = command.Wait()
 
-	// Wait for both streams to finish
-	<-done
-	<-done
+	if termTimer != nil {
+		termTimer.Stop()
+	}
+	timeoutMu.Lock()
+	if killTimer != nil {
+		killTimer.Stop()
+	}
+	timedOutResult := timedOut
+	timeoutMu.Unlock()
+
+	// Wait for every output stream to finish (one for --pty's combined
+	// output, two otherwise) - the underlying reads return once the child's
+	// pipes/pty are closed, which happens as part of it exiting above, so
+	// these don't block forever even when the timeout fired.
+	for i := 0; i < streamCount; i++ {
+		<-done
+	}
+
+	var dropped int64
+	if guard != nil {
+		dropped = guard.flushTail(session, fallback)
+	}
 
-	// Get exit code
+	if timedOutResult {
+		timeoutLine := fmt.Sprintf("[swiftlog] command timed out after %s, sent SIGTERM (SIGKILL after %s grace period)", timeout, killAfter)
+		if shouldEcho(echoMode, true) {
+			fmt.Fprintln(os.Stderr, timeoutLine)
+		}
+		if err := session.SendLogLine(true, timeoutLine); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to send log line: %v\n", err)
+			fallback.line(true, timeoutLine)
+		}
+		atomic.AddInt64(linesSent, 1)
+		return timeoutExitCode, true, dropped, nil
+	}
+
+	// Get exit code from command.ProcessState directly rather than type-
+	// asserting err into *exec.ExitError - ProcessState is populated by
+	// Wait() whether the command exited cleanly, non-zero, or by signal, so
+	// this covers all three the same way instead of only the non-zero path.
+	// exitCodeFromState carries the signal-death case (Unix-only) behind a
+	// build tag; on Windows there's no signal delivery, so it's always just
+	// ProcessState.ExitCode().
 	exitCode := 0
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-				exitCode = status.ExitStatus()
+	if command.ProcessState != nil {
+		exitCode = exitCodeFromState(command.ProcessState)
+	} else if err != nil {
+		return 1, false, dropped, fmt.Errorf("command execution error: %w", err)
+	}
+
+	return exitCode, false, dropped, nil
+}
+
+func streamOutput(pipe io.ReadCloser, isStderr bool, session *client.StreamSession, fallback *spoolFallback, done chan struct{}, echoMode string, maxLineLen int, stripANSIEnabled bool, redactor *redact.Redactor, linesSent *int64, guard *floodGuard, multiline *multilineMode, multilineFlush time.Duration, binaryMode string) {
+	defer func() { done <- struct{}{} }()
+
+	// sendRecord ships one already-processed (stripped/redacted) record -
+	// a single line normally, or a joined multiline record when merging is
+	// on - applying flood protection and the size cap, then spooling on
+	// send failure exactly like a single line would.
+	sendRecord := func(stored string) {
+		for _, chunk := range splitOversizedRecord(stored, maxLineLen) {
+			// Flood protection only ever applies to stdout (guard is
+			// always nil for the stderr goroutine) - a dropped chunk is
+			// remembered by the guard for flushTail rather than sent or
+			// spooled here.
+			if guard != nil && !guard.admit(chunk) {
+				continue
+			}
+
+			if err := session.SendLogLine(isStderr, chunk); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to send log line: %v\n", err)
+				fallback.line(isStderr, chunk)
+			}
+			atomic.AddInt64(linesSent, 1)
+		}
+	}
+
+	var merger *multilineMerger
+	if multiline != nil {
+		merger = newMultilineMerger(multiline, multilineFlush, sendRecord)
+	}
+
+	err := readLines(pipe, maxLineLen, func(line string) {
+		// The local echo keeps the original (possibly colored, possibly
+		// non-UTF-8) line so a human watching the terminal sees exactly
+		// what the command printed. sanitizeOutputLine only ever touches
+		// what's stored - same convention as ANSI stripping.
+		echoLine := line
+		stored := sanitizeOutputLine(line, binaryMode)
+		if stripANSIEnabled {
+			stored = ansi.Strip(stored)
+		}
+		if redactor != nil {
+			echoLine = redactor.Redact(echoLine)
+			stored = redactor.Redact(stored)
+		}
+
+		if shouldEcho(echoMode, isStderr) {
+			if isStderr {
+				fmt.Fprintln(os.Stderr, echoLine)
 			} else {
-				exitCode = 1
+				fmt.Println(echoLine)
 			}
-		} else {
-			return 1, fmt.Errorf("command execution error: %w", err)
 		}
+
+		if merger != nil {
+			merger.add(stored)
+			return
+		}
+		sendRecord(stored)
+	})
+
+	if merger != nil {
+		merger.close()
+	}
+
+	if err != nil {
+		// Ignore "file already closed" errors which occur when the command finishes
+		if err != io.ErrClosedPipe && !strings.Contains(err.Error(), "file already closed") {
+			fmt.Fprintf(os.Stderr, "Error reading output: %v\n", err)
+		}
+	}
+}
+
+// ptyProgressSnapshotInterval bounds how often a \r-redrawing progress bar
+// in --pty mode gets a stored snapshot sent to the server, instead of one
+// per redraw (which can be hundreds a second) - the local echo still shows
+// every redraw in real time, only what's stored is throttled.
+const ptyProgressSnapshotInterval = 500 * time.Millisecond
+
+// progressCollapser accumulates \r-terminated progress-bar redraws and
+// emits at most one snapshot per interval, plus whatever's pending once a
+// genuine line arrives or the stream ends.
+type progressCollapser struct {
+	emit     func(string)
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending string
+	dirty   bool
+	timer   *time.Timer
+}
+
+func newProgressCollapser(interval time.Duration, emit func(string)) *progressCollapser {
+	return &progressCollapser{emit: emit, interval: interval}
+}
+
+// line flushes any pending progress snapshot (so the state right before a
+// genuine newline isn't lost), then emits line itself.
+func (p *progressCollapser) line(line string) {
+	p.mu.Lock()
+	pending, ok := p.flushLocked()
+	p.mu.Unlock()
+	if ok {
+		p.emit(pending)
 	}
+	p.emit(line)
+}
+
+// progress remembers line as the latest redraw, scheduling a timer to send
+// it if one isn't already running.
+func (p *progressCollapser) progress(line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending = line
+	p.dirty = true
+	if p.timer == nil {
+		p.timer = time.AfterFunc(p.interval, p.onTick)
+	}
+}
 
-	return exitCode, nil
+func (p *progressCollapser) onTick() {
+	p.mu.Lock()
+	if !p.dirty {
+		p.timer = nil
+		p.mu.Unlock()
+		return
+	}
+	line := p.pending
+	p.dirty = false
+	p.mu.Unlock()
+
+	p.emit(line)
+
+	p.mu.Lock()
+	p.timer = time.AfterFunc(p.interval, p.onTick)
+	p.mu.Unlock()
+}
+
+func (p *progressCollapser) flushLocked() (string, bool) {
+	if !p.dirty {
+		return "", false
+	}
+	line := p.pending
+	p.dirty = false
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	return line, true
+}
+
+// close flushes whatever's still buffered, for when the stream ends
+// mid-redraw.
+func (p *progressCollapser) close() {
+	p.mu.Lock()
+	pending, ok := p.flushLocked()
+	p.mu.Unlock()
+	if ok {
+		p.emit(pending)
+	}
+}
+
+// isBenignPTYReadError reports whether err is just how a pty master
+// reports "the child exited and closed its side" - on Linux that's an EIO
+// from the read, not a real error, once every reference to the slave end
+// is gone.
+func isBenignPTYReadError(err error) bool {
+	if err == io.ErrClosedPipe || strings.Contains(err.Error(), "file already closed") {
+		return true
+	}
+	var pathErr *fs.PathError
+	return errors.As(err, &pathErr) && pathErr.Err == syscall.EIO
 }
 
-func streamOutput(pipe io.ReadCloser, isStderr bool, session *client.StreamSession, done chan struct{}) {
+// streamPTYOutput reads the combined stdout/stderr stream from a pty
+// master, echoing every redraw live (so the local terminal sees a real
+// progress bar) while only sending the server a periodic snapshot of any
+// \r-redrawing sequence rather than one record per redraw. Everything is
+// reported as stdout (isStderr false in SendLogLine) since the pty has
+// already merged the two.
+func streamPTYOutput(ptmx io.Reader, session *client.StreamSession, fallback *spoolFallback, done chan struct{}, echoMode string, maxLineLen int, stripANSIEnabled bool, redactor *redact.Redactor, linesSent *int64, guard *floodGuard, binaryMode string) {
 	defer func() { done <- struct{}{} }()
 
-	scanner := bufio.NewScanner(pipe)
-	for scanner.Scan() {
-		line := scanner.Text()
+	sendRecord := func(stored string) {
+		for _, chunk := range splitOversizedRecord(stored, maxLineLen) {
+			if guard != nil && !guard.admit(chunk) {
+				continue
+			}
+			if err := session.SendLogLine(false, chunk); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to send log line: %v\n", err)
+				fallback.line(false, chunk)
+			}
+			atomic.AddInt64(linesSent, 1)
+		}
+	}
+	collapser := newProgressCollapser(ptyProgressSnapshotInterval, sendRecord)
+
+	err := readPTYFrames(ptmx, maxLineLen, func(frame string, isProgress bool) {
+		echoLine := frame
+		stored := sanitizeOutputLine(frame, binaryMode)
+		if stripANSIEnabled {
+			stored = ansi.Strip(stored)
+		}
+		if redactor != nil {
+			echoLine = redactor.Redact(echoLine)
+			stored = redactor.Redact(stored)
+		}
 
-		// Print to terminal
-		if isStderr {
-			fmt.Fprintln(os.Stderr, line)
+		if shouldEcho(echoMode, false) {
+			if isProgress {
+				fmt.Fprint(os.Stdout, "\r"+echoLine)
+			} else {
+				fmt.Println(echoLine)
+			}
+		}
+
+		if isProgress {
+			collapser.progress(stored)
 		} else {
-			fmt.Println(line)
+			collapser.line(stored)
 		}
+	})
 
-		// Send to SwiftLog server
-		if err := session.SendLogLine(isStderr, line); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to send log line: %v\n", err)
+	collapser.close()
+
+	if err != nil && !isBenignPTYReadError(err) {
+		fmt.Fprintf(os.Stderr, "Error reading output: %v\n", err)
+	}
+}
+
+// readPTYFrames reads r (a pty master) and calls emit once per frame: a
+// \n-terminated frame (isProgress false) is a genuine line, while a
+// \r-terminated one (isProgress true) is a progress-bar redraw that hasn't
+// been followed by a newline yet. maxLineLen bounds a single frame the same
+// way readLines bounds a physical line.
+func readPTYFrames(r io.Reader, maxLineLen int, emit func(frame string, isProgress bool)) error {
+	if maxLineLen <= 0 {
+		maxLineLen = defaultMaxLineLength
+	}
+
+	reader := bufio.NewReaderSize(r, maxLineLen)
+	for {
+		data, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			emit(string(data)+lineContinuationSuffix, false)
+			continue
+		}
+
+		if len(data) > 0 {
+			chunk := bytes.TrimSuffix(data, []byte("\n"))
+			// A chunk may contain several \r-terminated progress-bar
+			// redraws before the terminating \n (or none at all, if the
+			// command never finishes the line) - everything but the last
+			// segment is an already-superseded redraw.
+			segments := bytes.Split(chunk, []byte("\r"))
+			for _, seg := range segments[:len(segments)-1] {
+				if len(seg) > 0 {
+					emit(string(seg), true)
+				}
+			}
+			last := string(segments[len(segments)-1])
+			if err == io.EOF {
+				if last != "" {
+					emit(last, true)
+				}
+			} else {
+				emit(last, false)
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
 		}
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		// Ignore "file already closed" errors which occur when the command finishes
-		if err != io.ErrClosedPipe && !strings.Contains(err.Error(), "file already closed") {
-			fmt.Fprintf(os.Stderr, "Error reading output: %v\n", err)
+// lineContinuationSuffix marks a chunk that doesn't end the physical line it
+// came from, so a reader downstream (or a human) can tell a long line was
+// split rather than actually ending mid-token.
+const lineContinuationSuffix = " [line too long, continued]"
+
+// binaryContentRatio is the fraction of non-printable runes above which
+// sanitizeOutputLine treats an invalid-UTF-8 line as binary data under
+// --binary skip, rather than a line with a few garbled characters worth
+// repairing in place.
+const binaryContentRatio = 0.3
+
+// isMostlyBinary reports whether line looks like binary data rather than
+// mostly-text output with a few garbled bytes: more than binaryContentRatio
+// of its runes are invalid UTF-8 or non-printable (tabs are exempted, since
+// legitimate output uses them for alignment).
+func isMostlyBinary(line string) bool {
+	if line == "" {
+		return false
+	}
+	var nonPrintable, total int
+	for _, r := range line {
+		total++
+		if r == utf8.RuneError || (!unicode.IsPrint(r) && r != '\t') {
+			nonPrintable++
+		}
+	}
+	return float64(nonPrintable)/float64(total) > binaryContentRatio
+}
+
+// sanitizeOutputLine makes line safe to store as a gRPC string field:
+// legacy tools that emit Latin-1 or raw binary chunks otherwise produce
+// invalid UTF-8 that gets garbled or rejected downstream (Loki, the AI
+// push). Valid UTF-8 passes through unchanged. Invalid UTF-8 is repaired by
+// replacing each bad sequence with U+FFFD, unless mode is "skip" and the
+// line is mostly non-printable - then the whole line is replaced with a
+// marker instead of repairing what's likely not text at all.
+func sanitizeOutputLine(line, mode string) string {
+	if utf8.ValidString(line) {
+		return line
+	}
+	if mode == "skip" && isMostlyBinary(line) {
+		return fmt.Sprintf("[binary data: %d bytes omitted]", len(line))
+	}
+	return strings.ToValidUTF8(line, "\uFFFD")
+}
+
+// readLines reads r and calls emit once per physical line, without the
+// "bufio.Scanner: token too long" failure mode: a line longer than maxLineLen
+// bytes is split into multiple chunks (all but the last suffixed with
+// lineContinuationSuffix) instead of aborting the whole read. maxLineLen <= 0
+// falls back to defaultMaxLineLength.
+func readLines(r io.Reader, maxLineLen int, emit func(line string)) error {
+	if maxLineLen <= 0 {
+		maxLineLen = defaultMaxLineLength
+	}
+
+	reader := bufio.NewReaderSize(r, maxLineLen)
+	for {
+		data, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			emit(string(data) + lineContinuationSuffix)
+			continue
+		}
+
+		if len(data) > 0 {
+			line := bytes.TrimSuffix(data, []byte("\n"))
+			line = bytes.TrimSuffix(line, []byte("\r"))
+			emit(string(line))
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
 		}
 	}
 }
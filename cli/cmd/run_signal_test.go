@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aliancn/swiftlog/cli/internal/client"
+	pb "github.com/aliancn/swiftlog/cli/proto"
+	"google.golang.org/grpc"
+)
+
+// abortTestIngestor is a minimal in-memory stand-in for the ingestor's
+// StreamLog RPC, just enough to start a stream and record the exit code a
+// completion event reports.
+type abortTestIngestor struct {
+	pb.UnimplementedLogStreamerServer
+
+	mu       sync.Mutex
+	exitCode int32
+	got      bool
+}
+
+func (f *abortTestIngestor) StreamLog(stream pb.LogStreamer_StreamLogServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if req.GetMetadata() == nil {
+		return nil
+	}
+	if err := stream.Send(&pb.StreamLogResponse{
+		Event: &pb.StreamLogResponse_Started{Started: &pb.StreamStarted{RunId: "test-run"}},
+	}); err != nil {
+		return err
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if completion := req.GetCompletion(); completion != nil {
+			f.mu.Lock()
+			f.exitCode = completion.ExitCode
+			f.got = true
+			f.mu.Unlock()
+			return nil
+		}
+	}
+}
+
+// newAbortTestSession dials a real (loopback TCP) ingestor server backed by
+// server, so executeCommand gets an actual *client.StreamSession without
+// reaching into client's unexported fields from another package.
+func newAbortTestSession(t *testing.T, server *abortTestIngestor) *client.StreamSession {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterLogStreamerServer(grpcServer, server)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	c, err := client.NewClient(&client.Config{ServerAddr: lis.Addr().String(), Token: "test-token"})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	session, err := c.StartStream(context.Background(), "proj", "group", nil, "host", "/tmp", "sleep 5", "")
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+	return session
+}
+
+// TestExecuteCommand_ForwardsSignalAndReportsAbortedExitCode covers Ctrl-C
+// on a wrapped command: the signal must reach the child's process group,
+// and once it exits, executeCommand must report the conventional
+// 128+signal exit code instead of losing the run as "still running".
+func TestExecuteCommand_ForwardsSignalAndReportsAbortedExitCode(t *testing.T) {
+	server := &abortTestIngestor{}
+	session := newAbortTestSession(t, server)
+	defer session.Close()
+
+	fallback := newSpoolFallback(session.GetRunID(), "proj", "group")
+	defer fallback.close()
+
+	sigCh := make(chan os.Signal, 1)
+	var linesSent int64
+
+	done := make(chan struct{})
+	var exitCode int
+	var timedOut bool
+	var err error
+	go func() {
+		defer close(done)
+		exitCode, timedOut, _, err = executeCommand([]string{"sleep", "5"}, session, fallback, "off", 0, false, nil, 0, time.Second, sigCh, &linesSent, nil, nil, 0, "", false)
+	}()
+
+	// Give the child a moment to actually start before interrupting it.
+	time.Sleep(200 * time.Millisecond)
+	sigCh <- syscall.SIGINT
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("executeCommand did not return after the child was signaled")
+	}
+
+	if err != nil {
+		t.Fatalf("executeCommand failed: %v", err)
+	}
+	if timedOut {
+		t.Fatal("executeCommand reported timedOut=true for a signal-forwarded interrupt, not a timeout")
+	}
+	wantExitCode := signalExitCodeBase + int(syscall.SIGINT)
+	if exitCode != wantExitCode {
+		t.Fatalf("exitCode = %d, want %d (128+SIGINT)", exitCode, wantExitCode)
+	}
+
+	if err := session.SendCompletion(int32(exitCode)); err != nil {
+		t.Fatalf("SendCompletion failed: %v", err)
+	}
+	if err := session.WaitForCompletion(2 * time.Second); err != nil {
+		t.Fatalf("WaitForCompletion failed: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !server.got {
+		t.Fatal("ingestor never received a completion event")
+	}
+	if server.exitCode != int32(wantExitCode) {
+		t.Fatalf("ingestor recorded exit code %d, want %d", server.exitCode, wantExitCode)
+	}
+}
@@ -0,0 +1,64 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+// setProcessGroup runs cmd in its own process group so a timeout or forwarded
+// signal can reach the whole tree (e.g. a shell script's children), not just
+// the immediate child.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup sends sig to pid's whole process group (setProcessGroup
+// having made pid the group id too), best-effort like the original single
+// syscall.Kill call this replaces.
+func killProcessGroup(pid int, sig syscall.Signal) {
+	_ = syscall.Kill(-pid, sig)
+}
+
+// exitCodeFromState reports the 128+signal exit code a shell would when ps
+// reflects a process killed by a signal (forwarded by us, or sent to the
+// child directly by something else), falling back to its plain exit code
+// otherwise.
+func exitCodeFromState(ps *os.ProcessState) int {
+	if status, ok := ps.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return signalExitCodeBase + int(status.Signal())
+	}
+	return ps.ExitCode()
+}
+
+// watchTerminalResize matches ptmx's window to ours whenever ours changes
+// (SIGWINCH), so full-screen and progress-bar tools under --pty render
+// correctly instead of assuming 80x24. The returned stop func undoes the
+// signal.Notify and stops the watcher goroutine.
+func watchTerminalResize(ptmx *os.File) (stop func()) {
+	_ = pty.InheritSize(os.Stdin, ptmx)
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-winch:
+				_ = pty.InheritSize(os.Stdin, ptmx)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(winch)
+		close(done)
+	}
+}
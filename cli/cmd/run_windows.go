@@ -0,0 +1,38 @@
+//go:build windows
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup is a no-op on Windows: exec.Cmd has no process-group
+// equivalent to Setpgid, so killProcessGroup below falls back to killing
+// just the immediate process rather than a whole tree.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills pid directly; Windows has no signal delivery to an
+// arbitrary process (sig is ignored) or a process-group-wide kill without
+// job objects, so --timeout and forwarded Ctrl-C/Break stop only the
+// immediate child, not any of its own children.
+func killProcessGroup(pid int, sig syscall.Signal) {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return
+	}
+	_ = proc.Kill()
+}
+
+// exitCodeFromState reports ps's exit code. Windows has no signal delivery,
+// so unlike the Unix build there's no 128+signal case to special-case here.
+func exitCodeFromState(ps *os.ProcessState) int {
+	return ps.ExitCode()
+}
+
+// watchTerminalResize is a no-op on Windows: there's no SIGWINCH, so a
+// --pty command's window size is set once at start and never live-resized.
+func watchTerminalResize(ptmx *os.File) (stop func()) {
+	return func() {}
+}
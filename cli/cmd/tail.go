@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aliancn/swiftlog/cli/internal/config"
+	"github.com/gorilla/websocket"
+	"github.com/spf13/cobra"
+)
+
+var tailCmd = &cobra.Command{
+	Use:   "tail <run_id>",
+	Short: "Follow a run's logs live",
+	Long: `Open a live WebSocket connection to a run and print log lines and
+status changes as they happen, for watching a job from a terminal instead
+of the browser. Reconnects automatically (with backoff) if the connection
+drops; Ctrl-C closes it cleanly.
+
+Example:
+  swiftlog tail 3f29c1e0-...
+  swiftlog tail 3f29c1e0-... --from-start`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTail,
+}
+
+var tailFromStart bool
+
+func init() {
+	rootCmd.AddCommand(tailCmd)
+
+	tailCmd.Flags().BoolVar(&tailFromStart, "from-start", false, "Fetch and print the run's full history via the API before following it live")
+}
+
+// tailReconnectInitialBackoff and tailReconnectMaxBackoff bound the
+// exponential backoff between reconnect attempts. Unlike the gRPC client's
+// ReconnectConfig, tail retries indefinitely (until the run finishes or the
+// user hits Ctrl-C) rather than giving up after a fixed number of attempts,
+// since there's no in-flight data to lose on a WebSocket read/reconnect.
+const (
+	tailReconnectInitialBackoff = 1 * time.Second
+	tailReconnectMaxBackoff     = 30 * time.Second
+)
+
+func runTail(cmd *cobra.Command, args []string) error {
+	runID := args[0]
+
+	cfg, err := config.Load(resolveProfile(cmd))
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w (run 'swiftlog config set --token YOUR_TOKEN' first)", err)
+	}
+	if token, _ := cmd.Flags().GetString("token"); token != "" {
+		cfg.Token = token
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("no API token configured. Run 'swiftlog config set --token YOUR_TOKEN' first")
+	}
+
+	if tailFromStart {
+		if err := printHistoricalLogs(cfg, runID); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	backoff := tailReconnectInitialBackoff
+	for {
+		done, err := tailOnce(ctx, cfg, runID)
+		if done {
+			if err != nil {
+				return err
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: connection to %s dropped (%v), reconnecting in %s...\n", cfg.WSAddr, err, backoff)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > tailReconnectMaxBackoff {
+			backoff = tailReconnectMaxBackoff
+		}
+	}
+}
+
+// printHistoricalLogs fetches and prints a run's full stored history via the
+// REST API (the same endpoint 'swiftlog logs' uses), before tailOnce takes
+// over with backlog=0 so nothing is printed twice.
+func printHistoricalLogs(cfg *config.Config, runID string) error {
+	reqURL := fmt.Sprintf("%s/api/v1/runs/%s/logs", strings.TrimRight(cfg.APIAddr, "/"), url.PathEscape(runID))
+
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach API server at %s: %w", cfg.APIAddr, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// fall through
+	case http.StatusNotFound:
+		return fmt.Errorf("run %s not found", runID)
+	case http.StatusUnauthorized:
+		return fmt.Errorf("authentication failed: check your API token")
+	case http.StatusForbidden:
+		return fmt.Errorf("access denied: token does not have permission to view run %s", runID)
+	default:
+		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var entries []logEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	colorize := isTerminal(os.Stdout)
+	for _, entry := range entries {
+		printLogLine(entry.Timestamp, entry.Level, entry.Content, colorize)
+	}
+	return nil
+}
+
+// wsMessage is the envelope every message on /ws/runs/:run_id carries; Type
+// determines how the rest of the payload is decoded, mirroring the shapes
+// in backend/internal/websocket/hub.go.
+type wsMessage struct {
+	Type      string            `json:"type"`
+	RunID     string            `json:"run_id"`
+	Timestamp string            `json:"timestamp"`
+	Level     string            `json:"level"`
+	Content   string            `json:"content"`
+	Status    *string           `json:"status,omitempty"`
+	ExitCode  *int32            `json:"exit_code,omitempty"`
+	AIStatus  *string           `json:"ai_status,omitempty"`
+	AIReport  *string           `json:"ai_report,omitempty"`
+	Message   string            `json:"message,omitempty"`
+	Dropped   int               `json:"dropped,omitempty"`
+	Entries   []json.RawMessage `json:"entries,omitempty"`
+}
+
+// tailOnce opens one WebSocket connection and prints messages until it
+// closes, ctx is cancelled, or the run reaches a terminal state that no
+// longer expects an AI report. done reports whether the run is over (so the
+// caller shouldn't reconnect); a non-nil err means the connection dropped
+// and the caller should retry.
+func tailOnce(ctx context.Context, cfg *config.Config, runID string) (done bool, err error) {
+	wsURL := fmt.Sprintf("%s/ws/runs/%s?backlog=0", strings.TrimRight(cfg.WSAddr, "/"), url.PathEscape(runID))
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+cfg.Token)
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, wsURL, header)
+	if err != nil {
+		if resp != nil {
+			switch resp.StatusCode {
+			case http.StatusNotFound:
+				return true, fmt.Errorf("run %s not found", runID)
+			case http.StatusUnauthorized:
+				return true, fmt.Errorf("authentication failed: check your API token")
+			case http.StatusForbidden:
+				return true, fmt.Errorf("access denied: token does not have permission to watch run %s", runID)
+			}
+		}
+		return false, err
+	}
+	defer conn.Close()
+
+	// Close the socket cleanly on Ctrl-C rather than just dropping the TCP
+	// connection, and unblock the ReadMessage loop below.
+	go func() {
+		<-ctx.Done()
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		conn.Close()
+	}()
+
+	colorize := isTerminal(os.Stdout)
+	aiPending := false
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return true, nil
+			}
+			return false, err
+		}
+
+		var msg wsMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not parse server message: %v\n", err)
+			continue
+		}
+
+		switch msg.Type {
+		case "log":
+			printLogLine(msg.Timestamp, msg.Level, msg.Content, colorize)
+
+		case "log_batch":
+			for _, raw := range msg.Entries {
+				var entry wsMessage
+				if err := json.Unmarshal(raw, &entry); err != nil {
+					continue
+				}
+				printLogLine(entry.Timestamp, entry.Level, entry.Content, colorize)
+			}
+
+		case "run_update":
+			status := ""
+			if msg.Status != nil {
+				status = *msg.Status
+			}
+			exitCode := ""
+			if msg.ExitCode != nil {
+				exitCode = fmt.Sprintf(", exit code %d", *msg.ExitCode)
+			}
+			fmt.Fprintf(os.Stderr, "--- run %s%s ---\n", status, exitCode)
+
+			if msg.AIReport != nil && *msg.AIReport != "" {
+				fmt.Fprintln(os.Stderr, "--- AI report ready ---")
+				fmt.Println(*msg.AIReport)
+			}
+
+			if isTerminalRunStatus(status) {
+				aiStatus := ""
+				if msg.AIStatus != nil {
+					aiStatus = *msg.AIStatus
+				}
+				if aiStatus == "pending" || aiStatus == "processing" {
+					aiPending = true
+					continue
+				}
+				return true, nil
+			}
+
+		case "ai_result":
+			aiResultStatus := ""
+			if msg.Status != nil {
+				aiResultStatus = *msg.Status
+			}
+			fmt.Fprintf(os.Stderr, "--- AI analysis %s%s ---\n", aiResultStatus, formatIfNonEmpty(msg.Message))
+			if aiPending {
+				return true, nil
+			}
+
+		case "gap":
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", msg.Message)
+
+		case "lagged":
+			fmt.Fprintf(os.Stderr, "Warning: server dropped %d buffered line(s) to keep up; some log lines may be missing\n", msg.Dropped)
+
+		case "error":
+			return false, fmt.Errorf("server error: %s", msg.Message)
+		}
+	}
+}
+
+// isTerminalRunStatus reports whether status means the run itself has
+// finished executing (independent of whether its AI analysis has).
+func isTerminalRunStatus(status string) bool {
+	switch status {
+	case "completed", "failed", "aborted":
+		return true
+	default:
+		return false
+	}
+}
+
+func formatIfNonEmpty(message string) string {
+	if message == "" {
+		return ""
+	}
+	return ": " + message
+}
+
+// printLogLine prints one log line in the same "<timestamp> [<level>] <content>"
+// format 'swiftlog logs' uses, colorized red for stderr when colorize is true.
+func printLogLine(timestamp, level, content string, colorize bool) {
+	line := fmt.Sprintf("%s [%s] %s", timestamp, level, content)
+	if colorize && level == "STDERR" {
+		fmt.Println("\033[31m" + line + "\033[0m")
+	} else {
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,41 @@
+// Package ansi strips terminal control sequences from captured command
+// output, so logs stored in Loki (and text handed to the AI analyzer)
+// aren't full of color codes and cursor movement a human reads fine in a
+// terminal but a log viewer or model doesn't.
+package ansi
+
+import (
+	"regexp"
+	"strings"
+)
+
+// oscRe matches OSC (Operating System Command) sequences: ESC ']' ... up to
+// a BEL or ST terminator. Used for things like setting the terminal title.
+var oscRe = regexp.MustCompile("\x1b\\][^\x07\x1b]*(?:\x07|\x1b\\\\)")
+
+// csiRe matches CSI (Control Sequence Introducer) sequences: ESC '[' zero or
+// more parameter/intermediate bytes, then a single final byte in 0x40-0x7E.
+// This covers SGR color codes (final byte 'm', including 256-color and
+// truecolor forms like "38;5;196" and "38;2;255;0;0") as well as
+// cursor-control sequences (final bytes like 'A', 'H', 'J', 'K').
+var csiRe = regexp.MustCompile(`\x1b\[[0-9:;<=>?]*[ -/]*[@-~]`)
+
+// escRe catches the handful of two-byte escape sequences that are neither
+// CSI nor OSC (e.g. ESC 7 / ESC 8 save/restore cursor, ESC c reset).
+var escRe = regexp.MustCompile(`\x1b[0-9A-Za-z=>()]`)
+
+// Strip removes ANSI SGR, cursor-control and OSC escape sequences from line,
+// and collapses carriage-return progress bars (content repeatedly
+// overwritten with \r before a final newline) down to their last state,
+// matching what a terminal would actually display.
+func Strip(line string) string {
+	line = oscRe.ReplaceAllString(line, "")
+	line = csiRe.ReplaceAllString(line, "")
+	line = escRe.ReplaceAllString(line, "")
+
+	if idx := strings.LastIndex(line, "\r"); idx != -1 {
+		line = line[idx+1:]
+	}
+
+	return line
+}
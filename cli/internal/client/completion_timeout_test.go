@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestWaitForCompletion_ReturnsOnAcknowledgment covers the common case:
+// the server flushes and acknowledges before the client asks, so
+// WaitForCompletion returns nil promptly.
+func TestWaitForCompletion_ReturnsOnAcknowledgment(t *testing.T) {
+	server := &fakeIngestor{sendCompleted: true}
+	c := newFakeClient(t, server)
+
+	session, err := c.StartStream(context.Background(), "proj", "group", nil, "host", "/tmp", "true", "")
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+	defer session.Close()
+
+	if err := session.SendCompletion(0); err != nil {
+		t.Fatalf("SendCompletion failed: %v", err)
+	}
+
+	if err := session.WaitForCompletion(time.Second); err != nil {
+		t.Fatalf("WaitForCompletion returned %v, want nil", err)
+	}
+}
+
+// TestWaitForCompletion_TimesOutWhenServerNeverAcknowledges is the whole
+// point of bounding WaitForCompletion: a stalled server that never
+// replies must not hang the CLI forever.
+func TestWaitForCompletion_TimesOutWhenServerNeverAcknowledges(t *testing.T) {
+	server := &fakeIngestor{neverReply: make(chan struct{})}
+	defer close(server.neverReply)
+	c := newFakeClient(t, server)
+
+	session, err := c.StartStream(context.Background(), "proj", "group", nil, "host", "/tmp", "true", "")
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+	defer session.Close()
+
+	if err := session.SendCompletion(0); err != nil {
+		t.Fatalf("SendCompletion failed: %v", err)
+	}
+
+	start := time.Now()
+	err = session.WaitForCompletion(100 * time.Millisecond)
+	elapsed := time.Since(start)
+
+	if err != ErrCompletionTimeout {
+		t.Fatalf("WaitForCompletion returned %v, want ErrCompletionTimeout", err)
+	}
+	if elapsed > time.Second {
+		t.Fatalf("WaitForCompletion took %v, want it bounded close to the 100ms timeout", elapsed)
+	}
+}
@@ -2,46 +2,191 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	pb "github.com/aliancn/swiftlog/cli/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// ReconnectConfig controls how a StreamSession recovers from a transient
+// gRPC error (ingestor restart, network blip) mid-stream: how many times to
+// re-dial and how long to back off between attempts.
+type ReconnectConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultReconnectConfig is used when Config.Reconnect is left zero-valued.
+func DefaultReconnectConfig() ReconnectConfig {
+	return ReconnectConfig{
+		MaxAttempts:    5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+	}
+}
+
+// maxReplayBuffer bounds how many recently-sent log lines a StreamSession
+// keeps around to replay after a reconnect. The server has no per-line ack
+// in this protocol, so there's no way to know exactly which of these lines
+// it already received; replaying all of them risks a handful of duplicates
+// right at the reconnect boundary, which is preferable to silently losing
+// the lines sent in the moments before the disconnect.
+const maxReplayBuffer = 1000
+
+// maxBatchLines and maxBatchDelay bound how long SendLogLine coalesces
+// lines into a single LogBatch before sending it: whichever limit is hit
+// first triggers the flush. Batching amortizes per-message gRPC overhead
+// for high-throughput commands, at the cost of up to maxBatchDelay of
+// added latency before a line reaches the server.
+const (
+	maxBatchLines = 100
+	maxBatchDelay = 50 * time.Millisecond
+)
+
+// heartbeatInterval is how long a StreamSession can go without sending any
+// other message before it sends an empty Heartbeat, so intermediate proxies
+// and the ingestor's own idle timeout don't mistake a long-silent (but
+// still running) command for a dead connection.
+const heartbeatInterval = 30 * time.Second
+
+// DefaultCompletionTimeout bounds how long WaitForCompletion waits for the
+// server's StreamCompleted acknowledgment before giving up, so a stalled
+// server or a lost final message can't hang the CLI forever.
+const DefaultCompletionTimeout = 10 * time.Second
+
+// ErrCompletionTimeout is returned by WaitForCompletion when its timeout
+// elapses without the stream reaching io.EOF or an error. Callers can check
+// for it with errors.Is to warn the user that the server's acknowledgment
+// was never received, without treating it as a hard failure.
+var ErrCompletionTimeout = errors.New("timed out waiting for completion acknowledgment")
+
 // Client wraps a gRPC connection to the SwiftLog ingestor service
 type Client struct {
-	conn   *grpc.ClientConn
-	client pb.LogStreamerClient
-	token  string
+	conn        *grpc.ClientConn
+	client      pb.LogStreamerClient
+	token       string
+	addr        string
+	reconnect   ReconnectConfig
+	compression bool
 }
 
 // Config holds client configuration
 type Config struct {
 	ServerAddr string
 	Token      string
+	// Reconnect controls retry behavior when a stream drops mid-run. Zero
+	// value falls back to DefaultReconnectConfig().
+	Reconnect ReconnectConfig
+
+	// TLS enables TLS for the connection to ServerAddr; the remaining
+	// fields are only consulted when it's true.
+	TLS bool
+	// CACertPath, if set, verifies the server against this CA instead of
+	// the system trust store.
+	CACertPath string
+	// ClientCertPath and ClientKeyPath, if both set, present a client
+	// certificate for mTLS.
+	ClientCertPath, ClientKeyPath string
+	// InsecureSkipVerify disables server certificate verification
+	// entirely. Only meant for testing against a self-signed server.
+	InsecureSkipVerify bool
+
+	// Compression gzip-compresses the log stream sent to the server. The
+	// ingestor decompresses transparently via gRPC's encoding registry, and
+	// keeps accepting uncompressed streams from older clients either way,
+	// so this only affects bytes on the wire for this connection.
+	Compression bool
 }
 
 // NewClient creates a new gRPC client
 func NewClient(cfg *Config) (*Client, error) {
-	// Create gRPC connection
+	conn, client, err := dial(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	reconnect := cfg.Reconnect
+	if reconnect == (ReconnectConfig{}) {
+		reconnect = DefaultReconnectConfig()
+	}
+
+	return &Client{
+		conn:        conn,
+		client:      client,
+		token:       cfg.Token,
+		addr:        cfg.ServerAddr,
+		reconnect:   reconnect,
+		compression: cfg.Compression,
+	}, nil
+}
+
+func dial(cfg *Config) (*grpc.ClientConn, pb.LogStreamerClient, error) {
+	creds, err := transportCredentials(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	conn, err := grpc.NewClient(
 		cfg.ServerAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to server: %w", err)
+		return nil, nil, fmt.Errorf("failed to connect to server: %w", err)
 	}
+	return conn, pb.NewLogStreamerClient(conn), nil
+}
 
-	return &Client{
-		conn:   conn,
-		client: pb.NewLogStreamerClient(conn),
-		token:  cfg.Token,
-	}, nil
+// transportCredentials builds the gRPC transport credentials for cfg,
+// falling back to plaintext when TLS isn't enabled.
+func transportCredentials(cfg *Config) (credentials.TransportCredentials, error) {
+	if !cfg.TLS {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CACertPath != "" {
+		pem, err := os.ReadFile(cfg.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate %s: %w", cfg.CACertPath, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in %s", cfg.CACertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCertPath != "" || cfg.ClientKeyPath != "" {
+		if cfg.ClientCertPath == "" || cfg.ClientKeyPath == "" {
+			return nil, fmt.Errorf("both a client certificate and client key are required for mTLS")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertPath, cfg.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
 }
 
 // Close closes the gRPC connection
@@ -51,55 +196,142 @@ func (c *Client) Close() error {
 
 // StreamSession represents an active log streaming session
 type StreamSession struct {
+	client *Client
+	ctx    context.Context
 	stream pb.LogStreamer_StreamLogClient
-	runID  string
+	// cancelStream tears down stream's own context, letting
+	// WaitForCompletion unblock a Recv that's waited past its timeout.
+	cancelStream context.CancelFunc
+	runID        string
+
+	projectName string
+	groupName   string
+	tags        map[string]string
+	hostname    string
+	workingDir  string
+	commandLine string
+	name        string
+
+	// mu guards stream, buffer, and pendingBatch, since a reconnect swaps
+	// the stream out from under any Send call that triggered it.
+	mu     sync.Mutex
+	buffer []*pb.LogLine
+
+	// pendingBatch holds lines not yet sent, coalesced into a single
+	// LogBatch by Flush once maxBatchLines or maxBatchDelay is reached.
+	// batchTimer fires the delay-based flush.
+	pendingBatch []*pb.LogLine
+	batchTimer   *time.Timer
+
+	// heartbeatTimer fires an empty Heartbeat message after heartbeatInterval
+	// of no other message being sent to the server.
+	heartbeatTimer *time.Timer
 }
 
-// StartStream initiates a new log streaming session
-func (c *Client) StartStream(ctx context.Context, projectName, groupName string) (*StreamSession, error) {
-	// Add authentication metadata
+// StartStream initiates a new log streaming session. hostname, workingDir
+// and commandLine are provenance the caller has already gathered (e.g.
+// os.Hostname(), os.Getwd(), the invoked command line) — any of them may be
+// empty if unavailable. name is an optional human-friendly name for the run,
+// also empty if the caller didn't set one.
+func (c *Client) StartStream(ctx context.Context, projectName, groupName string, tags map[string]string, hostname, workingDir, commandLine, name string) (*StreamSession, error) {
+	stream, runID, cancel, err := c.openStream(ctx, projectName, groupName, tags, hostname, workingDir, commandLine, name)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &StreamSession{
+		client:       c,
+		ctx:          ctx,
+		stream:       stream,
+		cancelStream: cancel,
+		runID:        runID,
+		projectName:  projectName,
+		groupName:    groupName,
+		tags:         tags,
+		hostname:     hostname,
+		workingDir:   workingDir,
+		commandLine:  commandLine,
+		name:         name,
+	}
+
+	session.mu.Lock()
+	session.scheduleHeartbeatLocked()
+	session.mu.Unlock()
+
+	return session, nil
+}
+
+// openStream dials a fresh stream, sends the initial metadata, and waits
+// for the server's StreamStarted response. It's used both by StartStream
+// and by reconnect, since re-establishing a session after a drop looks the
+// same as establishing it the first time — the server has no way to
+// resume a specific run ID yet, so a reconnect always starts a new run.
+// The returned cancel func tears down just this stream's own context (not
+// ctx, which the caller may still need afterward) - WaitForCompletion uses
+// it to unblock a Recv that's waited past --completion-timeout.
+func (c *Client) openStream(ctx context.Context, projectName, groupName string, tags map[string]string, hostname, workingDir, commandLine, name string) (pb.LogStreamer_StreamLogClient, string, context.CancelFunc, error) {
 	md := metadata.New(map[string]string{
 		"authorization": "Bearer " + c.token,
 	})
-	ctx = metadata.NewOutgoingContext(ctx, md)
+	streamCtx, cancel := context.WithCancel(metadata.NewOutgoingContext(ctx, md))
 
-	// Create bidirectional stream
-	stream, err := c.client.StreamLog(ctx)
+	var callOpts []grpc.CallOption
+	if c.compression {
+		callOpts = append(callOpts, grpc.UseCompressor(gzip.Name))
+	}
+
+	stream, err := c.client.StreamLog(streamCtx, callOpts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create stream: %w", err)
+		cancel()
+		return nil, "", nil, wrapStreamError(err)
 	}
 
-	// Send metadata as first message
 	err = stream.Send(&pb.StreamLogRequest{
 		Event: &pb.StreamLogRequest_Metadata{
 			Metadata: &pb.StreamMetadata{
 				ProjectName: projectName,
 				GroupName:   groupName,
+				Tags:        tags,
+				Hostname:    hostname,
+				WorkingDir:  workingDir,
+				CommandLine: commandLine,
+				Name:        name,
 			},
 		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to send metadata: %w", err)
+		cancel()
+		return nil, "", nil, fmt.Errorf("failed to send metadata: %w", err)
 	}
 
-	// Wait for StreamStarted response
 	resp, err := stream.Recv()
 	if err != nil {
-		return nil, fmt.Errorf("failed to receive started response: %w", err)
+		cancel()
+		return nil, "", nil, fmt.Errorf("failed to receive started response: %w", err)
 	}
 
 	started := resp.GetStarted()
 	if started == nil {
+		cancel()
 		if errMsg := resp.GetError(); errMsg != "" {
-			return nil, fmt.Errorf("server error: %s", errMsg)
+			return nil, "", nil, fmt.Errorf("server error: %s", errMsg)
 		}
-		return nil, fmt.Errorf("unexpected response from server")
+		return nil, "", nil, fmt.Errorf("unexpected response from server")
 	}
 
-	return &StreamSession{
-		stream: stream,
-		runID:  started.RunId,
-	}, nil
+	return stream, started.RunId, cancel, nil
+}
+
+// wrapStreamError gives a clearer message for the TLS handshake failures
+// most likely to send a user down the wrong path: an untrusted or expired
+// server certificate reads as an opaque "transport: authentication
+// handshake failed" from gRPC otherwise.
+func wrapStreamError(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "certificate") || strings.Contains(msg, "x509") {
+		return fmt.Errorf("failed to create stream: server presented an untrusted TLS certificate (%w); if this is expected, configure a --ca-cert or set --insecure-skip-verify", err)
+	}
+	return fmt.Errorf("failed to create stream: %w", err)
 }
 
 // GetRunID returns the run ID for this session
@@ -107,49 +339,288 @@ func (s *StreamSession) GetRunID() string {
 	return s.runID
 }
 
-// SendLogLine sends a log line to the server
+// SendLogLine buffers a log line for sending, coalescing it with other
+// buffered lines into a single LogBatch once maxBatchLines or
+// maxBatchDelay is reached (see Flush). A caller that needs the line sent
+// immediately - notably before SendCompletion - should call Flush right
+// after.
 func (s *StreamSession) SendLogLine(isStderr bool, content string) error {
+	return s.SendLogLineAt(isStderr, time.Now(), content)
+}
+
+// SendLogLineAt is SendLogLine with an explicit timestamp, for a caller
+// that already knows when the line was originally produced - notably
+// 'swiftlog ingest' replaying a file whose lines carry their own
+// timestamps, where using the ingestion time would misrepresent the run.
+func (s *StreamSession) SendLogLineAt(isStderr bool, ts time.Time, content string) error {
 	level := pb.LogLine_STDOUT
 	if isStderr {
 		level = pb.LogLine_STDERR
 	}
+	line := &pb.LogLine{
+		Timestamp: timestamppb.New(ts),
+		Level:     level,
+		Content:   content,
+	}
 
-	return s.stream.Send(&pb.StreamLogRequest{
-		Event: &pb.StreamLogRequest_Line{
-			Line: &pb.LogLine{
-				Timestamp: timestamppb.New(time.Now()),
-				Level:     level,
-				Content:   content,
-			},
-		},
+	s.mu.Lock()
+	s.pendingBatch = append(s.pendingBatch, line)
+	full := len(s.pendingBatch) >= maxBatchLines
+	if len(s.pendingBatch) == 1 && !full {
+		s.scheduleBatchFlushLocked()
+	}
+	s.mu.Unlock()
+
+	if full {
+		return s.Flush()
+	}
+	return nil
+}
+
+// scheduleBatchFlushLocked (re-)arms the timer that flushes a
+// partially-filled batch after maxBatchDelay, so a burst of lines followed
+// by a lull doesn't leave them sitting unsent. A background-triggered
+// flush that fails is logged rather than returned, since there's no
+// caller left to hand the error to - the same trade-off reconnectLocked
+// already makes for its own warnings. Callers must hold s.mu.
+func (s *StreamSession) scheduleBatchFlushLocked() {
+	if s.batchTimer != nil {
+		s.batchTimer.Stop()
+	}
+	s.batchTimer = time.AfterFunc(maxBatchDelay, func() {
+		if err := s.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to flush buffered log lines: %v\n", err)
+		}
+	})
+}
+
+// Flush sends any lines SendLogLine has buffered as a single LogBatch. It's
+// a no-op if nothing is pending. Callers must invoke it before
+// SendCompletion so a partially-filled batch isn't left unsent.
+func (s *StreamSession) Flush() error {
+	s.mu.Lock()
+	if s.batchTimer != nil {
+		s.batchTimer.Stop()
+	}
+	pending := s.pendingBatch
+	s.pendingBatch = nil
+	s.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	return s.sendBatch(pending)
+}
+
+// sendBatch sends lines as a single LogBatch event, reconnecting and
+// retrying once on a retryable error - the same recovery sendLine used to
+// do for a single line - and remembers every line in the replay buffer on
+// success.
+func (s *StreamSession) sendBatch(lines []*pb.LogLine) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.stream.Send(&pb.StreamLogRequest{
+		Event: &pb.StreamLogRequest_Batch{Batch: &pb.LogBatch{Lines: lines}},
+	})
+	if err != nil && isRetryableError(err) {
+		if reconnectErr := s.reconnectLocked(); reconnectErr != nil {
+			return fmt.Errorf("send failed (%v) and reconnect failed: %w", err, reconnectErr)
+		}
+		err = s.stream.Send(&pb.StreamLogRequest{
+			Event: &pb.StreamLogRequest_Batch{Batch: &pb.LogBatch{Lines: lines}},
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	s.buffer = append(s.buffer, lines...)
+	if len(s.buffer) > maxReplayBuffer {
+		s.buffer = s.buffer[len(s.buffer)-maxReplayBuffer:]
+	}
+	s.scheduleHeartbeatLocked()
+	return nil
+}
+
+// scheduleHeartbeatLocked (re-)arms the timer that sends an empty Heartbeat
+// after heartbeatInterval of no other message being sent, so a command that
+// goes quiet for a while (e.g. waiting on a slow subprocess) doesn't get
+// mistaken for a dead connection. A background-triggered heartbeat that
+// fails is logged rather than returned, since there's no caller left to
+// hand the error to - the same trade-off scheduleBatchFlushLocked makes.
+// Callers must hold s.mu.
+func (s *StreamSession) scheduleHeartbeatLocked() {
+	if s.heartbeatTimer != nil {
+		s.heartbeatTimer.Stop()
+	}
+	s.heartbeatTimer = time.AfterFunc(heartbeatInterval, func() {
+		if err := s.sendHeartbeat(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to send heartbeat: %v\n", err)
+		}
+	})
+}
+
+// sendHeartbeat sends an empty Heartbeat event, reconnecting and retrying
+// once on a retryable error like sendBatch does, and re-arms the heartbeat
+// timer on success.
+func (s *StreamSession) sendHeartbeat() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.stream.Send(&pb.StreamLogRequest{
+		Event: &pb.StreamLogRequest_Heartbeat{Heartbeat: &pb.Heartbeat{}},
 	})
+	if err != nil && isRetryableError(err) {
+		if reconnectErr := s.reconnectLocked(); reconnectErr != nil {
+			return fmt.Errorf("send heartbeat failed (%v) and reconnect failed: %w", err, reconnectErr)
+		}
+		err = s.stream.Send(&pb.StreamLogRequest{
+			Event: &pb.StreamLogRequest_Heartbeat{Heartbeat: &pb.Heartbeat{}},
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	s.scheduleHeartbeatLocked()
+	return nil
+}
+
+// reconnectLocked re-dials the ingestor and replays the buffered lines onto
+// the new stream. Callers must hold s.mu. Since the server can't resume an
+// existing run yet, this starts a new run — the old run ID is left
+// "running" server-side until its own stuck-processing reconciler (or an
+// operator) notices it. That limitation is called out in the package doc
+// above; closing it needs a resume_run_id added to StreamMetadata on the
+// server side, which is out of scope here.
+func (s *StreamSession) reconnectLocked() error {
+	cfg := s.client.reconnect
+	backoff := cfg.InitialBackoff
+	var lastErr error
+	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+		fmt.Fprintf(os.Stderr, "Warning: stream to SwiftLog server dropped, reconnecting (attempt %d/%d)...\n", attempt, cfg.MaxAttempts)
+
+		stream, runID, cancel, err := s.client.openStream(s.ctx, s.projectName, s.groupName, s.tags, s.hostname, s.workingDir, s.commandLine, s.name)
+		if err != nil {
+			lastErr = err
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > cfg.MaxBackoff {
+				backoff = cfg.MaxBackoff
+			}
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: reconnected as new run %s (previous run %s may still show as running); replaying %d buffered line(s)\n", runID, s.runID, len(s.buffer))
+
+		if s.cancelStream != nil {
+			s.cancelStream()
+		}
+		s.stream = stream
+		s.cancelStream = cancel
+		s.runID = runID
+
+		buffered := s.buffer
+		s.buffer = nil
+		for _, line := range buffered {
+			if sendErr := s.stream.Send(&pb.StreamLogRequest{Event: &pb.StreamLogRequest_Line{Line: line}}); sendErr != nil {
+				return fmt.Errorf("failed to replay buffered lines after reconnect: %w", sendErr)
+			}
+			s.buffer = append(s.buffer, line)
+		}
+		return nil
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", cfg.MaxAttempts, lastErr)
+}
+
+// isRetryableError reports whether err looks like a transient connectivity
+// failure (ingestor restart, network blip) rather than a request-shaped
+// rejection that a reconnect wouldn't fix.
+func isRetryableError(err error) bool {
+	if errors.Is(err, io.EOF) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.DeadlineExceeded, codes.Internal:
+		return true
+	default:
+		return false
+	}
 }
 
 // SendCompletion sends the completion message with exit code
 func (s *StreamSession) SendCompletion(exitCode int32) error {
-	return s.stream.Send(&pb.StreamLogRequest{
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.stream.Send(&pb.StreamLogRequest{
 		Event: &pb.StreamLogRequest_Completion{
 			Completion: &pb.StreamCompletion{
 				ExitCode: exitCode,
 			},
 		},
 	})
+	if err != nil && isRetryableError(err) {
+		if reconnectErr := s.reconnectLocked(); reconnectErr != nil {
+			return fmt.Errorf("send completion failed (%v) and reconnect failed: %w", err, reconnectErr)
+		}
+		err = s.stream.Send(&pb.StreamLogRequest{
+			Event: &pb.StreamLogRequest_Completion{
+				Completion: &pb.StreamCompletion{
+					ExitCode: exitCode,
+				},
+			},
+		})
+	}
+	return err
 }
 
 // Close closes the stream
 func (s *StreamSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.heartbeatTimer != nil {
+		s.heartbeatTimer.Stop()
+	}
 	return s.stream.CloseSend()
 }
 
-// WaitForCompletion waits for any final messages from the server
-func (s *StreamSession) WaitForCompletion() error {
-	for {
-		_, err := s.stream.Recv()
-		if err == io.EOF {
-			return nil
+// WaitForCompletion waits for the server's final messages (ideally a
+// StreamCompleted acknowledgment) up to timeout. If the stream ends first
+// (io.EOF or an error), that outcome is returned immediately. If timeout
+// elapses first, it cancels the stream's own context to unblock the
+// pending Recv and returns ErrCompletionTimeout.
+func (s *StreamSession) WaitForCompletion(timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		for {
+			_, err := s.stream.Recv()
+			if err == io.EOF {
+				done <- nil
+				return
+			}
+			if err != nil {
+				done <- err
+				return
+			}
 		}
-		if err != nil {
-			return err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		s.mu.Lock()
+		cancel := s.cancelStream
+		s.mu.Unlock()
+		if cancel != nil {
+			cancel()
 		}
+		<-done // wait for Recv to unblock and the goroutine to exit
+		return ErrCompletionTimeout
 	}
 }
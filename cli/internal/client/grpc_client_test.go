@@ -0,0 +1,274 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/aliancn/swiftlog/cli/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeIngestor is a minimal, in-memory stand-in for the real ingestor's
+// StreamLog RPC, used to drive Client/StreamSession through reconnect and
+// completion-acknowledgment scenarios without a real network or server.
+type fakeIngestor struct {
+	pb.UnimplementedLogStreamerServer
+
+	mu          sync.Mutex
+	connections int
+	// failFirstNBatches makes the first N connections return codes.Unavailable
+	// as soon as they receive a LogBatch, simulating a mid-stream ingestor
+	// crash/restart.
+	failFirstNBatches int
+	batchesSeenByConn []int
+	linesByConn       [][]string
+	// sendCompleted, if true, replies with a StreamCompleted acknowledgment
+	// right after a Completion event instead of just returning (ending the
+	// stream with io.EOF only).
+	sendCompleted bool
+	// neverReply, if true, never sends anything back and never returns,
+	// simulating a stalled server for the completion-timeout test.
+	neverReply chan struct{}
+}
+
+func (f *fakeIngestor) StreamLog(stream pb.LogStreamer_StreamLogServer) error {
+	f.mu.Lock()
+	connID := f.connections
+	f.connections++
+	f.linesByConn = append(f.linesByConn, nil)
+	f.batchesSeenByConn = append(f.batchesSeenByConn, 0)
+	failThisConn := connID < f.failFirstNBatches
+	f.mu.Unlock()
+
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if req.GetMetadata() == nil {
+		return status.Error(codes.InvalidArgument, "expected metadata first")
+	}
+	if err := stream.Send(&pb.StreamLogResponse{
+		Event: &pb.StreamLogResponse_Started{Started: &pb.StreamStarted{RunId: uuidLikeID(connID)}},
+	}); err != nil {
+		return err
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch e := req.Event.(type) {
+		case *pb.StreamLogRequest_Line:
+			f.mu.Lock()
+			f.linesByConn[connID] = append(f.linesByConn[connID], e.Line.GetContent())
+			f.mu.Unlock()
+		case *pb.StreamLogRequest_Batch:
+			f.mu.Lock()
+			f.batchesSeenByConn[connID]++
+			for _, line := range e.Batch.GetLines() {
+				f.linesByConn[connID] = append(f.linesByConn[connID], line.GetContent())
+			}
+			shouldFail := failThisConn
+			f.mu.Unlock()
+			if shouldFail {
+				return status.Error(codes.Unavailable, "simulated ingestor restart")
+			}
+		case *pb.StreamLogRequest_Completion:
+			if f.neverReply != nil {
+				<-f.neverReply
+				return nil
+			}
+			if f.sendCompleted {
+				if err := stream.Send(&pb.StreamLogResponse{
+					Event: &pb.StreamLogResponse_Completed{Completed: &pb.StreamCompleted{}},
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		case *pb.StreamLogRequest_Heartbeat:
+			// nothing to record
+		}
+	}
+}
+
+func uuidLikeID(connID int) string {
+	return "run-" + string(rune('a'+connID))
+}
+
+// erroringOnceStream wraps a real stream and fails its first Send with err,
+// then delegates every call after that as normal - standing in for a real
+// stream that's just had its connection reset by a crashed/restarted peer.
+type erroringOnceStream struct {
+	pb.LogStreamer_StreamLogClient
+	err    error
+	failed bool
+}
+
+func (s *erroringOnceStream) Send(req *pb.StreamLogRequest) error {
+	if !s.failed {
+		s.failed = true
+		return s.err
+	}
+	return s.LogStreamer_StreamLogClient.Send(req)
+}
+
+// newFakeClient wires a Client up to an in-process fakeIngestor over
+// bufconn, so tests can drive StreamSession against controlled server
+// behavior without a real network connection.
+func newFakeClient(t *testing.T, server *fakeIngestor) *Client {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	pb.RegisterLogStreamerServer(grpcServer, server)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &Client{
+		conn:      conn,
+		client:    pb.NewLogStreamerClient(conn),
+		token:     "test-token",
+		addr:      "bufnet",
+		reconnect: ReconnectConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond},
+	}
+}
+
+// TestStreamSession_ReconnectsAndReplaysAfterIngestorRestart is the
+// scenario an ingestor restart mid-run looks like: the first connection
+// dies as soon as it receives a batch, and the session must transparently
+// re-dial, obtain a new run, and get every subsequently-sent line through
+// without SendLogLine/Flush ever returning an error to the caller.
+func TestStreamSession_ReconnectsAndReplaysAfterIngestorRestart(t *testing.T) {
+	server := &fakeIngestor{sendCompleted: true}
+	c := newFakeClient(t, server)
+
+	session, err := c.StartStream(context.Background(), "proj", "group", nil, "host", "/tmp", "sleep 1", "")
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+	defer session.Close()
+
+	firstRunID := session.GetRunID()
+
+	if err := session.SendLogLine(false, "line before the crash"); err != nil {
+		t.Fatalf("SendLogLine failed: %v", err)
+	}
+	if err := session.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// A real ingestor restart surfaces as the peer resetting the connection
+	// at some unpredictable point; simulate that deterministically instead
+	// of racing against actual network timing, by swapping in a stream that
+	// fails the very next Send with the same error a dead connection would
+	// produce.
+	session.mu.Lock()
+	session.stream = &erroringOnceStream{
+		LogStreamer_StreamLogClient: session.stream,
+		err:                         status.Error(codes.Unavailable, "simulated ingestor restart"),
+	}
+	session.mu.Unlock()
+
+	if err := session.SendLogLine(false, "line after the crash"); err != nil {
+		t.Fatalf("SendLogLine after simulated crash failed: %v", err)
+	}
+	if err := session.Flush(); err != nil {
+		t.Fatalf("Flush after simulated crash failed: %v", err)
+	}
+
+	if session.GetRunID() == firstRunID {
+		t.Fatal("expected a new run ID after reconnect, got the same one")
+	}
+
+	if err := session.SendCompletion(0); err != nil {
+		t.Fatalf("SendCompletion failed: %v", err)
+	}
+	// Wait for the server's acknowledgment so its handler has definitely
+	// finished processing everything sent above before we inspect it.
+	if err := session.WaitForCompletion(2 * time.Second); err != nil {
+		t.Fatalf("WaitForCompletion failed: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.connections != 2 {
+		t.Fatalf("connections = %d, want 2 (one crash, one reconnect)", server.connections)
+	}
+	allLines := append(append([]string{}, server.linesByConn[0]...), server.linesByConn[1]...)
+	if !containsAll(allLines, "line before the crash", "line after the crash") {
+		t.Fatalf("lines observed by server = %v, missing an expected line", allLines)
+	}
+}
+
+func containsAll(haystack []string, wanted ...string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, h := range haystack {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// TestStreamSession_ReconnectGivesUpAfterMaxAttempts covers the case a
+// reconnect can't recover from: the server is gone entirely, so
+// reconnectLocked must return an error, once, after exhausting
+// ReconnectConfig.MaxAttempts, instead of retrying forever.
+func TestStreamSession_ReconnectGivesUpAfterMaxAttempts(t *testing.T) {
+	server := &fakeIngestor{failFirstNBatches: 1}
+	c := newFakeClient(t, server)
+	c.reconnect = ReconnectConfig{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	session, err := c.StartStream(context.Background(), "proj", "group", nil, "host", "/tmp", "sleep 1", "")
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+	defer session.Close()
+
+	// Kill the whole server so every reconnect attempt fails, not just the
+	// first connection.
+	if err := session.SendLogLine(false, "triggers the crash"); err != nil {
+		t.Fatalf("SendLogLine failed: %v", err)
+	}
+	if err := session.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	session.client.conn.Close()
+
+	if err := session.SendLogLine(false, "after the server is gone"); err != nil {
+		t.Fatalf("SendLogLine failed: %v", err)
+	}
+	if err := session.Flush(); err == nil {
+		t.Fatal("Flush succeeded after the server connection was closed, want a reconnect failure")
+	}
+}
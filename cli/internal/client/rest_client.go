@@ -0,0 +1,116 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Project is the subset of the server's Project JSON that REST callers in
+// the CLI need (shell completion, 'projects ls').
+type Project struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Group is the subset of the server's LogGroup JSON that REST callers in
+// the CLI need (shell completion, 'groups ls').
+type Group struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RESTClient talks to the HTTP API (as opposed to the gRPC ingestor that
+// the rest of this package wraps), for callers like shell completion that
+// only need to read small amounts of metadata.
+type RESTClient struct {
+	APIAddr    string
+	Token      string
+	httpClient *http.Client
+}
+
+// NewRESTClient returns a RESTClient for apiAddr, authenticating requests
+// with token when non-empty.
+func NewRESTClient(apiAddr, token string) *RESTClient {
+	return &RESTClient{
+		APIAddr:    strings.TrimRight(apiAddr, "/"),
+		Token:      token,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// ListProjects fetches every project visible to Token (or, if Token is
+// empty, whatever the server returns for an unauthenticated caller).
+func (r *RESTClient) ListProjects() ([]Project, error) {
+	var projects []Project
+	if err := r.getJSON("/api/v1/projects", &projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// ListGroups fetches every group under projectID.
+func (r *RESTClient) ListGroups(projectID string) ([]Group, error) {
+	var groups []Group
+	if err := r.getJSON("/api/v1/projects/"+projectID+"/groups", &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// FindProject resolves nameOrID against ListProjects, matching on name
+// first and then ID, since there's no "get project by name" endpoint to
+// call directly. Returns an error identifying nameOrID if nothing matches.
+func (r *RESTClient) FindProject(nameOrID string) (*Project, error) {
+	projects, err := r.ListProjects()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		if p.Name == nameOrID {
+			return &p, nil
+		}
+	}
+	for _, p := range projects {
+		if p.ID == nameOrID {
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("project %q not found", nameOrID)
+}
+
+func (r *RESTClient) getJSON(path string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, r.APIAddr+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach API server at %s: %w", r.APIAddr, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API request to %s failed with status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
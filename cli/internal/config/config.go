@@ -6,12 +6,117 @@ import (
 	"path/filepath"
 
 	"github.com/spf13/viper"
+	"github.com/zalando/go-keyring"
 )
 
+// keyringService namespaces this CLI's entries in the OS keychain from
+// anything else that happens to use the same keyring.
+const keyringService = "swiftlog-cli"
+
+// CredentialStore values for Config.CredentialStore.
+const (
+	// CredentialStorePlaintext (the zero value) stores Token directly in
+	// config.json.
+	CredentialStorePlaintext = "plaintext"
+	// CredentialStoreKeyring stores Token in the OS keychain (via
+	// zalando/go-keyring) instead, leaving config.json with only a
+	// reference to which profile owns it.
+	CredentialStoreKeyring = "keyring"
+)
+
+// keyringUser is the account name Token is filed under in the OS keychain:
+// one entry per profile, so switching profiles doesn't clobber another
+// profile's token.
+func keyringUser(profileName string) string {
+	if profileName == "" {
+		return defaultProfileName
+	}
+	return profileName
+}
+
 // Config holds CLI configuration
 type Config struct {
 	Token      string `mapstructure:"token"`
 	ServerAddr string `mapstructure:"server_addr"`
+
+	// TokenID is the server-side ID of Token, recorded when the token was
+	// minted by 'swiftlog login' so that 'swiftlog logout' knows which
+	// token to revoke. Empty for tokens pasted in manually via
+	// 'config set --token'.
+	TokenID string `mapstructure:"token_id"`
+
+	// APIAddr is the base URL of the HTTP API server (e.g.
+	// "http://localhost:8080"), used by commands like 'logs' that fetch
+	// stored data rather than stream to the gRPC ingestor at ServerAddr.
+	APIAddr string `mapstructure:"api_addr"`
+
+	// WSAddr is the base URL of the WebSocket server (e.g.
+	// "ws://localhost:8081"), used by 'tail' to watch a run live. It's a
+	// separate address from APIAddr since the WebSocket hub can be deployed
+	// as its own service (cmd/websocket) rather than bundled into the API.
+	WSAddr string `mapstructure:"ws_addr"`
+
+	// TLS controls whether the gRPC connection to ServerAddr is made over
+	// TLS. The remaining fields are only consulted when TLS is true.
+	TLS                bool   `mapstructure:"tls"`
+	CACert             string `mapstructure:"ca_cert"`
+	ClientCert         string `mapstructure:"client_cert"`
+	ClientKey          string `mapstructure:"client_key"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+
+	// StripANSI is the default for 'run'/'pipe''s --strip-ansi flag: when
+	// true, ANSI color and cursor-control codes are removed from log lines
+	// before they're sent to the server, while the local terminal still
+	// sees the original (colored) line.
+	StripANSI bool `mapstructure:"strip_ansi"`
+
+	// Redact holds user-supplied regex patterns, in addition to the CLI's
+	// built-in secret patterns, applied to every log line before it's sent
+	// to the server (see 'swiftlog config set --redact-add' and 'run'/
+	// 'pipe''s --no-redact).
+	Redact []string `mapstructure:"redact"`
+
+	// Project and Group are the default project/group used by 'run' and
+	// 'pipe' when their --project/--group flags aren't given. They're
+	// overridable by SWIFTLOG_PROJECT/SWIFTLOG_GROUP (see Load), and fall
+	// back to "default" if neither this nor the environment sets them.
+	Project string `mapstructure:"project"`
+	Group   string `mapstructure:"group"`
+
+	// DisableCompression turns off gzip compression of the gRPC log stream
+	// (see 'run'/'pipe''s --no-compress). Named as the negative so its zero
+	// value - false - means "compression on", which is the default.
+	DisableCompression bool `mapstructure:"disable_compression"`
+
+	// WebURL is the base URL of the web UI (e.g. "https://swiftlog.example.com"),
+	// used by 'run'/'pipe''s --print-url to build a link to the run's page.
+	// Left empty by default since there's no way to guess it from ServerAddr/
+	// APIAddr.
+	WebURL string `mapstructure:"web_url"`
+
+	// CredentialStore selects where Token actually lives: "" or
+	// CredentialStorePlaintext (the default) keeps it in this file;
+	// CredentialStoreKeyring stores it in the OS keychain instead, and
+	// Save/Load transparently route Token through it so callers never see
+	// the difference. See 'swiftlog config migrate-keyring'.
+	CredentialStore string `mapstructure:"credential_store"`
+}
+
+// defaultProfileName is both the name of the implicit profile backed by the
+// config file's flat top-level fields, and the fallback when no profile is
+// otherwise selected.
+const defaultProfileName = "default"
+
+// fileData mirrors the on-disk JSON layout. The embedded Config (squashed
+// into the top level) is what a pre-profile config.json already looked
+// like, and stays exactly that - the "default" profile - so old files and
+// tools that only ever wrote flat fields keep working untouched. Named,
+// non-default profiles live under "profiles"; "current_profile" is which
+// one applies when a command doesn't pass an explicit --profile.
+type fileData struct {
+	Config         `mapstructure:",squash"`
+	Profiles       map[string]Config `mapstructure:"profiles"`
+	CurrentProfile string            `mapstructure:"current_profile"`
 }
 
 var (
@@ -30,55 +135,273 @@ func init() {
 	configFile = filepath.Join(configDir, "config.json")
 }
 
-// Load loads the configuration from disk
-func Load() (*Config, error) {
+// readFile loads the raw on-disk structure (all profiles, not just one),
+// tolerating a missing file so a fresh install still gets zero-valued
+// fields and default handling downstream.
+func readFile() (fileData, error) {
 	viper.SetConfigFile(configFile)
 	viper.SetConfigType("json")
 
-	// Set defaults
-	viper.SetDefault("server_addr", "localhost:50051")
-
-	// Read config file if it exists
 	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, fmt.Errorf("failed to read config: %w", err)
+		// viper.SetConfigFile bypasses viper's search path, so a missing
+		// file surfaces as a raw *fs.PathError rather than viper's own
+		// ConfigFileNotFoundError; check both to tolerate a fresh install.
+		_, isNotFoundErr := err.(viper.ConfigFileNotFoundError)
+		if !isNotFoundErr && !os.IsNotExist(err) {
+			return fileData{}, fmt.Errorf("failed to read config: %w", err)
+		}
+	} else {
+		warnIfInsecurePermissions()
+	}
+
+	var raw fileData
+	if err := viper.Unmarshal(&raw); err != nil {
+		return fileData{}, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	return raw, nil
+}
+
+// warnIfInsecurePermissions flags a config file readable/writable beyond
+// its owner, since it can hold a plaintext API token (see
+// CredentialStorePlaintext). Best-effort: a Stat failure here isn't worth
+// failing the whole Load over.
+func warnIfInsecurePermissions() {
+	info, err := os.Stat(configFile)
+	if err != nil {
+		return
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s has permissions %04o, wider than the owner-only 0600 it should have; run 'chmod 600 %s'\n", configFile, info.Mode().Perm(), configFile)
+	}
+}
+
+// applyDefaults fills in zero-valued address fields, since only the
+// "default" profile goes through viper's SetDefault machinery - a named
+// profile that only sets a token would otherwise leave ServerAddr etc.
+// empty.
+func applyDefaults(cfg *Config) {
+	if cfg.ServerAddr == "" {
+		cfg.ServerAddr = "localhost:50051"
+	}
+	if cfg.APIAddr == "" {
+		cfg.APIAddr = "http://localhost:8080"
+	}
+	if cfg.WSAddr == "" {
+		cfg.WSAddr = "ws://localhost:8081"
+	}
+}
+
+// applyEnvOverrides layers SWIFTLOG_TOKEN/SERVER/PROJECT/GROUP on top of
+// whichever profile Load selected. These apply regardless of profile - CI
+// systems that can't write ~/.swiftlog/config.json shouldn't have to worry
+// about which profile is active - so plain os.Getenv is used here rather
+// than viper's env binding, which only reaches the top-level "default"
+// profile's fields.
+func applyEnvOverrides(cfg *Config) {
+	if v := os.Getenv("SWIFTLOG_TOKEN"); v != "" {
+		cfg.Token = v
+	}
+	if v := os.Getenv("SWIFTLOG_SERVER"); v != "" {
+		cfg.ServerAddr = v
+	}
+	if v := os.Getenv("SWIFTLOG_PROJECT"); v != "" {
+		cfg.Project = v
+	}
+	if v := os.Getenv("SWIFTLOG_GROUP"); v != "" {
+		cfg.Group = v
+	}
+}
+
+// Load loads the effective configuration for profileName. An empty
+// profileName defers to the file's "current_profile" (see UseProfile),
+// falling back to "default" - the config file's flat top-level fields -
+// if neither is set, which is what makes a pre-profile config.json keep
+// working unmodified.
+//
+// Precedence for the four env-overridable fields ends up flags (applied by
+// callers on top of the *Config this returns) > env > selected profile >
+// defaults.
+func Load(profileName string) (*Config, error) {
+	raw, err := readFile()
+	if err != nil {
+		return nil, err
+	}
+
+	active := profileName
+	if active == "" {
+		active = raw.CurrentProfile
+	}
+	if active == "" {
+		active = defaultProfileName
+	}
+
+	cfg := raw.Config
+	if active != defaultProfileName {
+		profile, ok := raw.Profiles[active]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q (create it with 'swiftlog config set --profile %s --token ...', or check %s)", active, active, configFile)
 		}
-		// Config file not found, use defaults
+		cfg = profile
 	}
 
-	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	if cfg.CredentialStore == CredentialStoreKeyring {
+		token, err := keyring.Get(keyringService, keyringUser(active))
+		if err != nil && err != keyring.ErrNotFound {
+			return nil, fmt.Errorf("failed to read token from system keyring: %w", err)
+		}
+		cfg.Token = token
 	}
 
+	applyDefaults(&cfg)
+	applyEnvOverrides(&cfg)
+
 	return &cfg, nil
 }
 
-// Save saves the configuration to disk
-func Save(cfg *Config) error {
-	// Ensure config directory exists
+// Save persists cfg as profileName, leaving every other profile in the
+// file untouched. An empty or "default" profileName writes to the file's
+// flat top-level fields, same as before profiles existed.
+//
+// When cfg.CredentialStore is CredentialStoreKeyring, Token is written to
+// the OS keychain instead of config.json - the file only ends up with the
+// CredentialStore marker, not the token itself. Switching a profile away
+// from CredentialStoreKeyring best-effort deletes its now-stale keyring
+// entry.
+func Save(cfg *Config, profileName string) error {
 	if err := os.MkdirAll(configDir, 0700); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	viper.Set("token", cfg.Token)
-	viper.Set("server_addr", cfg.ServerAddr)
+	raw, err := readFile()
+	if err != nil {
+		return err
+	}
+
+	prevCredentialStore := raw.Config.CredentialStore
+	if profileName != "" && profileName != defaultProfileName {
+		prevCredentialStore = raw.Profiles[profileName].CredentialStore
+	}
+
+	persisted := *cfg
+	if cfg.CredentialStore == CredentialStoreKeyring {
+		if cfg.Token != "" {
+			if err := keyring.Set(keyringService, keyringUser(profileName), cfg.Token); err != nil {
+				return fmt.Errorf("failed to save token to system keyring: %w", err)
+			}
+		}
+		persisted.Token = ""
+	} else if prevCredentialStore == CredentialStoreKeyring {
+		// No longer using the keyring for this profile - drop the token it
+		// left behind rather than leaving an orphaned entry around.
+		_ = keyring.Delete(keyringService, keyringUser(profileName))
+	}
+
+	if profileName == "" || profileName == defaultProfileName {
+		raw.Config = persisted
+	} else {
+		if raw.Profiles == nil {
+			raw.Profiles = make(map[string]Config)
+		}
+		raw.Profiles[profileName] = persisted
+	}
+
+	writeFile(raw)
 
 	if err := viper.WriteConfigAs(configFile); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
+	if err := os.Chmod(configFile, 0600); err != nil {
+		return fmt.Errorf("failed to restrict permissions on %s: %w", configFile, err)
+	}
 
 	return nil
 }
 
+// UseProfile makes profileName the active profile for future invocations
+// that don't pass an explicit --profile, persisting it as
+// "current_profile". profileName must already exist (as "default" or in
+// "profiles") - 'config use' doesn't create profiles, 'config set
+// --profile' does.
+func UseProfile(profileName string) error {
+	raw, err := readFile()
+	if err != nil {
+		return err
+	}
+
+	if profileName != defaultProfileName {
+		if _, ok := raw.Profiles[profileName]; !ok {
+			return fmt.Errorf("unknown profile %q (create it first with 'swiftlog config set --profile %s --token ...')", profileName, profileName)
+		}
+	}
+
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	raw.CurrentProfile = profileName
+	writeFile(raw)
+
+	if err := viper.WriteConfigAs(configFile); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return nil
+}
+
+// ListProfiles returns every profile name defined in the file ("default"
+// always included) and which one is currently active, for 'config get' to
+// display.
+func ListProfiles() (names []string, active string, err error) {
+	raw, err := readFile()
+	if err != nil {
+		return nil, "", err
+	}
+
+	names = append(names, defaultProfileName)
+	for name := range raw.Profiles {
+		names = append(names, name)
+	}
+
+	active = raw.CurrentProfile
+	if active == "" {
+		active = defaultProfileName
+	}
+
+	return names, active, nil
+}
+
+// writeFile stages raw's fields into viper ahead of a WriteConfigAs call.
+func writeFile(raw fileData) {
+	viper.Set("token", raw.Config.Token)
+	viper.Set("token_id", raw.Config.TokenID)
+	viper.Set("server_addr", raw.Config.ServerAddr)
+	viper.Set("api_addr", raw.Config.APIAddr)
+	viper.Set("ws_addr", raw.Config.WSAddr)
+	viper.Set("tls", raw.Config.TLS)
+	viper.Set("ca_cert", raw.Config.CACert)
+	viper.Set("client_cert", raw.Config.ClientCert)
+	viper.Set("client_key", raw.Config.ClientKey)
+	viper.Set("insecure_skip_verify", raw.Config.InsecureSkipVerify)
+	viper.Set("strip_ansi", raw.Config.StripANSI)
+	viper.Set("redact", raw.Config.Redact)
+	viper.Set("project", raw.Config.Project)
+	viper.Set("group", raw.Config.Group)
+	viper.Set("disable_compression", raw.Config.DisableCompression)
+	viper.Set("web_url", raw.Config.WebURL)
+	viper.Set("credential_store", raw.Config.CredentialStore)
+	viper.Set("profiles", raw.Profiles)
+	viper.Set("current_profile", raw.CurrentProfile)
+}
+
 // GetConfigPath returns the path to the config file
 func GetConfigPath() string {
 	return configFile
 }
 
-// IsConfigured checks if the CLI is configured with a token
+// IsConfigured checks if the CLI's active profile has a token configured
 func IsConfigured() bool {
-	cfg, err := Load()
+	cfg, err := Load("")
 	if err != nil {
 		return false
 	}
@@ -0,0 +1,49 @@
+// Package redact masks likely secrets in captured command output before it
+// leaves the machine, so a script that echoes a token or connection string
+// doesn't leave it sitting in Loki for anyone with run access to read.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// builtinPatterns covers the secret shapes scripts most commonly leak:
+// cloud provider keys, bearer tokens, and inline password/secret
+// assignments.
+var builtinPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`AKIA[0-9A-Z]{16}`),
+	regexp.MustCompile(`(?i)\bBearer\s+[A-Za-z0-9\-._~+/]{20,}=*`),
+	regexp.MustCompile(`(?i)\b(password|passwd|pwd|secret|api[_-]?key)\s*[:=]\s*\S+`),
+}
+
+// Redactor replaces secret-shaped substrings in log lines with
+// "[REDACTED]" before they're sent to the server.
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// New builds a Redactor from the built-in pattern set plus any additional
+// user-supplied regexes (from the CLI config's "redact" list).
+func New(extraPatterns []string) (*Redactor, error) {
+	patterns := make([]*regexp.Regexp, len(builtinPatterns))
+	copy(patterns, builtinPatterns)
+
+	for _, raw := range extraPatterns {
+		re, err := regexp.Compile(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", raw, err)
+		}
+		patterns = append(patterns, re)
+	}
+
+	return &Redactor{patterns: patterns}, nil
+}
+
+// Redact replaces every match of every pattern in line with "[REDACTED]".
+func (r *Redactor) Redact(line string) string {
+	for _, re := range r.patterns {
+		line = re.ReplaceAllString(line, "[REDACTED]")
+	}
+	return line
+}
@@ -0,0 +1,252 @@
+// Package spool persists log lines to disk when the ingestor is
+// unreachable, so a run started on a laptop that loses connectivity mid-way
+// doesn't silently lose everything sent after the drop. Callers write to a
+// Writer as lines fail to send; a later 'swiftlog flush' (or the automatic
+// flush at the start of the next run) reads the spooled records back and
+// replays them onto a fresh stream.
+package spool
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSpoolFileSize bounds how large a single spool part file can grow
+// before writes roll over to a new part. A run that stays offline for a
+// long time still spools indefinitely across parts; this just keeps any one
+// file, and any one open file descriptor, bounded.
+const maxSpoolFileSize = 5 * 1024 * 1024 // 5MB
+
+// RecordType distinguishes the kinds of entries appended to a spool file.
+type RecordType string
+
+const (
+	RecordMetadata   RecordType = "metadata"
+	RecordLine       RecordType = "line"
+	RecordCompletion RecordType = "completion"
+)
+
+// Record is one NDJSON line in a spool file. Which fields are populated
+// depends on Type: RecordMetadata carries ProjectName/GroupName,
+// RecordLine carries Timestamp/Level/Content, RecordCompletion carries
+// ExitCode.
+type Record struct {
+	Type        RecordType `json:"type"`
+	ProjectName string     `json:"project_name,omitempty"`
+	GroupName   string     `json:"group_name,omitempty"`
+	Timestamp   time.Time  `json:"timestamp,omitempty"`
+	Level       string     `json:"level,omitempty"`
+	Content     string     `json:"content,omitempty"`
+	ExitCode    int32      `json:"exit_code,omitempty"`
+}
+
+// Dir returns ~/.swiftlog/spool/, the directory spool files live in.
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".swiftlog", "spool"), nil
+}
+
+func partPath(dir, runID string, part int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s.%d.ndjson", runID, part))
+}
+
+// Writer appends spool records for a single run, rotating to a new part
+// file once the current one crosses maxSpoolFileSize.
+type Writer struct {
+	mu    sync.Mutex
+	dir   string
+	runID string
+	part  int
+	file  *os.File
+	size  int64
+}
+
+// NewWriter creates (or reopens) the spool for runID and writes a metadata
+// record identifying its project and group, needed later to replay the run
+// onto a fresh stream.
+func NewWriter(runID, projectName, groupName string) (*Writer, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	w := &Writer{dir: dir, runID: runID, part: 1}
+	if err := w.openPart(); err != nil {
+		return nil, err
+	}
+	if err := w.writeRecord(Record{
+		Type:        RecordMetadata,
+		ProjectName: projectName,
+		GroupName:   groupName,
+		Timestamp:   time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) openPart() error {
+	path := partPath(w.dir, w.runID, w.part)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open spool file %s: %w", path, err)
+	}
+	w.file = f
+	w.size = 0
+	return nil
+}
+
+func (w *Writer) writeRecord(rec Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode spool record: %w", err)
+	}
+	data = append(data, '\n')
+
+	n, err := w.file.Write(data)
+	if err != nil {
+		return fmt.Errorf("failed to write spool record: %w", err)
+	}
+	w.size += int64(n)
+
+	if w.size >= maxSpoolFileSize {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("failed to close spool part: %w", err)
+		}
+		w.part++
+		if err := w.openPart(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteLine appends a log line to the spool.
+func (w *Writer) WriteLine(isStderr bool, content string, ts time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	level := "STDOUT"
+	if isStderr {
+		level = "STDERR"
+	}
+	return w.writeRecord(Record{Type: RecordLine, Timestamp: ts, Level: level, Content: content})
+}
+
+// WriteCompletion appends the run's completion (exit code) to the spool.
+func (w *Writer) WriteCompletion(exitCode int32) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.writeRecord(Record{Type: RecordCompletion, Timestamp: time.Now(), ExitCode: exitCode})
+}
+
+// Close closes the currently-open spool part file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
+
+// PendingRuns lists the run IDs that have spooled records waiting to be
+// flushed, in a stable order.
+func PendingRuns() ([]string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	var runIDs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		// Every run has a "<runID>.1.ndjson" part, so listing those alone
+		// gives one entry per run regardless of how many parts it rotated
+		// into.
+		name := e.Name()
+		if !strings.HasSuffix(name, ".1.ndjson") {
+			continue
+		}
+		runIDs = append(runIDs, strings.TrimSuffix(name, ".1.ndjson"))
+	}
+	sort.Strings(runIDs)
+	return runIDs, nil
+}
+
+// ReadRun reads every record spooled for runID, across all of its rotated
+// parts, in order.
+func ReadRun(runID string) ([]Record, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for part := 1; ; part++ {
+		path := partPath(dir, runID, part)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			return nil, fmt.Errorf("failed to read spool file %s: %w", path, err)
+		}
+
+		for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			var rec Record
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				return nil, fmt.Errorf("failed to decode spool record in %s: %w", path, err)
+			}
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// DeleteRun removes every spooled part file for runID. Called once its
+// records have been successfully replayed.
+func DeleteRun(runID string) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+
+	for part := 1; ; part++ {
+		path := partPath(dir, runID, part)
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			return fmt.Errorf("failed to remove spool file %s: %w", path, err)
+		}
+	}
+	return nil
+}